@@ -3,12 +3,14 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/oschwald/maxminddb-mcp/internal/config"
 	"github.com/oschwald/maxminddb-mcp/internal/database"
@@ -37,6 +39,11 @@ func main() {
 	}
 	setupLogger()
 
+	if err := applyConfigFlags(os.Args[1:]); err != nil {
+		slog.Error("Invalid flags", "err", err)
+		os.Exit(2)
+	}
+
 	// Load configuration using centralized loader
 	cfg, err := config.Load()
 	if err != nil {
@@ -50,6 +57,9 @@ func main() {
 		slog.Error("Failed to create database manager", "err", err)
 		os.Exit(1)
 	}
+	dbManager.SetMaxDatabases(cfg.MaxDatabases)
+	dbManager.SetAllowedPaths(cfg.AllowedPaths)
+	dbManager.SetDescriptions(cfg.DatabaseDescriptions)
 
 	// Initialize databases based on mode
 	if err := initializeDatabases(cfg, dbManager); err != nil {
@@ -71,6 +81,11 @@ func main() {
 		cfg.IteratorTTLDuration,
 		cfg.IteratorCleanupIntervalDuration,
 	)
+	iterMgr.SetMaxResponseBytes(cfg.MaxResponseBytes)
+	iterMgr.SetGracePeriod(cfg.IteratorGracePeriodDuration)
+	iterMgr.SetMaxBatches(cfg.MaxBatchesPerIterator)
+	iterMgr.SetMaxRegexLength(cfg.MaxRegexLength)
+	iterMgr.SetDeterministicIDs(cfg.DeterministicIteratorIDs)
 	iterMgr.StartCleanup()
 	defer iterMgr.StopCleanup()
 
@@ -81,18 +96,25 @@ func main() {
 	// Check if databases need initial update
 	if updater != nil && len(dbManager.ListDatabases()) == 0 {
 		slog.Info("No databases found, triggering initial update...")
-		if _, err := updater.UpdateAll(ctx); err != nil {
-			slog.Warn("Initial database update failed", "err", err)
-		}
+		updater.InitialUpdate(ctx, cfg.InitialUpdateTimeoutDuration)
 	}
 
+	// Warm the page cache for the configured networks
+	warmup(ctx, cfg, dbManager)
+
 	// Start scheduled updates if configured
 	if updater != nil {
 		updater.StartScheduledUpdates(ctx)
+		updater.StartJanitor(ctx)
 	}
 
-	// Start file watcher
-	dbManager.StartWatching()
+	// Re-apply GeoIP.conf when it changes if configured
+	if updater != nil && cfg.GeoIPCompat.WatchConfig && cfg.GeoIPCompat.ConfigPath != "" {
+		updater.WatchGeoIPConfig(ctx, cfg.GeoIPCompat.ConfigPath, cfg.PollIntervalDuration)
+	}
+
+	// Start file watcher, falling back to polling if it is unavailable
+	startWatching(cfg, dbManager)
 
 	// Handle graceful shutdown
 	sigChan := make(chan os.Signal, 1)
@@ -108,7 +130,7 @@ func main() {
 	logStartupSummary(cfg, dbManager, updater != nil)
 
 	// Create and start MCP server (blocks until client disconnects)
-	server := mcp.New(cfg, dbManager, updater, iterMgr)
+	server := mcp.New(cfg, dbManager, updater, iterMgr, mcp.BuildInfo{Version: version, Commit: commit})
 	err = server.Serve()
 	cancel() // Always call cancel before exiting
 	if err != nil {
@@ -128,11 +150,20 @@ Usage:
   maxminddb-mcp [flags]
 
 Flags:
-  -h, --help     Show this help message
-  -v, --version  Show version information
+  -h, --help              Show this help message
+  -v, --version           Show version information
+  --config PATH           Configuration file, or - to read it from stdin
+                          (overrides MAXMINDDB_MCP_CONFIG)
+  --config-format FORMAT  Format of a configuration read from stdin:
+                          toml, json, or auto (default: auto)
 
 Environment Variables:
-  MAXMINDDB_MCP_CONFIG      Path to configuration file
+  MAXMINDDB_MCP_CONFIG      Path to configuration file, or a list of TOML
+                            files to merge separated by the OS path list
+                            separator (':' on Unix, ';' on Windows), or -
+                            to read it from stdin up to a line containing
+                            only ---, after which the MCP session starts
+  MAXMINDDB_MCP_CONFIG_FORMAT  Format of a configuration read from stdin
   MAXMINDDB_MCP_LOG_LEVEL   Logging level (debug|info|warn|error)
   MAXMINDDB_MCP_LOG_FORMAT  Log format (text|json)
 
@@ -147,6 +178,29 @@ For more information, visit: https://github.com/oschwald/maxminddb-mcp
 `, version)
 }
 
+// applyConfigFlags applies the --config and --config-format flags by setting
+// the environment variables that config.Load reads.
+func applyConfigFlags(args []string) error {
+	flags := flag.NewFlagSet("maxminddb-mcp", flag.ContinueOnError)
+	configPath := flags.String("config", "", "configuration file, or - for stdin")
+	configFormat := flags.String("config-format", "", "format of a configuration read from stdin")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *configPath != "" {
+		if err := os.Setenv("MAXMINDDB_MCP_CONFIG", *configPath); err != nil {
+			return err
+		}
+	}
+	if *configFormat != "" {
+		if err := os.Setenv("MAXMINDDB_MCP_CONFIG_FORMAT", *configFormat); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // setupLogger configures a global slog logger with simple env controls.
 func setupLogger() {
 	format := strings.ToLower(os.Getenv("MAXMINDDB_MCP_LOG_FORMAT"))  // "text" (default) or "json"
@@ -188,14 +242,16 @@ func initializeDatabases(cfg *config.Config, dbManager *database.Manager) error
 		return dbManager.WatchDirectory(cfg.MaxMind.DatabaseDir)
 
 	case config.ModeDirectory:
-		// Load all configured directories
-		for _, path := range cfg.Directory.Paths {
-			if err := dbManager.LoadDirectory(path); err != nil {
-				return fmt.Errorf("failed to load directory %s: %w", path, err)
-			}
-			if err := dbManager.WatchDirectory(path); err != nil {
-				return fmt.Errorf("failed to watch directory %s: %w", path, err)
-			}
+		// Load all configured directories and individual database files
+		return dbManager.LoadPaths(cfg.Directory.Paths, database.PathOptions{
+			CreateMissing: cfg.Directory.AutoCreateDirs,
+			SkipMissing:   cfg.Directory.SkipMissingPaths,
+		})
+
+	case config.ModeManifest:
+		// Load and watch only the databases listed in the manifest
+		if err := dbManager.LoadManifest(cfg.Manifest.Path); err != nil {
+			return fmt.Errorf("failed to load manifest %s: %w", cfg.Manifest.Path, err)
 		}
 		return nil
 
@@ -204,6 +260,68 @@ func initializeDatabases(cfg *config.Config, dbManager *database.Manager) error
 	}
 }
 
+// warmup reads the warmup_networks of every loaded database so that their
+// pages are cached before the first queries arrive. It gives up once
+// warmup_timeout has passed.
+func warmup(ctx context.Context, cfg *config.Config, dbManager *database.Manager) {
+	if len(cfg.WarmupNetworkPrefixes) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cfg.WarmupTimeoutDuration)
+	defer cancel()
+
+	start := time.Now()
+	var records int64
+	databases := 0
+	complete := true
+	for _, info := range dbManager.ListDatabases() {
+		reader, exists := dbManager.GetReader(info.Name)
+		if !exists {
+			continue
+		}
+		result := iterator.Warmup(ctx, reader, cfg.WarmupNetworkPrefixes)
+		records += result.Records
+		if !result.Complete {
+			complete = false
+			break
+		}
+		databases++
+	}
+
+	if !complete {
+		slog.Warn("Warmup stopped at its time budget",
+			"databases", databases,
+			"records", records,
+			"timeout", cfg.WarmupTimeoutDuration,
+		)
+		return
+	}
+	slog.Info("Warmup complete",
+		"databases", databases,
+		"records", records,
+		"duration", time.Since(start),
+	)
+}
+
+// startWatching starts watching the database files for changes according to
+// the configured watch mode.
+func startWatching(cfg *config.Config, dbManager *database.Manager) {
+	switch cfg.WatchMode {
+	case config.WatchModePoll:
+		dbManager.StartPolling(cfg.PollIntervalDuration)
+	case config.WatchModeFsnotify:
+		dbManager.StartWatching()
+	default:
+		dbManager.StartWatching()
+		if dbManager.WatchFailed() {
+			slog.Warn("File watcher unavailable, falling back to polling",
+				"poll_interval", cfg.PollIntervalDuration)
+			dbManager.StartPolling(cfg.PollIntervalDuration)
+		}
+	}
+}
+
 func logStartupSummary(cfg *config.Config, dbManager *database.Manager, autoUpdateEnabled bool) {
 	databases := dbManager.ListDatabases()
 
@@ -213,6 +331,7 @@ func logStartupSummary(cfg *config.Config, dbManager *database.Manager, autoUpda
 		"auto_update_enabled", autoUpdateEnabled,
 		"iterator_ttl", cfg.IteratorTTL,
 		"iterator_cleanup_interval", cfg.IteratorCleanupInterval,
+		"watch_mode", cfg.WatchMode,
 	)
 
 	switch cfg.Mode {
@@ -232,6 +351,10 @@ func logStartupSummary(cfg *config.Config, dbManager *database.Manager, autoUpda
 		if len(cfg.Directory.Paths) > 0 {
 			slog.Debug("Watched directories", "paths", cfg.Directory.Paths)
 		}
+	case config.ModeManifest:
+		slog.Info("Manifest mode configuration",
+			"manifest_path", cfg.Manifest.Path,
+		)
 	default:
 		slog.Warn("Unknown mode configuration", "mode", cfg.Mode)
 	}
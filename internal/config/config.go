@@ -4,6 +4,7 @@ package config
 import (
 	"errors"
 	"fmt"
+	"net/netip"
 	"os"
 	"path/filepath"
 	"strings"
@@ -17,41 +18,188 @@ const (
 	ModeMaxMind     = "maxmind"
 	ModeDirectory   = "directory"
 	ModeGeoIPCompat = "geoip_compat"
+	ModeManifest    = "manifest"
 )
 
+// Watch mode constants for configuration.
+const (
+	WatchModeAuto     = "auto"
+	WatchModeFsnotify = "fsnotify"
+	WatchModePoll     = "poll"
+)
+
+// Filter mode constants for configuration.
+const (
+	FilterModeAnd = "and"
+	FilterModeOr  = "or"
+)
+
+// Scopes of lookup_ip calls without a database parameter.
+const (
+	LookupScopeAll     = "all"
+	LookupScopeDefault = "default"
+)
+
+// defaultPollInterval is used when poll_interval is not set.
+const defaultPollInterval = 30 * time.Second
+
+// minIteratorCleanupInterval is the shortest allowed iterator cleanup
+// interval. Shorter values, including zero and negative ones, are raised to
+// it so that the cleanup loop cannot spin.
+const minIteratorCleanupInterval = time.Second
+
+// Default timeouts for database update requests.
+const (
+	defaultUpdateConnectTimeout = 30 * time.Second
+	defaultUpdateReadTimeout    = 60 * time.Second
+	defaultInitialUpdateTimeout = 30 * time.Second
+)
+
+// DefaultEditions returns the editions downloaded in maxmind mode when no
+// editions are configured and use_default_editions is set.
+func DefaultEditions() []string {
+	return []string{"GeoLite2-City", "GeoLite2-ASN"}
+}
+
+// defaultWarmupTimeout is used when warmup_timeout is not set.
+const defaultWarmupTimeout = 10 * time.Second
+
+// defaultJanitorRetention is used when janitor_retention is not set.
+const defaultJanitorRetention = 7 * 24 * time.Hour
+
 // Config represents the application configuration.
 type Config struct {
+	DefaultLanguage                 map[string]string `toml:"default_language"`      // Database name to language code
+	DatabaseDescriptions            map[string]string `toml:"database_descriptions"` // Name or type:<type> to description
 	GeoIPCompat                     GeoIPCompatConfig `toml:"geoip_compat"`
+	BogonNetworks                   []string          `toml:"bogon_networks"` // Replaces the built-in bogon list
+	BogonNetworkPrefixes            []netip.Prefix    `toml:"-"`
+	WarmupNetworks                  []string          `toml:"warmup_networks"` // Read at startup to warm the page cache
+	WarmupNetworkPrefixes           []netip.Prefix    `toml:"-"`
+	DatabasePrecedence              []string          `toml:"database_precedence"` // Order of first_match lookups
+	AllowedPaths                    []string          `toml:"allowed_paths"`       // Directories the server may read and write
 	Mode                            string            `toml:"mode"`
 	UpdateInterval                  string            `toml:"update_interval"`
 	IteratorTTL                     string            `toml:"iterator_ttl"`
 	IteratorCleanupInterval         string            `toml:"iterator_cleanup_interval"`
+	IteratorGracePeriod             string            `toml:"iterator_grace_period"`
+	WatchMode                       string            `toml:"watch_mode"`
+	PollInterval                    string            `toml:"poll_interval"`
+	DefaultFilterMode               string            `toml:"default_filter_mode"`
+	ConfidenceField                 string            `toml:"confidence_field"` // Score used by sort_by_confidence
+	DefaultDatabase                 string            `toml:"default_database"` // Name or type:<type> selector
+	LookupIPScope                   string            `toml:"lookup_ip_scope"`
+	UpdateConnectTimeout            string            `toml:"update_connect_timeout"`
+	UpdateReadTimeout               string            `toml:"update_read_timeout"`
+	InitialUpdateTimeout            string            `toml:"initial_update_timeout"`
+	ToolQueueTimeout                string            `toml:"tool_queue_timeout"`
+	SlowQueryThreshold              string            `toml:"slow_query_threshold"`
+	WarmupTimeout                   string            `toml:"warmup_timeout"`
+	ServerName                      string            `toml:"server_name"` // Advertised MCP server name
+	SourcePath                      string            `toml:"-"`           // Config file that was loaded, if any
 	Directory                       DirectoryConfig   `toml:"directory"`
+	Export                          ExportConfig      `toml:"export"`
+	Manifest                        ManifestConfig    `toml:"manifest"`
 	MaxMind                         MaxMindConfig     `toml:"maxmind"`
 	UpdateIntervalDuration          time.Duration     `toml:"-"`
 	IteratorTTLDuration             time.Duration     `toml:"-"`
 	IteratorCleanupIntervalDuration time.Duration     `toml:"-"`
+	IteratorGracePeriodDuration     time.Duration     `toml:"-"`
+	PollIntervalDuration            time.Duration     `toml:"-"`
+	UpdateConnectTimeoutDuration    time.Duration     `toml:"-"`
+	UpdateReadTimeoutDuration       time.Duration     `toml:"-"`
+	InitialUpdateTimeoutDuration    time.Duration     `toml:"-"`
+	ToolQueueTimeoutDuration        time.Duration     `toml:"-"`
+	SlowQueryThresholdDuration      time.Duration     `toml:"-"`
+	WarmupTimeoutDuration           time.Duration     `toml:"-"`
+	DefaultMaxResults               int               `toml:"default_max_results"` // 0 uses the built-in default
+	MaxResponseBytes                int               `toml:"max_response_bytes"`
+	MaxRegexLength                  int               `toml:"max_regex_length"`
+	MaxDatabases                    int               `toml:"max_databases"`
+	MaxBatchesPerIterator           int               `toml:"max_batches_per_iterator"`
+	MaxConcurrentTools              int               `toml:"max_concurrent_tools"`
+	MinFreeSpaceFactor              float64           `toml:"min_free_space_factor"` // 0 disables the check
 	AutoUpdate                      bool              `toml:"auto_update"`
+	AnonymizeLogIPs                 bool              `toml:"anonymize_log_ips"`
+	PruneEmpty                      bool              `toml:"prune_empty"`
+	SkipNullFilters                 bool              `toml:"skip_null_filters"`
+	StrictFilterPaths               bool              `toml:"strict_filter_paths"`
+	IncludeDatabaseType             bool              `toml:"include_database_type"`
+	CollapseSingleDatabase          bool              `toml:"collapse_single_database"`
+	DeterministicIteratorIDs        bool              `toml:"deterministic_iterator_ids"`
 }
 
 // MaxMindConfig holds configuration for MaxMind database updates.
 type MaxMindConfig struct {
-	LicenseKey  string   `toml:"license_key"`
-	DatabaseDir string   `toml:"database_dir"`
-	Endpoint    string   `toml:"endpoint"`
-	Editions    []string `toml:"editions"`
-	AccountID   int      `toml:"account_id"`
+	// EditionIntervals maps edition IDs to their own update interval.
+	// Editions without an entry use update_interval.
+	EditionIntervals         map[string]string        `toml:"edition_intervals"`
+	EditionIntervalDurations map[string]time.Duration `toml:"-"`
+	LicenseKey               string                   `toml:"license_key"`
+	DatabaseDir              string                   `toml:"database_dir"`
+	// StateDir is where update state such as checksums is kept. It
+	// defaults to DatabaseDir.
+	StateDir string `toml:"state_dir"`
+	// StagingDir is where downloads are written and verified before being
+	// moved into DatabaseDir. It defaults to DatabaseDir and may be on
+	// another filesystem.
+	StagingDir string `toml:"staging_dir"`
+	Endpoint   string `toml:"endpoint"`
+	// JanitorInterval enables the periodic removal of stale files from
+	// DatabaseDir. Empty disables it.
+	JanitorInterval string `toml:"janitor_interval"`
+	// JanitorRetention is the minimum age of a stale file before the
+	// janitor removes it.
+	JanitorRetention string   `toml:"janitor_retention"`
+	Editions         []string `toml:"editions"`
+	// ProtectedDatabases lists database files the updater must never
+	// overwrite, by edition ID, file name, or path.
+	ProtectedDatabases       []string      `toml:"protected_databases"`
+	JanitorIntervalDuration  time.Duration `toml:"-"`
+	JanitorRetentionDuration time.Duration `toml:"-"`
+	AccountID                int           `toml:"account_id"`
+	// JanitorKeepDated is the number of the newest dated copies of each
+	// edition, such as GeoLite2-City_20240102.mmdb, kept regardless of age.
+	JanitorKeepDated int `toml:"janitor_keep_dated"`
+	// UseDefaultEditions downloads DefaultEditions when Editions is empty
+	// instead of failing validation.
+	UseDefaultEditions bool `toml:"use_default_editions"`
 }
 
 // DirectoryConfig holds configuration for directory mode.
 type DirectoryConfig struct {
 	Paths []string `toml:"paths"`
+	// SkipMissingPaths logs a warning for paths that do not exist instead
+	// of failing startup.
+	SkipMissingPaths bool `toml:"skip_missing_paths"`
+	// AutoCreateDirs creates directory paths that do not exist.
+	AutoCreateDirs bool `toml:"auto_create_dirs"`
+}
+
+// ManifestConfig holds configuration for manifest mode.
+type ManifestConfig struct {
+	Path string `toml:"path"`
+}
+
+// ExportConfig holds configuration for the export_database tool.
+type ExportConfig struct {
+	// Dir is the directory exports are written to. Clients can only name
+	// files within it.
+	Dir string `toml:"dir"`
+	// MaxRecords is the maximum number of records per export. Zero uses
+	// the built-in default.
+	MaxRecords int `toml:"max_records"`
+	// Enabled registers the export_database tool.
+	Enabled bool `toml:"enabled"`
 }
 
 // GeoIPCompatConfig holds configuration for GeoIP.conf compatibility.
 type GeoIPCompatConfig struct {
 	ConfigPath  string `toml:"config_path"`
 	DatabaseDir string `toml:"database_dir"`
+	// WatchConfig re-reads ConfigPath when it changes and applies its
+	// editions and database directory.
+	WatchConfig bool `toml:"watch_config"`
 }
 
 // DefaultConfig returns a configuration with default values.
@@ -64,6 +212,13 @@ func DefaultConfig() *Config {
 		UpdateInterval:          "24h",
 		IteratorTTL:             "10m",
 		IteratorCleanupInterval: "1m",
+		WatchMode:               WatchModeAuto,
+		PollInterval:            "30s",
+		DefaultFilterMode:       FilterModeAnd,
+		UpdateConnectTimeout:    "30s",
+		UpdateReadTimeout:       "60s",
+		InitialUpdateTimeout:    "30s",
+		MinFreeSpaceFactor:      2,
 		MaxMind: MaxMindConfig{
 			DatabaseDir: filepath.Join(homeDir, ".cache", "maxminddb-mcp", "databases"),
 			Endpoint:    "https://updates.maxmind.com",
@@ -78,15 +233,16 @@ func DefaultConfig() *Config {
 func (c *Config) Validate() error {
 	// Validate mode
 	switch c.Mode {
-	case ModeMaxMind, ModeDirectory, ModeGeoIPCompat:
+	case ModeMaxMind, ModeDirectory, ModeGeoIPCompat, ModeManifest:
 		// Valid modes
 	default:
 		return fmt.Errorf(
-			"invalid mode: %s (must be %s, %s, or %s)",
+			"invalid mode: %s (must be %s, %s, %s, or %s)",
 			c.Mode,
 			ModeMaxMind,
 			ModeDirectory,
 			ModeGeoIPCompat,
+			ModeManifest,
 		)
 	}
 
@@ -97,6 +253,43 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid update_interval: %w", err)
 	}
 
+	c.MaxMind.EditionIntervalDurations = make(map[string]time.Duration, len(c.MaxMind.EditionIntervals))
+	for edition, interval := range c.MaxMind.EditionIntervals {
+		duration, err := time.ParseDuration(interval)
+		if err != nil {
+			return fmt.Errorf("invalid edition_intervals entry for %s: %w", edition, err)
+		}
+		if duration <= 0 {
+			return fmt.Errorf("edition_intervals entry for %s must be positive", edition)
+		}
+		c.MaxMind.EditionIntervalDurations[edition] = duration
+	}
+
+	// An empty janitor_interval disables the janitor.
+	c.MaxMind.JanitorIntervalDuration, err = parsePositiveDuration("janitor_interval", c.MaxMind.JanitorInterval, 0)
+	if err != nil {
+		return err
+	}
+
+	c.MaxMind.JanitorRetentionDuration, err = parsePositiveDuration(
+		"janitor_retention",
+		c.MaxMind.JanitorRetention,
+		defaultJanitorRetention,
+	)
+	if err != nil {
+		return err
+	}
+
+	if c.MaxMind.JanitorKeepDated < 0 {
+		return errors.New("janitor_keep_dated must not be negative")
+	}
+
+	for _, entry := range c.MaxMind.ProtectedDatabases {
+		if strings.TrimSpace(entry) == "" {
+			return errors.New("protected_databases entries must not be empty")
+		}
+	}
+
 	c.IteratorTTLDuration, err = time.ParseDuration(c.IteratorTTL)
 	if err != nil {
 		return fmt.Errorf("invalid iterator_ttl: %w", err)
@@ -106,6 +299,203 @@ func (c *Config) Validate() error {
 	if err != nil {
 		return fmt.Errorf("invalid iterator_cleanup_interval: %w", err)
 	}
+	c.IteratorCleanupIntervalDuration = max(c.IteratorCleanupIntervalDuration, minIteratorCleanupInterval)
+
+	c.IteratorGracePeriodDuration = 0
+	if c.IteratorGracePeriod != "" {
+		c.IteratorGracePeriodDuration, err = time.ParseDuration(c.IteratorGracePeriod)
+		if err != nil {
+			return fmt.Errorf("invalid iterator_grace_period: %w", err)
+		}
+		if c.IteratorGracePeriodDuration < 0 {
+			return errors.New("iterator_grace_period must not be negative")
+		}
+	}
+
+	switch c.WatchMode {
+	case "":
+		c.WatchMode = WatchModeAuto
+	case WatchModeAuto, WatchModeFsnotify, WatchModePoll:
+		// Valid watch modes
+	default:
+		return fmt.Errorf(
+			"invalid watch_mode: %s (must be %s, %s, or %s)",
+			c.WatchMode,
+			WatchModeAuto,
+			WatchModeFsnotify,
+			WatchModePoll,
+		)
+	}
+
+	c.PollIntervalDuration = defaultPollInterval
+	if c.PollInterval != "" {
+		c.PollIntervalDuration, err = time.ParseDuration(c.PollInterval)
+		if err != nil {
+			return fmt.Errorf("invalid poll_interval: %w", err)
+		}
+		if c.PollIntervalDuration <= 0 {
+			return errors.New("poll_interval must be positive")
+		}
+	}
+
+	c.UpdateConnectTimeoutDuration, err = parsePositiveDuration(
+		"update_connect_timeout",
+		c.UpdateConnectTimeout,
+		defaultUpdateConnectTimeout,
+	)
+	if err != nil {
+		return err
+	}
+
+	c.UpdateReadTimeoutDuration, err = parsePositiveDuration(
+		"update_read_timeout",
+		c.UpdateReadTimeout,
+		defaultUpdateReadTimeout,
+	)
+	if err != nil {
+		return err
+	}
+
+	c.InitialUpdateTimeoutDuration, err = parsePositiveDuration(
+		"initial_update_timeout",
+		c.InitialUpdateTimeout,
+		defaultInitialUpdateTimeout,
+	)
+	if err != nil {
+		return err
+	}
+
+	switch c.DefaultFilterMode {
+	case "":
+		c.DefaultFilterMode = FilterModeAnd
+	case FilterModeAnd, FilterModeOr:
+		// Valid filter modes
+	default:
+		return fmt.Errorf(
+			"invalid default_filter_mode: %s (must be %s or %s)",
+			c.DefaultFilterMode,
+			FilterModeAnd,
+			FilterModeOr,
+		)
+	}
+
+	switch c.LookupIPScope {
+	case "":
+		c.LookupIPScope = LookupScopeAll
+	case LookupScopeAll:
+		// Valid scope
+	case LookupScopeDefault:
+		if c.DefaultDatabase == "" {
+			return errors.New("lookup_ip_scope default requires default_database")
+		}
+	default:
+		return fmt.Errorf(
+			"invalid lookup_ip_scope: %s (must be %s or %s)",
+			c.LookupIPScope,
+			LookupScopeAll,
+			LookupScopeDefault,
+		)
+	}
+
+	for database, language := range c.DefaultLanguage {
+		if language == "" {
+			return fmt.Errorf("default_language for %s must not be empty", database)
+		}
+	}
+
+	for selector, description := range c.DatabaseDescriptions {
+		if strings.TrimSpace(selector) == "" {
+			return errors.New("database_descriptions keys must not be empty")
+		}
+		if description == "" {
+			return fmt.Errorf("database_descriptions for %s must not be empty", selector)
+		}
+	}
+
+	if c.DefaultMaxResults < 0 {
+		return errors.New("default_max_results must not be negative")
+	}
+
+	if c.MaxResponseBytes < 0 {
+		return errors.New("max_response_bytes must not be negative")
+	}
+
+	if c.MaxRegexLength < 0 {
+		return errors.New("max_regex_length must not be negative")
+	}
+
+	if c.MaxDatabases < 0 {
+		return errors.New("max_databases must not be negative")
+	}
+
+	if c.MaxBatchesPerIterator < 0 {
+		return errors.New("max_batches_per_iterator must not be negative")
+	}
+
+	if c.MaxConcurrentTools < 0 {
+		return errors.New("max_concurrent_tools must not be negative")
+	}
+
+	// An empty tool_queue_timeout rejects calls beyond the limit at once.
+	c.ToolQueueTimeoutDuration, err = parsePositiveDuration("tool_queue_timeout", c.ToolQueueTimeout, 0)
+	if err != nil {
+		return err
+	}
+
+	// An empty slow_query_threshold disables the slow query log.
+	c.SlowQueryThresholdDuration, err = parsePositiveDuration("slow_query_threshold", c.SlowQueryThreshold, 0)
+	if err != nil {
+		return err
+	}
+
+	if c.MinFreeSpaceFactor < 0 {
+		return errors.New("min_free_space_factor must not be negative")
+	}
+
+	for _, entry := range c.DatabasePrecedence {
+		if strings.TrimSpace(entry) == "" {
+			return errors.New("database_precedence entries must not be empty")
+		}
+	}
+
+	c.BogonNetworkPrefixes = nil
+	for _, network := range c.BogonNetworks {
+		prefix, err := netip.ParsePrefix(network)
+		if err != nil {
+			return fmt.Errorf("invalid bogon_networks entry: %w", err)
+		}
+		c.BogonNetworkPrefixes = append(c.BogonNetworkPrefixes, prefix)
+	}
+
+	if c.Export.Enabled && c.Export.Dir == "" {
+		return errors.New("export requires dir")
+	}
+	if c.Export.MaxRecords < 0 {
+		return errors.New("export max_records must not be negative")
+	}
+
+	c.WarmupNetworkPrefixes = nil
+	for _, network := range c.WarmupNetworks {
+		prefix, err := netip.ParsePrefix(network)
+		if err != nil {
+			return fmt.Errorf("invalid warmup_networks entry: %w", err)
+		}
+		c.WarmupNetworkPrefixes = append(c.WarmupNetworkPrefixes, prefix)
+	}
+
+	c.WarmupTimeoutDuration, err = parsePositiveDuration("warmup_timeout", c.WarmupTimeout, defaultWarmupTimeout)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range c.AllowedPaths {
+		if strings.TrimSpace(entry) == "" {
+			return errors.New("allowed_paths entries must not be empty")
+		}
+	}
+	if err := c.checkConfiguredPaths(); err != nil {
+		return err
+	}
 
 	// Mode-specific validation
 	switch c.Mode {
@@ -116,8 +506,11 @@ func (c *Config) Validate() error {
 		if c.MaxMind.LicenseKey == "" {
 			return errors.New("maxmind mode requires license_key")
 		}
+		if len(c.MaxMind.Editions) == 0 && c.MaxMind.UseDefaultEditions {
+			c.MaxMind.Editions = DefaultEditions()
+		}
 		if len(c.MaxMind.Editions) == 0 {
-			return errors.New("maxmind mode requires at least one edition")
+			return errors.New("maxmind mode requires at least one edition; list editions or set use_default_editions")
 		}
 		if c.MaxMind.DatabaseDir == "" {
 			return errors.New("maxmind mode requires database_dir")
@@ -126,11 +519,30 @@ func (c *Config) Validate() error {
 		if len(c.Directory.Paths) == 0 {
 			return errors.New("directory mode requires at least one path")
 		}
+		for _, path := range c.Directory.Paths {
+			if err := validateDirectoryPath(path); err != nil {
+				return err
+			}
+		}
+	case ModeManifest:
+		if c.Manifest.Path == "" {
+			return errors.New("manifest mode requires path")
+		}
 	case ModeGeoIPCompat:
 		// Config path is optional, will search default locations
 		if c.GeoIPCompat.DatabaseDir == "" {
 			return errors.New("geoip_compat mode requires database_dir")
 		}
+		// Editions come from the EditionIDs line of GeoIP.conf.
+		if len(c.MaxMind.Editions) == 0 {
+			if c.GeoIPCompat.ConfigPath != "" {
+				return fmt.Errorf(
+					"geoip_compat mode requires at least one edition; set EditionIDs in %s",
+					c.GeoIPCompat.ConfigPath,
+				)
+			}
+			return errors.New("geoip_compat mode requires at least one edition; set EditionIDs in GeoIP.conf")
+		}
 	default:
 		// No additional validation for other modes
 	}
@@ -150,6 +562,16 @@ func (c *Config) ExpandPaths() error {
 		c.MaxMind.DatabaseDir = expandPath(c.MaxMind.DatabaseDir, homeDir)
 	}
 
+	// Expand MaxMind state dir
+	if c.MaxMind.StateDir != "" {
+		c.MaxMind.StateDir = expandPath(c.MaxMind.StateDir, homeDir)
+	}
+
+	// Expand MaxMind staging dir
+	if c.MaxMind.StagingDir != "" {
+		c.MaxMind.StagingDir = expandPath(c.MaxMind.StagingDir, homeDir)
+	}
+
 	// Expand GeoIP compat database dir
 	if c.GeoIPCompat.DatabaseDir != "" {
 		c.GeoIPCompat.DatabaseDir = expandPath(c.GeoIPCompat.DatabaseDir, homeDir)
@@ -160,11 +582,21 @@ func (c *Config) ExpandPaths() error {
 		c.GeoIPCompat.ConfigPath = expandPath(c.GeoIPCompat.ConfigPath, homeDir)
 	}
 
+	// Expand manifest path
+	if c.Manifest.Path != "" {
+		c.Manifest.Path = expandPath(c.Manifest.Path, homeDir)
+	}
+
 	// Expand directory paths
 	for i, path := range c.Directory.Paths {
 		c.Directory.Paths[i] = expandPath(path, homeDir)
 	}
 
+	// Expand allowed paths
+	for i, path := range c.AllowedPaths {
+		c.AllowedPaths[i] = expandPath(path, homeDir)
+	}
+
 	return nil
 }
 
@@ -185,9 +617,40 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, fmt.Errorf("config validation failed: %w", err)
 	}
 
+	cfg.SourcePath = path
 	return &cfg, nil
 }
 
+// parsePositiveDuration parses an optional duration setting, returning
+// defaultValue when it is empty.
+func parsePositiveDuration(name, value string, defaultValue time.Duration) (time.Duration, error) {
+	if value == "" {
+		return defaultValue, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", name, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("%s must be positive", name)
+	}
+	return d, nil
+}
+
+// validateDirectoryPath checks a directory mode path. Paths may be
+// directories or individual .mmdb files; paths that do not exist yet are
+// reported when the databases are loaded.
+func validateDirectoryPath(path string) error {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return nil
+	}
+	if !strings.EqualFold(filepath.Ext(path), ".mmdb") {
+		return fmt.Errorf("directory mode path %s must be a directory or an .mmdb file", path)
+	}
+	return nil
+}
+
 // expandPath replaces ~ with home directory.
 func expandPath(path, homeDir string) string {
 	if path == "~" {
@@ -1,8 +1,11 @@
 package config
 
 import (
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 )
@@ -35,6 +38,15 @@ func TestDefaultConfig(t *testing.T) {
 }
 
 func TestConfigValidation(t *testing.T) {
+	tempDir := t.TempDir()
+	mmdbFile := filepath.Join(tempDir, "GeoLite2-City.mmdb")
+	otherFile := filepath.Join(tempDir, "notes.txt")
+	for _, path := range []string{mmdbFile, otherFile} {
+		if err := os.WriteFile(path, []byte("test"), 0o600); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
 	tests := []struct {
 		name        string
 		config      *Config
@@ -61,7 +73,408 @@ func TestConfigValidation(t *testing.T) {
 			name:        "invalid mode",
 			config:      &Config{Mode: "invalid"},
 			expectError: true,
-			errorMsg:    "invalid mode: invalid (must be maxmind, directory, or geoip_compat)",
+			errorMsg:    "invalid mode: invalid (must be maxmind, directory, geoip_compat, or manifest)",
+		},
+		{
+			name: "invalid watch_mode",
+			config: &Config{
+				Mode:                    "directory",
+				UpdateInterval:          "24h",
+				IteratorTTL:             "10m",
+				IteratorCleanupInterval: "1m",
+				WatchMode:               "inotify",
+				Directory:               DirectoryConfig{Paths: []string{tempDir}},
+			},
+			expectError: true,
+			errorMsg:    "invalid watch_mode: inotify (must be auto, fsnotify, or poll)",
+		},
+		{
+			name: "invalid poll_interval",
+			config: &Config{
+				Mode:                    "directory",
+				UpdateInterval:          "24h",
+				IteratorTTL:             "10m",
+				IteratorCleanupInterval: "1m",
+				WatchMode:               "poll",
+				PollInterval:            "0s",
+				Directory:               DirectoryConfig{Paths: []string{tempDir}},
+			},
+			expectError: true,
+			errorMsg:    "poll_interval must be positive",
+		},
+		{
+			name: "invalid update_read_timeout",
+			config: &Config{
+				Mode:                    "directory",
+				UpdateInterval:          "24h",
+				IteratorTTL:             "10m",
+				IteratorCleanupInterval: "1m",
+				UpdateReadTimeout:       "-1s",
+				Directory:               DirectoryConfig{Paths: []string{tempDir}},
+			},
+			expectError: true,
+			errorMsg:    "update_read_timeout must be positive",
+		},
+		{
+			name: "invalid initial_update_timeout",
+			config: &Config{
+				Mode:                    "directory",
+				UpdateInterval:          "24h",
+				IteratorTTL:             "10m",
+				IteratorCleanupInterval: "1m",
+				InitialUpdateTimeout:    "0s",
+				Directory:               DirectoryConfig{Paths: []string{tempDir}},
+			},
+			expectError: true,
+			errorMsg:    "initial_update_timeout must be positive",
+		},
+		{
+			name: "empty default_language",
+			config: &Config{
+				Mode:                    "directory",
+				UpdateInterval:          "24h",
+				IteratorTTL:             "10m",
+				IteratorCleanupInterval: "1m",
+				DefaultLanguage:         map[string]string{"GeoLite2-City.mmdb": ""},
+				Directory:               DirectoryConfig{Paths: []string{tempDir}},
+			},
+			expectError: true,
+			errorMsg:    "default_language for GeoLite2-City.mmdb must not be empty",
+		},
+		{
+			name: "empty database_descriptions entry",
+			config: &Config{
+				Mode:                    "directory",
+				UpdateInterval:          "24h",
+				IteratorTTL:             "10m",
+				IteratorCleanupInterval: "1m",
+				DatabaseDescriptions:    map[string]string{"type:City": ""},
+				Directory:               DirectoryConfig{Paths: []string{tempDir}},
+			},
+			expectError: true,
+			errorMsg:    "database_descriptions for type:City must not be empty",
+		},
+		{
+			name: "invalid default_filter_mode",
+			config: &Config{
+				Mode:                    "directory",
+				UpdateInterval:          "24h",
+				IteratorTTL:             "10m",
+				IteratorCleanupInterval: "1m",
+				DefaultFilterMode:       "xor",
+				Directory:               DirectoryConfig{Paths: []string{tempDir}},
+			},
+			expectError: true,
+			errorMsg:    "invalid default_filter_mode: xor (must be and or or)",
+		},
+		{
+			name: "invalid bogon_networks",
+			config: &Config{
+				Mode:                    "directory",
+				UpdateInterval:          "24h",
+				IteratorTTL:             "10m",
+				IteratorCleanupInterval: "1m",
+				BogonNetworks:           []string{"10.0.0.0/8", "bogus"},
+				Directory:               DirectoryConfig{Paths: []string{tempDir}},
+			},
+			expectError: true,
+			errorMsg:    `invalid bogon_networks entry: netip.ParsePrefix("bogus"): no '/'`,
+		},
+		{
+			name: "export without dir",
+			config: &Config{
+				Mode:                    "directory",
+				UpdateInterval:          "24h",
+				IteratorTTL:             "10m",
+				IteratorCleanupInterval: "1m",
+				Export:                  ExportConfig{Enabled: true},
+				Directory:               DirectoryConfig{Paths: []string{tempDir}},
+			},
+			expectError: true,
+			errorMsg:    "export requires dir",
+		},
+		{
+			name: "empty allowed_paths entry",
+			config: &Config{
+				Mode:                    "directory",
+				UpdateInterval:          "24h",
+				IteratorTTL:             "10m",
+				IteratorCleanupInterval: "1m",
+				AllowedPaths:            []string{" "},
+				Directory:               DirectoryConfig{Paths: []string{tempDir}},
+			},
+			expectError: true,
+			errorMsg:    "allowed_paths entries must not be empty",
+		},
+		{
+			name: "directory path outside allowed_paths",
+			config: &Config{
+				Mode:                    "directory",
+				UpdateInterval:          "24h",
+				IteratorTTL:             "10m",
+				IteratorCleanupInterval: "1m",
+				AllowedPaths:            []string{filepath.Join(tempDir, "allowed")},
+				Directory:               DirectoryConfig{Paths: []string{mmdbFile}},
+			},
+			expectError: true,
+			errorMsg:    "directory path: " + mmdbFile + " is outside allowed_paths",
+		},
+		{
+			name: "directory path within allowed_paths",
+			config: &Config{
+				Mode:                    "directory",
+				UpdateInterval:          "24h",
+				IteratorTTL:             "10m",
+				IteratorCleanupInterval: "1m",
+				AllowedPaths:            []string{tempDir},
+				Directory:               DirectoryConfig{Paths: []string{mmdbFile}},
+			},
+			expectError: false,
+		},
+		{
+			name: "invalid warmup_networks",
+			config: &Config{
+				Mode:                    "directory",
+				UpdateInterval:          "24h",
+				IteratorTTL:             "10m",
+				IteratorCleanupInterval: "1m",
+				WarmupNetworks:          []string{"bogus"},
+				Directory:               DirectoryConfig{Paths: []string{tempDir}},
+			},
+			expectError: true,
+			errorMsg:    `invalid warmup_networks entry: netip.ParsePrefix("bogus"): no '/'`,
+		},
+		{
+			name: "invalid warmup_timeout",
+			config: &Config{
+				Mode:                    "directory",
+				UpdateInterval:          "24h",
+				IteratorTTL:             "10m",
+				IteratorCleanupInterval: "1m",
+				WarmupNetworks:          []string{"81.2.69.0/24"},
+				WarmupTimeout:           "-1s",
+				Directory:               DirectoryConfig{Paths: []string{tempDir}},
+			},
+			expectError: true,
+			errorMsg:    "warmup_timeout must be positive",
+		},
+		{
+			name: "negative iterator_grace_period",
+			config: &Config{
+				Mode:                    "directory",
+				UpdateInterval:          "24h",
+				IteratorTTL:             "10m",
+				IteratorCleanupInterval: "1m",
+				IteratorGracePeriod:     "-1s",
+				Directory:               DirectoryConfig{Paths: []string{tempDir}},
+			},
+			expectError: true,
+			errorMsg:    "iterator_grace_period must not be negative",
+		},
+		{
+			name: "non-positive edition_intervals entry",
+			config: &Config{
+				Mode:                    "maxmind",
+				UpdateInterval:          "24h",
+				IteratorTTL:             "10m",
+				IteratorCleanupInterval: "1m",
+				MaxMind: MaxMindConfig{
+					AccountID:        12345,
+					LicenseKey:       "test-key",
+					Editions:         []string{"GeoLite2-City"},
+					DatabaseDir:      "/tmp/db",
+					EditionIntervals: map[string]string{"GeoLite2-City": "0s"},
+				},
+			},
+			expectError: true,
+			errorMsg:    "edition_intervals entry for GeoLite2-City must be positive",
+		},
+		{
+			name: "negative min_free_space_factor",
+			config: &Config{
+				Mode:                    "directory",
+				UpdateInterval:          "24h",
+				IteratorTTL:             "10m",
+				IteratorCleanupInterval: "1m",
+				MinFreeSpaceFactor:      -1,
+				Directory:               DirectoryConfig{Paths: []string{tempDir}},
+			},
+			expectError: true,
+			errorMsg:    "min_free_space_factor must not be negative",
+		},
+		{
+			name: "negative max_batches_per_iterator",
+			config: &Config{
+				Mode:                    "directory",
+				UpdateInterval:          "24h",
+				IteratorTTL:             "10m",
+				IteratorCleanupInterval: "1m",
+				MaxBatchesPerIterator:   -1,
+				Directory:               DirectoryConfig{Paths: []string{tempDir}},
+			},
+			expectError: true,
+			errorMsg:    "max_batches_per_iterator must not be negative",
+		},
+		{
+			name: "empty database_precedence entry",
+			config: &Config{
+				Mode:                    "directory",
+				UpdateInterval:          "24h",
+				IteratorTTL:             "10m",
+				IteratorCleanupInterval: "1m",
+				DatabasePrecedence:      []string{"type:City", " "},
+				Directory:               DirectoryConfig{Paths: []string{tempDir}},
+			},
+			expectError: true,
+			errorMsg:    "database_precedence entries must not be empty",
+		},
+		{
+			name: "invalid janitor_interval",
+			config: &Config{
+				Mode:                    "directory",
+				UpdateInterval:          "24h",
+				IteratorTTL:             "10m",
+				IteratorCleanupInterval: "1m",
+				MaxMind:                 MaxMindConfig{JanitorInterval: "-1h"},
+				Directory:               DirectoryConfig{Paths: []string{tempDir}},
+			},
+			expectError: true,
+			errorMsg:    "janitor_interval must be positive",
+		},
+		{
+			name: "negative janitor_keep_dated",
+			config: &Config{
+				Mode:                    "directory",
+				UpdateInterval:          "24h",
+				IteratorTTL:             "10m",
+				IteratorCleanupInterval: "1m",
+				MaxMind:                 MaxMindConfig{JanitorKeepDated: -1},
+				Directory:               DirectoryConfig{Paths: []string{tempDir}},
+			},
+			expectError: true,
+			errorMsg:    "janitor_keep_dated must not be negative",
+		},
+		{
+			name: "negative default_max_results",
+			config: &Config{
+				Mode:                    "directory",
+				UpdateInterval:          "24h",
+				IteratorTTL:             "10m",
+				IteratorCleanupInterval: "1m",
+				DefaultMaxResults:       -1,
+				Directory:               DirectoryConfig{Paths: []string{tempDir}},
+			},
+			expectError: true,
+			errorMsg:    "default_max_results must not be negative",
+		},
+		{
+			name: "empty protected_databases entry",
+			config: &Config{
+				Mode:                    "directory",
+				UpdateInterval:          "24h",
+				IteratorTTL:             "10m",
+				IteratorCleanupInterval: "1m",
+				MaxMind:                 MaxMindConfig{ProtectedDatabases: []string{" "}},
+				Directory:               DirectoryConfig{Paths: []string{tempDir}},
+			},
+			expectError: true,
+			errorMsg:    "protected_databases entries must not be empty",
+		},
+		{
+			name: "negative max_concurrent_tools",
+			config: &Config{
+				Mode:                    "directory",
+				UpdateInterval:          "24h",
+				IteratorTTL:             "10m",
+				IteratorCleanupInterval: "1m",
+				MaxConcurrentTools:      -1,
+				Directory:               DirectoryConfig{Paths: []string{tempDir}},
+			},
+			expectError: true,
+			errorMsg:    "max_concurrent_tools must not be negative",
+		},
+		{
+			name: "invalid tool_queue_timeout",
+			config: &Config{
+				Mode:                    "directory",
+				UpdateInterval:          "24h",
+				IteratorTTL:             "10m",
+				IteratorCleanupInterval: "1m",
+				ToolQueueTimeout:        "-1s",
+				Directory:               DirectoryConfig{Paths: []string{tempDir}},
+			},
+			expectError: true,
+			errorMsg:    "tool_queue_timeout must be positive",
+		},
+		{
+			name: "invalid slow_query_threshold",
+			config: &Config{
+				Mode:                    "directory",
+				UpdateInterval:          "24h",
+				IteratorTTL:             "10m",
+				IteratorCleanupInterval: "1m",
+				SlowQueryThreshold:      "0s",
+				Directory:               DirectoryConfig{Paths: []string{tempDir}},
+			},
+			expectError: true,
+			errorMsg:    "slow_query_threshold must be positive",
+		},
+		{
+			name: "invalid lookup_ip_scope",
+			config: &Config{
+				Mode:                    "directory",
+				UpdateInterval:          "24h",
+				IteratorTTL:             "10m",
+				IteratorCleanupInterval: "1m",
+				LookupIPScope:           "some",
+				Directory:               DirectoryConfig{Paths: []string{tempDir}},
+			},
+			expectError: true,
+			errorMsg:    "invalid lookup_ip_scope: some (must be all or default)",
+		},
+		{
+			name: "default lookup_ip_scope without default_database",
+			config: &Config{
+				Mode:                    "directory",
+				UpdateInterval:          "24h",
+				IteratorTTL:             "10m",
+				IteratorCleanupInterval: "1m",
+				LookupIPScope:           LookupScopeDefault,
+				Directory:               DirectoryConfig{Paths: []string{tempDir}},
+			},
+			expectError: true,
+			errorMsg:    "lookup_ip_scope default requires default_database",
+		},
+		{
+			name: "negative max_databases",
+			config: &Config{
+				Mode:                    "directory",
+				UpdateInterval:          "24h",
+				IteratorTTL:             "10m",
+				IteratorCleanupInterval: "1m",
+				MaxDatabases:            -1,
+				Directory:               DirectoryConfig{Paths: []string{tempDir}},
+			},
+			expectError: true,
+			errorMsg:    "max_databases must not be negative",
+		},
+		{
+			name: "negative max_response_bytes",
+			config: &Config{
+				Mode:                    "maxmind",
+				UpdateInterval:          "24h",
+				IteratorTTL:             "10m",
+				IteratorCleanupInterval: "1m",
+				MaxResponseBytes:        -1,
+				MaxMind: MaxMindConfig{
+					AccountID:   12345,
+					LicenseKey:  "test-key",
+					Editions:    []string{"GeoLite2-City"},
+					DatabaseDir: "/tmp/db",
+				},
+			},
+			expectError: true,
+			errorMsg:    "max_response_bytes must not be negative",
 		},
 		{
 			name: "maxmind missing account_id",
@@ -95,6 +508,42 @@ func TestConfigValidation(t *testing.T) {
 			expectError: true,
 			errorMsg:    "maxmind mode requires license_key",
 		},
+		{
+			name: "maxmind missing editions",
+			config: &Config{
+				Mode:                    "maxmind",
+				UpdateInterval:          "24h",
+				IteratorTTL:             "10m",
+				IteratorCleanupInterval: "1m",
+				MaxMind: MaxMindConfig{
+					AccountID:   12345,
+					LicenseKey:  "test-key",
+					DatabaseDir: "/tmp/db",
+				},
+			},
+			expectError: true,
+			errorMsg:    "maxmind mode requires at least one edition; list editions or set use_default_editions",
+		},
+		{
+			name: "geoip_compat missing editions",
+			config: &Config{
+				Mode:                    "geoip_compat",
+				UpdateInterval:          "24h",
+				IteratorTTL:             "10m",
+				IteratorCleanupInterval: "1m",
+				MaxMind: MaxMindConfig{
+					AccountID:   12345,
+					LicenseKey:  "test-key",
+					DatabaseDir: "/tmp/db",
+				},
+				GeoIPCompat: GeoIPCompatConfig{
+					ConfigPath:  "/etc/GeoIP.conf",
+					DatabaseDir: "/tmp/db",
+				},
+			},
+			expectError: true,
+			errorMsg:    "geoip_compat mode requires at least one edition; set EditionIDs in /etc/GeoIP.conf",
+		},
 		{
 			name: "directory mode valid",
 			config: &Config{
@@ -119,6 +568,57 @@ func TestConfigValidation(t *testing.T) {
 			expectError: true,
 			errorMsg:    "directory mode requires at least one path",
 		},
+		{
+			name: "directory mode with single mmdb file",
+			config: &Config{
+				Mode:                    "directory",
+				UpdateInterval:          "24h",
+				IteratorTTL:             "10m",
+				IteratorCleanupInterval: "1m",
+				Directory: DirectoryConfig{
+					Paths: []string{"/tmp/mmdb", mmdbFile},
+				},
+			},
+			expectError: false,
+		},
+		{
+			name: "directory mode with non-mmdb file",
+			config: &Config{
+				Mode:                    "directory",
+				UpdateInterval:          "24h",
+				IteratorTTL:             "10m",
+				IteratorCleanupInterval: "1m",
+				Directory: DirectoryConfig{
+					Paths: []string{otherFile},
+				},
+			},
+			expectError: true,
+			errorMsg:    "directory mode path " + otherFile + " must be a directory or an .mmdb file",
+		},
+		{
+			name: "manifest mode valid",
+			config: &Config{
+				Mode:                    "manifest",
+				UpdateInterval:          "24h",
+				IteratorTTL:             "10m",
+				IteratorCleanupInterval: "1m",
+				Manifest: ManifestConfig{
+					Path: "/tmp/databases.toml",
+				},
+			},
+			expectError: false,
+		},
+		{
+			name: "manifest mode missing path",
+			config: &Config{
+				Mode:                    "manifest",
+				UpdateInterval:          "24h",
+				IteratorTTL:             "10m",
+				IteratorCleanupInterval: "1m",
+			},
+			expectError: true,
+			errorMsg:    "manifest mode requires path",
+		},
 		{
 			name: "invalid duration",
 			config: &Config{
@@ -206,6 +706,9 @@ func TestConfigParseDurations(t *testing.T) {
 		Directory: DirectoryConfig{
 			Paths: []string{"/tmp"},
 		},
+		MaxMind: MaxMindConfig{
+			EditionIntervals: map[string]string{"GeoLite2-City": "168h"},
+		},
 	}
 
 	err := cfg.Validate()
@@ -231,4 +734,238 @@ func TestConfigParseDurations(t *testing.T) {
 			cfg.IteratorCleanupIntervalDuration,
 		)
 	}
+
+	expectedEdition := 168 * time.Hour
+	if got := cfg.MaxMind.EditionIntervalDurations["GeoLite2-City"]; got != expectedEdition {
+		t.Errorf("Expected GeoLite2-City interval %v, got %v", expectedEdition, got)
+	}
+}
+
+func TestConfigClampsCleanupInterval(t *testing.T) {
+	for _, interval := range []string{"0s", "-5s", "10ms"} {
+		t.Run(interval, func(t *testing.T) {
+			cfg := &Config{
+				Mode:                    "directory",
+				UpdateInterval:          "1h",
+				IteratorTTL:             "5m",
+				IteratorCleanupInterval: interval,
+				Directory: DirectoryConfig{
+					Paths: []string{"/tmp"},
+				},
+			}
+
+			if err := cfg.Validate(); err != nil {
+				t.Fatalf("Validation failed: %v", err)
+			}
+			if cfg.IteratorCleanupIntervalDuration != time.Second {
+				t.Errorf(
+					"Expected cleanup interval clamped to 1s, got %v",
+					cfg.IteratorCleanupIntervalDuration,
+				)
+			}
+		})
+	}
+}
+
+func TestConfigUseDefaultEditions(t *testing.T) {
+	newConfig := func(editions []string) *Config {
+		return &Config{
+			Mode:                    "maxmind",
+			UpdateInterval:          "24h",
+			IteratorTTL:             "10m",
+			IteratorCleanupInterval: "1m",
+			MaxMind: MaxMindConfig{
+				AccountID:          12345,
+				LicenseKey:         "test-key",
+				DatabaseDir:        "/tmp/db",
+				Editions:           editions,
+				UseDefaultEditions: true,
+			},
+		}
+	}
+
+	cfg := newConfig(nil)
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validation failed: %v", err)
+	}
+	if !slices.Equal(cfg.MaxMind.Editions, []string{"GeoLite2-City", "GeoLite2-ASN"}) {
+		t.Errorf("Expected the default editions, got %v", cfg.MaxMind.Editions)
+	}
+
+	// Configured editions are kept.
+	cfg = newConfig([]string{"GeoIP2-Country"})
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validation failed: %v", err)
+	}
+	if !slices.Equal(cfg.MaxMind.Editions, []string{"GeoIP2-Country"}) {
+		t.Errorf("Expected the configured editions, got %v", cfg.MaxMind.Editions)
+	}
+}
+
+func TestLoadConfigRecordsSourcePath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	content := `mode = "directory"
+update_interval = "24h"
+iterator_ttl = "10m"
+iterator_cleanup_interval = "1m"
+
+[directory]
+paths = ["/tmp/mmdb"]
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	t.Setenv("MAXMINDDB_MCP_CONFIG", path)
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.SourcePath != path {
+		t.Errorf("Expected source path %s, got %s", path, cfg.SourcePath)
+	}
+
+	cfg, err = LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.SourcePath != path {
+		t.Errorf("Expected source path %s, got %s", path, cfg.SourcePath)
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.MaxMind.AccountID = 123456
+	cfg.MaxMind.LicenseKey = "secret_license_key"
+
+	redacted, err := cfg.Redacted()
+	if err != nil {
+		t.Fatalf("Failed to redact config: %v", err)
+	}
+
+	maxmind, ok := redacted["maxmind"].(map[string]any)
+	if !ok {
+		t.Fatalf("Expected maxmind section, got %v", redacted["maxmind"])
+	}
+	if maxmind["license_key"] != redactedValue {
+		t.Errorf("Expected redacted license key, got %v", maxmind["license_key"])
+	}
+	if maxmind["account_id"] != "****56" {
+		t.Errorf("Expected masked account ID, got %v", maxmind["account_id"])
+	}
+	if redacted["update_interval"] != "24h" {
+		t.Errorf("Expected update_interval 24h, got %v", redacted["update_interval"])
+	}
+
+	// The original configuration is unchanged.
+	if cfg.MaxMind.LicenseKey != "secret_license_key" {
+		t.Error("Redacted should not modify the configuration")
+	}
+}
+
+func TestLoadFilesMergesConfigs(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.toml")
+	baseContent := `mode = "maxmind"
+update_interval = "24h"
+max_response_bytes = 1000
+
+[default_language]
+"GeoLite2-City.mmdb" = "en"
+
+[maxmind]
+account_id = 12345
+license_key = "base-key"
+editions = ["GeoLite2-City", "GeoLite2-Country"]
+database_dir = "/tmp/db"
+`
+	override := filepath.Join(dir, "production.toml")
+	overrideContent := `update_interval = "6h"
+
+[default_language]
+"GeoLite2-Country.mmdb" = "de"
+
+[maxmind]
+license_key = "production-key"
+editions = ["GeoLite2-Country", "GeoLite2-ASN"]
+`
+	for path, content := range map[string]string{base: baseContent, override: overrideContent} {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+	}
+
+	t.Setenv("MAXMINDDB_MCP_CONFIG", base+string(os.PathListSeparator)+override)
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Failed to load merged config: %v", err)
+	}
+
+	// Later files override scalar settings.
+	if cfg.UpdateIntervalDuration != 6*time.Hour {
+		t.Errorf("Expected update interval 6h, got %v", cfg.UpdateIntervalDuration)
+	}
+	if cfg.MaxMind.LicenseKey != "production-key" {
+		t.Errorf("Expected the override license key, got %s", cfg.MaxMind.LicenseKey)
+	}
+	// Settings missing from later files are kept.
+	if cfg.MaxMind.AccountID != 12345 || cfg.MaxResponseBytes != 1000 || cfg.MaxMind.DatabaseDir != "/tmp/db" {
+		t.Errorf("Expected base settings to be kept, got %+v", cfg)
+	}
+	// Lists are concatenated without duplicates and maps are merged.
+	expectedEditions := []string{"GeoLite2-City", "GeoLite2-Country", "GeoLite2-ASN"}
+	if !slices.Equal(cfg.MaxMind.Editions, expectedEditions) {
+		t.Errorf("Expected editions %v, got %v", expectedEditions, cfg.MaxMind.Editions)
+	}
+	expectedLanguages := map[string]string{"GeoLite2-City.mmdb": "en", "GeoLite2-Country.mmdb": "de"}
+	if !maps.Equal(cfg.DefaultLanguage, expectedLanguages) {
+		t.Errorf("Expected default languages %v, got %v", expectedLanguages, cfg.DefaultLanguage)
+	}
+	if expected := base + string(os.PathListSeparator) + override; cfg.SourcePath != expected {
+		t.Errorf("Expected source path %s, got %s", expected, cfg.SourcePath)
+	}
+}
+
+func TestLoadFilesErrors(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.toml")
+	if err := os.WriteFile(base, []byte(`mode = "directory"`+"\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	override := filepath.Join(dir, "override.toml")
+	if err := os.WriteFile(override, []byte(`update_interval = "1h"`+"\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		errorMsg string
+		paths    []string
+	}{
+		{
+			name:     "merged result is validated",
+			paths:    []string{base, override},
+			errorMsg: "directory mode requires at least one path",
+		},
+		{
+			name:     "missing file",
+			paths:    []string{base, filepath.Join(dir, "missing.toml")},
+			errorMsg: "failed to load TOML config from " + filepath.Join(dir, "missing.toml"),
+		},
+		{
+			name:     "GeoIP.conf",
+			paths:    []string{base, filepath.Join(dir, "GeoIP.conf")},
+			errorMsg: "only TOML config files can be merged",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := LoadFiles(test.paths)
+			if err == nil || !strings.Contains(err.Error(), test.errorMsg) {
+				t.Errorf("Expected error containing %q, got %v", test.errorMsg, err)
+			}
+		})
+	}
 }
@@ -70,35 +70,36 @@ func ParseGeoIPConfig(path string) (*GeoIPConfig, error) {
 			continue // Skip malformed lines
 		}
 
-		key := parts[0]
+		// Keys are matched case-insensitively, e.g. accountid or LICENSEKEY
+		key := strings.ToLower(parts[0])
 		value := strings.TrimSpace(parts[1])
 
 		switch key {
-		case "AccountID", "UserId": // UserId is deprecated but still supported
+		case "accountid", "userid": // UserId is deprecated but still supported
 			if accountID, err := strconv.Atoi(value); err == nil {
 				config.AccountID = accountID
 			}
-		case "LicenseKey":
-			config.LicenseKey = value
-		case "EditionIDs", "ProductIds": // ProductIds is deprecated but still supported
+		case "licensekey":
+			config.LicenseKey = unquote(value)
+		case "editionids", "productids": // ProductIds is deprecated but still supported
 			config.EditionIDs = strings.Fields(value)
-		case "DatabaseDirectory":
-			config.DatabaseDirectory = value
-		case "Host":
-			config.Host = value
-		case "Proxy":
-			config.Proxy = value
-		case "ProxyUserPassword":
-			config.ProxyUserPassword = value
-		case "PreserveFileTimes":
+		case "databasedirectory":
+			config.DatabaseDirectory = unquote(value)
+		case "host":
+			config.Host = unquote(value)
+		case "proxy":
+			config.Proxy = unquote(value)
+		case "proxyuserpassword":
+			config.ProxyUserPassword = unquote(value)
+		case "preservefiletimes":
 			if preserve, err := strconv.Atoi(value); err == nil {
 				config.PreserveFileTimes = preserve
 			}
-		case "LockFile":
-			config.LockFile = value
-		case "RetryFor":
+		case "lockfile":
+			config.LockFile = unquote(value)
+		case "retryfor":
 			config.RetryFor = value
-		case "Parallelism":
+		case "parallelism":
 			if parallelism, err := strconv.Atoi(value); err == nil {
 				config.Parallelism = parallelism
 			}
@@ -114,8 +115,24 @@ func ParseGeoIPConfig(path string) (*GeoIPConfig, error) {
 	return config, nil
 }
 
-// loadGeoIPConfig loads a GeoIP.conf file and converts it to our config format.
+// unquote removes the double quotes around a single-value setting, so that
+// values such as "/var/lib/Geo IP" keep their spaces. The value between the
+// quotes is used as is; there are no escape sequences, so Windows paths need
+// no doubled backslashes. Unquoted values are returned unchanged.
+func unquote(value string) string {
+	if len(value) >= 2 && strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`) {
+		return value[1 : len(value)-1]
+	}
+	return value
+}
+
+// loadGeoIPConfig loads a GeoIP.conf file and converts it to our config
+// format. The file must be within the allowed_paths of config, if any.
 func loadGeoIPConfig(path string, config *Config) error {
+	if err := config.CheckPathAllowed(path); err != nil {
+		return err
+	}
+
 	geoipConfig, err := ParseGeoIPConfig(path)
 	if err != nil {
 		return err
@@ -6,6 +6,7 @@ import (
 	"slices"
 	"strings"
 	"testing"
+	"time"
 )
 
 const testMaxMindEndpoint = "https://updates.maxmind.com"
@@ -116,6 +117,94 @@ DatabaseDirectory /old/path
 	}
 }
 
+func TestParseGeoIPConfigCaseInsensitiveKeys(t *testing.T) {
+	content := `accountid 123456
+licensekey test_license_key
+EDITIONIDS GeoLite2-Country GeoLite2-City
+databasedirectory /custom/path
+userID 654321
+productids GeoLite2-ASN
+`
+
+	configPath := filepath.Join(t.TempDir(), "GeoIP.conf")
+	if err := os.WriteFile(configPath, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	geoipConfig, err := ParseGeoIPConfig(configPath)
+	if err != nil {
+		t.Fatalf("Failed to parse GeoIP config: %v", err)
+	}
+
+	if geoipConfig.LicenseKey != "test_license_key" {
+		t.Errorf("Expected LicenseKey test_license_key, got %s", geoipConfig.LicenseKey)
+	}
+	if geoipConfig.DatabaseDirectory != "/custom/path" {
+		t.Errorf("Expected DatabaseDirectory /custom/path, got %s", geoipConfig.DatabaseDirectory)
+	}
+	// The legacy aliases are matched case-insensitively as well, and later
+	// lines override earlier ones.
+	if geoipConfig.AccountID != 654321 {
+		t.Errorf("Expected AccountID from userID 654321, got %d", geoipConfig.AccountID)
+	}
+	if !slices.Equal(geoipConfig.EditionIDs, []string{"GeoLite2-ASN"}) {
+		t.Errorf("Expected editions from productids, got %v", geoipConfig.EditionIDs)
+	}
+}
+
+func TestParseGeoIPConfigQuotedValues(t *testing.T) {
+	content := `AccountID 123456
+LicenseKey "key with spaces"
+DatabaseDirectory "/var/lib/Geo IP"
+Proxy "proxy.example.com:8080"
+ProxyUserPassword "user:pass word"
+LockFile "C:\GeoIP\.geoipupdate.lock"
+Host unquoted.example.com
+`
+
+	configPath := filepath.Join(t.TempDir(), "GeoIP.conf")
+	if err := os.WriteFile(configPath, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	geoipConfig, err := ParseGeoIPConfig(configPath)
+	if err != nil {
+		t.Fatalf("Failed to parse GeoIP config: %v", err)
+	}
+
+	tests := []struct {
+		name, got, expected string
+	}{
+		{"LicenseKey", geoipConfig.LicenseKey, "key with spaces"},
+		{"DatabaseDirectory", geoipConfig.DatabaseDirectory, "/var/lib/Geo IP"},
+		{"Proxy", geoipConfig.Proxy, "proxy.example.com:8080"},
+		{"ProxyUserPassword", geoipConfig.ProxyUserPassword, "user:pass word"},
+		{"LockFile", geoipConfig.LockFile, `C:\GeoIP\.geoipupdate.lock`},
+		{"Host", geoipConfig.Host, "unquoted.example.com"},
+	}
+	for _, test := range tests {
+		if test.got != test.expected {
+			t.Errorf("Expected %s %q, got %q", test.name, test.expected, test.got)
+		}
+	}
+}
+
+func TestUnquote(t *testing.T) {
+	tests := map[string]string{
+		`"quoted value"`: "quoted value",
+		`""`:             "",
+		`"`:              `"`,
+		`unquoted`:       "unquoted",
+		`"unterminated`:  `"unterminated`,
+		`mid"quote"`:     `mid"quote"`,
+	}
+	for value, expected := range tests {
+		if got := unquote(value); got != expected {
+			t.Errorf("unquote(%q) = %q, expected %q", value, got, expected)
+		}
+	}
+}
+
 func TestParseGeoIPConfigWithComments(t *testing.T) {
 	content := `# This is a comment
 # Another comment
@@ -216,6 +305,148 @@ Host https://custom.endpoint.com
 	}
 }
 
+func TestLoadGeoIPConfigWithoutEditions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "GeoIP.conf")
+	content := `AccountID 123456
+LicenseKey test_key
+DatabaseDirectory /custom/path
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write GeoIP.conf: %v", err)
+	}
+
+	t.Setenv("MAXMINDDB_MCP_CONFIG", path)
+	_, err := Load()
+	if err == nil {
+		t.Fatal("Expected error for GeoIP.conf without EditionIDs, got nil")
+	}
+	if !strings.Contains(err.Error(), "requires at least one edition; set EditionIDs in "+path) {
+		t.Errorf("Expected missing editions error naming %s, got: %v", path, err)
+	}
+}
+
+func TestLoadTOMLConfigWithGeoIPConfigPath(t *testing.T) {
+	dir := t.TempDir()
+	geoipPath := filepath.Join(dir, "GeoIP.conf")
+	geoipContent := `AccountID 123456
+LicenseKey test_key
+EditionIDs GeoLite2-City GeoLite2-ASN
+DatabaseDirectory /custom/path
+`
+	if err := os.WriteFile(geoipPath, []byte(geoipContent), 0o600); err != nil {
+		t.Fatalf("Failed to write GeoIP.conf: %v", err)
+	}
+
+	path := filepath.Join(dir, "config.toml")
+	content := `mode = "geoip_compat"
+
+[geoip_compat]
+config_path = "` + geoipPath + `"
+watch_config = true
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	t.Setenv("MAXMINDDB_MCP_CONFIG", path)
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if !slices.Equal(cfg.MaxMind.Editions, []string{"GeoLite2-City", "GeoLite2-ASN"}) {
+		t.Errorf("Expected editions from GeoIP.conf, got %v", cfg.MaxMind.Editions)
+	}
+	if cfg.MaxMind.DatabaseDir != "/custom/path" {
+		t.Errorf("Expected DatabaseDir '/custom/path', got '%s'", cfg.MaxMind.DatabaseDir)
+	}
+	if !cfg.GeoIPCompat.WatchConfig {
+		t.Error("Expected watch_config to be set")
+	}
+	if cfg.SourcePath != path {
+		t.Errorf("Expected source path %s, got %s", path, cfg.SourcePath)
+	}
+}
+
+func TestLoadFilesWithGeoIPConfigPath(t *testing.T) {
+	dir := t.TempDir()
+	geoipPath := filepath.Join(dir, "GeoIP.conf")
+	geoipContent := `AccountID 123456
+LicenseKey test_key
+EditionIDs GeoLite2-City GeoLite2-ASN
+DatabaseDirectory /custom/path
+`
+	if err := os.WriteFile(geoipPath, []byte(geoipContent), 0o600); err != nil {
+		t.Fatalf("Failed to write GeoIP.conf: %v", err)
+	}
+
+	base := filepath.Join(dir, "base.toml")
+	baseContent := `mode = "geoip_compat"
+update_interval = "24h"
+`
+	override := filepath.Join(dir, "override.toml")
+	overrideContent := `update_interval = "6h"
+
+[geoip_compat]
+config_path = "` + geoipPath + `"
+`
+	for path, content := range map[string]string{base: baseContent, override: overrideContent} {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+	}
+
+	cfg, err := LoadFiles([]string{base, override})
+	if err != nil {
+		t.Fatalf("Failed to load merged config: %v", err)
+	}
+	if !slices.Equal(cfg.MaxMind.Editions, []string{"GeoLite2-City", "GeoLite2-ASN"}) {
+		t.Errorf("Expected editions from GeoIP.conf, got %v", cfg.MaxMind.Editions)
+	}
+	if cfg.MaxMind.AccountID != 123456 || cfg.MaxMind.LicenseKey != "test_key" {
+		t.Errorf("Expected credentials from GeoIP.conf, got %d/%s", cfg.MaxMind.AccountID, cfg.MaxMind.LicenseKey)
+	}
+	if cfg.MaxMind.DatabaseDir != "/custom/path" {
+		t.Errorf("Expected DatabaseDir '/custom/path', got '%s'", cfg.MaxMind.DatabaseDir)
+	}
+	if cfg.UpdateIntervalDuration != 6*time.Hour {
+		t.Errorf("Expected update interval 6h, got %v", cfg.UpdateIntervalDuration)
+	}
+}
+
+func TestLoadTOMLConfigWithGeoIPConfigPathOutsideAllowedPaths(t *testing.T) {
+	dir := t.TempDir()
+	allowed := filepath.Join(dir, "allowed")
+	if err := os.Mkdir(allowed, 0o750); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	geoipPath := filepath.Join(dir, "GeoIP.conf")
+	geoipContent := `AccountID 123456
+LicenseKey test_key
+EditionIDs GeoLite2-City
+DatabaseDirectory ` + allowed + `
+`
+	if err := os.WriteFile(geoipPath, []byte(geoipContent), 0o600); err != nil {
+		t.Fatalf("Failed to write GeoIP.conf: %v", err)
+	}
+
+	path := filepath.Join(dir, "config.toml")
+	content := `mode = "geoip_compat"
+allowed_paths = ["` + allowed + `"]
+
+[geoip_compat]
+config_path = "` + geoipPath + `"
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	t.Setenv("MAXMINDDB_MCP_CONFIG", path)
+	_, err := Load()
+	if err == nil || !strings.Contains(err.Error(), geoipPath+" is outside allowed_paths") {
+		t.Errorf("Expected allowed_paths error for %s, got %v", geoipPath, err)
+	}
+}
+
 func TestConvertGeoIPToTOML(t *testing.T) {
 	content := `AccountID 987654
 LicenseKey convert_test_key
@@ -6,6 +6,8 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 
 	"github.com/pelletier/go-toml/v2"
 )
@@ -35,8 +37,18 @@ func Paths() []string {
 	return paths
 }
 
-// Load loads configuration from the first available config file.
+// Load loads configuration from the first available config file. If
+// MAXMINDDB_MCP_CONFIG lists several files, they are merged instead; see
+// LoadFiles. If it is "-", the configuration is read from stdin; see
+// LoadReader.
 func Load() (*Config, error) {
+	if os.Getenv("MAXMINDDB_MCP_CONFIG") == StdinSource {
+		return loadStdinConfig()
+	}
+	if paths := filepath.SplitList(os.Getenv("MAXMINDDB_MCP_CONFIG")); len(paths) > 1 {
+		return LoadFiles(paths)
+	}
+
 	config := DefaultConfig()
 
 	configPaths := Paths()
@@ -71,9 +83,8 @@ func Load() (*Config, error) {
 	// If no config file found, use defaults.
 	// In maxmind mode without credentials, this will fail validation later.
 
-	// Expand ~ in paths
-	if err := config.ExpandPaths(); err != nil {
-		return nil, fmt.Errorf("failed to expand paths: %w", err)
+	if err := finishLoad(config, configPath); err != nil {
+		return nil, err
 	}
 
 	// Validate configuration
@@ -84,9 +95,85 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("invalid default configuration: %w", err)
 	}
 
+	config.SourcePath = configPath
+	return config, nil
+}
+
+// LoadFiles loads and merges the TOML config files at paths in order, e.g. a
+// base config followed by environment-specific overrides. Settings in later
+// files override those in earlier ones, except that the directory paths and
+// MaxMind editions of all files are concatenated, skipping duplicates.
+// Every file must exist, and only the merged result is validated.
+func LoadFiles(paths []string) (*Config, error) {
+	config := DefaultConfig()
+
+	for _, path := range paths {
+		if filepath.Ext(path) != ".toml" {
+			return nil, fmt.Errorf("cannot merge %s: only TOML config files can be merged", path)
+		}
+		if err := mergeTOMLConfig(path, config); err != nil {
+			return nil, fmt.Errorf("failed to load TOML config from %s: %w", path, err)
+		}
+	}
+
+	sourcePath := strings.Join(paths, string(os.PathListSeparator))
+	if err := finishLoad(config, sourcePath); err != nil {
+		return nil, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration in %s: %w", sourcePath, err)
+	}
+
+	config.SourcePath = sourcePath
 	return config, nil
 }
 
+// finishLoad completes a config loaded by Load, LoadFiles, or LoadReader
+// from source, before it is validated. It expands ~ in paths and, in
+// geoip_compat mode, takes the MaxMind settings from the GeoIP.conf at
+// config_path unless source is that file.
+func finishLoad(config *Config, source string) error {
+	// Expand ~ in paths
+	if err := config.ExpandPaths(); err != nil {
+		return fmt.Errorf("failed to expand paths: %w", err)
+	}
+
+	geoipPath := config.GeoIPCompat.ConfigPath
+	if config.Mode != ModeGeoIPCompat || geoipPath == "" || geoipPath == source {
+		return nil
+	}
+	if err := loadGeoIPConfig(geoipPath, config); err != nil {
+		return fmt.Errorf("failed to load GeoIP.conf from %s: %w", geoipPath, err)
+	}
+	return nil
+}
+
+// mergeTOMLConfig loads a TOML file into config, concatenating its list
+// settings with those already in config.
+func mergeTOMLConfig(path string, config *Config) error {
+	editions, dirPaths := config.MaxMind.Editions, config.Directory.Paths
+	config.MaxMind.Editions, config.Directory.Paths = nil, nil
+
+	if err := loadTOMLConfig(path, config); err != nil {
+		return err
+	}
+
+	config.MaxMind.Editions = appendUnique(editions, config.MaxMind.Editions)
+	config.Directory.Paths = appendUnique(dirPaths, config.Directory.Paths)
+	return nil
+}
+
+// appendUnique appends the values that are not already in list.
+func appendUnique(list, values []string) []string {
+	for _, value := range values {
+		if !slices.Contains(list, value) {
+			list = append(list, value)
+		}
+	}
+	return list
+}
+
 // loadTOMLConfig loads configuration from a TOML file.
 func loadTOMLConfig(path string, config *Config) error {
 	data, err := os.ReadFile(path)
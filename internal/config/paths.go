@@ -0,0 +1,122 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// CheckPathAllowed returns an error if path is outside every allowed_paths
+// entry. See CheckAllowedPath.
+func (c *Config) CheckPathAllowed(path string) error {
+	return CheckAllowedPath(c.AllowedPaths, path)
+}
+
+// CheckAllowedPath returns an error if path is outside every directory in
+// allowedPaths. Paths are compared after making them absolute and resolving
+// symbolic links, so neither ".." elements nor links lead outside an allowed
+// directory. Paths that do not exist yet, such as a file about to be
+// created, are resolved through their nearest existing parent. Every path is
+// allowed when allowedPaths is empty.
+func CheckAllowedPath(allowedPaths []string, path string) error {
+	if len(allowedPaths) == 0 {
+		return nil
+	}
+
+	canonical, err := canonicalPath(path)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", path, err)
+	}
+	for _, allowed := range allowedPaths {
+		root, err := canonicalPath(allowed)
+		if err != nil {
+			return fmt.Errorf("failed to resolve allowed_paths entry %s: %w", allowed, err)
+		}
+		if withinPath(root, canonical) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s is outside allowed_paths", path)
+}
+
+// checkConfiguredPaths checks the directories and files the server reads
+// and writes in the configured mode against allowed_paths.
+func (c *Config) checkConfiguredPaths() error {
+	type namedPath struct {
+		name string
+		path string
+	}
+	var paths []namedPath
+
+	switch c.Mode {
+	case ModeMaxMind, ModeGeoIPCompat:
+		paths = append(paths,
+			namedPath{"database_dir", c.MaxMind.DatabaseDir},
+			namedPath{"state_dir", c.MaxMind.StateDir},
+			namedPath{"staging_dir", c.MaxMind.StagingDir},
+		)
+		if c.Mode == ModeGeoIPCompat {
+			paths = append(paths, namedPath{"geoip_compat config_path", c.GeoIPCompat.ConfigPath})
+		}
+	case ModeDirectory:
+		for _, path := range c.Directory.Paths {
+			paths = append(paths, namedPath{"directory path", path})
+		}
+	case ModeManifest:
+		paths = append(paths, namedPath{"manifest path", c.Manifest.Path})
+	default:
+		// No paths for other modes
+	}
+	if c.Export.Enabled {
+		paths = append(paths, namedPath{"export dir", c.Export.Dir})
+	}
+
+	for _, p := range paths {
+		if p.path == "" {
+			continue
+		}
+		if err := c.CheckPathAllowed(p.path); err != nil {
+			return fmt.Errorf("%s: %w", p.name, err)
+		}
+	}
+	return nil
+}
+
+// canonicalPath returns the absolute form of path with symbolic links
+// resolved. Missing trailing elements are kept as they are, joined to the
+// resolved form of their nearest existing parent.
+func canonicalPath(path string) (string, error) {
+	current, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	var missing []string
+	for {
+		resolved, err := filepath.EvalSymlinks(current)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, missing...)...), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		parent := filepath.Dir(current)
+		if parent == current {
+			return filepath.Join(append([]string{current}, missing...)...), nil
+		}
+		missing = append([]string{filepath.Base(current)}, missing...)
+		current = parent
+	}
+}
+
+// withinPath reports whether path is root or inside it. Both must be
+// canonical.
+func withinPath(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
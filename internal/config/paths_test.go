@@ -0,0 +1,53 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckPathAllowed(t *testing.T) {
+	allowed := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Mkdir(filepath.Join(allowed, "exports"), 0o750); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	// A link inside the allowed directory that leads outside it.
+	escape := filepath.Join(allowed, "escape")
+	if err := os.Symlink(outside, escape); err != nil {
+		t.Fatalf("Failed to create symlink: %v", err)
+	}
+
+	cfg := &Config{AllowedPaths: []string{allowed}}
+
+	tests := []struct {
+		name    string
+		path    string
+		allowed bool
+	}{
+		{name: "allowed directory", path: allowed, allowed: true},
+		{name: "existing subdirectory", path: filepath.Join(allowed, "exports"), allowed: true},
+		{name: "file to be created", path: filepath.Join(allowed, "exports", "new", "out.ndjson"), allowed: true},
+		{name: "other directory", path: outside, allowed: false},
+		{name: "dot-dot escape", path: filepath.Join(allowed, "exports", "..", "..", "etc"), allowed: false},
+		{name: "symlink escape", path: filepath.Join(escape, "out.ndjson"), allowed: false},
+		{name: "name prefix of allowed directory", path: allowed + "-other", allowed: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := cfg.CheckPathAllowed(test.path)
+			if test.allowed && err != nil {
+				t.Errorf("Expected %s to be allowed, got %v", test.path, err)
+			}
+			if !test.allowed && err == nil {
+				t.Errorf("Expected %s to be rejected", test.path)
+			}
+		})
+	}
+
+	// Without allowed_paths, every path is allowed.
+	if err := (&Config{}).CheckPathAllowed(outside); err != nil {
+		t.Errorf("Expected every path to be allowed without allowed_paths, got %v", err)
+	}
+}
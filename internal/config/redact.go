@@ -0,0 +1,48 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// redactedValue replaces secrets in redacted configurations.
+const redactedValue = "[REDACTED]"
+
+// Redacted returns the configuration as a map keyed by the TOML setting
+// names, with the license key replaced and all but the last two digits of
+// the account ID masked. Settings that are not set are included with their
+// zero values.
+func (c *Config) Redacted() (map[string]any, error) {
+	data, err := toml.Marshal(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode config: %w", err)
+	}
+
+	var redacted map[string]any
+	if err := toml.Unmarshal(data, &redacted); err != nil {
+		return nil, fmt.Errorf("failed to decode config: %w", err)
+	}
+
+	if maxmind, ok := redacted["maxmind"].(map[string]any); ok {
+		if c.MaxMind.LicenseKey != "" {
+			maxmind["license_key"] = redactedValue
+		}
+		if c.MaxMind.AccountID != 0 {
+			maxmind["account_id"] = maskAccountID(c.MaxMind.AccountID)
+		}
+	}
+
+	return redacted, nil
+}
+
+// maskAccountID masks all but the last two digits of an account ID.
+func maskAccountID(accountID int) string {
+	id := strconv.Itoa(accountID)
+	if len(id) <= 2 {
+		return strings.Repeat("*", len(id))
+	}
+	return strings.Repeat("*", len(id)-2) + id[len(id)-2:]
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// StdinSource is the config path that reads the configuration from stdin.
+const StdinSource = "-"
+
+// Config formats accepted by LoadReader. FormatAuto tries TOML, then JSON.
+const (
+	FormatAuto = "auto"
+	FormatTOML = "toml"
+	FormatJSON = "json"
+)
+
+// documentEnd is a line that ends a configuration read from stdin. As the
+// MCP session also runs over stdin, a client that pipes in the configuration
+// ends it with this line and then continues with the session.
+const documentEnd = "---"
+
+// maxDocumentSize is the maximum size of a configuration read from stdin.
+const maxDocumentSize = 1 << 20
+
+// LoadReader loads the configuration from r, which is read up to a line
+// containing only "---" or the end of input. format is FormatTOML,
+// FormatJSON, or FormatAuto; an empty format is FormatAuto. The JSON keys
+// are the same as the TOML ones.
+func LoadReader(r io.Reader, format string) (*Config, error) {
+	data, err := readDocument(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config from stdin: %w", err)
+	}
+
+	config, err := decodeConfig(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config from stdin: %w", err)
+	}
+
+	if err := finishLoad(config, StdinSource); err != nil {
+		return nil, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration from stdin: %w", err)
+	}
+
+	config.SourcePath = StdinSource
+	return config, nil
+}
+
+// loadStdinConfig loads the configuration from stdin in the format named by
+// MAXMINDDB_MCP_CONFIG_FORMAT.
+func loadStdinConfig() (*Config, error) {
+	return LoadReader(os.Stdin, os.Getenv("MAXMINDDB_MCP_CONFIG_FORMAT"))
+}
+
+// readDocument reads r up to and excluding a documentEnd line, or to the end
+// of input. It reads a byte at a time so that nothing after the documentEnd
+// line is consumed.
+func readDocument(r io.Reader) ([]byte, error) {
+	var data []byte
+	lineStart := 0
+	buf := make([]byte, 1)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			data = append(data, buf[0])
+			if buf[0] == '\n' {
+				if isDocumentEnd(data[lineStart:]) {
+					return data[:lineStart], nil
+				}
+				lineStart = len(data)
+			}
+			if len(data) > maxDocumentSize {
+				return nil, fmt.Errorf("config exceeds %d bytes", maxDocumentSize)
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			if isDocumentEnd(data[lineStart:]) {
+				return data[:lineStart], nil
+			}
+			return data, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// isDocumentEnd reports whether line is a documentEnd line.
+func isDocumentEnd(line []byte) bool {
+	return string(bytes.TrimRight(line, "\r\n")) == documentEnd
+}
+
+// decodeConfig decodes data in format over the default configuration.
+func decodeConfig(data []byte, format string) (*Config, error) {
+	switch format {
+	case FormatTOML:
+		config := DefaultConfig()
+		return config, toml.Unmarshal(data, config)
+	case FormatJSON:
+		config := DefaultConfig()
+		return config, decodeJSONConfig(data, config)
+	case "", FormatAuto:
+		config := DefaultConfig()
+		tomlErr := toml.Unmarshal(data, config)
+		if tomlErr == nil {
+			return config, nil
+		}
+		config = DefaultConfig()
+		jsonErr := decodeJSONConfig(data, config)
+		if jsonErr == nil {
+			return config, nil
+		}
+		return nil, fmt.Errorf("config is neither TOML (%w) nor JSON (%w)", tomlErr, jsonErr)
+	default:
+		return nil, fmt.Errorf(
+			"invalid config format: %s (must be %s, %s, or %s)",
+			format,
+			FormatTOML,
+			FormatJSON,
+			FormatAuto,
+		)
+	}
+}
+
+// decodeJSONConfig decodes a JSON configuration into config. The JSON is
+// converted to TOML first, so that it uses the TOML keys.
+func decodeJSONConfig(data []byte, config *Config) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var values map[string]any
+	if err := decoder.Decode(&values); err != nil {
+		return err
+	}
+
+	tomlData, err := toml.Marshal(jsonNumbers(values))
+	if err != nil {
+		return err
+	}
+	return toml.Unmarshal(tomlData, config)
+}
+
+// jsonNumbers replaces the json.Number values in value with int64 values,
+// or float64 values for numbers that are not integers.
+func jsonNumbers(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			v[key] = jsonNumbers(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = jsonNumbers(item)
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+	}
+	return value
+}
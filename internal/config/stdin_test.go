@@ -0,0 +1,143 @@
+package config
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigFromStdin(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name   string
+		format string
+		input  string
+	}{
+		{
+			name:   "toml",
+			format: "",
+			input:  "mode = \"directory\"\nmax_databases = 3\n[directory]\npaths = [\"" + dir + "\"]\n",
+		},
+		{
+			name:   "json",
+			format: "",
+			input:  `{"mode": "directory", "max_databases": 3, "directory": {"paths": ["` + dir + `"]}}` + "\n",
+		},
+		{
+			name:   "explicit json",
+			format: FormatJSON,
+			input:  `{"mode": "directory", "max_databases": 3, "directory": {"paths": ["` + dir + `"]}}` + "\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stdin, stdinWriter, err := os.Pipe()
+			if err != nil {
+				t.Fatalf("Failed to create pipe: %v", err)
+			}
+			defer func() { _ = stdin.Close() }()
+
+			originalStdin := os.Stdin
+			os.Stdin = stdin
+			t.Cleanup(func() { os.Stdin = originalStdin })
+			t.Setenv("MAXMINDDB_MCP_CONFIG", StdinSource)
+			t.Setenv("MAXMINDDB_MCP_CONFIG_FORMAT", test.format)
+
+			// The MCP session follows the configuration on stdin.
+			session := `{"jsonrpc":"2.0","id":1,"method":"ping"}` + "\n"
+			go func() {
+				_, _ = io.WriteString(stdinWriter, test.input+"---\n"+session)
+				_ = stdinWriter.Close()
+			}()
+
+			cfg, err := Load()
+			if err != nil {
+				t.Fatalf("Failed to load config from stdin: %v", err)
+			}
+			if cfg.Mode != ModeDirectory || cfg.MaxDatabases != 3 {
+				t.Errorf("Expected directory mode with max_databases 3, got %s and %d", cfg.Mode, cfg.MaxDatabases)
+			}
+			if len(cfg.Directory.Paths) != 1 || cfg.Directory.Paths[0] != dir {
+				t.Errorf("Expected paths [%s], got %v", dir, cfg.Directory.Paths)
+			}
+			if cfg.SourcePath != StdinSource {
+				t.Errorf("Expected source path %q, got %q", StdinSource, cfg.SourcePath)
+			}
+
+			rest, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				t.Fatalf("Failed to read rest of stdin: %v", err)
+			}
+			if string(rest) != session {
+				t.Errorf("Expected the session to be left on stdin, got %q", rest)
+			}
+		})
+	}
+}
+
+func TestLoadReaderErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		input    string
+		errorMsg string
+	}{
+		{
+			name:     "neither toml nor json",
+			input:    "mode = ",
+			errorMsg: "config is neither TOML",
+		},
+		{
+			name:     "json as toml",
+			format:   FormatTOML,
+			input:    `{"mode": "directory"}`,
+			errorMsg: "failed to load config from stdin",
+		},
+		{
+			name:     "invalid format",
+			format:   "yaml",
+			input:    `mode = "directory"`,
+			errorMsg: "invalid config format: yaml",
+		},
+		{
+			name:     "invalid config",
+			input:    `{"mode": "bogus"}`,
+			errorMsg: "invalid configuration from stdin: invalid mode: bogus",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := LoadReader(strings.NewReader(test.input), test.format)
+			if err == nil || !strings.Contains(err.Error(), test.errorMsg) {
+				t.Errorf("Expected error containing %q, got %v", test.errorMsg, err)
+			}
+		})
+	}
+}
+
+func TestReadDocument(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{input: "a = 1\n", expected: "a = 1\n"},
+		{input: "a = 1\n---\nrest", expected: "a = 1\n"},
+		{input: "a = 1\r\n---\r\nrest", expected: "a = 1\r\n"},
+		{input: "a = 1\n---", expected: "a = 1\n"},
+		{input: "a = \"---\"\n", expected: "a = \"---\"\n"},
+	}
+
+	for _, test := range tests {
+		data, err := readDocument(strings.NewReader(test.input))
+		if err != nil {
+			t.Fatalf("Failed to read %q: %v", test.input, err)
+		}
+		if string(data) != test.expected {
+			t.Errorf("Expected %q from %q, got %q", test.expected, test.input, data)
+		}
+	}
+}
@@ -0,0 +1,7 @@
+package database
+
+import "errors"
+
+// errFreeSpaceUnsupported is returned by freeDiskSpace on platforms where the
+// free space of a filesystem cannot be determined.
+var errFreeSpaceUnsupported = errors.New("free disk space is not available on this platform")
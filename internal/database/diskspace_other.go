@@ -0,0 +1,8 @@
+//go:build !(linux || darwin || freebsd)
+
+package database
+
+// freeDiskSpace always fails with errFreeSpaceUnsupported on this platform.
+func freeDiskSpace(string) (uint64, error) {
+	return 0, errFreeSpaceUnsupported
+}
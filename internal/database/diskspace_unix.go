@@ -0,0 +1,16 @@
+//go:build linux || darwin || freebsd
+
+package database
+
+import "syscall"
+
+// freeDiskSpace returns the number of bytes available to unprivileged users
+// on the filesystem containing dir.
+func freeDiskSpace(dir string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(dir, &stat); err != nil {
+		return 0, err
+	}
+	// The field types vary by platform.
+	return uint64(stat.Bavail) * uint64(stat.Bsize), nil //nolint:unconvert,gosec // see above
+}
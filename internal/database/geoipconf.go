@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/oschwald/maxminddb-mcp/internal/config"
+)
+
+// ReloadGeoIPConfig re-reads the GeoIP.conf file at path and applies its
+// editions and database directory to the updater. New editions are
+// downloaded by the next update. If the database directory changed, it is
+// created, loaded, and watched; databases already loaded from the previous
+// directory stay loaded. A file without DatabaseDirectory keeps the current
+// directory. The new settings are validated like the startup configuration,
+// and a file outside allowed_paths, or whose DatabaseDirectory is outside
+// them, is rejected. The shared configuration is not modified.
+func (u *Updater) ReloadGeoIPConfig(path string) error {
+	if err := u.config.CheckPathAllowed(path); err != nil {
+		return err
+	}
+
+	geoipConfig, err := config.ParseGeoIPConfig(path)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if len(geoipConfig.EditionIDs) == 0 {
+		return fmt.Errorf("%s lists no editions; set EditionIDs", path)
+	}
+
+	dir := geoipConfig.DatabaseDirectory
+	if dir != "" {
+		if err := u.config.CheckPathAllowed(dir); err != nil {
+			return fmt.Errorf("invalid DatabaseDirectory in %s: %w", path, err)
+		}
+	}
+
+	u.mu.Lock()
+	next := *u.config
+	next.MaxMind.Editions = slices.Clone(geoipConfig.EditionIDs)
+	next.MaxMind.DatabaseDir = u.databaseDir
+	if dir != "" {
+		next.MaxMind.DatabaseDir = dir
+		next.GeoIPCompat.DatabaseDir = dir
+	}
+	if err := next.Validate(); err != nil {
+		u.mu.Unlock()
+		return fmt.Errorf("invalid settings in %s: %w", path, err)
+	}
+
+	u.editionIDs = next.MaxMind.Editions
+	dirChanged := next.MaxMind.DatabaseDir != u.databaseDir
+	if dirChanged {
+		u.databaseDir = next.MaxMind.DatabaseDir
+		// Without a state directory the checksums live in the database
+		// directory.
+		u.checksums = make(map[string]string)
+		u.loadChecksums()
+	}
+	u.mu.Unlock()
+
+	if !dirChanged {
+		return nil
+	}
+
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return fmt.Errorf("failed to create database directory: %w", err)
+	}
+	if err := u.manager.LoadDirectory(dir); err != nil {
+		return fmt.Errorf("failed to load databases: %w", err)
+	}
+	return u.manager.WatchDirectory(dir)
+}
+
+// WatchGeoIPConfig starts a goroutine that checks the GeoIP.conf file at
+// path every interval and calls ReloadGeoIPConfig when its modification time
+// or size changes. Invalid files are logged and the previous settings are
+// kept. Watching stops when ctx is canceled.
+func (u *Updater) WatchGeoIPConfig(ctx context.Context, path string, interval time.Duration) {
+	previous, _ := statFile(path)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			current, ok := statFile(path)
+			if !ok || current == previous {
+				continue
+			}
+			previous = current
+
+			if err := u.ReloadGeoIPConfig(path); err != nil {
+				slog.Warn("Failed to reload GeoIP.conf", "path", path, "err", err)
+				continue
+			}
+			slog.Info("Reloaded GeoIP.conf", "path", path)
+		}
+	}()
+}
+
+// statFile returns the modification time and size of the file at path.
+func statFile(path string) (fileState, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{}, false
+	}
+	return fileState{modTime: info.ModTime(), size: info.Size()}, true
+}
@@ -0,0 +1,162 @@
+package database
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/oschwald/maxminddb-mcp/internal/config"
+)
+
+// newGeoIPCompatUpdater writes a GeoIP.conf with the given content and
+// returns its path and an updater in geoip_compat mode.
+func newGeoIPCompatUpdater(t *testing.T, content string) (string, *Updater) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "GeoIP.conf")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("Failed to write GeoIP.conf: %v", err)
+	}
+
+	// Reloads validate the settings, so start from a complete config.
+	cfg := config.DefaultConfig()
+	cfg.Mode = "geoip_compat"
+	cfg.MaxMind.AccountID = 999999
+	cfg.MaxMind.LicenseKey = "test_license_key"
+	cfg.MaxMind.Editions = []string{"GeoLite2-City"}
+	cfg.MaxMind.DatabaseDir = t.TempDir()
+	cfg.GeoIPCompat.ConfigPath = path
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Invalid test config: %v", err)
+	}
+
+	manager, err := New()
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	t.Cleanup(func() { _ = manager.Close() })
+
+	updater, err := NewUpdater(cfg, manager)
+	if err != nil {
+		t.Fatalf("Failed to create updater: %v", err)
+	}
+	return path, updater
+}
+
+// editions returns the editions the updater currently downloads.
+func (u *Updater) editions() []string {
+	u.mu.RLock()
+	defer u.mu.RUnlock()
+	return u.editionIDs
+}
+
+func TestReloadGeoIPConfig(t *testing.T) {
+	path, updater := newGeoIPCompatUpdater(t, "AccountID 1\nLicenseKey key\nEditionIDs GeoLite2-City\n")
+
+	// The new directory holds a database, which is loaded on reload.
+	newDir := filepath.Join(t.TempDir(), "databases")
+	if err := os.MkdirAll(newDir, 0o750); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile("../../testdata/test-data/GeoLite2-City-Test.mmdb")
+	if err != nil {
+		t.Fatalf("Failed to read test database: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(newDir, "GeoLite2-City-Test.mmdb"), data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	content := "AccountID 1\nLicenseKey key\nEditionIDs GeoLite2-City GeoLite2-ASN\nDatabaseDirectory " + newDir + "\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := updater.ReloadGeoIPConfig(path); err != nil {
+		t.Fatalf("Failed to reload GeoIP.conf: %v", err)
+	}
+
+	if editions := updater.editions(); !slices.Equal(editions, []string{"GeoLite2-City", "GeoLite2-ASN"}) {
+		t.Errorf("Expected the new editions, got %v", editions)
+	}
+	if updater.databaseDir != newDir {
+		t.Errorf("Expected database dir %s, got %s", newDir, updater.databaseDir)
+	}
+	// The configuration shared with the server is left alone.
+	if !slices.Equal(updater.config.MaxMind.Editions, []string{"GeoLite2-City"}) ||
+		updater.config.MaxMind.DatabaseDir == newDir || updater.config.GeoIPCompat.DatabaseDir == newDir {
+		t.Errorf("Expected the shared config to be unchanged, got %+v", updater.config.MaxMind)
+	}
+	if _, ok := updater.manager.GetDatabase("GeoLite2-City-Test.mmdb"); !ok {
+		t.Error("Expected the database in the new directory to be loaded")
+	}
+}
+
+func TestReloadGeoIPConfigKeepsSettingsOnError(t *testing.T) {
+	path, updater := newGeoIPCompatUpdater(t, "AccountID 1\nLicenseKey key\n")
+
+	err := updater.ReloadGeoIPConfig(path)
+	if err == nil || !strings.Contains(err.Error(), "lists no editions") {
+		t.Fatalf("Expected missing editions error, got %v", err)
+	}
+	if editions := updater.editions(); !slices.Equal(editions, []string{"GeoLite2-City"}) {
+		t.Errorf("Expected the previous editions to be kept, got %v", editions)
+	}
+
+	if err := updater.ReloadGeoIPConfig(filepath.Join(t.TempDir(), "missing.conf")); err == nil {
+		t.Error("Expected error for a missing GeoIP.conf")
+	}
+}
+
+func TestReloadGeoIPConfigRejectsDisallowedDirectory(t *testing.T) {
+	path, updater := newGeoIPCompatUpdater(t, "AccountID 1\nLicenseKey key\nEditionIDs GeoLite2-City\n")
+	previousDir := updater.databaseDir
+	updater.config.AllowedPaths = []string{previousDir}
+
+	outsideDir := t.TempDir()
+	content := "AccountID 1\nLicenseKey key\nEditionIDs GeoLite2-ASN\nDatabaseDirectory " + outsideDir + "\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	err := updater.ReloadGeoIPConfig(path)
+	if err == nil || !strings.Contains(err.Error(), "outside allowed_paths") {
+		t.Fatalf("Expected allowed_paths error, got %v", err)
+	}
+	if updater.databaseDir != previousDir {
+		t.Errorf("Expected database dir %s to be kept, got %s", previousDir, updater.databaseDir)
+	}
+	if editions := updater.editions(); !slices.Equal(editions, []string{"GeoLite2-City"}) {
+		t.Errorf("Expected the previous editions to be kept, got %v", editions)
+	}
+
+	// A GeoIP.conf outside allowed_paths is not read at all.
+	updater.config.AllowedPaths = []string{outsideDir}
+	err = updater.ReloadGeoIPConfig(path)
+	if err == nil || !strings.Contains(err.Error(), path+" is outside allowed_paths") {
+		t.Errorf("Expected allowed_paths error for %s, got %v", path, err)
+	}
+}
+
+func TestWatchGeoIPConfig(t *testing.T) {
+	path, updater := newGeoIPCompatUpdater(t, "AccountID 1\nLicenseKey key\nEditionIDs GeoLite2-City\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updater.WatchGeoIPConfig(ctx, path, 10*time.Millisecond)
+
+	content := "AccountID 1\nLicenseKey key\nEditionIDs GeoLite2-City GeoLite2-Country GeoLite2-ASN\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{"GeoLite2-City", "GeoLite2-Country", "GeoLite2-ASN"}
+	deadline := time.Now().Add(5 * time.Second)
+	for !slices.Equal(updater.editions(), want) {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected editions %v to be picked up, got %v", want, updater.editions())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,35 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RetainedVersions returns the paths of the older versions of the database
+// at path that are kept next to it: backups such as GeoLite2-City.mmdb.bak
+// and dated copies such as GeoLite2-City_20240102.mmdb. They are the files
+// the janitor treats as stale. Paths are returned in name order.
+func RetainedVersions(path string) ([]string, error) {
+	dir, name := filepath.Split(path)
+	edition := strings.TrimSuffix(name, ".mmdb")
+
+	entries, err := os.ReadDir(filepath.Clean(dir))
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []string
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || entry.Name() == name {
+			continue
+		}
+		entryName := entry.Name()
+		if strings.HasPrefix(entryName, name) && strings.HasSuffix(entryName, ".bak") {
+			versions = append(versions, filepath.Join(dir, entryName))
+		} else if match := datedDatabasePattern.FindStringSubmatch(entryName); match != nil && match[1] == edition {
+			versions = append(versions, filepath.Join(dir, entryName))
+		}
+	}
+	return versions, nil
+}
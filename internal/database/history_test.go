@@ -0,0 +1,39 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestRetainedVersions(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"GeoLite2-City.mmdb",
+		"GeoLite2-City.mmdb.bak",
+		"GeoLite2-City.mmdb.1.bak",
+		"GeoLite2-City_20240102.mmdb",
+		"GeoLite2-City.mmdb.tmp",
+		"GeoLite2-City-Extra.mmdb.bak",
+		"GeoLite2-ASN.mmdb.bak",
+		"GeoLite2-ASN_20240102.mmdb",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	versions, err := RetainedVersions(filepath.Join(dir, "GeoLite2-City.mmdb"))
+	if err != nil {
+		t.Fatalf("Failed to list retained versions: %v", err)
+	}
+	expected := []string{
+		filepath.Join(dir, "GeoLite2-City.mmdb.1.bak"),
+		filepath.Join(dir, "GeoLite2-City.mmdb.bak"),
+		filepath.Join(dir, "GeoLite2-City_20240102.mmdb"),
+	}
+	if !slices.Equal(versions, expected) {
+		t.Errorf("Expected %v, got %v", expected, versions)
+	}
+}
@@ -0,0 +1,102 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// newHTTPClient returns the HTTP client used to download database updates.
+// connectTimeout bounds establishing the connection, including the TLS
+// handshake. readTimeout bounds waiting for the response headers and every
+// gap between reads of the body, so a stalled download fails while a slow
+// but progressing one completes. Zero disables either timeout.
+func newHTTPClient(connectTimeout, readTimeout time.Duration) *http.Client {
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	if defaultTransport, ok := http.DefaultTransport.(*http.Transport); ok {
+		transport = defaultTransport.Clone()
+	}
+	if connectTimeout > 0 {
+		dialer := &net.Dialer{Timeout: connectTimeout, KeepAlive: 30 * time.Second}
+		transport.DialContext = dialer.DialContext
+		transport.TLSHandshakeTimeout = connectTimeout
+	}
+
+	if readTimeout <= 0 {
+		return &http.Client{Transport: transport}
+	}
+	return &http.Client{
+		Transport: &stallTimeoutTransport{base: transport, timeout: readTimeout},
+	}
+}
+
+// stallTimeoutTransport cancels requests that receive no data for longer
+// than timeout.
+type stallTimeoutTransport struct {
+	base    http.RoundTripper
+	timeout time.Duration
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *stallTimeoutTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(req.Context())
+	body := &stallTimeoutBody{timeout: t.timeout, cancel: cancel}
+	body.timer = time.AfterFunc(t.timeout, func() {
+		body.stalled.Store(true)
+		cancel()
+	})
+
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		body.timer.Stop()
+		cancel()
+		return nil, body.wrapError(err)
+	}
+
+	body.body = resp.Body
+	body.timer.Reset(t.timeout)
+	resp.Body = body
+	return resp, nil
+}
+
+// stallTimeoutBody is a response body that restarts the stall timer after
+// every successful read.
+type stallTimeoutBody struct {
+	body    io.ReadCloser
+	timer   *time.Timer
+	cancel  context.CancelFunc
+	timeout time.Duration
+	stalled atomic.Bool
+}
+
+// Read implements io.Reader.
+func (b *stallTimeoutBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+	if n > 0 && !b.stalled.Load() {
+		b.timer.Reset(b.timeout)
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		err = b.wrapError(err)
+	}
+	return n, err
+}
+
+// Close implements io.Closer.
+func (b *stallTimeoutBody) Close() error {
+	b.timer.Stop()
+	b.cancel()
+	return b.body.Close()
+}
+
+// wrapError explains errors caused by the stall timeout.
+func (b *stallTimeoutBody) wrapError(err error) error {
+	if b.stalled.Load() {
+		return fmt.Errorf("no data received for %s: %w", b.timeout, err)
+	}
+	return err
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// datedDatabasePattern matches dated copies of an edition, such as
+// GeoLite2-City_20240102.mmdb, capturing the edition and the date.
+var datedDatabasePattern = regexp.MustCompile(`^(.+)_(\d{8})\.mmdb$`)
+
+// StartJanitor starts a goroutine that removes stale files from the database
+// directory every janitor_interval. It does nothing unless janitor_interval
+// is set.
+func (u *Updater) StartJanitor(ctx context.Context) {
+	interval := u.config.MaxMind.JanitorIntervalDuration
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticks, stop := u.newTicker(interval)
+		defer stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticks:
+				u.RemoveStaleFiles()
+			}
+		}
+	}()
+}
+
+// RemoveStaleFiles removes stale files from the database directory and
+// returns their paths. Stale files are .tmp and .bak files and dated .mmdb
+// copies of an edition, such as GeoLite2-City_20240102.mmdb, that are not
+// loaded. Files younger than janitor_retention are kept, as are the newest
+// janitor_keep_dated dated copies of each edition. Current databases,
+// protected_databases entries, and subdirectories are never removed.
+func (u *Updater) RemoveStaleFiles() []string {
+	// Hold the update lock so that the temporary file of an update in
+	// progress is not removed.
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	dir := u.databaseDir
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Warn("Failed to read database directory for cleanup", "dir", dir, "err", err)
+		}
+		return nil
+	}
+
+	loaded := make(map[string]bool)
+	for _, db := range u.manager.ListDatabases() {
+		loaded[db.Path] = true
+	}
+
+	cutoff := u.now().Add(-u.config.MaxMind.JanitorRetentionDuration)
+	var candidates []string
+	dated := make(map[string][]string)
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		name := entry.Name()
+		if strings.HasSuffix(name, ".tmp") || strings.HasSuffix(name, ".bak") {
+			candidates = append(candidates, name)
+		} else if match := datedDatabasePattern.FindStringSubmatch(name); match != nil {
+			dated[match[1]] = append(dated[match[1]], name)
+		}
+	}
+
+	// Dated copies are kept newest first, by the date in their name.
+	for _, names := range dated {
+		slices.SortFunc(names, func(a, b string) int {
+			return cmp.Compare(datedDatabaseDate(b), datedDatabaseDate(a))
+		})
+		candidates = append(candidates, names[min(u.config.MaxMind.JanitorKeepDated, len(names)):]...)
+	}
+	slices.Sort(candidates)
+
+	var removed []string
+	for _, name := range candidates {
+		path := filepath.Join(dir, name)
+		if absPath, err := filepath.Abs(path); err == nil && loaded[absPath] {
+			continue
+		}
+		if u.isProtected(path) {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			slog.Warn("Failed to remove stale file", "path", path, "err", err)
+			continue
+		}
+		slog.Info("Removed stale file", "path", path, "age", u.now().Sub(info.ModTime()).Round(time.Second))
+		removed = append(removed, path)
+	}
+	return removed
+}
+
+// datedDatabaseDate returns the date in the name of a dated database copy.
+func datedDatabaseDate(name string) string {
+	return datedDatabasePattern.FindStringSubmatch(name)[2]
+}
@@ -0,0 +1,164 @@
+package database
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRemoveStaleFiles(t *testing.T) {
+	cfg := createTestConfig(t)
+	cfg.MaxMind.JanitorRetentionDuration = 24 * time.Hour
+	cfg.MaxMind.JanitorKeepDated = 1
+	cfg.MaxMind.ProtectedDatabases = []string{"GeoLite2-ASN.mmdb.bak"}
+	dir := cfg.MaxMind.DatabaseDir
+
+	manager, err := New()
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer func() { _ = manager.Close() }()
+
+	updater, err := NewUpdater(cfg, manager)
+	if err != nil {
+		t.Fatalf("Failed to create updater: %v", err)
+	}
+	now := time.Date(2025, 1, 10, 0, 0, 0, 0, time.UTC)
+	updater.now = func() time.Time { return now }
+
+	old := now.Add(-48 * time.Hour)
+	recent := now.Add(-time.Hour)
+	files := map[string]time.Time{
+		"GeoLite2-City.mmdb":                 old,
+		"GeoLite2-City.mmdb.tmp":             old,
+		"GeoLite2-Country.mmdb.tmp":          recent,
+		"GeoLite2-City.mmdb.bak":             old,
+		"GeoLite2-ASN.mmdb.bak":              old,
+		"GeoLite2-City_20240101.mmdb":        old,
+		"GeoLite2-City_20240301.mmdb":        old,
+		"GeoLite2-ASN_20240101.mmdb":         recent,
+		"GeoLite2-ASN_20231201.mmdb":         recent,
+		"GeoLite2-Country_20240101.mmdb":     old,
+		"GeoLite2-Country_20240201.mmdb":     old,
+		"GeoLite2-Country_20231201.mmdb.txt": old,
+		".checksums":                         old,
+	}
+	data, err := os.ReadFile(testDBPath)
+	if err != nil {
+		t.Fatalf("Failed to read test database: %v", err)
+	}
+	for name, modTime := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("Failed to set the time of %s: %v", name, err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "old.bak"), 0o750); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	// An older dated copy that is loaded is in use, so it is kept.
+	if err := manager.LoadDatabase(filepath.Join(dir, "GeoLite2-Country_20240101.mmdb")); err != nil {
+		t.Fatalf("Failed to load database: %v", err)
+	}
+
+	removed := updater.RemoveStaleFiles()
+	expected := []string{
+		filepath.Join(dir, "GeoLite2-City.mmdb.bak"),
+		filepath.Join(dir, "GeoLite2-City.mmdb.tmp"),
+		filepath.Join(dir, "GeoLite2-City_20240101.mmdb"),
+	}
+	if !slices.Equal(removed, expected) {
+		t.Errorf("Expected %v to be removed, got %v", expected, removed)
+	}
+
+	for name := range files {
+		_, err := os.Stat(filepath.Join(dir, name))
+		if wantRemoved := slices.Contains(expected, filepath.Join(dir, name)); wantRemoved != os.IsNotExist(err) {
+			t.Errorf("Expected %s removed to be %v, got stat error %v", name, wantRemoved, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "old.bak")); err != nil {
+		t.Errorf("Expected directories to be kept, got %v", err)
+	}
+}
+
+func TestStartJanitor(t *testing.T) {
+	cfg := createTestConfig(t)
+	cfg.MaxMind.JanitorIntervalDuration = time.Hour
+	cfg.MaxMind.JanitorRetentionDuration = time.Minute
+
+	manager, err := New()
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer func() { _ = manager.Close() }()
+
+	updater, err := NewUpdater(cfg, manager)
+	if err != nil {
+		t.Fatalf("Failed to create updater: %v", err)
+	}
+	clock := &fakeClock{}
+	updater.newTicker = clock.newTicker
+
+	path := filepath.Join(cfg.MaxMind.DatabaseDir, "GeoLite2-City.mmdb.tmp")
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatalf("Failed to write temp file: %v", err)
+	}
+	modTime := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("Failed to set the time of temp file: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updater.StartJanitor(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for clock.tickerCount() < 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the janitor to start a ticker")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	clock.Advance(time.Hour)
+	for {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the janitor to remove the stale temp file")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestStartJanitorDisabled(t *testing.T) {
+	cfg := createTestConfig(t)
+
+	manager, err := New()
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer func() { _ = manager.Close() }()
+
+	updater, err := NewUpdater(cfg, manager)
+	if err != nil {
+		t.Fatalf("Failed to create updater: %v", err)
+	}
+	clock := &fakeClock{}
+	updater.newTicker = clock.newTicker
+
+	updater.StartJanitor(context.Background())
+	time.Sleep(10 * time.Millisecond)
+	if count := clock.tickerCount(); count != 0 {
+		t.Errorf("Expected no ticker without janitor_interval, got %d", count)
+	}
+}
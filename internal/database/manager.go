@@ -15,46 +15,208 @@ import (
 	"time"
 
 	"github.com/fsnotify/fsnotify"
+	"github.com/oschwald/maxminddb-mcp/internal/config"
 
 	"github.com/oschwald/maxminddb-golang/v2"
 )
 
+// TypeSelectorPrefix marks a database selector that selects by type, e.g.
+// "type:City".
+const TypeSelectorPrefix = "type:"
+
+// Errors returned when resolving database selectors.
+var (
+	ErrDatabaseNotFound  = errors.New("database not found")
+	ErrAmbiguousDatabase = errors.New("ambiguous database selection")
+)
+
+// ErrPathNotExist is returned by LoadPath for paths that do not exist.
+var ErrPathNotExist = errors.New("path does not exist")
+
+// ErrDatabaseLimit is returned when loading a new database would exceed the
+// limit set with SetMaxDatabases.
+var ErrDatabaseLimit = errors.New("database limit reached")
+
+// ErrEmptySearchTree is returned when loading a file whose metadata opens
+// but describes an empty search tree, so that no lookup could succeed.
+var ErrEmptySearchTree = errors.New("database has an empty search tree")
+
+// PathOptions controls how LoadPaths handles paths that do not exist.
+type PathOptions struct {
+	// CreateMissing creates missing directories. Missing paths ending in
+	// .mmdb are files and are never created.
+	CreateMissing bool
+	// SkipMissing logs a warning for missing paths and continues with the
+	// remaining paths instead of failing.
+	SkipMissing bool
+}
+
 // Info holds metadata about a database.
 type Info struct {
-	LastUpdated time.Time `json:"last_updated"`
-	Name        string    `json:"name"`
-	Type        string    `json:"type"`
-	Description string    `json:"description"`
-	Path        string    `json:"-"`
-	Size        int64     `json:"size"`
+	LastUpdated  time.Time `json:"last_updated"`
+	LastReload   time.Time `json:"last_reload"` // First load or last reload with changed contents
+	Name         string    `json:"name"`
+	Type         string    `json:"type"`
+	DatabaseType string    `json:"database_type"`
+	Description  string    `json:"description"`
+	Path         string    `json:"-"`
+	Size         int64     `json:"size"`
+	signature    signature
+}
+
+// signature cheaply identifies the contents of a database file.
+type signature struct {
+	buildEpoch uint
+	size       int64
+}
+
+// ChangeKind identifies how the set of loaded databases changed.
+type ChangeKind int
+
+// Kinds of database changes.
+const (
+	// DatabaseAdded reports a newly loaded database. Reloads of an already
+	// loaded file are not reported.
+	DatabaseAdded ChangeKind = iota
+	// DatabaseRemoved reports a database that is no longer available.
+	DatabaseRemoved
+)
+
+// Change describes a database being added or removed.
+type Change struct {
+	Name        string
+	Description string // Set for added databases
+	Kind        ChangeKind
 }
 
 // Manager handles MMDB database lifecycle.
 type Manager struct {
 	readers       map[string]*maxminddb.Reader
 	databases     map[string]*Info
-	displayToPath map[string]string // Fast lookup from display name to absolute path
+	displayToPath map[string]string        // Fast lookup from display name to absolute path
+	watchFiles    map[string]bool          // Files watched individually via their parent directory
+	manifest      map[string]ManifestEntry // Manifest entries by absolute path
+	descriptions  map[string]string        // Description overrides by name or type:<type> selector
 	watcher       *fsnotify.Watcher
+	newWatcher    func() (*fsnotify.Watcher, error)
+	onChange      func(Change)
+	pollState     map[string]fileState
+	stopPolling   chan struct{}
 	watchDirs     []string
+	allowedPaths  []string // Directories databases may be loaded from; empty allows all
+	health        WatcherHealth
+	maxDatabases  int
 	mu            sync.RWMutex
+	healthMu      sync.Mutex
+	closed        bool
+	watchFailed   bool
 }
 
-// New creates a new database manager.
+// New creates a new database manager. If the file watcher cannot be
+// created, the manager is still usable; WatchFailed reports the failure so
+// that callers can fall back to polling.
 func New() (*Manager, error) {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
-		return nil, fmt.Errorf("failed to create file watcher: %w", err)
+		slog.Warn("Failed to create file watcher", "err", err)
+		watcher = nil
 	}
 
 	return &Manager{
+		watchFailed:   watcher == nil,
 		readers:       make(map[string]*maxminddb.Reader),
 		databases:     make(map[string]*Info),
 		displayToPath: make(map[string]string),
+		watchFiles:    make(map[string]bool),
+		manifest:      make(map[string]ManifestEntry),
 		watcher:       watcher,
+		newWatcher:    fsnotify.NewWatcher,
 		watchDirs:     make([]string, 0),
+		health:        WatcherHealth{Status: WatcherNotStarted},
 	}, nil
 }
 
+// SetChangeCallback registers a function called whenever a database is added
+// or removed, e.g. by the file watcher. It replaces any previous callback.
+// The callback is called with the manager's lock held, so it must not call
+// back into the Manager and should return quickly.
+func (m *Manager) SetChangeCallback(callback func(Change)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onChange = callback
+}
+
+// SetMaxDatabases limits the number of loaded databases. Once the limit is
+// reached, new databases are skipped with a warning while already loaded
+// databases can still be reloaded. Zero means no limit.
+func (m *Manager) SetMaxDatabases(limit int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.maxDatabases = limit
+}
+
+// SetAllowedPaths restricts the databases that can be loaded, including by
+// manifests and the file watcher, to files within the given directories, as
+// checked by config.CheckAllowedPath. An empty list allows every path.
+func (m *Manager) SetAllowedPaths(paths []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.allowedPaths = slices.Clone(paths)
+}
+
+// SetDescriptions sets descriptions that override or extend the built-in
+// ones, keyed by database name or by "type:<type>" selector. A name entry
+// takes precedence over a type entry. Loaded databases are updated too.
+func (m *Manager) SetDescriptions(descriptions map[string]string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.descriptions = descriptions
+	// Callers may hold the current Info values, so they are replaced rather
+	// than modified.
+	for path, db := range m.databases {
+		updated := *db
+		updated.Description = m.describe(db.Name, db.Type)
+		m.databases[path] = &updated
+	}
+}
+
+// describe returns the description of a database with the given name and
+// type (must be called with lock held).
+func (m *Manager) describe(name, dbType string) string {
+	if desc, ok := m.descriptions[name]; ok {
+		return desc
+	}
+	for selector, desc := range m.descriptions {
+		if selectorType, isType := strings.CutPrefix(selector, TypeSelectorPrefix); isType &&
+			strings.EqualFold(selectorType, dbType) {
+			return desc
+		}
+	}
+	return getDatabaseDescription(dbType)
+}
+
+// atDatabaseLimit reports whether loading path would exceed the database
+// limit (must be called with lock held).
+func (m *Manager) atDatabaseLimit(path string) bool {
+	if m.maxDatabases <= 0 || len(m.databases) < m.maxDatabases {
+		return false
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		absPath = path
+	}
+	_, loaded := m.databases[absPath]
+	return !loaded
+}
+
+// notifyChange calls the change callback, if any (must be called with lock
+// held).
+func (m *Manager) notifyChange(change Change) {
+	if m.onChange != nil {
+		m.onChange(change)
+	}
+}
+
 // LoadDirectory scans a directory for MMDB files and loads them.
 func (m *Manager) LoadDirectory(dir string) error {
 	m.mu.Lock()
@@ -66,6 +228,7 @@ func (m *Manager) LoadDirectory(dir string) error {
 	}
 
 	// Walk the directory looking for .mmdb files and subdirectories to watch
+	skipped := 0
 	err := filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
 		if err != nil {
 			return err
@@ -76,6 +239,10 @@ func (m *Manager) LoadDirectory(dir string) error {
 		}
 
 		if !d.IsDir() && strings.HasSuffix(strings.ToLower(d.Name()), ".mmdb") {
+			if m.atDatabaseLimit(path) {
+				skipped++
+				return nil
+			}
 			return m.loadMMDBFile(path, d)
 		}
 
@@ -85,6 +252,13 @@ func (m *Manager) LoadDirectory(dir string) error {
 		return fmt.Errorf("failed to scan directory %s: %w", dir, err)
 	}
 
+	if skipped > 0 {
+		slog.Warn("Database limit reached, skipping remaining databases",
+			"dir", dir,
+			"max_databases", m.maxDatabases,
+			"skipped", skipped)
+	}
+
 	return nil
 }
 
@@ -101,75 +275,179 @@ func (m *Manager) LoadDatabase(path string) error {
 	return m.loadDatabase(path, info)
 }
 
+// LoadPath loads and watches a configured path. Directories are scanned for
+// MMDB files and watched; individual files are loaded directly and watched
+// through their parent directory.
+func (m *Manager) LoadPath(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("%w: %s", ErrPathNotExist, path)
+		}
+		return fmt.Errorf("failed to stat path %s: %w", path, err)
+	}
+
+	if !info.IsDir() {
+		if err := m.LoadDatabase(path); err != nil {
+			return err
+		}
+		return m.WatchFile(path)
+	}
+
+	if err := m.LoadDirectory(path); err != nil {
+		return err
+	}
+	return m.WatchDirectory(path)
+}
+
+// LoadPaths loads and watches each path with LoadPath, handling missing
+// paths according to opts. Without options, a missing path is an error.
+func (m *Manager) LoadPaths(paths []string, opts PathOptions) error {
+	for _, path := range paths {
+		if opts.CreateMissing && !strings.EqualFold(filepath.Ext(path), ".mmdb") {
+			if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+				if err := os.MkdirAll(path, 0o750); err != nil {
+					return fmt.Errorf("failed to create directory %s: %w", path, err)
+				}
+				slog.Info("Created missing database directory", "path", path)
+			}
+		}
+
+		err := m.LoadPath(path)
+		if opts.SkipMissing && errors.Is(err, ErrPathNotExist) {
+			slog.Warn("Skipping missing database path", "path", path)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load path %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// WatchFile watches a single database file for changes. The parent directory
+// is added to the watcher, but events for other files in it are ignored
+// unless that directory is also watched in full.
+func (m *Manager) WatchFile(path string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dir := filepath.Dir(path)
+	if err := m.addWatch(dir); err != nil {
+		return err
+	}
+
+	m.watchFiles[filepath.Clean(path)] = true
+	return nil
+}
+
 // WatchDirectory adds a directory to be watched for file changes.
 func (m *Manager) WatchDirectory(dir string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	// Add to watcher
-	if err := m.watcher.Add(dir); err != nil {
-		return fmt.Errorf("failed to watch directory %s: %w", dir, err)
+	if err := m.addWatch(dir); err != nil {
+		return err
 	}
 
 	m.watchDirs = append(m.watchDirs, dir)
 	return nil
 }
 
-// StartWatching starts the file watcher goroutine.
+// StartWatching starts the file watcher goroutine. If the watcher stops
+// unexpectedly, the watches are re-established with a new watcher.
 func (m *Manager) StartWatching() {
-	go func() {
-		for {
-			select {
-			case event, ok := <-m.watcher.Events:
-				if !ok {
-					return
-				}
+	m.mu.RLock()
+	watcher := m.watcher
+	m.mu.RUnlock()
 
-				if event.Op&fsnotify.Write == fsnotify.Write ||
-					event.Op&fsnotify.Create == fsnotify.Create {
-					if strings.HasSuffix(strings.ToLower(event.Name), ".mmdb") {
-						if err := m.LoadDatabase(event.Name); err != nil {
-							slog.Warn(
-								"Failed to load database on event",
-								"path",
-								event.Name,
-								"err",
-								err,
-							)
-						}
-					}
-				}
+	if watcher == nil {
+		m.recordWatcherError(errWatcherUnavailable)
+		m.setWatcherStatus(WatcherStopped)
+		return
+	}
 
-				if event.Op&fsnotify.Remove == fsnotify.Remove {
-					// Convert to absolute path for removal
-					absPath, err := filepath.Abs(event.Name)
-					if err != nil {
-						absPath = event.Name
-					}
-					m.RemoveDatabaseByPath(absPath)
-				}
+	m.setWatcherStatus(WatcherHealthy)
+	go m.runWatcher(watcher.Events, watcher.Errors)
+}
 
-				if event.Op&fsnotify.Rename == fsnotify.Rename {
-					// Handle rename as remove - the old path is no longer valid
-					absPath, err := filepath.Abs(event.Name)
-					if err != nil {
-						absPath = event.Name
-					}
-					m.RemoveDatabaseByPath(absPath)
-
-					// Note: We don't try to reload here because event.Name is the old path.
-					// If the file was renamed within a watched directory, we'll get a
-					// subsequent Create event for the new path.
-				}
+// runWatcher processes watcher events until the watcher stops and could not
+// be re-established.
+func (m *Manager) runWatcher(events <-chan fsnotify.Event, errs <-chan error) {
+	for {
+		m.watchLoop(events, errs)
 
-			case err, ok := <-m.watcher.Errors:
-				if !ok {
-					return
-				}
-				slog.Error("Watcher error", "err", err)
+		watcher, ok := m.recoverWatcher()
+		if !ok {
+			return
+		}
+		events, errs = watcher.Events, watcher.Errors
+	}
+}
+
+// watchLoop handles watcher events and errors until either channel closes.
+func (m *Manager) watchLoop(events <-chan fsnotify.Event, errs <-chan error) {
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			m.handleEvent(event)
+
+		case err, ok := <-errs:
+			if !ok {
+				return
+			}
+			slog.Error("Watcher error", "err", err)
+			m.recordWatcherError(err)
+		}
+	}
+}
+
+// handleEvent reloads or removes databases in response to a watcher event.
+func (m *Manager) handleEvent(event fsnotify.Event) {
+	if !m.isWatched(event.Name) {
+		return
+	}
+
+	if event.Op&fsnotify.Write == fsnotify.Write ||
+		event.Op&fsnotify.Create == fsnotify.Create {
+		if strings.HasSuffix(strings.ToLower(event.Name), ".mmdb") {
+			if err := m.LoadDatabase(event.Name); err != nil {
+				slog.Warn(
+					"Failed to load database on event",
+					"path",
+					event.Name,
+					"err",
+					err,
+				)
 			}
 		}
-	}()
+	}
+
+	if event.Op&fsnotify.Remove == fsnotify.Remove {
+		// Convert to absolute path for removal
+		absPath, err := filepath.Abs(event.Name)
+		if err != nil {
+			absPath = event.Name
+		}
+		m.RemoveDatabaseByPath(absPath)
+	}
+
+	if event.Op&fsnotify.Rename == fsnotify.Rename {
+		// Handle rename as remove - the old path is no longer valid
+		absPath, err := filepath.Abs(event.Name)
+		if err != nil {
+			absPath = event.Name
+		}
+		m.RemoveDatabaseByPath(absPath)
+
+		// Note: We don't try to reload here because event.Name is the old path.
+		// If the file was renamed within a watched directory, we'll get a
+		// subsequent Create event for the new path.
+	}
 }
 
 // GetReader returns a reader for the specified database by display name.
@@ -208,6 +486,48 @@ func (m *Manager) ListDatabases() []*Info {
 	return slices.Collect(maps.Values(m.databases))
 }
 
+// ResolveName resolves a database selector to a display name. Selectors of
+// the form "type:<type>" select the only loaded database of that type, as
+// matched by MatchesType; a type matching several databases is an error
+// rather than a silent pick. Other selectors are returned unchanged.
+func (m *Manager) ResolveName(selector string) (string, error) {
+	dbType, isType := strings.CutPrefix(selector, TypeSelectorPrefix)
+	if !isType {
+		return selector, nil
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var names []string
+	for _, db := range m.databases {
+		if db.MatchesType(dbType) {
+			names = append(names, db.Name)
+		}
+	}
+
+	switch len(names) {
+	case 0:
+		return "", fmt.Errorf("%w: no database of type %s", ErrDatabaseNotFound, dbType)
+	case 1:
+		return names[0], nil
+	default:
+		slices.Sort(names)
+		return "", fmt.Errorf(
+			"%w: type %s matches %s",
+			ErrAmbiguousDatabase,
+			dbType,
+			strings.Join(names, ", "),
+		)
+	}
+}
+
+// MatchesType reports whether the database matches the given type,
+// compared case-insensitively against the inferred and metadata types.
+func (db *Info) MatchesType(dbType string) bool {
+	return strings.EqualFold(db.Type, dbType) || strings.EqualFold(db.DatabaseType, dbType)
+}
+
 // RemoveDatabase removes a database by display name from the manager.
 func (m *Manager) RemoveDatabase(name string) {
 	m.mu.Lock()
@@ -221,6 +541,7 @@ func (m *Manager) RemoveDatabase(name string) {
 		delete(m.readers, path)
 		delete(m.databases, path)
 		delete(m.displayToPath, name)
+		m.notifyChange(Change{Name: name, Kind: DatabaseRemoved})
 	}
 }
 
@@ -231,11 +552,15 @@ func (m *Manager) RemoveDatabaseByPath(path string) {
 
 	// Remove from all maps but don't close reader - let GC handle it
 	// since iterators may still be using the reader
-	if db, exists := m.databases[path]; exists {
+	db, exists := m.databases[path]
+	if exists {
 		delete(m.displayToPath, db.Name)
 	}
 	delete(m.readers, path)
 	delete(m.databases, path)
+	if exists {
+		m.notifyChange(Change{Name: db.Name, Kind: DatabaseRemoved})
+	}
 }
 
 // Close closes the file watcher and clears the database maps.
@@ -250,18 +575,41 @@ func (m *Manager) Close() error {
 	m.readers = make(map[string]*maxminddb.Reader)
 	m.databases = make(map[string]*Info)
 	m.displayToPath = make(map[string]string)
+	m.closed = true
+	if m.stopPolling != nil {
+		close(m.stopPolling)
+		m.stopPolling = nil
+	}
+
+	m.setWatcherStatus(WatcherStopped)
 
 	// Close watcher
+	if m.watcher == nil {
+		return nil
+	}
 	return m.watcher.Close()
 }
 
 // loadDatabase loads a database file (must be called with lock held).
 func (m *Manager) loadDatabase(path string, info os.FileInfo) error {
+	if err := config.CheckAllowedPath(m.allowedPaths, path); err != nil {
+		return fmt.Errorf("not loading %s: %w", path, err)
+	}
+	if m.atDatabaseLimit(path) {
+		return fmt.Errorf("not loading %s: %w (max_databases = %d)", path, ErrDatabaseLimit, m.maxDatabases)
+	}
+
 	// Open the database
 	reader, err := maxminddb.Open(path)
 	if err != nil {
 		return fmt.Errorf("failed to open MMDB file %s: %w", path, err)
 	}
+	// Open only checks that the metadata fits in the file. A file with an
+	// empty search tree would be loaded and then fail every lookup.
+	if reader.Metadata.NodeCount == 0 {
+		_ = reader.Close()
+		return fmt.Errorf("failed to open MMDB file %s: %w", path, ErrEmptySearchTree)
+	}
 
 	// Use absolute path as key to avoid collisions
 	absPath, err := filepath.Abs(path)
@@ -274,15 +622,31 @@ func (m *Manager) loadDatabase(path string, info os.FileInfo) error {
 
 	name := filepath.Base(path)
 	dbType := inferDatabaseType(name)
-	description := getDatabaseDescription(dbType)
+	if entry, ok := m.manifest[absPath]; ok {
+		name = entry.name()
+		if entry.Type != "" {
+			dbType = entry.Type
+		}
+	}
+	description := m.describe(name, dbType)
 
 	dbInfo := &Info{
-		Name:        name, // Display name remains the base filename
-		Type:        dbType,
-		Description: description,
-		LastUpdated: info.ModTime(),
-		Size:        info.Size(),
-		Path:        absPath, // Store absolute path
+		Name:         name, // Display name is the base filename or manifest alias
+		Type:         dbType,
+		DatabaseType: reader.Metadata.DatabaseType,
+		Description:  description,
+		LastUpdated:  info.ModTime(),
+		LastReload:   time.Now(),
+		Size:         info.Size(),
+		Path:         absPath, // Store absolute path
+		signature:    signature{buildEpoch: reader.Metadata.BuildEpoch, size: info.Size()},
+	}
+
+	// Reloading a file with the same contents, e.g. after a touch, is not
+	// reported as a reload.
+	existing, reloaded := m.databases[absPath]
+	if reloaded && existing.signature == dbInfo.signature {
+		dbInfo.LastReload = existing.LastReload
 	}
 
 	// Store reader and metadata using absolute path as key
@@ -300,6 +664,14 @@ func (m *Manager) loadDatabase(path string, info os.FileInfo) error {
 	// Update display name to path mapping for O(1) lookups
 	m.displayToPath[dbInfo.Name] = absPath
 
+	if !reloaded {
+		m.notifyChange(Change{
+			Name:        dbInfo.Name,
+			Description: dbInfo.Description,
+			Kind:        DatabaseAdded,
+		})
+	}
+
 	return nil
 }
 
@@ -356,9 +728,26 @@ func getDatabaseDescription(dbType string) string {
 	return descriptions["Unknown"]
 }
 
+// isWatched reports whether a file event should be handled, i.e. whether the
+// file is in a fully watched directory or is an individually watched file.
+func (m *Manager) isWatched(path string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	path = filepath.Clean(path)
+	if m.watchFiles[path] {
+		return true
+	}
+
+	dir := filepath.Dir(path)
+	return slices.ContainsFunc(m.watchDirs, func(watched string) bool {
+		return filepath.Clean(watched) == dir
+	})
+}
+
 // watchSubdirectory adds a subdirectory to the watcher.
 func (m *Manager) watchSubdirectory(path string) error {
-	if watchErr := m.watcher.Add(path); watchErr != nil {
+	if watchErr := m.addWatch(path); watchErr != nil {
 		slog.Warn("Failed to watch subdirectory", "path", path, "err", watchErr)
 		return nil // Continue processing other directories
 	}
@@ -366,6 +755,28 @@ func (m *Manager) watchSubdirectory(path string) error {
 	return nil
 }
 
+// addWatch adds a directory to the file watcher (must be called with lock
+// held). A directory that does not exist is an error. If the directory exists
+// but cannot be watched, the failure is recorded and nil is returned so that
+// the directory can still be polled.
+func (m *Manager) addWatch(dir string) error {
+	if _, err := os.Stat(dir); err != nil {
+		return fmt.Errorf("failed to watch directory %s: %w", dir, err)
+	}
+
+	var err error
+	if m.watcher == nil {
+		err = errWatcherUnavailable
+	} else {
+		err = m.watcher.Add(dir)
+	}
+	if err != nil {
+		slog.Warn("Failed to watch directory", "path", dir, "err", err)
+		m.watchFailed = true
+	}
+	return nil
+}
+
 // loadMMDBFile loads a single MMDB file entry.
 func (m *Manager) loadMMDBFile(path string, d os.DirEntry) error {
 	info, statErr := d.Info()
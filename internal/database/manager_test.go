@@ -1,7 +1,10 @@
 package database
 
 import (
+	"errors"
 	"os"
+	"path/filepath"
+	"slices"
 	"testing"
 	"time"
 )
@@ -84,6 +87,64 @@ func TestLoadDatabase(t *testing.T) {
 	}
 }
 
+// emptySearchTreeMMDB returns a MaxMind DB file whose metadata is valid but
+// declares no search tree nodes.
+func emptySearchTreeMMDB() []byte {
+	data := make([]byte, 16) // data section separator
+	data = append(data, "\xab\xcd\xefMaxMind.com"...)
+	// Metadata map with 5 entries.
+	data = append(data, 0xe5)
+	data = append(data, "\x4anode_count\xc0"...)                      // uint32 0
+	data = append(data, "\x4brecord_size\xa1\x18"...)                 // uint16 24
+	data = append(data, "\x4aip_version\xa1\x06"...)                  // uint16 6
+	data = append(data, "\x4ddatabase_type\x44Test"...)               // string
+	data = append(data, "\x5bbinary_format_major_version\xa1\x02"...) // uint16 2
+	return data
+}
+
+func TestLoadDatabaseRejectsUnusableFiles(t *testing.T) {
+	valid, err := os.ReadFile(testDBPath)
+	if err != nil {
+		t.Fatalf("Failed to read test database: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		content []byte
+		want    error
+	}{
+		{name: "empty file"},
+		{name: "truncated file", content: valid[:len(valid)/2]},
+		{name: "empty search tree", content: emptySearchTreeMMDB(), want: ErrEmptySearchTree},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			manager, err := New()
+			if err != nil {
+				t.Fatalf("Failed to create manager: %v", err)
+			}
+			defer func() { _ = manager.Close() }()
+
+			path := filepath.Join(t.TempDir(), "GeoLite2-City.mmdb")
+			if err := os.WriteFile(path, test.content, 0o600); err != nil {
+				t.Fatalf("Failed to write test file: %v", err)
+			}
+
+			err = manager.LoadDatabase(path)
+			if err == nil {
+				t.Fatal("Expected an error loading an unusable database")
+			}
+			if test.want != nil && !errors.Is(err, test.want) {
+				t.Errorf("Expected %v, got %v", test.want, err)
+			}
+			if databases := manager.ListDatabases(); len(databases) != 0 {
+				t.Errorf("Expected no databases to be loaded, got %v", databases)
+			}
+		})
+	}
+}
+
 func TestLoadDirectory(t *testing.T) {
 	manager, err := New()
 	if err != nil {
@@ -118,6 +179,55 @@ func TestLoadDirectory(t *testing.T) {
 	}
 }
 
+func TestLoadDirectoryMaxDatabases(t *testing.T) {
+	manager, err := New()
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer func() { _ = manager.Close() }()
+
+	testDir := "../../testdata/test-data"
+	entries, err := os.ReadDir(testDir)
+	if err != nil {
+		t.Fatalf("Failed to read test directory: %v", err)
+	}
+	const limit = 3
+	if len(entries) <= limit {
+		t.Fatalf("Expected more than %d test databases, got %d", limit, len(entries))
+	}
+
+	manager.SetMaxDatabases(limit)
+	if err := manager.LoadDirectory(testDir); err != nil {
+		t.Fatalf("Failed to load directory: %v", err)
+	}
+
+	// The walk is in lexical order, so the first databases are loaded.
+	databases := manager.ListDatabases()
+	if len(databases) != limit {
+		t.Fatalf("Expected %d databases, got %d", limit, len(databases))
+	}
+	for _, entry := range entries[:limit] {
+		if _, exists := manager.GetDatabase(entry.Name()); !exists {
+			t.Errorf("Expected %s to be loaded", entry.Name())
+		}
+	}
+
+	// Loaded databases can still be reloaded, but no new ones are added.
+	if err := manager.LoadDirectory(testDir); err != nil {
+		t.Fatalf("Failed to reload directory: %v", err)
+	}
+	if got := len(manager.ListDatabases()); got != limit {
+		t.Errorf("Expected %d databases after reload, got %d", limit, got)
+	}
+	if err := manager.LoadDatabase(filepath.Join(testDir, entries[0].Name())); err != nil {
+		t.Errorf("Failed to reload database: %v", err)
+	}
+	err = manager.LoadDatabase(filepath.Join(testDir, entries[limit].Name()))
+	if !errors.Is(err, ErrDatabaseLimit) {
+		t.Errorf("Expected ErrDatabaseLimit, got %v", err)
+	}
+}
+
 func TestWatchDirectory(t *testing.T) {
 	manager, err := New()
 	if err != nil {
@@ -352,6 +462,50 @@ func TestGetDatabaseDescription(t *testing.T) {
 	}
 }
 
+func TestSetDescriptions(t *testing.T) {
+	manager, err := New()
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer func() { _ = manager.Close() }()
+
+	asnPath := "../../testdata/test-data/GeoLite2-ASN-Test.mmdb"
+	for _, path := range []string{testDBPath, asnPath} {
+		if err := manager.LoadDatabase(path); err != nil {
+			t.Fatalf("Failed to load test database: %v", err)
+		}
+	}
+
+	// Loaded databases pick up the descriptions, with name entries taking
+	// precedence over type entries.
+	manager.SetDescriptions(map[string]string{
+		testDBName:   "Vendor city data",
+		"type:city":  "Any city database",
+		"type:Trace": "Traceroute hints",
+	})
+	expected := map[string]string{
+		testDBName:               "Vendor city data",
+		"GeoLite2-ASN-Test.mmdb": "Autonomous system number and organization",
+	}
+	for _, db := range manager.ListDatabases() {
+		if db.Description != expected[db.Name] {
+			t.Errorf("Expected description %q for %s, got %q", expected[db.Name], db.Name, db.Description)
+		}
+	}
+
+	// Databases loaded later use them too.
+	manager.SetDescriptions(map[string]string{"type:ASN": "Vendor ASN data"})
+	if err := manager.LoadDatabase(asnPath); err != nil {
+		t.Fatalf("Failed to reload test database: %v", err)
+	}
+	if db, _ := manager.GetDatabase("GeoLite2-ASN-Test.mmdb"); db.Description != "Vendor ASN data" {
+		t.Errorf("Expected the type description, got %q", db.Description)
+	}
+	if db, _ := manager.GetDatabase(testDBName); db.Description != "IP geolocation with city-level precision" {
+		t.Errorf("Expected the built-in description, got %q", db.Description)
+	}
+}
+
 // Helper function to create an invalid MMDB file for testing.
 func createInvalidMMDB(t *testing.T) string {
 	tempFile, err := os.CreateTemp(t.TempDir(), "invalid-*.mmdb")
@@ -449,3 +603,269 @@ func TestReloadDatabase(t *testing.T) {
 		t.Error("Expected same timestamp after reload of unchanged file")
 	}
 }
+
+func TestLastReload(t *testing.T) {
+	manager, err := New()
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer func() { _ = manager.Close() }()
+
+	dir := t.TempDir()
+	path := copyTestDB(t, dir, "GeoLite2-City-Test.mmdb")
+
+	if err := manager.LoadDatabase(path); err != nil {
+		t.Fatalf("Failed to load test database: %v", err)
+	}
+	db, _ := manager.GetDatabase("GeoLite2-City-Test.mmdb")
+	initialReload := db.LastReload
+	if initialReload.IsZero() {
+		t.Fatal("Expected last reload to be set on first load")
+	}
+
+	// Reloading unchanged contents keeps the timestamp.
+	time.Sleep(10 * time.Millisecond)
+	if err := manager.LoadDatabase(path); err != nil {
+		t.Fatalf("Failed to reload test database: %v", err)
+	}
+	db, _ = manager.GetDatabase("GeoLite2-City-Test.mmdb")
+	if !db.LastReload.Equal(initialReload) {
+		t.Errorf("Expected last reload %v for unchanged file, got %v", initialReload, db.LastReload)
+	}
+
+	// Replacing the contents updates it.
+	data, err := os.ReadFile("../../testdata/test-data/GeoIP2-City-Test.mmdb")
+	if err != nil {
+		t.Fatalf("Failed to read replacement database: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("Failed to replace database: %v", err)
+	}
+	if err := manager.LoadDatabase(path); err != nil {
+		t.Fatalf("Failed to reload changed database: %v", err)
+	}
+	db, _ = manager.GetDatabase("GeoLite2-City-Test.mmdb")
+	if !db.LastReload.After(initialReload) {
+		t.Errorf("Expected last reload after %v for changed file, got %v", initialReload, db.LastReload)
+	}
+}
+
+func TestChangeCallback(t *testing.T) {
+	manager, err := New()
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer func() { _ = manager.Close() }()
+
+	var changes []Change
+	manager.SetChangeCallback(func(change Change) {
+		change.Description = ""
+		changes = append(changes, change)
+	})
+
+	dir := t.TempDir()
+	cityPath := copyTestDB(t, dir, "GeoLite2-City-Test.mmdb")
+	asnPath := copyTestDB(t, dir, "GeoLite2-ASN-Test.mmdb")
+
+	if err := manager.LoadDatabase(cityPath); err != nil {
+		t.Fatalf("Failed to load test database: %v", err)
+	}
+	// Reloads are not reported.
+	if err := manager.LoadDatabase(cityPath); err != nil {
+		t.Fatalf("Failed to reload test database: %v", err)
+	}
+	if err := manager.LoadDatabase(asnPath); err != nil {
+		t.Fatalf("Failed to load test database: %v", err)
+	}
+	manager.RemoveDatabaseByPath(cityPath)
+	manager.RemoveDatabase("GeoLite2-ASN-Test.mmdb")
+	// Removing a database that is not loaded is not reported.
+	manager.RemoveDatabase("GeoLite2-ASN-Test.mmdb")
+
+	expected := []Change{
+		{Name: "GeoLite2-City-Test.mmdb", Kind: DatabaseAdded},
+		{Name: "GeoLite2-ASN-Test.mmdb", Kind: DatabaseAdded},
+		{Name: "GeoLite2-City-Test.mmdb", Kind: DatabaseRemoved},
+		{Name: "GeoLite2-ASN-Test.mmdb", Kind: DatabaseRemoved},
+	}
+	if !slices.Equal(changes, expected) {
+		t.Errorf("Expected changes %v, got %v", expected, changes)
+	}
+}
+
+func TestLoadPathMixedDirectoryAndFile(t *testing.T) {
+	manager, err := New()
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer func() { _ = manager.Close() }()
+
+	// Directory containing a single ASN database
+	dbDir := t.TempDir()
+	asnData, err := os.ReadFile("../../testdata/test-data/GeoLite2-ASN-Test.mmdb")
+	if err != nil {
+		t.Fatalf("Failed to read ASN test database: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dbDir, "GeoLite2-ASN-Test.mmdb"), asnData, 0o600); err != nil {
+		t.Fatalf("Failed to write ASN test database: %v", err)
+	}
+
+	if err := manager.LoadPath(dbDir); err != nil {
+		t.Fatalf("Failed to load directory path: %v", err)
+	}
+	if err := manager.LoadPath(testDBPath); err != nil {
+		t.Fatalf("Failed to load file path: %v", err)
+	}
+
+	databases := manager.ListDatabases()
+	if len(databases) != 2 {
+		t.Fatalf("Expected 2 databases, got %d", len(databases))
+	}
+	for _, name := range []string{"GeoLite2-ASN-Test.mmdb", testDBName} {
+		if _, exists := manager.GetReader(name); !exists {
+			t.Errorf("Expected %s to be loaded", name)
+		}
+	}
+
+	// The single file is watched, but its siblings are not.
+	if !manager.isWatched(testDBPath) {
+		t.Error("Individually loaded file should be watched")
+	}
+	sibling := filepath.Join(filepath.Dir(testDBPath), "GeoLite2-Country-Test.mmdb")
+	if manager.isWatched(sibling) {
+		t.Error("Sibling of an individually loaded file should not be watched")
+	}
+
+	// Every file in a loaded directory is watched.
+	if !manager.isWatched(filepath.Join(dbDir, "new.mmdb")) {
+		t.Error("Files in a loaded directory should be watched")
+	}
+
+	// Non-existent paths fail.
+	if err := manager.LoadPath("/nonexistent/path.mmdb"); err == nil {
+		t.Error("Expected error when loading non-existent path")
+	}
+}
+
+func TestLoadPathsMissingPath(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing")
+	paths := []string{missing, testDBPath}
+
+	tests := []struct {
+		name        string
+		opts        PathOptions
+		expectError bool
+		expectDir   bool
+	}{
+		{name: "strict", opts: PathOptions{}, expectError: true},
+		{name: "skip missing", opts: PathOptions{SkipMissing: true}},
+		{name: "auto create", opts: PathOptions{CreateMissing: true}, expectDir: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Cleanup(func() { _ = os.RemoveAll(missing) })
+
+			manager, err := New()
+			if err != nil {
+				t.Fatalf("Failed to create manager: %v", err)
+			}
+			defer func() { _ = manager.Close() }()
+
+			err = manager.LoadPaths(paths, test.opts)
+			if test.expectError {
+				if !errors.Is(err, ErrPathNotExist) {
+					t.Fatalf("Expected ErrPathNotExist, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to load paths: %v", err)
+			}
+
+			// The remaining paths are still loaded.
+			if _, exists := manager.GetReader(testDBName); !exists {
+				t.Errorf("Expected %s to be loaded", testDBName)
+			}
+
+			info, err := os.Stat(missing)
+			if test.expectDir {
+				if err != nil || !info.IsDir() {
+					t.Fatalf("Expected %s to be created, got %v", missing, err)
+				}
+				if !manager.isWatched(filepath.Join(missing, "new.mmdb")) {
+					t.Error("Expected the created directory to be watched")
+				}
+			} else if !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("Expected %s not to be created, got %v", missing, err)
+			}
+		})
+	}
+}
+
+func TestLoadPathsDoesNotCreateMissingFile(t *testing.T) {
+	manager, err := New()
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer func() { _ = manager.Close() }()
+
+	missing := filepath.Join(t.TempDir(), "GeoIP2-City.mmdb")
+	err = manager.LoadPaths([]string{missing}, PathOptions{CreateMissing: true})
+	if !errors.Is(err, ErrPathNotExist) {
+		t.Fatalf("Expected ErrPathNotExist, got %v", err)
+	}
+	if _, err := os.Stat(missing); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected %s not to be created, got %v", missing, err)
+	}
+}
+
+func TestResolveName(t *testing.T) {
+	manager, err := New()
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer func() { _ = manager.Close() }()
+
+	for _, path := range []string{
+		testDBPath,
+		"../../testdata/test-data/GeoIP2-City-Test.mmdb",
+		"../../testdata/test-data/GeoLite2-ASN-Test.mmdb",
+	} {
+		if err := manager.LoadDatabase(path); err != nil {
+			t.Fatalf("Failed to load %s: %v", path, err)
+		}
+	}
+
+	tests := []struct {
+		expectedErr error
+		selector    string
+		expected    string
+	}{
+		{selector: "type:ASN", expected: "GeoLite2-ASN-Test.mmdb"},
+		{selector: "type:asn", expected: "GeoLite2-ASN-Test.mmdb"},
+		{selector: "type:GeoLite2-ASN", expected: "GeoLite2-ASN-Test.mmdb"},
+		{selector: "type:GeoLite2-City", expected: testDBName},
+		{selector: testDBName, expected: testDBName},
+		{selector: "type:City", expectedErr: ErrAmbiguousDatabase},
+		{selector: "type:ISP", expectedErr: ErrDatabaseNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.selector, func(t *testing.T) {
+			name, err := manager.ResolveName(test.selector)
+			if test.expectedErr != nil {
+				if !errors.Is(err, test.expectedErr) {
+					t.Errorf("Expected error %v, got %v (%q)", test.expectedErr, err, name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if name != test.expected {
+				t.Errorf("Expected %s, got %s", test.expected, name)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/oschwald/maxminddb-mcp/internal/config"
+	"github.com/pelletier/go-toml/v2"
+)
+
+// ManifestEntry describes a database listed in a manifest file.
+type ManifestEntry struct {
+	// Path is the database file. Relative paths are resolved against the
+	// directory containing the manifest.
+	Path string `json:"path" toml:"path"`
+	// Alias is the display name of the database. It defaults to the base
+	// filename.
+	Alias string `json:"alias" toml:"alias"`
+	// Type overrides the type inferred from the filename, e.g. "City".
+	Type string `json:"type" toml:"type"`
+}
+
+// manifest is the layout of a manifest file.
+type manifest struct {
+	Databases []ManifestEntry `json:"databases" toml:"databases"`
+}
+
+// ParseManifest reads a manifest file. Files with a .json extension are
+// parsed as JSON and all others as TOML. Every listed path must be an
+// existing file and aliases must be unique.
+func ParseManifest(path string) ([]ManifestEntry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	var m manifest
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &m)
+	} else {
+		err = toml.Unmarshal(data, &m)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", path, err)
+	}
+
+	if len(m.Databases) == 0 {
+		return nil, fmt.Errorf("manifest %s lists no databases", path)
+	}
+
+	baseDir := filepath.Dir(path)
+	names := make(map[string]string, len(m.Databases))
+	for i := range m.Databases {
+		entry := &m.Databases[i]
+		if entry.Path == "" {
+			return nil, fmt.Errorf("manifest entry %d has no path", i+1)
+		}
+		if !filepath.IsAbs(entry.Path) {
+			entry.Path = filepath.Join(baseDir, entry.Path)
+		}
+
+		info, err := os.Stat(entry.Path)
+		if err != nil {
+			return nil, fmt.Errorf("manifest database %s: %w", entry.Path, err)
+		}
+		if info.IsDir() {
+			return nil, fmt.Errorf("manifest database %s is a directory", entry.Path)
+		}
+
+		name := entry.name()
+		if other, exists := names[name]; exists {
+			return nil, fmt.Errorf(
+				"manifest databases %s and %s share the name %s",
+				other,
+				entry.Path,
+				name,
+			)
+		}
+		names[name] = entry.Path
+	}
+
+	return m.Databases, nil
+}
+
+// name returns the display name of the entry.
+func (e ManifestEntry) name() string {
+	if e.Alias != "" {
+		return e.Alias
+	}
+	return filepath.Base(e.Path)
+}
+
+// LoadManifest loads and watches the databases listed in a manifest file.
+// Only the listed files are loaded; other files in the same directories are
+// ignored. The aliases and types apply whenever the files are reloaded. A
+// manifest listing a file outside the allowed paths is rejected without
+// loading any of its databases.
+func (m *Manager) LoadManifest(path string) error {
+	entries, err := ParseManifest(path)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	for _, entry := range entries {
+		if err := config.CheckAllowedPath(m.allowedPaths, entry.Path); err != nil {
+			m.mu.Unlock()
+			return fmt.Errorf("manifest database %s: %w", entry.Path, err)
+		}
+	}
+	for _, entry := range entries {
+		absPath, err := filepath.Abs(entry.Path)
+		if err != nil {
+			absPath = entry.Path
+		}
+		m.manifest[absPath] = entry
+	}
+	m.mu.Unlock()
+
+	var errs []error
+	for _, entry := range entries {
+		if err := m.LoadDatabase(entry.Path); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := m.WatchFile(entry.Path); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,231 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// copyTestDB copies a test database into dir and returns its path.
+func copyTestDB(t *testing.T, dir, name string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("../../testdata/test-data", name))
+	if err != nil {
+		t.Fatalf("Failed to read test database %s: %v", name, err)
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("Failed to write test database %s: %v", name, err)
+	}
+	return path
+}
+
+func TestLoadManifest(t *testing.T) {
+	dir := t.TempDir()
+	copyTestDB(t, dir, "GeoLite2-City-Test.mmdb")
+	asnPath := copyTestDB(t, dir, "GeoLite2-ASN-Test.mmdb")
+	// A stray database in the same directory must not be loaded.
+	copyTestDB(t, dir, "GeoLite2-Country-Test.mmdb")
+
+	tests := []struct {
+		name     string
+		filename string
+		content  string
+	}{
+		{
+			name:     "toml",
+			filename: "databases.toml",
+			content: `
+[[databases]]
+path = "GeoLite2-City-Test.mmdb"
+alias = "city"
+
+[[databases]]
+path = "` + asnPath + `"
+alias = "asn"
+type = "Network"
+`,
+		},
+		{
+			name:     "json",
+			filename: "databases.json",
+			content: `{"databases": [
+				{"path": "GeoLite2-City-Test.mmdb", "alias": "city"},
+				{"path": "` + asnPath + `", "alias": "asn", "type": "Network"}
+			]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifestPath := filepath.Join(dir, tt.filename)
+			if err := os.WriteFile(manifestPath, []byte(tt.content), 0o600); err != nil {
+				t.Fatalf("Failed to write manifest: %v", err)
+			}
+
+			manager, err := New()
+			if err != nil {
+				t.Fatalf("Failed to create manager: %v", err)
+			}
+			defer func() { _ = manager.Close() }()
+
+			if err := manager.LoadManifest(manifestPath); err != nil {
+				t.Fatalf("Failed to load manifest: %v", err)
+			}
+
+			if databases := manager.ListDatabases(); len(databases) != 2 {
+				t.Fatalf("Expected 2 databases, got %d", len(databases))
+			}
+
+			city, exists := manager.GetDatabase("city")
+			if !exists {
+				t.Fatal("Expected database with alias city")
+			}
+			if city.Type != "City" {
+				t.Errorf("Expected inferred type City, got %s", city.Type)
+			}
+
+			asn, exists := manager.GetDatabase("asn")
+			if !exists {
+				t.Fatal("Expected database with alias asn")
+			}
+			if asn.Type != "Network" {
+				t.Errorf("Expected type Network from manifest, got %s", asn.Type)
+			}
+
+			if _, exists := manager.GetReader("GeoLite2-Country-Test.mmdb"); exists {
+				t.Error("Database not listed in the manifest should not be loaded")
+			}
+
+			// Listed files are watched, unlisted siblings are not.
+			if !manager.isWatched(asnPath) {
+				t.Error("Manifest database should be watched")
+			}
+			if manager.isWatched(filepath.Join(dir, "GeoLite2-Country-Test.mmdb")) {
+				t.Error("Database not listed in the manifest should not be watched")
+			}
+
+			// Reloading keeps the alias.
+			if err := manager.LoadDatabase(asnPath); err != nil {
+				t.Fatalf("Failed to reload database: %v", err)
+			}
+			if _, exists := manager.GetDatabase("asn"); !exists {
+				t.Error("Expected alias to survive a reload")
+			}
+		})
+	}
+}
+
+func TestLoadManifestAllowedPaths(t *testing.T) {
+	root := t.TempDir()
+	allowed := filepath.Join(root, "allowed")
+	outside := filepath.Join(root, "outside")
+	for _, dir := range []string{allowed, outside} {
+		if err := os.Mkdir(dir, 0o750); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+	}
+	copyTestDB(t, allowed, "GeoLite2-City-Test.mmdb")
+	outsidePath := copyTestDB(t, outside, "GeoLite2-ASN-Test.mmdb")
+
+	tests := []struct {
+		name  string
+		entry string
+	}{
+		{name: "absolute path", entry: outsidePath},
+		{name: "relative path", entry: "../outside/GeoLite2-ASN-Test.mmdb"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifestPath := filepath.Join(allowed, "databases.toml")
+			content := `
+[[databases]]
+path = "GeoLite2-City-Test.mmdb"
+
+[[databases]]
+path = "` + tt.entry + `"
+`
+			if err := os.WriteFile(manifestPath, []byte(content), 0o600); err != nil {
+				t.Fatalf("Failed to write manifest: %v", err)
+			}
+
+			manager, err := New()
+			if err != nil {
+				t.Fatalf("Failed to create manager: %v", err)
+			}
+			defer func() { _ = manager.Close() }()
+			manager.SetAllowedPaths([]string{allowed})
+
+			err = manager.LoadManifest(manifestPath)
+			if err == nil || !strings.Contains(err.Error(), "outside allowed_paths") {
+				t.Fatalf("Expected allowed_paths error, got %v", err)
+			}
+			if databases := manager.ListDatabases(); len(databases) != 0 {
+				t.Errorf("Expected no databases to be loaded, got %d", len(databases))
+			}
+
+			// Reloads, e.g. by the file watcher, are checked too.
+			if err := manager.LoadDatabase(outsidePath); err == nil {
+				t.Error("Expected error loading a database outside allowed_paths")
+			}
+		})
+	}
+}
+
+func TestParseManifestErrors(t *testing.T) {
+	dir := t.TempDir()
+	cityPath := copyTestDB(t, dir, "GeoLite2-City-Test.mmdb")
+
+	tests := []struct {
+		name     string
+		content  string
+		errorMsg string
+	}{
+		{
+			name:     "no databases",
+			content:  ``,
+			errorMsg: "lists no databases",
+		},
+		{
+			name:     "missing path",
+			content:  "[[databases]]\nalias = \"city\"\n",
+			errorMsg: "has no path",
+		},
+		{
+			name:     "nonexistent path",
+			content:  "[[databases]]\npath = \"missing.mmdb\"\n",
+			errorMsg: "missing.mmdb",
+		},
+		{
+			name:     "directory path",
+			content:  "[[databases]]\npath = \"" + dir + "\"\n",
+			errorMsg: "is a directory",
+		},
+		{
+			name: "duplicate alias",
+			content: "[[databases]]\npath = \"" + cityPath + "\"\nalias = \"db\"\n" +
+				"[[databases]]\npath = \"" + cityPath + "\"\nalias = \"db\"\n",
+			errorMsg: "share the name db",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifestPath := filepath.Join(dir, "databases.toml")
+			if err := os.WriteFile(manifestPath, []byte(tt.content), 0o600); err != nil {
+				t.Fatalf("Failed to write manifest: %v", err)
+			}
+
+			_, err := ParseManifest(manifestPath)
+			if err == nil {
+				t.Fatal("Expected error")
+			}
+			if !strings.Contains(err.Error(), tt.errorMsg) {
+				t.Errorf("Expected error containing %q, got %v", tt.errorMsg, err)
+			}
+		})
+	}
+}
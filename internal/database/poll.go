@@ -0,0 +1,134 @@
+package database
+
+import (
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// fileState is the modification time and size of a polled database file.
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// WatchFailed reports whether the file watcher could not be created or could
+// not watch one of the watched paths. Callers may then fall back to polling.
+func (m *Manager) WatchFailed() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.watchFailed
+}
+
+// StartPolling starts a goroutine that rescans the watched directories and
+// files every interval, reloading databases whose modification time or size
+// changed and removing databases whose files disappeared. It is an
+// alternative to StartWatching for filesystems where file events are not
+// delivered. Polling stops when the manager is closed.
+func (m *Manager) StartPolling(interval time.Duration) {
+	m.mu.Lock()
+	if m.closed || m.stopPolling != nil {
+		m.mu.Unlock()
+		return
+	}
+	stop := make(chan struct{})
+	m.stopPolling = stop
+	m.pollState = m.scanWatchedFiles()
+	m.mu.Unlock()
+
+	m.healthMu.Lock()
+	m.health.Polling = true
+	m.healthMu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				m.pollOnce()
+			}
+		}
+	}()
+}
+
+// pollOnce compares the watched files against the previous scan and applies
+// the changes.
+func (m *Manager) pollOnce() {
+	m.mu.RLock()
+	current := m.scanWatchedFiles()
+	previous := maps.Clone(m.pollState)
+	m.mu.RUnlock()
+
+	next := make(map[string]fileState, len(current))
+	for path, state := range current {
+		if old, ok := previous[path]; ok && old == state {
+			next[path] = state
+			continue
+		}
+		if err := m.LoadDatabase(path); err != nil {
+			// Leave the file out of the state so that it is retried, e.g.
+			// when it was still being written.
+			slog.Warn("Failed to load database on poll", "path", path, "err", err)
+			continue
+		}
+		next[path] = state
+	}
+
+	for path := range previous {
+		if _, ok := current[path]; !ok {
+			m.RemoveDatabaseByPath(path)
+		}
+	}
+
+	m.mu.Lock()
+	m.pollState = next
+	m.mu.Unlock()
+}
+
+// scanWatchedFiles returns the state of every .mmdb file in the watched
+// directories and of every individually watched file, keyed by absolute path
+// (must be called with lock held).
+func (m *Manager) scanWatchedFiles() map[string]fileState {
+	files := make(map[string]fileState)
+	add := func(path string, info os.FileInfo) {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			absPath = path
+		}
+		files[absPath] = fileState{modTime: info.ModTime(), size: info.Size()}
+	}
+
+	for _, dir := range m.watchDirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || !strings.HasSuffix(strings.ToLower(entry.Name()), ".mmdb") {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil {
+				continue
+			}
+			add(filepath.Join(dir, entry.Name()), info)
+		}
+	}
+
+	for path := range m.watchFiles {
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		add(path, info)
+	}
+
+	return files
+}
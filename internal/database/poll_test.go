@@ -0,0 +1,103 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// waitFor polls condition until it holds or the timeout expires.
+func waitFor(t *testing.T, what string, condition func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestPollingDetectsChanges(t *testing.T) {
+	manager, err := New()
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer func() { _ = manager.Close() }()
+
+	dir := t.TempDir()
+	if err := manager.LoadPath(dir); err != nil {
+		t.Fatalf("Failed to load path: %v", err)
+	}
+
+	// StartWatching is never called, so only polling can pick up changes.
+	manager.StartPolling(20 * time.Millisecond)
+	if !manager.WatcherHealth().Polling {
+		t.Error("Expected health to report polling")
+	}
+
+	cityData, err := os.ReadFile(testDBPath)
+	if err != nil {
+		t.Fatalf("Failed to read test database: %v", err)
+	}
+	asnData, err := os.ReadFile("../../testdata/test-data/GeoLite2-ASN-Test.mmdb")
+	if err != nil {
+		t.Fatalf("Failed to read test database: %v", err)
+	}
+
+	dbPath := filepath.Join(dir, "polled.mmdb")
+	if err := os.WriteFile(dbPath, cityData, 0o600); err != nil {
+		t.Fatalf("Failed to write database: %v", err)
+	}
+	waitFor(t, "new database to be loaded", func() bool {
+		db, ok := manager.GetDatabase("polled.mmdb")
+		return ok && db.DatabaseType == "GeoLite2-City"
+	})
+
+	// Replacing the contents changes the size, so the database is reloaded.
+	if err := os.WriteFile(dbPath, asnData, 0o600); err != nil {
+		t.Fatalf("Failed to write database: %v", err)
+	}
+	waitFor(t, "changed database to be reloaded", func() bool {
+		db, ok := manager.GetDatabase("polled.mmdb")
+		return ok && db.DatabaseType == "GeoLite2-ASN"
+	})
+
+	if err := os.Remove(dbPath); err != nil {
+		t.Fatalf("Failed to remove database: %v", err)
+	}
+	waitFor(t, "removed database to be unloaded", func() bool {
+		_, ok := manager.GetDatabase("polled.mmdb")
+		return !ok
+	})
+}
+
+func TestWatchFailedFallsBackToRecordingPaths(t *testing.T) {
+	manager, err := New()
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer func() { _ = manager.Close() }()
+
+	// Simulate a watcher that could not be created.
+	_ = manager.watcher.Close()
+	manager.watcher = nil
+
+	dir := t.TempDir()
+	if err := manager.WatchDirectory(dir); err != nil {
+		t.Fatalf("Expected watch failure to be tolerated, got %v", err)
+	}
+	if !manager.WatchFailed() {
+		t.Error("Expected WatchFailed to report the failure")
+	}
+	if !manager.isWatched(filepath.Join(dir, "GeoLite2-City.mmdb")) {
+		t.Error("Expected directory to be recorded for polling")
+	}
+
+	manager.StartWatching()
+	if status := manager.WatcherHealth().Status; status != WatcherStopped {
+		t.Errorf("Expected status %s without a watcher, got %s", WatcherStopped, status)
+	}
+}
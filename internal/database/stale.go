@@ -0,0 +1,61 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"syscall"
+
+	"github.com/oschwald/maxminddb-golang/v2"
+)
+
+// IsStaleFileHandle reports whether err is caused by a stale file handle
+// (ESTALE), as returned by NFS when a file was replaced on the server while
+// it was open.
+func IsStaleFileHandle(err error) bool {
+	return errors.Is(err, syscall.ESTALE)
+}
+
+// Reload reopens the named database from its path and returns the new
+// reader, e.g. after a stale file handle error. The old reader is left for
+// the garbage collector, as on other reloads.
+func (m *Manager) Reload(name string) (*maxminddb.Reader, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	path, exists := m.displayToPath[name]
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrDatabaseNotFound, name)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat file %s: %w", path, err)
+	}
+	if err := m.loadDatabase(path, info); err != nil {
+		return nil, err
+	}
+	return m.readers[path], nil
+}
+
+// WithReader calls op with the reader of the named database. If op fails
+// with a stale file handle error, the database is reloaded from its path and
+// op is retried once with the new reader.
+func (m *Manager) WithReader(name string, op func(*maxminddb.Reader) error) error {
+	reader, exists := m.GetReader(name)
+	if !exists {
+		return fmt.Errorf("%w: %s", ErrDatabaseNotFound, name)
+	}
+
+	err := op(reader)
+	if !IsStaleFileHandle(err) {
+		return err
+	}
+
+	slog.Warn("Stale file handle, reloading database", "name", name, "err", err)
+	reader, reloadErr := m.Reload(name)
+	if reloadErr != nil {
+		return fmt.Errorf("failed to reload database after %w: %w", err, reloadErr)
+	}
+	return op(reader)
+}
@@ -0,0 +1,83 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"syscall"
+	"testing"
+
+	"github.com/oschwald/maxminddb-golang/v2"
+)
+
+func TestIsStaleFileHandle(t *testing.T) {
+	stale := &fs.PathError{Op: "read", Path: "/mnt/nfs/GeoIP2-City.mmdb", Err: syscall.ESTALE}
+	if !IsStaleFileHandle(fmt.Errorf("decoding failed: %w", stale)) {
+		t.Error("Expected a wrapped ESTALE error to be detected")
+	}
+	if IsStaleFileHandle(errors.New("some other error")) || IsStaleFileHandle(nil) {
+		t.Error("Expected other errors not to be detected")
+	}
+}
+
+func TestWithReaderReloadsStaleDatabase(t *testing.T) {
+	manager, err := New()
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer func() { _ = manager.Close() }()
+
+	if err := manager.LoadDatabase(testDBPath); err != nil {
+		t.Fatalf("Failed to load test database: %v", err)
+	}
+	original, _ := manager.GetReader(testDBName)
+
+	// The first call fails as if the file had been replaced on an NFS
+	// server while it was open.
+	var readers []*maxminddb.Reader
+	err = manager.WithReader(testDBName, func(reader *maxminddb.Reader) error {
+		readers = append(readers, reader)
+		if len(readers) == 1 {
+			return fmt.Errorf("lookup failed: %w", &fs.PathError{Op: "read", Err: syscall.ESTALE})
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected the retry to succeed, got %v", err)
+	}
+	if len(readers) != 2 {
+		t.Fatalf("Expected 2 calls, got %d", len(readers))
+	}
+	if readers[0] != original || readers[1] == original {
+		t.Error("Expected the retry to use a fresh reader")
+	}
+	if current, _ := manager.GetReader(testDBName); current != readers[1] {
+		t.Error("Expected the fresh reader to replace the stale one")
+	}
+
+	// Other errors are returned without a retry.
+	calls := 0
+	errOther := errors.New("other failure")
+	err = manager.WithReader(testDBName, func(*maxminddb.Reader) error {
+		calls++
+		return errOther
+	})
+	if !errors.Is(err, errOther) || calls != 1 {
+		t.Errorf("Expected one call returning the error, got %d calls and %v", calls, err)
+	}
+
+	// A database that stays stale fails after one retry.
+	calls = 0
+	err = manager.WithReader(testDBName, func(*maxminddb.Reader) error {
+		calls++
+		return syscall.ESTALE
+	})
+	if !IsStaleFileHandle(err) || calls != 2 {
+		t.Errorf("Expected two calls returning ESTALE, got %d calls and %v", calls, err)
+	}
+
+	err = manager.WithReader("missing.mmdb", func(*maxminddb.Reader) error { return nil })
+	if !errors.Is(err, ErrDatabaseNotFound) {
+		t.Errorf("Expected ErrDatabaseNotFound, got %v", err)
+	}
+}
@@ -10,8 +10,10 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/maxmind/geoipupdate/v7/client"
@@ -27,13 +29,51 @@ type UpdateResult struct {
 	Updated    bool      `json:"updated"`
 }
 
+// Circuit breaker settings for scheduled updates.
+const (
+	// breakerThreshold is the number of consecutive failed update runs
+	// after which scheduled updates are paused.
+	breakerThreshold = 3
+	// breakerCooldownIntervals is the pause length, in update intervals.
+	breakerCooldownIntervals = 4
+)
+
+// BreakerStatus describes the state of the circuit breaker of the editions
+// sharing one update interval.
+type BreakerStatus struct {
+	OpenUntil           time.Time `json:"open_until,omitzero"`
+	Interval            string    `json:"interval"`
+	LastError           string    `json:"last_error,omitempty"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	Open                bool      `json:"open"`
+}
+
+// breakerState is the circuit breaker state of one update interval.
+type breakerState struct {
+	openUntil           time.Time
+	lastError           string
+	consecutiveFailures int
+}
+
 // Updater handles downloading and updating MaxMind databases.
 type Updater struct {
 	config    *config.Config
 	client    *client.Client
 	manager   *Manager
 	checksums map[string]string
-	mu        sync.RWMutex
+	now       func() time.Time
+	newTicker func(d time.Duration) (<-chan time.Time, func())
+	freeSpace func(dir string) (uint64, error)
+	rename    func(oldPath, newPath string) error
+	// breakers holds the circuit breaker of each update interval. It is
+	// guarded by breakerMu.
+	breakers map[time.Duration]*breakerState
+	// editionIDs and databaseDir start from the config and change when
+	// GeoIP.conf is reloaded. They are guarded by mu.
+	editionIDs  []string
+	databaseDir string
+	mu          sync.RWMutex
+	breakerMu   sync.Mutex
 }
 
 // NewUpdater creates a new database updater.
@@ -47,16 +87,27 @@ func NewUpdater(cfg *config.Config, manager *Manager) (*Updater, error) {
 		cfg.MaxMind.AccountID,
 		cfg.MaxMind.LicenseKey,
 		client.WithEndpoint(cfg.MaxMind.Endpoint),
+		client.WithHTTPClient(newHTTPClient(
+			cfg.UpdateConnectTimeoutDuration,
+			cfg.UpdateReadTimeoutDuration,
+		)),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create MaxMind client: %w", err)
 	}
 
 	updater := &Updater{
-		config:    cfg,
-		client:    &mclient,
-		manager:   manager,
-		checksums: make(map[string]string),
+		config:      cfg,
+		client:      &mclient,
+		manager:     manager,
+		checksums:   make(map[string]string),
+		now:         time.Now,
+		newTicker:   newTimeTicker,
+		freeSpace:   freeDiskSpace,
+		rename:      os.Rename,
+		breakers:    make(map[time.Duration]*breakerState),
+		editionIDs:  slices.Clone(cfg.MaxMind.Editions),
+		databaseDir: cfg.MaxMind.DatabaseDir,
 	}
 
 	// Load existing checksums
@@ -67,20 +118,81 @@ func NewUpdater(cfg *config.Config, manager *Manager) (*Updater, error) {
 
 // UpdateAll updates all configured databases.
 func (u *Updater) UpdateAll(ctx context.Context) ([]UpdateResult, error) {
+	return u.UpdateAllWithProgress(ctx, nil)
+}
+
+// ProgressFunc is called by UpdateAllWithProgress with the result of each
+// edition as soon as it completes. done is the number of completed editions
+// out of total.
+type ProgressFunc func(done, total int, result UpdateResult)
+
+// UpdateAllWithProgress updates all configured databases like UpdateAll,
+// calling progress after each edition. progress may be nil. It is called
+// with the updater lock held, so it must not call back into the updater.
+func (u *Updater) UpdateAllWithProgress(ctx context.Context, progress ProgressFunc) ([]UpdateResult, error) {
+	return u.updateEditions(ctx, func(string) bool { return true }, progress), nil
+}
+
+// updateEditions updates the configured editions for which include returns
+// true, calling progress after each one if it is not nil.
+func (u *Updater) updateEditions(
+	ctx context.Context,
+	include func(edition string) bool,
+	progress ProgressFunc,
+) []UpdateResult {
 	u.mu.Lock()
 	defer u.mu.Unlock()
 
-	results := make([]UpdateResult, 0, len(u.config.MaxMind.Editions))
+	editions := slices.DeleteFunc(slices.Clone(u.editionIDs), func(edition string) bool {
+		return !include(edition)
+	})
+	results := make([]UpdateResult, 0, len(editions))
 
-	for _, edition := range u.config.MaxMind.Editions {
+	for _, edition := range editions {
 		result := u.updateDatabase(ctx, edition)
 		results = append(results, result)
+		if progress != nil {
+			progress(len(results), len(editions), result)
+		}
 	}
 
 	// Save updated checksums
 	u.saveChecksums()
 
-	return results, nil
+	u.recordOutcome(results)
+
+	return results
+}
+
+// InitialUpdate runs UpdateAll to fetch missing databases at startup, waiting
+// at most timeout for it. If the update takes longer, InitialUpdate returns
+// false and the update continues in the background until it completes or ctx
+// is canceled, so a slow link does not hold up startup.
+func (u *Updater) InitialUpdate(ctx context.Context, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		if _, err := u.UpdateAll(ctx); err != nil {
+			slog.Warn("Initial database update failed", "err", err)
+		}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		slog.Warn(
+			"Initial database update is taking longer than the startup timeout; "+
+				"continuing startup while it finishes in the background",
+			"timeout", timeout,
+		)
+		return false
+	case <-ctx.Done():
+		return false
+	}
 }
 
 // UpdateDatabase updates a specific database.
@@ -94,44 +206,174 @@ func (u *Updater) UpdateDatabase(ctx context.Context, edition string) (UpdateRes
 	return result, nil
 }
 
-// StartScheduledUpdates starts a goroutine that periodically updates databases.
+// StartScheduledUpdates starts goroutines that periodically update
+// databases. Each distinct update interval gets its own ticker, so editions
+// listed in edition_intervals update independently of the rest, which use
+// update_interval.
 func (u *Updater) StartScheduledUpdates(ctx context.Context) {
 	if !u.config.AutoUpdate {
 		return
 	}
 
-	go func() {
-		ticker := time.NewTicker(u.config.UpdateIntervalDuration)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ctx.Done():
-				return
-			case <-ticker.C:
-				results, err := u.UpdateAll(ctx)
-				if err != nil {
-					slog.Error("Scheduled update failed", "err", err)
-					continue
-				}
-
-				// Log update results
-				for _, result := range results {
-					if result.Error != "" {
-						slog.Error(
-							"Database update error",
-							"edition",
-							result.Database,
-							"error",
-							result.Error,
-						)
-					} else if result.Updated {
-						slog.Info("Database updated", "edition", result.Database, "size", result.Size)
-					}
+	for _, interval := range u.scheduleIntervals() {
+		go func() {
+			ticks, stop := u.newTicker(interval)
+			defer stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticks:
+					u.runScheduledUpdate(ctx, interval)
 				}
 			}
+		}()
+	}
+}
+
+// scheduleIntervals returns the distinct update intervals: update_interval
+// followed by the edition_intervals values, sorted.
+func (u *Updater) scheduleIntervals() []time.Duration {
+	var overrides []time.Duration
+	for _, interval := range u.config.MaxMind.EditionIntervalDurations {
+		if interval != u.config.UpdateIntervalDuration && !slices.Contains(overrides, interval) {
+			overrides = append(overrides, interval)
 		}
-	}()
+	}
+	slices.Sort(overrides)
+	return append([]time.Duration{u.config.UpdateIntervalDuration}, overrides...)
+}
+
+// editionInterval returns the update interval of edition.
+func (u *Updater) editionInterval(edition string) time.Duration {
+	if interval, ok := u.config.MaxMind.EditionIntervalDurations[edition]; ok {
+		return interval
+	}
+	return u.config.UpdateIntervalDuration
+}
+
+// runScheduledUpdate updates the editions scheduled every interval unless the
+// circuit breaker of that interval is open.
+func (u *Updater) runScheduledUpdate(ctx context.Context, interval time.Duration) {
+	if status := u.BreakerStatus(interval); status.Open {
+		slog.Debug("Skipping scheduled update while circuit breaker is open",
+			"interval", interval,
+			"open_until", status.OpenUntil)
+		return
+	}
+
+	results := u.updateEditions(ctx, func(edition string) bool {
+		return u.editionInterval(edition) == interval
+	}, nil)
+
+	// Log update results
+	for _, result := range results {
+		if result.Error != "" {
+			slog.Error(
+				"Database update error",
+				"edition",
+				result.Database,
+				"error",
+				result.Error,
+			)
+		} else if result.Updated {
+			slog.Info("Database updated", "edition", result.Database, "size", result.Size)
+		}
+	}
+}
+
+// BreakerStatus returns the current state of the circuit breaker of the
+// editions updated every interval.
+func (u *Updater) BreakerStatus(interval time.Duration) BreakerStatus {
+	u.breakerMu.Lock()
+	defer u.breakerMu.Unlock()
+
+	status := BreakerStatus{Interval: interval.String()}
+	breaker, ok := u.breakers[interval]
+	if !ok {
+		return status
+	}
+	status.ConsecutiveFailures = breaker.consecutiveFailures
+	status.LastError = breaker.lastError
+	if u.now().Before(breaker.openUntil) {
+		status.Open = true
+		status.OpenUntil = breaker.openUntil
+	}
+	return status
+}
+
+// BreakerStatuses returns the state of the circuit breaker of each update
+// interval, in the order of scheduleIntervals.
+func (u *Updater) BreakerStatuses() []BreakerStatus {
+	intervals := u.scheduleIntervals()
+	statuses := make([]BreakerStatus, 0, len(intervals))
+	for _, interval := range intervals {
+		statuses = append(statuses, u.BreakerStatus(interval))
+	}
+	return statuses
+}
+
+// recordOutcome updates the circuit breakers after an update run. Each update
+// interval has its own breaker, fed by the results of the editions updated
+// at that interval. A run fails for an interval when every such edition
+// failed. After breakerThreshold consecutive failed runs, scheduled updates
+// at that interval are paused for breakerCooldownIntervals of the interval.
+// Any successful run resets the breaker.
+func (u *Updater) recordOutcome(results []UpdateResult) {
+	groups := make(map[time.Duration][]UpdateResult)
+	for _, result := range results {
+		interval := u.editionInterval(result.Database)
+		groups[interval] = append(groups[interval], result)
+	}
+
+	u.breakerMu.Lock()
+	defer u.breakerMu.Unlock()
+
+	for interval, group := range groups {
+		u.recordIntervalOutcome(interval, group)
+	}
+}
+
+// recordIntervalOutcome updates the circuit breaker of interval with the
+// non-empty results of its editions. It must be called with breakerMu held.
+func (u *Updater) recordIntervalOutcome(interval time.Duration, results []UpdateResult) {
+	breaker, ok := u.breakers[interval]
+	if !ok {
+		breaker = &breakerState{}
+		u.breakers[interval] = breaker
+	}
+
+	lastError := ""
+	for _, result := range results {
+		if result.Error == "" {
+			*breaker = breakerState{}
+			return
+		}
+		lastError = result.Error
+	}
+
+	breaker.consecutiveFailures++
+	breaker.lastError = lastError
+
+	if breaker.consecutiveFailures < breakerThreshold || u.now().Before(breaker.openUntil) {
+		return
+	}
+
+	cooldown := breakerCooldownIntervals * interval
+	breaker.openUntil = u.now().Add(cooldown)
+	slog.Warn("Pausing scheduled updates after repeated failures",
+		"interval", interval,
+		"consecutive_failures", breaker.consecutiveFailures,
+		"cooldown", cooldown,
+		"last_error", lastError,
+	)
+}
+
+// newTimeTicker returns the channel and stop function of a new time.Ticker.
+func newTimeTicker(d time.Duration) (<-chan time.Time, func()) {
+	ticker := time.NewTicker(d)
+	return ticker.C, ticker.Stop
 }
 
 // updateDatabase performs the actual update (must be called with lock held).
@@ -158,14 +400,35 @@ func (u *Updater) updateDatabase(ctx context.Context, edition string) UpdateResu
 	}
 
 	// Create database directory if it doesn't exist
-	if err := os.MkdirAll(u.config.MaxMind.DatabaseDir, 0o750); err != nil {
+	if err := os.MkdirAll(u.databaseDir, 0o750); err != nil {
 		result.Error = fmt.Sprintf("failed to create database directory: %v", err)
 		return result
 	}
 
-	// Write to temporary file first
-	dbPath := filepath.Join(u.config.MaxMind.DatabaseDir, edition+".mmdb")
-	tempPath := dbPath + ".tmp"
+	dbPath := filepath.Join(u.databaseDir, edition+".mmdb")
+
+	// Write to a temporary file in the staging directory first
+	stagingDir := u.config.MaxMind.StagingDir
+	if stagingDir == "" {
+		stagingDir = u.databaseDir
+	}
+	if err := os.MkdirAll(stagingDir, 0o750); err != nil {
+		result.Error = fmt.Sprintf("failed to create staging directory: %v", err)
+		return result
+	}
+
+	// The download lands in the staging directory. A staging directory on
+	// another filesystem also needs room for a copy in the database
+	// directory, see moveIntoPlace.
+	checkDirs := []string{stagingDir}
+	if stagingDir != u.databaseDir {
+		checkDirs = append(checkDirs, u.databaseDir)
+	}
+	if err := u.checkFreeSpace(dbPath, checkDirs); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	tempPath := filepath.Join(stagingDir, edition+".mmdb.tmp")
 
 	file, err := os.Create(tempPath)
 	if err != nil {
@@ -199,8 +462,19 @@ func (u *Updater) updateDatabase(ctx context.Context, edition string) UpdateResu
 		return result
 	}
 
+	// Never replace a protected file, e.g. a manually placed database
+	// whose name collides with the edition.
+	if u.isProtected(dbPath) {
+		_ = os.Remove(tempPath)
+		result.Error = fmt.Sprintf(
+			"refusing to overwrite %s: it is listed in protected_databases",
+			dbPath,
+		)
+		return result
+	}
+
 	// Atomically replace the old file
-	if err := os.Rename(tempPath, dbPath); err != nil {
+	if err := u.moveIntoPlace(tempPath, dbPath); err != nil {
 		_ = os.Remove(tempPath)
 		result.Error = fmt.Sprintf("failed to replace database file: %v", err)
 		return result
@@ -221,9 +495,123 @@ func (u *Updater) updateDatabase(ctx context.Context, edition string) UpdateResu
 	return result
 }
 
+// moveIntoPlace atomically replaces the database at dbPath with the
+// downloaded file at tempPath. A staging_dir on another filesystem cannot be
+// renamed from, so on EXDEV the file is first copied next to dbPath and
+// renamed from there.
+func (u *Updater) moveIntoPlace(tempPath, dbPath string) error {
+	err := u.rename(tempPath, dbPath)
+	if !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+
+	copyPath := dbPath + ".tmp"
+	if err := copyFile(tempPath, copyPath); err != nil {
+		_ = os.Remove(copyPath)
+		return err
+	}
+	if err := u.rename(copyPath, dbPath); err != nil {
+		_ = os.Remove(copyPath)
+		return err
+	}
+	_ = os.Remove(tempPath)
+	return nil
+}
+
+// copyFile copies the file at src to a new file at dst and syncs it to disk.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// isProtected reports whether the database file at path is listed in
+// protected_databases, by edition ID, file name, or path.
+func (u *Updater) isProtected(path string) bool {
+	name := filepath.Base(path)
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		absPath = path
+	}
+
+	for _, entry := range u.config.MaxMind.ProtectedDatabases {
+		if entry == name || entry+".mmdb" == name {
+			return true
+		}
+		if absEntry, err := filepath.Abs(entry); err == nil && absEntry == absPath {
+			return true
+		}
+	}
+	return false
+}
+
+// checkFreeSpace returns an error if the filesystem holding any of dirs has
+// less free space than min_free_space_factor times the size of the current
+// database at dbPath, so that a nearly full disk does not leave a truncated
+// download behind. Databases that do not exist yet are not checked, as their
+// size is unknown until downloaded.
+func (u *Updater) checkFreeSpace(dbPath string, dirs []string) error {
+	factor := u.config.MinFreeSpaceFactor
+	if factor <= 0 {
+		return nil
+	}
+	info, err := os.Stat(dbPath)
+	if err != nil {
+		return nil //nolint:nilerr // No size estimate without a current database
+	}
+	required := uint64(factor * float64(info.Size()))
+
+	for _, dir := range dirs {
+		free, err := u.freeSpace(dir)
+		if err != nil {
+			if !errors.Is(err, errFreeSpaceUnsupported) {
+				slog.Warn("Failed to check free disk space", "dir", dir, "err", err)
+			}
+			continue
+		}
+		if free < required {
+			return fmt.Errorf(
+				"insufficient disk space in %s: %d bytes free, %d required (%g times the current database size)",
+				dir,
+				free,
+				required,
+				factor,
+			)
+		}
+	}
+	return nil
+}
+
+// checksumPath returns the path of the checksum file. It is stored in the
+// state directory if one is configured and in the database directory
+// otherwise.
+func (u *Updater) checksumPath() string {
+	dir := u.config.MaxMind.StateDir
+	if dir == "" {
+		dir = u.databaseDir
+	}
+	return filepath.Join(dir, ".checksums")
+}
+
 // loadChecksums loads existing MD5 checksums from file.
 func (u *Updater) loadChecksums() {
-	checksumFile := filepath.Join(u.config.MaxMind.DatabaseDir, ".checksums")
+	checksumFile := u.checksumPath()
 
 	data, err := os.ReadFile(checksumFile)
 	if err != nil {
@@ -248,7 +636,7 @@ func (u *Updater) loadChecksums() {
 
 // saveChecksums saves current MD5 checksums to file.
 func (u *Updater) saveChecksums() {
-	checksumFile := filepath.Join(u.config.MaxMind.DatabaseDir, ".checksums")
+	checksumFile := u.checksumPath()
 
 	// Create directory if needed
 	if err := os.MkdirAll(filepath.Dir(checksumFile), 0o750); err != nil {
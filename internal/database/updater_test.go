@@ -1,10 +1,20 @@
 package database
 
 import (
+	"archive/tar"
+	"compress/gzip"
 	"context"
+	"crypto/md5" //nolint:gosec // MD5 used for file integrity checksums, not cryptographic security
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"slices"
+	"sync"
+	"syscall"
 	"testing"
 	"time"
 
@@ -190,6 +200,125 @@ func TestStartScheduledUpdates(t *testing.T) {
 	updater2.StartScheduledUpdates(ctx)
 }
 
+// fakeClock drives updater tickers from tests. Advance fires each ticker
+// whose period has elapsed.
+type fakeClock struct {
+	tickers []*fakeTicker
+	now     time.Duration
+	mu      sync.Mutex
+}
+
+type fakeTicker struct {
+	c      chan time.Time
+	period time.Duration
+	next   time.Duration
+}
+
+func (c *fakeClock) newTicker(d time.Duration) (<-chan time.Time, func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ticker := &fakeTicker{c: make(chan time.Time, 1), period: d, next: c.now + d}
+	c.tickers = append(c.tickers, ticker)
+	return ticker.c, func() {}
+}
+
+func (c *fakeClock) tickerCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.tickers)
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now += d
+	for _, ticker := range c.tickers {
+		for ticker.next <= c.now {
+			select {
+			case ticker.c <- time.Time{}:
+			default:
+			}
+			ticker.next += ticker.period
+		}
+	}
+}
+
+func TestScheduledUpdatesPerEditionInterval(t *testing.T) {
+	requested := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		edition := r.URL.Query().Get("edition_id")
+		requested <- edition
+		// An empty MD5 matches the missing checksum, so no download follows.
+		_, _ = w.Write([]byte(`{"databases":[{"edition_id":"` + edition + `","md5":"","date":"2025-01-01"}]}`))
+	}))
+	defer server.Close()
+
+	cfg := createTestConfig(t)
+	cfg.AutoUpdate = true
+	cfg.UpdateIntervalDuration = 3 * time.Hour
+	cfg.MaxMind.Endpoint = server.URL
+	cfg.MaxMind.EditionIntervalDurations = map[string]time.Duration{"GeoLite2-City": time.Hour}
+
+	manager, err := New()
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer func() { _ = manager.Close() }()
+
+	updater, err := NewUpdater(cfg, manager)
+	if err != nil {
+		t.Fatalf("Failed to create updater: %v", err)
+	}
+	clock := &fakeClock{}
+	updater.newTicker = clock.newTicker
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updater.StartScheduledUpdates(ctx)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for clock.tickerCount() < 2 {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected 2 tickers, got %d", clock.tickerCount())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	expectUpdates := func(expected ...string) {
+		t.Helper()
+		var got []string
+		for range expected {
+			select {
+			case edition := <-requested:
+				got = append(got, edition)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("Expected updates of %v, got %v", expected, got)
+			}
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, expected) {
+			t.Errorf("Expected updates of %v, got %v", expected, got)
+		}
+	}
+
+	// The city edition updates hourly while the country edition waits for
+	// the global interval.
+	clock.Advance(time.Hour)
+	expectUpdates("GeoLite2-City")
+	clock.Advance(time.Hour)
+	expectUpdates("GeoLite2-City")
+	clock.Advance(time.Hour)
+	expectUpdates("GeoLite2-City", "GeoLite2-Country")
+
+	select {
+	case edition := <-requested:
+		t.Errorf("Unexpected update of %s", edition)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
 func TestLoadChecksums(t *testing.T) {
 	cfg := createTestConfig(t)
 	manager, err := New()
@@ -274,6 +403,45 @@ func TestSaveChecksums(t *testing.T) {
 	}
 }
 
+func TestSaveChecksumsToStateDir(t *testing.T) {
+	cfg := createTestConfig(t)
+	cfg.MaxMind.StateDir = filepath.Join(t.TempDir(), "state", "nested")
+	manager, err := New()
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer func() { _ = manager.Close() }()
+
+	updater, err := NewUpdater(cfg, manager)
+	if err != nil {
+		t.Fatalf("Failed to create updater: %v", err)
+	}
+
+	updater.mu.Lock()
+	updater.checksums["GeoLite2-City"] = "test1234"
+	updater.mu.Unlock()
+
+	updater.saveChecksums()
+
+	checksumFile := filepath.Join(cfg.MaxMind.StateDir, ".checksums")
+	if _, err := os.Stat(checksumFile); err != nil {
+		t.Fatalf("Checksum file should have been created in state dir: %v", err)
+	}
+	dbChecksumFile := filepath.Join(cfg.MaxMind.DatabaseDir, ".checksums")
+	if _, err := os.Stat(dbChecksumFile); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Checksum file should not be written to database dir, got err %v", err)
+	}
+
+	// A new updater should load the checksums from the state dir.
+	reloaded, err := NewUpdater(cfg, manager)
+	if err != nil {
+		t.Fatalf("Failed to create updater: %v", err)
+	}
+	if got := reloaded.checksums["GeoLite2-City"]; got != "test1234" {
+		t.Errorf("Expected checksum test1234 from state dir, got %q", got)
+	}
+}
+
 func TestUpdateResult(t *testing.T) {
 	// Test UpdateResult struct
 	result := UpdateResult{
@@ -353,6 +521,196 @@ func TestUpdaterConcurrency(t *testing.T) {
 	// Should not deadlock or panic
 }
 
+func TestUpdateAllWithProgress(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, `{"code":"AUTHORIZATION_INVALID","error":"invalid"}`, http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	cfg := createTestConfig(t)
+	cfg.MaxMind.Endpoint = server.URL
+
+	manager, err := New()
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer func() { _ = manager.Close() }()
+
+	updater, err := NewUpdater(cfg, manager)
+	if err != nil {
+		t.Fatalf("Failed to create updater: %v", err)
+	}
+
+	var reported []UpdateResult
+	results, err := updater.UpdateAllWithProgress(
+		context.Background(),
+		func(done, total int, result UpdateResult) {
+			reported = append(reported, result)
+			if done != len(reported) || total != len(cfg.MaxMind.Editions) {
+				t.Errorf("Expected progress %d/%d, got %d/%d", len(reported), len(cfg.MaxMind.Editions), done, total)
+			}
+		},
+	)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(reported) != len(cfg.MaxMind.Editions) {
+		t.Fatalf("Expected progress for each of %d editions, got %d", len(cfg.MaxMind.Editions), len(reported))
+	}
+	for i, result := range reported {
+		if result != results[i] {
+			t.Errorf("Expected progress %d to report %+v, got %+v", i, results[i], result)
+		}
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests++
+		http.Error(w, `{"code":"AUTHORIZATION_INVALID","error":"invalid"}`, http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	cfg := createTestConfig(t)
+	cfg.MaxMind.Endpoint = server.URL
+
+	manager, err := New()
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer func() { _ = manager.Close() }()
+
+	updater, err := NewUpdater(cfg, manager)
+	if err != nil {
+		t.Fatalf("Failed to create updater: %v", err)
+	}
+
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	updater.now = func() time.Time { return now }
+
+	ctx := context.Background()
+
+	// Failures below the threshold keep the breaker closed.
+	for i := 1; i < breakerThreshold; i++ {
+		updater.runScheduledUpdate(ctx, cfg.UpdateIntervalDuration)
+		status := updater.BreakerStatus(cfg.UpdateIntervalDuration)
+		if status.Open {
+			t.Fatalf("Breaker should be closed after %d failures", i)
+		}
+		if status.ConsecutiveFailures != i {
+			t.Errorf("Expected %d consecutive failures, got %d", i, status.ConsecutiveFailures)
+		}
+	}
+
+	updater.runScheduledUpdate(ctx, cfg.UpdateIntervalDuration)
+	status := updater.BreakerStatus(cfg.UpdateIntervalDuration)
+	if !status.Open {
+		t.Fatalf("Breaker should be open after %d failures", breakerThreshold)
+	}
+	expectedUntil := now.Add(breakerCooldownIntervals * cfg.UpdateIntervalDuration)
+	if !status.OpenUntil.Equal(expectedUntil) {
+		t.Errorf("Expected breaker open until %v, got %v", expectedUntil, status.OpenUntil)
+	}
+	if status.LastError == "" {
+		t.Error("Expected last error to be recorded")
+	}
+
+	// While open, scheduled updates do not contact the endpoint.
+	before := requests
+	now = now.Add(cfg.UpdateIntervalDuration)
+	updater.runScheduledUpdate(ctx, cfg.UpdateIntervalDuration)
+	if requests != before {
+		t.Errorf("Expected no requests while breaker is open, got %d", requests-before)
+	}
+
+	// After the cooldown, updates are attempted again.
+	now = expectedUntil
+	updater.runScheduledUpdate(ctx, cfg.UpdateIntervalDuration)
+	if requests == before {
+		t.Error("Expected update attempt after cooldown")
+	}
+	if !updater.BreakerStatus(cfg.UpdateIntervalDuration).Open {
+		t.Error("Breaker should reopen when the update after cooldown fails")
+	}
+
+	// A successful run resets the breaker.
+	updater.recordOutcome([]UpdateResult{{Database: "GeoLite2-City"}})
+	status = updater.BreakerStatus(cfg.UpdateIntervalDuration)
+	if status.Open || status.ConsecutiveFailures != 0 || status.LastError != "" {
+		t.Errorf("Expected breaker to reset after success, got %+v", status)
+	}
+}
+
+func TestCircuitBreakerPerInterval(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		requests++
+		http.Error(w, `{"code":"AUTHORIZATION_INVALID","error":"invalid"}`, http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	weekly := 7 * 24 * time.Hour
+	cfg := createTestConfig(t)
+	cfg.MaxMind.Endpoint = server.URL
+	cfg.MaxMind.EditionIntervalDurations = map[string]time.Duration{"GeoLite2-Country": weekly}
+
+	manager, err := New()
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer func() { _ = manager.Close() }()
+
+	updater, err := NewUpdater(cfg, manager)
+	if err != nil {
+		t.Fatalf("Failed to create updater: %v", err)
+	}
+
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	updater.now = func() time.Time { return now }
+
+	// Successful weekly runs do not reset the failures of the daily group.
+	for range breakerThreshold {
+		updater.recordOutcome([]UpdateResult{
+			{Database: "GeoLite2-City", Error: "download failed"},
+			{Database: "GeoLite2-Country"},
+		})
+	}
+
+	daily := updater.BreakerStatus(cfg.UpdateIntervalDuration)
+	if !daily.Open {
+		t.Fatalf("Expected daily breaker to open, got %+v", daily)
+	}
+	expectedUntil := now.Add(breakerCooldownIntervals * cfg.UpdateIntervalDuration)
+	if !daily.OpenUntil.Equal(expectedUntil) {
+		t.Errorf("Expected daily breaker open until %v, got %v", expectedUntil, daily.OpenUntil)
+	}
+	if status := updater.BreakerStatus(weekly); status.Open || status.ConsecutiveFailures != 0 {
+		t.Errorf("Expected weekly breaker to stay closed, got %+v", status)
+	}
+
+	// The open daily breaker does not pause the weekly group.
+	updater.runScheduledUpdate(context.Background(), weekly)
+	if requests == 0 {
+		t.Error("Expected weekly update while the daily breaker is open")
+	}
+
+	// The weekly cooldown is based on the weekly interval.
+	for range breakerThreshold {
+		updater.recordOutcome([]UpdateResult{{Database: "GeoLite2-Country", Error: "download failed"}})
+	}
+	status := updater.BreakerStatus(weekly)
+	if expected := now.Add(breakerCooldownIntervals * weekly); !status.OpenUntil.Equal(expected) {
+		t.Errorf("Expected weekly breaker open until %v, got %v", expected, status.OpenUntil)
+	}
+
+	statuses := updater.BreakerStatuses()
+	if len(statuses) != 2 || statuses[0].Interval != "24h0m0s" || statuses[1].Interval != "168h0m0s" {
+		t.Errorf("Expected daily and weekly breaker statuses, got %+v", statuses)
+	}
+}
+
 // Helper functions
 
 func createTestConfig(t *testing.T) *config.Config {
@@ -387,3 +745,392 @@ func containsMiddle(s, substr string) bool {
 	}
 	return false
 }
+
+func TestUpdateStalledDownloadTimesOut(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if contains(r.URL.Path, "/metadata") {
+			_, _ = w.Write([]byte(
+				`{"databases":[{"edition_id":"GeoLite2-City","md5":"new","date":"2025-01-01"}]}`,
+			))
+			return
+		}
+
+		// Send the headers, then stall until the client gives up.
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	cfg := createTestConfig(t)
+	cfg.MaxMind.Endpoint = server.URL
+	cfg.UpdateReadTimeoutDuration = 100 * time.Millisecond
+
+	manager, err := New()
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer func() { _ = manager.Close() }()
+
+	updater, err := NewUpdater(cfg, manager)
+	if err != nil {
+		t.Fatalf("Failed to create updater: %v", err)
+	}
+
+	done := make(chan UpdateResult, 1)
+	go func() {
+		result, _ := updater.UpdateDatabase(context.Background(), "GeoLite2-City")
+		done <- result
+	}()
+
+	select {
+	case result := <-done:
+		if !contains(result.Error, "no data received") {
+			t.Errorf("Expected stall timeout error, got %q", result.Error)
+		}
+		if result.Updated {
+			t.Error("Stalled download should not be reported as updated")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Stalled download did not time out")
+	}
+}
+
+func TestInitialUpdateTimesOut(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Respond slowly, as on a slow link.
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	cfg := createTestConfig(t)
+	cfg.MaxMind.Endpoint = server.URL
+
+	manager, err := New()
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer func() { _ = manager.Close() }()
+
+	updater, err := NewUpdater(cfg, manager)
+	if err != nil {
+		t.Fatalf("Failed to create updater: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	start := time.Now()
+	if updater.InitialUpdate(ctx, 100*time.Millisecond) {
+		t.Error("Expected the initial update not to finish within the timeout")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected startup within the timeout, took %v", elapsed)
+	}
+
+	// The update keeps running in the background and finishes once the
+	// server responds.
+	close(release)
+	deadline := time.Now().Add(10 * time.Second)
+	for updater.BreakerStatus(cfg.UpdateIntervalDuration).ConsecutiveFailures == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Background update did not finish")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestInitialUpdateFinishesWithinTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	cfg := createTestConfig(t)
+	cfg.MaxMind.Endpoint = server.URL
+
+	manager, err := New()
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer func() { _ = manager.Close() }()
+
+	updater, err := NewUpdater(cfg, manager)
+	if err != nil {
+		t.Fatalf("Failed to create updater: %v", err)
+	}
+
+	if !updater.InitialUpdate(context.Background(), 10*time.Second) {
+		t.Error("Expected the initial update to finish within the timeout")
+	}
+}
+
+// newDownloadServer returns a test update server that serves content as the
+// GeoLite2-City edition.
+func newDownloadServer(t *testing.T, content []byte) *httptest.Server {
+	sum := md5.Sum(content) //nolint:gosec // Matches the updater's checksum
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if contains(r.URL.Path, "/metadata") {
+			_, _ = w.Write([]byte(
+				`{"databases":[{"edition_id":"GeoLite2-City","md5":"` +
+					hex.EncodeToString(sum[:]) + `","date":"2025-01-01"}]}`,
+			))
+			return
+		}
+
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		gz := gzip.NewWriter(w)
+		tw := tar.NewWriter(gz)
+		_ = tw.WriteHeader(&tar.Header{Name: "GeoLite2-City.mmdb", Mode: 0o644, Size: int64(len(content))})
+		_, _ = tw.Write(content)
+		_ = tw.Close()
+		_ = gz.Close()
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestUpdateChecksFreeDiskSpace(t *testing.T) {
+	content := []byte("new database content")
+	server := newDownloadServer(t, content)
+
+	cfg := createTestConfig(t)
+	cfg.MaxMind.Endpoint = server.URL
+	cfg.MinFreeSpaceFactor = 2
+
+	manager, err := New()
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer func() { _ = manager.Close() }()
+
+	updater, err := NewUpdater(cfg, manager)
+	if err != nil {
+		t.Fatalf("Failed to create updater: %v", err)
+	}
+
+	dbPath := filepath.Join(cfg.MaxMind.DatabaseDir, "GeoLite2-City.mmdb")
+	current := make([]byte, 1000)
+	if err := os.WriteFile(dbPath, current, 0o600); err != nil {
+		t.Fatalf("Failed to write database: %v", err)
+	}
+
+	// Less than twice the current database size is free.
+	var checkedDir string
+	updater.freeSpace = func(dir string) (uint64, error) {
+		checkedDir = dir
+		return 1999, nil
+	}
+
+	result, _ := updater.UpdateDatabase(context.Background(), "GeoLite2-City")
+	if !contains(result.Error, "insufficient disk space") {
+		t.Errorf("Expected insufficient disk space error, got %q", result.Error)
+	}
+	if result.Updated {
+		t.Error("Update should be skipped when disk space is low")
+	}
+	if checkedDir != cfg.MaxMind.DatabaseDir {
+		t.Errorf("Expected free space check of %s, got %q", cfg.MaxMind.DatabaseDir, checkedDir)
+	}
+	if data, err := os.ReadFile(dbPath); err != nil || len(data) != len(current) {
+		t.Errorf("Expected current database to be left alone, got %d bytes, err %v", len(data), err)
+	}
+	if _, err := os.Stat(dbPath + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("Expected no temp file, got err %v", err)
+	}
+
+	// With enough space, the update proceeds.
+	updater.freeSpace = func(string) (uint64, error) { return 2000, nil }
+	result, _ = updater.UpdateDatabase(context.Background(), "GeoLite2-City")
+	if !result.Updated {
+		t.Errorf("Expected update with enough free space, got error %q", result.Error)
+	}
+	if data, err := os.ReadFile(dbPath); err != nil || string(data) != string(content) {
+		t.Errorf("Expected updated database, got %q, err %v", data, err)
+	}
+}
+
+func TestUpdateChecksFreeDiskSpaceOfStagingDir(t *testing.T) {
+	server := newDownloadServer(t, []byte("new database content"))
+
+	for _, low := range []string{"staging", "database"} {
+		t.Run(low, func(t *testing.T) {
+			cfg := createTestConfig(t)
+			cfg.MaxMind.Endpoint = server.URL
+			cfg.MaxMind.StagingDir = filepath.Join(t.TempDir(), "staging")
+			cfg.MinFreeSpaceFactor = 2
+
+			manager, err := New()
+			if err != nil {
+				t.Fatalf("Failed to create manager: %v", err)
+			}
+			defer func() { _ = manager.Close() }()
+
+			updater, err := NewUpdater(cfg, manager)
+			if err != nil {
+				t.Fatalf("Failed to create updater: %v", err)
+			}
+
+			dbPath := filepath.Join(cfg.MaxMind.DatabaseDir, "GeoLite2-City.mmdb")
+			if err := os.WriteFile(dbPath, make([]byte, 1000), 0o600); err != nil {
+				t.Fatalf("Failed to write database: %v", err)
+			}
+
+			// Only one filesystem is low on space. The download lands in the
+			// staging directory, and a copy across filesystems needs room
+			// in the database directory too.
+			lowDir := cfg.MaxMind.StagingDir
+			if low == "database" {
+				lowDir = cfg.MaxMind.DatabaseDir
+			}
+			updater.freeSpace = func(dir string) (uint64, error) {
+				if dir == lowDir {
+					return 1999, nil
+				}
+				return 1 << 40, nil
+			}
+
+			result, _ := updater.UpdateDatabase(context.Background(), "GeoLite2-City")
+			if !contains(result.Error, "insufficient disk space in "+lowDir) {
+				t.Errorf("Expected insufficient disk space error for %s, got %q", lowDir, result.Error)
+			}
+			if result.Updated {
+				t.Error("Update should be skipped when disk space is low")
+			}
+		})
+	}
+}
+
+func TestUpdateSkipsProtectedDatabases(t *testing.T) {
+	server := newDownloadServer(t, []byte("new database content"))
+
+	for _, entry := range []string{"GeoLite2-City", "GeoLite2-City.mmdb", "path"} {
+		t.Run(entry, func(t *testing.T) {
+			cfg := createTestConfig(t)
+			cfg.MaxMind.Endpoint = server.URL
+
+			dbPath := filepath.Join(cfg.MaxMind.DatabaseDir, "GeoLite2-City.mmdb")
+			if entry == "path" {
+				entry = dbPath
+			}
+			cfg.MaxMind.ProtectedDatabases = []string{"GeoLite2-Country", entry}
+
+			manager, err := New()
+			if err != nil {
+				t.Fatalf("Failed to create manager: %v", err)
+			}
+			defer func() { _ = manager.Close() }()
+
+			updater, err := NewUpdater(cfg, manager)
+			if err != nil {
+				t.Fatalf("Failed to create updater: %v", err)
+			}
+
+			current := []byte("manually placed database")
+			if err := os.WriteFile(dbPath, current, 0o600); err != nil {
+				t.Fatalf("Failed to write database: %v", err)
+			}
+
+			result, _ := updater.UpdateDatabase(context.Background(), "GeoLite2-City")
+			if !contains(result.Error, "protected_databases") {
+				t.Errorf("Expected protected database error, got %q", result.Error)
+			}
+			if result.Updated {
+				t.Error("Protected database should not be reported as updated")
+			}
+			if data, err := os.ReadFile(dbPath); err != nil || string(data) != string(current) {
+				t.Errorf("Expected protected database to be left alone, got %q, err %v", data, err)
+			}
+			if _, err := os.Stat(dbPath + ".tmp"); !os.IsNotExist(err) {
+				t.Errorf("Expected no temp file, got err %v", err)
+			}
+			if _, ok := updater.checksums["GeoLite2-City"]; ok {
+				t.Error("Expected no checksum for the discarded download")
+			}
+		})
+	}
+}
+
+func TestUpdateUsesStagingDir(t *testing.T) {
+	content := []byte("new database content")
+	server := newDownloadServer(t, content)
+
+	for _, crossFS := range []bool{false, true} {
+		t.Run(fmt.Sprintf("cross filesystem %t", crossFS), func(t *testing.T) {
+			cfg := createTestConfig(t)
+			cfg.MaxMind.Endpoint = server.URL
+			cfg.MaxMind.StagingDir = filepath.Join(t.TempDir(), "staging")
+
+			manager, err := New()
+			if err != nil {
+				t.Fatalf("Failed to create manager: %v", err)
+			}
+			defer func() { _ = manager.Close() }()
+
+			updater, err := NewUpdater(cfg, manager)
+			if err != nil {
+				t.Fatalf("Failed to create updater: %v", err)
+			}
+
+			// Simulate a staging directory on another filesystem by failing
+			// renames between directories as the kernel would.
+			var renames []string
+			updater.rename = func(oldPath, newPath string) error {
+				renames = append(renames, oldPath)
+				if crossFS && filepath.Dir(oldPath) != filepath.Dir(newPath) {
+					return &os.LinkError{Op: "rename", Old: oldPath, New: newPath, Err: syscall.EXDEV}
+				}
+				return os.Rename(oldPath, newPath)
+			}
+
+			result, _ := updater.UpdateDatabase(context.Background(), "GeoLite2-City")
+			if !result.Updated {
+				t.Fatalf("Expected the database to be updated, got %+v", result)
+			}
+
+			dbPath := filepath.Join(cfg.MaxMind.DatabaseDir, "GeoLite2-City.mmdb")
+			if data, err := os.ReadFile(dbPath); err != nil || string(data) != string(content) {
+				t.Errorf("Expected the downloaded database at %s, got %q, err %v", dbPath, data, err)
+			}
+
+			stagedPath := filepath.Join(cfg.MaxMind.StagingDir, "GeoLite2-City.mmdb.tmp")
+			if renames[0] != stagedPath {
+				t.Errorf("Expected the download to be staged at %s, got %s", stagedPath, renames[0])
+			}
+			wantRenames := 1
+			if crossFS {
+				wantRenames = 2
+			}
+			if len(renames) != wantRenames {
+				t.Errorf("Expected %d renames, got %v", wantRenames, renames)
+			}
+			for _, path := range []string{stagedPath, dbPath + ".tmp"} {
+				if _, err := os.Stat(path); !os.IsNotExist(err) {
+					t.Errorf("Expected no leftover %s, got err %v", path, err)
+				}
+			}
+			if updater.checksums["GeoLite2-City"] == "" {
+				t.Error("Expected the checksum to be recorded")
+			}
+		})
+	}
+}
+
+func TestFreeDiskSpace(t *testing.T) {
+	free, err := freeDiskSpace(t.TempDir())
+	if errors.Is(err, errFreeSpaceUnsupported) {
+		t.Skip("free disk space is not available on this platform")
+	}
+	if err != nil {
+		t.Fatalf("Failed to get free disk space: %v", err)
+	}
+	if free == 0 {
+		t.Error("Expected some free disk space")
+	}
+}
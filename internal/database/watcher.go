@@ -0,0 +1,129 @@
+package database
+
+import (
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Watcher health states.
+const (
+	WatcherNotStarted = "not_started"
+	WatcherHealthy    = "healthy"
+	WatcherDegraded   = "degraded"
+	WatcherStopped    = "stopped"
+)
+
+// errWatcherUnavailable is reported when no file watcher could be created.
+var errWatcherUnavailable = errors.New("file watcher unavailable")
+
+// WatcherHealth describes the state of the file watcher.
+type WatcherHealth struct {
+	LastErrorTime time.Time `json:"last_error_time,omitzero"`
+	Status        string    `json:"status"`
+	LastError     string    `json:"last_error,omitempty"`
+	Errors        int       `json:"errors"`
+	Restarts      int       `json:"restarts"`
+	Polling       bool      `json:"polling"`
+}
+
+// WatcherHealth returns the current state of the file watcher.
+func (m *Manager) WatcherHealth() WatcherHealth {
+	m.healthMu.Lock()
+	defer m.healthMu.Unlock()
+	return m.health
+}
+
+// setWatcherStatus sets the watcher status without recording an error.
+func (m *Manager) setWatcherStatus(status string) {
+	m.healthMu.Lock()
+	defer m.healthMu.Unlock()
+	m.health.Status = status
+}
+
+// recordWatcherError records an error reported by the watcher. Event queue
+// overflows mean that changes may have been missed, so they degrade health.
+func (m *Manager) recordWatcherError(err error) {
+	m.healthMu.Lock()
+	defer m.healthMu.Unlock()
+
+	m.health.Errors++
+	m.health.LastError = err.Error()
+	m.health.LastErrorTime = time.Now()
+	if errors.Is(err, fsnotify.ErrEventOverflow) {
+		m.health.Status = WatcherDegraded
+	}
+}
+
+// recoverWatcher replaces a watcher that stopped unexpectedly and re-adds
+// all watches. It returns false if the manager was closed or a new watcher
+// could not be created, in which case watching has stopped.
+func (m *Manager) recoverWatcher() (*fsnotify.Watcher, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return nil, false
+	}
+
+	m.recordWatcherError(errors.New("watcher stopped unexpectedly"))
+
+	watcher, err := m.newWatcher()
+	if err != nil {
+		slog.Error("Failed to re-establish file watcher", "err", err)
+		m.recordWatcherError(err)
+		m.setWatcherStatus(WatcherStopped)
+		return nil, false
+	}
+
+	failed := false
+	for _, dir := range m.watchedDirectories() {
+		if err := watcher.Add(dir); err != nil {
+			slog.Warn("Failed to re-establish watch", "path", dir, "err", err)
+			m.recordWatcherError(err)
+			failed = true
+		}
+	}
+
+	if m.watcher != nil {
+		_ = m.watcher.Close()
+	}
+	m.watcher = watcher
+
+	m.healthMu.Lock()
+	m.health.Restarts++
+	m.health.Status = WatcherHealthy
+	if failed {
+		m.health.Status = WatcherDegraded
+	}
+	restarts := m.health.Restarts
+	m.healthMu.Unlock()
+
+	slog.Info("Re-established file watcher", "restarts", restarts)
+	return watcher, true
+}
+
+// watchedDirectories returns every directory that should be watched (must be
+// called with lock held).
+func (m *Manager) watchedDirectories() []string {
+	dirs := make([]string, 0, len(m.watchDirs)+len(m.watchFiles))
+	seen := make(map[string]bool, cap(dirs))
+	add := func(dir string) {
+		dir = filepath.Clean(dir)
+		if !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+
+	for _, dir := range m.watchDirs {
+		add(dir)
+	}
+	for path := range m.watchFiles {
+		add(filepath.Dir(path))
+	}
+	return dirs
+}
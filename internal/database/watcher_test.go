@@ -0,0 +1,112 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestWatcherHealthAfterErrorChannelClose(t *testing.T) {
+	manager, err := New()
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer func() { _ = manager.Close() }()
+
+	if status := manager.WatcherHealth().Status; status != WatcherNotStarted {
+		t.Errorf("Expected status %s before watching, got %s", WatcherNotStarted, status)
+	}
+
+	manager.newWatcher = func() (*fsnotify.Watcher, error) {
+		return nil, errors.New("too many open files")
+	}
+	manager.setWatcherStatus(WatcherHealthy)
+
+	events := make(chan fsnotify.Event)
+	errs := make(chan error)
+	done := make(chan struct{})
+	go func() {
+		manager.runWatcher(events, errs)
+		close(done)
+	}()
+
+	errs <- fsnotify.ErrEventOverflow
+	close(errs)
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Watcher goroutine did not stop")
+	}
+
+	health := manager.WatcherHealth()
+	if health.Status != WatcherStopped {
+		t.Errorf("Expected status %s, got %s", WatcherStopped, health.Status)
+	}
+	if health.Errors < 2 {
+		t.Errorf("Expected overflow and restart errors to be counted, got %d", health.Errors)
+	}
+	if health.LastError != "too many open files" {
+		t.Errorf("Expected last error from failed restart, got %q", health.LastError)
+	}
+	if health.LastErrorTime.IsZero() {
+		t.Error("Expected last error time to be set")
+	}
+}
+
+func TestWatcherRecoversAfterStop(t *testing.T) {
+	manager, err := New()
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defer func() { _ = manager.Close() }()
+
+	dir := t.TempDir()
+	if err := manager.WatchDirectory(dir); err != nil {
+		t.Fatalf("Failed to watch directory: %v", err)
+	}
+
+	events := make(chan fsnotify.Event)
+	errs := make(chan error)
+	go manager.runWatcher(events, errs)
+	close(errs)
+
+	// The new watcher must pick up databases added to the watched directory.
+	data, err := os.ReadFile(testDBPath)
+	if err != nil {
+		t.Fatalf("Failed to read test database: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for manager.WatcherHealth().Restarts == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Watcher was not re-established")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	health := manager.WatcherHealth()
+	if health.Status != WatcherHealthy {
+		t.Errorf("Expected status %s after recovery, got %s", WatcherHealthy, health.Status)
+	}
+	if health.Errors == 0 {
+		t.Error("Expected the unexpected stop to be recorded as an error")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, testDBName), data, 0o600); err != nil {
+		t.Fatalf("Failed to write test database: %v", err)
+	}
+	for {
+		if _, ok := manager.GetDatabase(testDBName); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Database was not loaded by the re-established watcher")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -2,13 +2,61 @@
 package filter
 
 import (
+	"bytes"
+	"cmp"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"math"
+	"math/big"
+	"net/netip"
 	"reflect"
 	"regexp"
+	"regexp/syntax"
+	"slices"
 	"strconv"
 	"strings"
+	"unicode"
 )
 
+// DefaultMaxRegexLength is the default maximum length of a regex pattern.
+const DefaultMaxRegexLength = 256
+
+// DefaultApproxEpsilon is the tolerance of approx_equals filters that do not
+// specify one.
+const DefaultApproxEpsilon = 1e-6
+
+// Pseudo-fields describe the network a record was found in rather than the
+// record itself. They are only available when iterating networks.
+const (
+	// PrefixField is the network of the record in CIDR notation, e.g.
+	// "81.2.69.160/27".
+	PrefixField = "__prefix__"
+	// PrefixLengthField is the prefix length of the record's network.
+	PrefixLengthField = "__prefix_length__"
+)
+
+// IsPseudoField reports whether field is a pseudo-field.
+func IsPseudoField(field string) bool {
+	return field == PrefixField || field == PrefixLengthField
+}
+
+// maxRegexProgramSize is the maximum number of instructions in a compiled
+// regex pattern.
+const maxRegexProgramSize = 10000
+
+// Limits bounds client-supplied filter values.
+type Limits struct {
+	// MaxRegexLength is the maximum length of a regex pattern. Zero or
+	// less means no limit.
+	MaxRegexLength int
+}
+
+// DefaultLimits returns the default filter limits.
+func DefaultLimits() Limits {
+	return Limits{MaxRegexLength: DefaultMaxRegexLength}
+}
+
 // Filter represents a single filter condition.
 type Filter struct {
 	Value    any    `json:"value"`
@@ -34,11 +82,14 @@ func SupportedOperators() []string {
 		"in",
 		"not_in",
 		"contains",
+		"contains_word",
 		"regex",
 		"greater_than",
 		"greater_than_or_equal",
 		"less_than",
 		"less_than_or_equal",
+		"approx_equals",
+		"within",
 		"exists",
 	}
 }
@@ -47,6 +98,12 @@ func SupportedOperators() []string {
 type Engine struct {
 	mode    Mode
 	filters []Filter
+	// sets holds the precomputed value sets of in and not_in filters, by
+	// filter index. Entries for other operators are nil.
+	sets []*valueSet
+	// order holds the filter indexes in evaluation order, cheapest operator
+	// first, so that records that fail a cheap filter never reach a regex.
+	order []int
 }
 
 // New creates a new filter engine.
@@ -54,13 +111,132 @@ func New(filters []Filter, mode Mode) *Engine {
 	if mode == "" {
 		mode = ModeAnd
 	}
+
+	sets := make([]*valueSet, len(filters))
+	for i, filter := range filters {
+		if filter.Operator != "in" && filter.Operator != "not_in" {
+			continue
+		}
+		if values, ok := filter.Value.([]any); ok {
+			sets[i] = newValueSet(values)
+		}
+	}
+
+	// Filters have no side effects, so evaluating them in any order gives
+	// the same result in both modes.
+	order := make([]int, len(filters))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int {
+		return cmp.Compare(operatorCost(filters[a].Operator), operatorCost(filters[b].Operator))
+	})
+
 	return &Engine{
 		filters: filters,
 		mode:    mode,
+		sets:    sets,
+		order:   order,
+	}
+}
+
+// operatorCost ranks operators by the relative cost of evaluating them.
+func operatorCost(operator string) int {
+	switch operator {
+	case "exists", "equals", "not_equals",
+		"greater_than", "greater_than_or_equal", "less_than", "less_than_or_equal", "approx_equals":
+		return 0
+	case "in", "not_in":
+		return 1
+	case "contains", "contains_word", "within":
+		return 2
+	case "regex":
+		// Patterns are compiled on every evaluation.
+		return 3
+	default:
+		return 0
+	}
+}
+
+// valueSet is the array of an in or not_in filter prepared for membership
+// tests. Hashable values are looked up in a map, with numbers keyed by
+// numberKey; other values, such as arrays and maps, are compared one by one.
+type valueSet struct {
+	hashed map[any]struct{}
+	other  []any
+}
+
+// newValueSet builds a valueSet from a filter array.
+func newValueSet(values []any) *valueSet {
+	set := &valueSet{hashed: make(map[any]struct{}, len(values))}
+	for _, value := range values {
+		if isHashable(value) {
+			set.hashed[setKey(value)] = struct{}{}
+		} else {
+			set.other = append(set.other, value)
+		}
+	}
+	return set
+}
+
+// contains reports whether the set holds a value deeply equal to value.
+func (s *valueSet) contains(value any) bool {
+	if isHashable(value) {
+		if _, ok := s.hashed[setKey(value)]; ok {
+			return true
+		}
+	}
+	for _, item := range s.other {
+		if compareEqual(value, item) {
+			return true
+		}
+	}
+	return false
+}
+
+// setKey returns the map key of a hashable value. Numbers of any type map to
+// their numberKey, matching compareEqual.
+func setKey(value any) any {
+	if isNumber(value) {
+		return numberKey(value)
+	}
+	return value
+}
+
+// isHashable reports whether value can be used as a map key, through setKey,
+// with the same result as comparing it with compareEqual. This holds for nil,
+// strings, booleans, and numbers; NaN is never equal to anything either way.
+func isHashable(value any) bool {
+	if value == nil || isNumber(value) {
+		return true
+	}
+	switch reflect.TypeOf(value).Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return true
+	default:
+		return false
 	}
 }
 
-// Matches evaluates all filters against the given data.
+// Fields returns the distinct field paths referenced by the filters, in the
+// order they first appear.
+func (e *Engine) Fields() []string {
+	fields := make([]string, 0, len(e.filters))
+	seen := make(map[string]bool, len(e.filters))
+	for _, filter := range e.filters {
+		if !seen[filter.Field] {
+			seen[filter.Field] = true
+			fields = append(fields, filter.Field)
+		}
+	}
+	return fields
+}
+
+// Matches evaluates all filters against the given data. Cheap operators are
+// evaluated first, and evaluation stops as soon as the result is known.
 func (e *Engine) Matches(data map[string]any) bool {
 	if len(e.filters) == 0 {
 		return true // No filters means everything matches
@@ -68,15 +244,15 @@ func (e *Engine) Matches(data map[string]any) bool {
 
 	switch e.mode {
 	case ModeAnd:
-		for _, filter := range e.filters {
-			if !e.evaluateFilter(filter, data) {
+		for _, i := range e.order {
+			if !e.evaluateFilter(i, e.filters[i], data) {
 				return false
 			}
 		}
 		return true
 	case ModeOr:
-		for _, filter := range e.filters {
-			if e.evaluateFilter(filter, data) {
+		for _, i := range e.order {
+			if e.evaluateFilter(i, e.filters[i], data) {
 				return true
 			}
 		}
@@ -86,8 +262,8 @@ func (e *Engine) Matches(data map[string]any) bool {
 	}
 }
 
-// evaluateFilter evaluates a single filter against the data.
-func (*Engine) evaluateFilter(filter Filter, data map[string]any) bool {
+// evaluateFilter evaluates the filter at index i against the data.
+func (e *Engine) evaluateFilter(i int, filter Filter, data map[string]any) bool {
 	fieldValue := getNestedField(data, filter.Field)
 
 	switch filter.Operator {
@@ -96,11 +272,13 @@ func (*Engine) evaluateFilter(filter Filter, data map[string]any) bool {
 	case "not_equals":
 		return !compareEqual(fieldValue, filter.Value)
 	case "in":
-		return containsValue(filter.Value, fieldValue)
+		return e.contains(i, filter, fieldValue)
 	case "not_in":
-		return !containsValue(filter.Value, fieldValue)
+		return !e.contains(i, filter, fieldValue)
 	case "contains":
 		return containsString(fieldValue, filter.Value)
+	case "contains_word":
+		return containsWord(fieldValue, filter.Value)
 	case "regex":
 		return matchesRegex(fieldValue, filter.Value)
 	case "greater_than":
@@ -111,6 +289,10 @@ func (*Engine) evaluateFilter(filter Filter, data map[string]any) bool {
 		return compareLess(fieldValue, filter.Value)
 	case "less_than_or_equal":
 		return compareLessEqual(fieldValue, filter.Value)
+	case "approx_equals":
+		return compareApproxEqual(fieldValue, filter.Value)
+	case "within":
+		return withinNetwork(fieldValue, filter.Value)
 	case "exists":
 		exists := fieldValue != nil
 		if boolValue, ok := filter.Value.(bool); ok {
@@ -153,11 +335,114 @@ func getNestedField(data map[string]any, fieldPath string) any {
 	return nil
 }
 
-// compareEqual compares two values for equality.
+// compareEqual compares two values for equality. Numbers are compared by
+// value regardless of their type, as filter values arrive from JSON as
+// float64 while database integers decode as int or uint types.
 func compareEqual(fieldValue, filterValue any) bool {
+	if isNumber(fieldValue) && isNumber(filterValue) {
+		return numberKey(fieldValue) == numberKey(filterValue)
+	}
 	return reflect.DeepEqual(fieldValue, filterValue)
 }
 
+// bigIntKey is the numberKey of an integer outside the uint64 range, in
+// decimal.
+type bigIntKey string
+
+// numberKey returns a comparable key for a number, equal for two numbers
+// exactly when they are equal in value. Integers, including floats without a
+// fractional part, are compared exactly as int64, uint64, or bigIntKey, so
+// 64-bit and uint128 values above 2^53 stay distinct. Only fractional and
+// non-finite floats are keyed by their float64 value, which never equals an
+// integer key.
+func numberKey(value any) any {
+	switch v := value.(type) {
+	case int:
+		return int64(v)
+	case int8:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case int64:
+		return v
+	case uint:
+		return uintKey(uint64(v))
+	case uint8:
+		return int64(v)
+	case uint16:
+		return int64(v)
+	case uint32:
+		return int64(v)
+	case uint64:
+		return uintKey(v)
+	case float32:
+		return floatKey(float64(v))
+	case float64:
+		return floatKey(v)
+	case *big.Int:
+		// uint128 values decode as *big.Int.
+		if v == nil {
+			return math.NaN()
+		}
+		return bigKey(v)
+	default:
+		return value
+	}
+}
+
+// uintKey returns the numberKey of an unsigned integer.
+func uintKey(v uint64) any {
+	if v <= math.MaxInt64 {
+		return int64(v)
+	}
+	return v
+}
+
+// floatKey returns the numberKey of a float.
+func floatKey(f float64) any {
+	if math.IsInf(f, 0) || f != math.Trunc(f) {
+		return f
+	}
+	if f >= math.MinInt64 && f < math.MaxInt64 {
+		return int64(f)
+	}
+	i, _ := big.NewFloat(f).Int(nil)
+	return bigKey(i)
+}
+
+// bigKey returns the numberKey of a big integer.
+func bigKey(i *big.Int) any {
+	switch {
+	case i.IsInt64():
+		return i.Int64()
+	case i.IsUint64():
+		return i.Uint64()
+	default:
+		return bigIntKey(i.String())
+	}
+}
+
+// isNumber reports whether value is of a numeric type.
+func isNumber(value any) bool {
+	switch value.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, *big.Int:
+		return true
+	default:
+		return false
+	}
+}
+
+// contains reports whether fieldValue is in the array of the in or not_in
+// filter at index i, using the precomputed set when available.
+func (e *Engine) contains(i int, filter Filter, fieldValue any) bool {
+	if i < len(e.sets) && e.sets[i] != nil {
+		return e.sets[i].contains(fieldValue)
+	}
+	return containsValue(filter.Value, fieldValue)
+}
+
 // containsValue checks if a value is in an array.
 func containsValue(filterValue, fieldValue any) bool {
 	// filterValue should be an array
@@ -175,8 +460,42 @@ func containsValue(filterValue, fieldValue any) bool {
 	return false
 }
 
-// containsString checks if a string contains a substring.
+// containsString checks if a string contains a substring. For binary fields,
+// filterValue is a hex string, optionally prefixed with 0x, and the field
+// must contain the bytes it encodes.
 func containsString(fieldValue, filterValue any) bool {
+	filterStr, ok := filterValue.(string)
+	if !ok {
+		return false
+	}
+
+	switch field := fieldValue.(type) {
+	case string:
+		return strings.Contains(field, filterStr)
+	case []byte:
+		sub, err := decodeHex(filterStr)
+		if err != nil {
+			return false
+		}
+		return bytes.Contains(field, sub)
+	default:
+		return false
+	}
+}
+
+// decodeHex decodes a hex string, optionally prefixed with 0x or 0X.
+func decodeHex(s string) ([]byte, error) {
+	if len(s) >= 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X') {
+		s = s[2:]
+	}
+	return hex.DecodeString(s)
+}
+
+// containsWord checks if a string contains the words of filterValue as whole
+// words, e.g. "AS7922 Comcast" contains "AS7922" but not "79". Words are
+// runs of letters and digits, so punctuation and spacing are ignored. A
+// value with several words matches them consecutively and in order.
+func containsWord(fieldValue, filterValue any) bool {
 	fieldStr, ok1 := fieldValue.(string)
 	filterStr, ok2 := filterValue.(string)
 
@@ -184,7 +503,51 @@ func containsString(fieldValue, filterValue any) bool {
 		return false
 	}
 
-	return strings.Contains(fieldStr, filterStr)
+	want := splitWords(filterStr)
+	if len(want) == 0 {
+		return false
+	}
+	words := splitWords(fieldStr)
+	for i := 0; i+len(want) <= len(words); i++ {
+		if slices.Equal(words[i:i+len(want)], want) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitWords splits s into runs of letters and digits.
+func splitWords(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// withinNetwork checks if fieldValue, an IP address or network, is inside
+// the network filterValue, e.g. "10.1.0.0/16" is within "10.0.0.0/8".
+func withinNetwork(fieldValue, filterValue any) bool {
+	fieldStr, ok1 := fieldValue.(string)
+	filterStr, ok2 := filterValue.(string)
+
+	if !ok1 || !ok2 {
+		return false
+	}
+
+	network, err := netip.ParsePrefix(filterStr)
+	if err != nil {
+		return false
+	}
+
+	prefix, err := netip.ParsePrefix(fieldStr)
+	if err != nil {
+		addr, err := netip.ParseAddr(fieldStr)
+		if err != nil {
+			return false
+		}
+		prefix = netip.PrefixFrom(addr, addr.BitLen())
+	}
+
+	return prefix.Bits() >= network.Bits() && network.Contains(prefix.Addr())
 }
 
 // matchesRegex checks if a string matches a regular expression.
@@ -252,6 +615,54 @@ func compareLessEqual(fieldValue, filterValue any) bool {
 	return fieldNum <= filterNum
 }
 
+// compareApproxEqual compares if fieldValue is within a tolerance of the
+// filter value. filterValue is either a number, compared with
+// DefaultApproxEpsilon, or an object with a value and an optional epsilon.
+func compareApproxEqual(fieldValue, filterValue any) bool {
+	fieldNum, err1 := toFloat64(fieldValue)
+	filterNum, epsilon, err2 := approxValue(filterValue)
+
+	if err1 != nil || err2 != nil {
+		return false
+	}
+
+	return math.Abs(fieldNum-filterNum) <= epsilon
+}
+
+// approxValue returns the value and tolerance of an approx_equals filter.
+func approxValue(filterValue any) (value, epsilon float64, err error) {
+	object, ok := filterValue.(map[string]any)
+	if !ok {
+		value, err = toNumber(filterValue)
+		return value, DefaultApproxEpsilon, err
+	}
+
+	value, err = toNumber(object["value"])
+	if err != nil {
+		return 0, 0, fmt.Errorf("value: %w", err)
+	}
+	epsilon = DefaultApproxEpsilon
+	if rawEpsilon, exists := object["epsilon"]; exists {
+		epsilon, err = toNumber(rawEpsilon)
+		if err != nil {
+			return 0, 0, fmt.Errorf("epsilon: %w", err)
+		}
+		if epsilon < 0 {
+			return 0, 0, errors.New("epsilon must not be negative")
+		}
+	}
+	return value, epsilon, nil
+}
+
+// toNumber converts numeric types to float64. Unlike toFloat64, it does not
+// parse strings.
+func toNumber(value any) (float64, error) {
+	if _, ok := value.(string); ok {
+		return 0, fmt.Errorf("expected a number, got %T", value)
+	}
+	return toFloat64(value)
+}
+
 // toFloat64 converts various numeric types to float64.
 func toFloat64(value any) (float64, error) {
 	switch v := value.(type) {
@@ -279,6 +690,13 @@ func toFloat64(value any) (float64, error) {
 		return float64(v), nil
 	case float64:
 		return v, nil
+	case *big.Int:
+		// uint128 values decode as *big.Int.
+		if v == nil {
+			return 0, errors.New("cannot convert nil *big.Int to float64")
+		}
+		f, _ := new(big.Float).SetInt(v).Float64()
+		return f, nil
 	case string:
 		return strconv.ParseFloat(v, 64)
 	default:
@@ -288,6 +706,12 @@ func toFloat64(value any) (float64, error) {
 
 // Validate validates a filter configuration.
 func Validate(filters []Filter) error {
+	return ValidateWithLimits(filters, DefaultLimits())
+}
+
+// ValidateWithLimits validates filters like Validate, using the given limits
+// for client-supplied values.
+func ValidateWithLimits(filters []Filter, limits Limits) error {
 	supportedOps := make(map[string]bool)
 	for _, op := range SupportedOperators() {
 		supportedOps[op] = true
@@ -317,18 +741,132 @@ func Validate(filters []Filter) error {
 			if !ok {
 				return fmt.Errorf("filter %d: regex operator requires a string value", i)
 			}
-			if _, err := regexp.Compile(regexStr); err != nil {
-				return fmt.Errorf("filter %d: invalid regex '%s': %w", i, regexStr, err)
+			if err := validateRegex(regexStr, limits); err != nil {
+				return fmt.Errorf("filter %d: %w", i, err)
+			}
+		case "approx_equals":
+			if _, _, err := approxValue(filter.Value); err != nil {
+				return fmt.Errorf(
+					"filter %d: approx_equals operator requires a number or {value, epsilon}: %w",
+					i,
+					err,
+				)
+			}
+		case "contains":
+			if _, ok := filter.Value.(string); !ok {
+				return fmt.Errorf(
+					"filter %d: contains operator requires a string value (hex for binary fields)",
+					i,
+				)
+			}
+		case "contains_word":
+			if _, ok := filter.Value.(string); !ok {
+				return fmt.Errorf("filter %d: contains_word operator requires a string value", i)
+			}
+		case "within":
+			networkStr, ok := filter.Value.(string)
+			if !ok {
+				return fmt.Errorf("filter %d: within operator requires a network string value", i)
+			}
+			if _, err := netip.ParsePrefix(networkStr); err != nil {
+				return fmt.Errorf("filter %d: within operator requires a valid network: %w", i, err)
 			}
 		case "exists":
 			if _, ok := filter.Value.(bool); !ok {
 				return fmt.Errorf("filter %d: exists operator requires a boolean value", i)
 			}
 		default:
-			// Other operators (eq, ne, lt, le, gt, ge, contains, starts_with, ends_with)
+			// Other operators (eq, ne, lt, le, gt, ge, starts_with, ends_with)
 			// don't require specific value type validation
 		}
 	}
 
 	return nil
 }
+
+// ValidatePaths checks the field paths of filters against a sample record,
+// e.g. one decoded from the database being queried. It returns an error for
+// the first path that descends into a value that is not a map, such as
+// country.iso_code.foo, as such a filter can never match. Paths whose
+// segments are missing from the sample cannot be judged and are accepted,
+// as are pseudo-fields.
+func ValidatePaths(filters []Filter, sample map[string]any) error {
+	for i, filter := range filters {
+		if IsPseudoField(filter.Field) {
+			continue
+		}
+
+		parts := strings.Split(filter.Field, ".")
+		current := sample
+		for j, part := range parts[:len(parts)-1] {
+			value, exists := current[part]
+			if !exists {
+				break
+			}
+			next, ok := value.(map[string]any)
+			if !ok {
+				return fmt.Errorf(
+					"filter %d: field '%s' cannot match: %s is %s, not a map",
+					i,
+					filter.Field,
+					strings.Join(parts[:j+1], "."),
+					describeKind(value),
+				)
+			}
+			current = next
+		}
+	}
+	return nil
+}
+
+// describeKind describes the kind of a decoded value for error messages.
+func describeKind(value any) string {
+	switch value.(type) {
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	case []any:
+		return "an array"
+	case []byte:
+		return "binary data"
+	default:
+		if isNumber(value) {
+			return "a number"
+		}
+		return fmt.Sprintf("a %T", value)
+	}
+}
+
+// validateRegex checks that a regex pattern compiles and is within limits.
+// Go regular expressions match in time linear in the input, so the cost of
+// matching a record is bounded by the size of the compiled program. Patterns
+// are therefore limited in length, and patterns that expand to very large
+// programs, such as nested counted repetitions, are rejected.
+func validateRegex(pattern string, limits Limits) error {
+	if limits.MaxRegexLength > 0 && len(pattern) > limits.MaxRegexLength {
+		return fmt.Errorf(
+			"regex pattern is %d characters long, exceeding the limit of %d",
+			len(pattern),
+			limits.MaxRegexLength,
+		)
+	}
+
+	if _, err := regexp.Compile(pattern); err != nil {
+		return fmt.Errorf("invalid regex '%s': %w", pattern, err)
+	}
+
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return fmt.Errorf("invalid regex '%s': %w", pattern, err)
+	}
+	prog, err := syntax.Compile(re.Simplify())
+	if err != nil {
+		return fmt.Errorf("invalid regex '%s': %w", pattern, err)
+	}
+	if len(prog.Inst) > maxRegexProgramSize {
+		return fmt.Errorf("regex '%s' is too complex", pattern)
+	}
+
+	return nil
+}
@@ -1,7 +1,11 @@
 package filter
 
 import (
+	"fmt"
+	"math/big"
 	"slices"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -259,6 +263,168 @@ func TestFilterEngine(t *testing.T) {
 	}
 }
 
+func TestContainsWord(t *testing.T) {
+	tests := []struct {
+		field       string
+		value       string
+		shouldMatch bool
+	}{
+		{field: "AS7922 Comcast Cable Communications", value: "AS7922", shouldMatch: true},
+		{field: "AS7922 Comcast Cable Communications", value: "79", shouldMatch: false},
+		{field: "Comcast Cable Communications", value: "Cable", shouldMatch: true},
+		{field: "Comcast Cable Communications", value: "Cab", shouldMatch: false},
+		{field: "Comcast Cable Communications", value: "Cable Communications", shouldMatch: true},
+		{field: "Comcast Cable Communications", value: "Communications Cable", shouldMatch: false},
+		{field: "Deutsche Telekom AG", value: "AG", shouldMatch: true},
+		{field: "Telefonica de Espana, S.A.U.", value: "Espana", shouldMatch: true},
+		{field: "Telefonica de Espana, S.A.U.", value: "S.A.U", shouldMatch: true},
+		{field: "Verizon Business", value: "verizon", shouldMatch: false},
+		{field: "Verizon Business", value: "", shouldMatch: false},
+		{field: "Verizon Business", value: " - ", shouldMatch: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.field+"/"+test.value, func(t *testing.T) {
+			engine := New([]Filter{{Field: "isp", Operator: "contains_word", Value: test.value}}, ModeAnd)
+			if matches := engine.Matches(map[string]any{"isp": test.field}); matches != test.shouldMatch {
+				t.Errorf("Expected match=%t, got match=%t", test.shouldMatch, matches)
+			}
+		})
+	}
+
+	// Plain contains matches substrings that contains_word rejects.
+	engine := New([]Filter{{Field: "isp", Operator: "contains", Value: "79"}}, ModeAnd)
+	if !engine.Matches(map[string]any{"isp": "AS7922 Comcast"}) {
+		t.Error("Expected contains to match a substring")
+	}
+}
+
+func TestContainsBytes(t *testing.T) {
+	data := map[string]any{"blob": []byte{0x00, 0x00, 0x00, 0x2a, 0xff}}
+
+	tests := []struct {
+		value       string
+		shouldMatch bool
+	}{
+		{value: "00002a", shouldMatch: true},
+		{value: "0x2aff", shouldMatch: true},
+		{value: "0X2AFF", shouldMatch: true},
+		{value: "", shouldMatch: true},
+		{value: "2b", shouldMatch: false},
+		{value: "ff00", shouldMatch: false},
+		{value: "not hex", shouldMatch: false},
+		{value: "2", shouldMatch: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			filters := []Filter{{Field: "blob", Operator: "contains", Value: test.value}}
+			if err := Validate(filters); err != nil {
+				t.Fatalf("Expected hex string to validate, got %v", err)
+			}
+			if matches := New(filters, ModeAnd).Matches(data); matches != test.shouldMatch {
+				t.Errorf("Expected match=%t, got match=%t", test.shouldMatch, matches)
+			}
+		})
+	}
+}
+
+func TestApproxEquals(t *testing.T) {
+	// Coordinates as decoded from a database. At run time, 0.1 + 0.2 is not
+	// exactly 0.3 in floating point, so equals does not match it.
+	tenth := 0.1
+	testData := map[string]any{
+		"location": map[string]any{
+			"latitude":  51.5142,
+			"longitude": tenth + 0.2,
+			"radius":    uint16(100),
+		},
+	}
+
+	tests := []struct {
+		name        string
+		field       string
+		operator    string
+		value       any
+		shouldMatch bool
+	}{
+		{name: "equals misses float", field: "location.longitude", operator: "equals", value: 0.3},
+		{
+			name:        "default epsilon",
+			field:       "location.longitude",
+			operator:    "approx_equals",
+			value:       0.3,
+			shouldMatch: true,
+		},
+		{name: "default epsilon too small", field: "location.latitude", operator: "approx_equals", value: 51.51},
+		{
+			name:        "custom epsilon",
+			field:       "location.latitude",
+			operator:    "approx_equals",
+			value:       map[string]any{"value": 51.51, "epsilon": 0.01},
+			shouldMatch: true,
+		},
+		{
+			name:     "custom epsilon too small",
+			field:    "location.latitude",
+			operator: "approx_equals",
+			value:    map[string]any{"value": 51.51, "epsilon": 0.001},
+		},
+		{
+			name:        "default epsilon in object",
+			field:       "location.latitude",
+			operator:    "approx_equals",
+			value:       map[string]any{"value": 51.5142},
+			shouldMatch: true,
+		},
+		{
+			name:        "integer field",
+			field:       "location.radius",
+			operator:    "approx_equals",
+			value:       100.0000001,
+			shouldMatch: true,
+		},
+		{name: "missing field", field: "location.accuracy", operator: "approx_equals", value: 0.0},
+		{name: "string value", field: "location.latitude", operator: "approx_equals", value: "51.5142"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			engine := New([]Filter{{Field: test.field, Operator: test.operator, Value: test.value}}, ModeAnd)
+			if matches := engine.Matches(testData); matches != test.shouldMatch {
+				t.Errorf("Expected match=%t, got match=%t", test.shouldMatch, matches)
+			}
+		})
+	}
+}
+
+func TestWithin(t *testing.T) {
+	tests := []struct {
+		field       any
+		network     string
+		shouldMatch bool
+	}{
+		{field: "10.1.0.0/16", network: "10.0.0.0/8", shouldMatch: true},
+		{field: "10.0.0.0/8", network: "10.0.0.0/8", shouldMatch: true},
+		{field: "10.0.0.0/7", network: "10.0.0.0/8", shouldMatch: false},
+		{field: "11.0.0.0/16", network: "10.0.0.0/8", shouldMatch: false},
+		{field: "10.2.3.4", network: "10.0.0.0/8", shouldMatch: true},
+		{field: "2001:db8::/48", network: "2001:db8::/32", shouldMatch: true},
+		{field: "2001:db8::/48", network: "10.0.0.0/8", shouldMatch: false},
+		{field: "not a network", network: "10.0.0.0/8", shouldMatch: false},
+		{field: 24, network: "10.0.0.0/8", shouldMatch: false},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprintf("%v/%s", test.field, test.network), func(t *testing.T) {
+			engine := New([]Filter{{Field: PrefixField, Operator: "within", Value: test.network}}, ModeAnd)
+			if matches := engine.Matches(map[string]any{PrefixField: test.field}); matches != test.shouldMatch {
+				t.Errorf("Expected match=%t, got match=%t", test.shouldMatch, matches)
+			}
+		})
+	}
+}
+
 func TestFilterEngineComparisonOperators(t *testing.T) {
 	testData := map[string]any{
 		"traits": map[string]any{
@@ -272,6 +438,30 @@ func TestFilterEngineComparisonOperators(t *testing.T) {
 		mode        Mode
 		shouldMatch bool
 	}{
+		{
+			name: "equals float64 against int",
+			filters: []Filter{
+				{Field: "traits.autonomous_system_number", Operator: "equals", Value: float64(7922)},
+			},
+			mode:        ModeAnd,
+			shouldMatch: true,
+		},
+		{
+			name: "not_equals float64 against int",
+			filters: []Filter{
+				{Field: "traits.autonomous_system_number", Operator: "not_equals", Value: float64(7922)},
+			},
+			mode:        ModeAnd,
+			shouldMatch: false,
+		},
+		{
+			name: "equals string against int",
+			filters: []Filter{
+				{Field: "traits.autonomous_system_number", Operator: "equals", Value: "7922"},
+			},
+			mode:        ModeAnd,
+			shouldMatch: false,
+		},
 		{
 			name: "greater_than_or_equal match",
 			filters: []Filter{
@@ -410,6 +600,7 @@ func TestToFloat64(t *testing.T) {
 		{uint64(42), 42.0, false},
 		{float32(42.5), 42.5, false},
 		{float64(42.5), 42.5, false},
+		{big.NewInt(42), 42.0, false},
 		{"42.5", 42.5, false},
 		{"invalid", 0, true},
 		{true, 0, true},
@@ -487,6 +678,78 @@ func TestValidate(t *testing.T) {
 			expectError: true,
 			errorMsg:    "filter 0: regex operator requires a string value",
 		},
+		{
+			name:        "contains_word operator with non-string",
+			filters:     []Filter{{Field: "test", Operator: "contains_word", Value: 7922}},
+			expectError: true,
+			errorMsg:    "filter 0: contains_word operator requires a string value",
+		},
+		{
+			name:        "within operator with network",
+			filters:     []Filter{{Field: PrefixField, Operator: "within", Value: "10.0.0.0/8"}},
+			expectError: false,
+		},
+		{
+			name:        "within operator with invalid network",
+			filters:     []Filter{{Field: PrefixField, Operator: "within", Value: "10.0.0.0"}},
+			expectError: true,
+		},
+		{
+			name:        "within operator with non-string",
+			filters:     []Filter{{Field: PrefixField, Operator: "within", Value: 8}},
+			expectError: true,
+			errorMsg:    "filter 0: within operator requires a network string value",
+		},
+		{
+			name:        "approx_equals operator with number",
+			filters:     []Filter{{Field: "test", Operator: "approx_equals", Value: 51.5142}},
+			expectError: false,
+		},
+		{
+			name: "approx_equals operator with value and epsilon",
+			filters: []Filter{{
+				Field:    "test",
+				Operator: "approx_equals",
+				Value:    map[string]any{"value": 51.5142, "epsilon": 0.01},
+			}},
+			expectError: false,
+		},
+		{
+			name:        "approx_equals operator with string",
+			filters:     []Filter{{Field: "test", Operator: "approx_equals", Value: "51.5"}},
+			expectError: true,
+		},
+		{
+			name: "approx_equals operator without value",
+			filters: []Filter{{
+				Field:    "test",
+				Operator: "approx_equals",
+				Value:    map[string]any{"epsilon": 0.01},
+			}},
+			expectError: true,
+		},
+		{
+			name: "approx_equals operator with negative epsilon",
+			filters: []Filter{{
+				Field:    "test",
+				Operator: "approx_equals",
+				Value:    map[string]any{"value": 51.5142, "epsilon": -0.01},
+			}},
+			expectError: true,
+			errorMsg: "filter 0: approx_equals operator requires a number or {value, epsilon}: " +
+				"epsilon must not be negative",
+		},
+		{
+			name:        "contains operator with non-string",
+			filters:     []Filter{{Field: "test", Operator: "contains", Value: 42.0}},
+			expectError: true,
+			errorMsg:    "filter 0: contains operator requires a string value (hex for binary fields)",
+		},
+		{
+			name:        "contains operator with hex string",
+			filters:     []Filter{{Field: "test", Operator: "contains", Value: "0x00002a"}},
+			expectError: false,
+		},
 		{
 			name:        "exists operator with non-boolean",
 			filters:     []Filter{{Field: "test", Operator: "exists", Value: "not_bool"}},
@@ -523,8 +786,9 @@ func TestSupportedOperators(t *testing.T) {
 	operators := SupportedOperators()
 
 	expectedOperators := []string{
-		"equals", "not_equals", "in", "not_in", "contains",
-		"regex", "greater_than", "greater_than_or_equal", "less_than", "less_than_or_equal", "exists",
+		"equals", "not_equals", "in", "not_in", "contains", "contains_word",
+		"regex", "greater_than", "greater_than_or_equal", "less_than", "less_than_or_equal", "approx_equals", "within",
+		"exists",
 	}
 
 	if len(operators) != len(expectedOperators) {
@@ -584,3 +848,258 @@ func TestFilterEngineEdgeCases(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateRegexLimits(t *testing.T) {
+	longPattern := "^" + strings.Repeat("a", DefaultMaxRegexLength) + "$"
+
+	err := Validate([]Filter{{Field: "test", Operator: "regex", Value: longPattern}})
+	if err == nil || !strings.Contains(err.Error(), "exceeding the limit of 256") {
+		t.Errorf("Expected over-long pattern to be rejected, got %v", err)
+	}
+
+	// A larger configured limit accepts the same pattern.
+	limits := Limits{MaxRegexLength: 1024}
+	if err := ValidateWithLimits(
+		[]Filter{{Field: "test", Operator: "regex", Value: longPattern}},
+		limits,
+	); err != nil {
+		t.Errorf("Expected pattern within a larger limit to be accepted, got %v", err)
+	}
+
+	// Nested counted repetitions expand to a very large program.
+	err = ValidateWithLimits(
+		[]Filter{{Field: "test", Operator: "regex", Value: "((a{100}){100}){10}"}},
+		limits,
+	)
+	if err == nil {
+		t.Error("Expected overly complex pattern to be rejected")
+	}
+
+	if err := Validate([]Filter{{Field: "test", Operator: "regex", Value: "^(Lon|Par)"}}); err != nil {
+		t.Errorf("Expected simple pattern to be accepted, got %v", err)
+	}
+}
+
+func TestValidatePaths(t *testing.T) {
+	sample := map[string]any{
+		"country": map[string]any{
+			"iso_code": "GB",
+			"names":    map[string]any{"en": "United Kingdom"},
+		},
+		"subdivisions": []any{map[string]any{"iso_code": "ENG"}},
+		"location":     map[string]any{"latitude": 51.5142},
+	}
+
+	tests := []struct {
+		name     string
+		field    string
+		errorMsg string
+	}{
+		{name: "leaf", field: "country.iso_code"},
+		{name: "nested map", field: "country.names.en"},
+		{name: "missing section", field: "traits.is_anycast"},
+		{name: "missing leaf parent", field: "country.geoname.id"},
+		{name: "pseudo-field", field: PrefixLengthField},
+		{
+			name:     "past a string",
+			field:    "country.iso_code.foo",
+			errorMsg: "filter 0: field 'country.iso_code.foo' cannot match: country.iso_code is a string, not a map",
+		},
+		{
+			name:     "past a number",
+			field:    "location.latitude.value",
+			errorMsg: "filter 0: field 'location.latitude.value' cannot match: location.latitude is a number, not a map",
+		},
+		{
+			name:     "into an array",
+			field:    "subdivisions.iso_code",
+			errorMsg: "filter 0: field 'subdivisions.iso_code' cannot match: subdivisions is an array, not a map",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePaths([]Filter{{Field: tt.field, Operator: "exists", Value: true}}, sample)
+			if tt.errorMsg == "" {
+				if err != nil {
+					t.Errorf("Expected %s to be accepted, got %v", tt.field, err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.errorMsg {
+				t.Errorf("Expected error %q, got %v", tt.errorMsg, err)
+			}
+		})
+	}
+}
+
+func TestInSetMatchesLinearScan(t *testing.T) {
+	values := []any{
+		"US",
+		float64(7922),
+		uint64(1221),
+		true,
+		nil,
+		[]any{"a", "b"},
+		map[string]any{"k": "v"},
+	}
+
+	fieldValues := []any{
+		"US",
+		"GB",
+		float64(7922),
+		uint64(7922),
+		uint64(1221),
+		float64(1221),
+		true,
+		false,
+		nil,
+		[]any{"a", "b"},
+		[]any{"a"},
+		map[string]any{"k": "v"},
+		map[string]any{"k": "w"},
+	}
+
+	for _, operator := range []string{"in", "not_in"} {
+		engine := New([]Filter{{Field: "value", Operator: operator, Value: values}}, ModeAnd)
+		for _, fieldValue := range fieldValues {
+			want := containsValue(values, fieldValue)
+			if operator == "not_in" {
+				want = !want
+			}
+			if got := engine.Matches(map[string]any{"value": fieldValue}); got != want {
+				t.Errorf("%s %#v: expected %v, got %v", operator, fieldValue, want, got)
+			}
+		}
+	}
+}
+
+func TestEqualsLargeIntegers(t *testing.T) {
+	// 2^53 and 2^53+1 are distinct integers but the same float64.
+	const above53 = uint64(1) << 53
+	big128, _ := new(big.Int).SetString("170141183460469231731687303715884105728", 10)
+	big128Next := new(big.Int).Add(big128, big.NewInt(1))
+
+	tests := []struct {
+		fieldValue  any
+		filterValue any
+		name        string
+		want        bool
+	}{
+		{name: "adjacent uint64", fieldValue: above53 + 1, filterValue: above53, want: false},
+		{name: "same uint64", fieldValue: above53 + 1, filterValue: above53 + 1, want: true},
+		{name: "adjacent int64", fieldValue: -int64(above53) - 1, filterValue: -int64(above53), want: false},
+		{name: "adjacent uint128", fieldValue: big128Next, filterValue: big128, want: false},
+		{name: "same uint128", fieldValue: big128Next, filterValue: new(big.Int).Set(big128Next), want: true},
+		{
+			name:        "uint64 and big.Int",
+			fieldValue:  above53 + 1,
+			filterValue: new(big.Int).SetUint64(above53 + 1),
+			want:        true,
+		},
+		{name: "uint64 and integral float", fieldValue: above53, filterValue: float64(above53), want: true},
+		{name: "adjacent uint64 and float", fieldValue: above53 + 1, filterValue: float64(above53), want: false},
+		{name: "uint128 and integral float", fieldValue: big128, filterValue: float64(1 << 127), want: true},
+		{name: "fractional float", fieldValue: uint64(1), filterValue: 1.5, want: false},
+		{name: "same fractional float", fieldValue: float32(1.5), filterValue: 1.5, want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := map[string]any{"value": tt.fieldValue}
+			for _, f := range []Filter{
+				{Field: "value", Operator: "equals", Value: tt.filterValue},
+				{Field: "value", Operator: "in", Value: []any{tt.filterValue}},
+			} {
+				if got := New([]Filter{f}, ModeAnd).Matches(data); got != tt.want {
+					t.Errorf("%s %v: expected %v, got %v", f.Operator, tt.filterValue, tt.want, got)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkMatchesLargeIn(b *testing.B) {
+	values := make([]any, 10000)
+	for i := range values {
+		values[i] = "value-" + strconv.Itoa(i)
+	}
+	filters := []Filter{{Field: "value", Operator: "in", Value: values}}
+	data := map[string]any{"value": "value-9999"}
+
+	b.Run("Set", func(b *testing.B) {
+		engine := New(filters, ModeAnd)
+		for b.Loop() {
+			if !engine.Matches(data) {
+				b.Fatal("Expected match")
+			}
+		}
+	})
+
+	b.Run("LinearScan", func(b *testing.B) {
+		engine := New(filters, ModeAnd)
+		engine.sets = nil
+		for b.Loop() {
+			if !engine.Matches(data) {
+				b.Fatal("Expected match")
+			}
+		}
+	})
+}
+
+func TestMatchesEvaluatesCheapOperatorsFirst(t *testing.T) {
+	filters := []Filter{
+		{Field: "city", Operator: "regex", Value: "^Lon"},
+		{Field: "ip", Operator: "within", Value: "81.2.69.0/24"},
+		{Field: "country", Operator: "in", Value: []any{"GB", "US"}},
+		{Field: "country", Operator: "equals", Value: "GB"},
+		{Field: "postal", Operator: "exists", Value: true},
+	}
+	engine := New(filters, ModeAnd)
+
+	expected := []int{3, 4, 2, 1, 0}
+	if !slices.Equal(engine.order, expected) {
+		t.Errorf("Expected evaluation order %v, got %v", expected, engine.order)
+	}
+
+	// The order does not change the result in either mode.
+	data := map[string]any{"city": "London", "ip": "81.2.69.142", "country": "GB"}
+	if engine.Matches(data) {
+		t.Error("Expected no match without postal in and mode")
+	}
+	if !New(filters, ModeOr).Matches(data) {
+		t.Error("Expected a match in or mode")
+	}
+	data["postal"] = "SW1"
+	if !engine.Matches(data) {
+		t.Error("Expected a match with postal in and mode")
+	}
+}
+
+func BenchmarkMatchesCheapFailFirst(b *testing.B) {
+	// The regex is listed first, but the equals filter rejects the record.
+	filters := []Filter{
+		{Field: "city", Operator: "regex", Value: "^(Lon|Man)[a-z]+ter$"},
+		{Field: "country", Operator: "equals", Value: "US"},
+	}
+	data := map[string]any{"city": "Manchester", "country": "GB"}
+
+	b.Run("CheapFirst", func(b *testing.B) {
+		engine := New(filters, ModeAnd)
+		for b.Loop() {
+			if engine.Matches(data) {
+				b.Fatal("Expected no match")
+			}
+		}
+	})
+
+	b.Run("DeclaredOrder", func(b *testing.B) {
+		engine := New(filters, ModeAnd)
+		engine.order = []int{0, 1}
+		for b.Loop() {
+			if engine.Matches(data) {
+				b.Fatal("Expected no match")
+			}
+		}
+	})
+}
@@ -0,0 +1,128 @@
+package iterator
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/oschwald/maxminddb-mcp/internal/filter"
+
+	"github.com/oschwald/maxminddb-golang/v2"
+)
+
+const testCityDBPath = "../../testdata/test-data/GeoLite2-City-Test.mmdb"
+
+func TestIterateDecodePathMatchesFullDecode(t *testing.T) {
+	reader, err := maxminddb.Open(testCityDBPath)
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	manager := New(30*time.Minute, 5*time.Minute)
+	network := netip.MustParsePrefix("::/0")
+	filters := []filter.Filter{
+		{Field: "country.iso_code", Operator: "equals", Value: "GB"},
+		{Field: "location.accuracy_radius", Operator: "greater_than", Value: 0},
+	}
+
+	optimized, err := manager.CreateIterator(reader, testDB, network, filters, filterModeAnd)
+	if err != nil {
+		t.Fatalf("Failed to create iterator: %v", err)
+	}
+	if optimized.decodePaths == nil {
+		t.Fatal("Expected DecodePath to be used for two filter fields")
+	}
+
+	full, err := manager.CreateIterator(reader, testDB, network, filters, filterModeAnd)
+	if err != nil {
+		t.Fatalf("Failed to create iterator: %v", err)
+	}
+	full.decodePaths = nil
+
+	optimizedResult, err := manager.Iterate(optimized, 10000)
+	if err != nil {
+		t.Fatalf("Failed to iterate: %v", err)
+	}
+	fullResult, err := manager.Iterate(full, 10000)
+	if err != nil {
+		t.Fatalf("Failed to iterate: %v", err)
+	}
+
+	if len(optimizedResult.Results) == 0 {
+		t.Fatal("Expected GB results")
+	}
+	if !reflect.DeepEqual(optimizedResult.Results, fullResult.Results) {
+		t.Errorf(
+			"DecodePath results differ from full decode: %d vs %d results",
+			len(optimizedResult.Results),
+			len(fullResult.Results),
+		)
+	}
+	for _, res := range optimizedResult.Results {
+		if _, hasCity := res.Data["city"]; !hasCity {
+			t.Errorf("Expected fully decoded record for %s, got %v", res.Network, res.Data)
+		}
+	}
+}
+
+func TestFilterDecodePaths(t *testing.T) {
+	engine := filter.New([]filter.Filter{
+		{Field: "country.iso_code", Operator: "equals", Value: "US"},
+		{Field: "country.iso_code", Operator: "not_equals", Value: "CA"},
+		{Field: "traits.user_type", Operator: "exists", Value: true},
+	}, filter.ModeAnd)
+
+	expected := [][]any{{"country", "iso_code"}, {"traits", "user_type"}}
+	if got := filterDecodePaths(engine); !reflect.DeepEqual(got, expected) {
+		t.Errorf("filterDecodePaths() = %v, expected %v", got, expected)
+	}
+
+	many := make([]filter.Filter, 0, maxDecodePathFields+1)
+	for _, field := range []string{"a", "b", "c", "d", "e"} {
+		many = append(many, filter.Filter{Field: field, Operator: "exists", Value: true})
+	}
+	if got := filterDecodePaths(filter.New(many, filter.ModeOr)); got != nil {
+		t.Errorf("Expected nil paths for %d fields, got %v", len(many), got)
+	}
+}
+
+func BenchmarkIterateSelectiveFilter(b *testing.B) {
+	reader, err := maxminddb.Open(testCityDBPath)
+	if err != nil {
+		b.Fatalf("Failed to open test database: %v", err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	manager := New(30*time.Minute, 5*time.Minute)
+	network := netip.MustParsePrefix("::/0")
+	filters := []filter.Filter{
+		{Field: "country.iso_code", Operator: "equals", Value: "SE"},
+	}
+
+	for _, bench := range []struct {
+		name          string
+		useDecodePath bool
+	}{
+		{"DecodePath", true},
+		{"FullDecode", false},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				iter, err := manager.CreateIterator(reader, testDB, network, filters, filterModeAnd)
+				if err != nil {
+					b.Fatalf("Failed to create iterator: %v", err)
+				}
+				if !bench.useDecodePath {
+					iter.decodePaths = nil
+				}
+				if _, err := manager.Iterate(iter, 10000); err != nil {
+					b.Fatalf("Failed to iterate: %v", err)
+				}
+				manager.RemoveIterator(iter.ID)
+			}
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package iterator
+
+// DedupeRecords replaces the record of each result with a reference into
+// the Records of the batch, which holds each distinct record once. Many
+// networks of a database usually share a record, e.g. those of a city, so
+// this shrinks large batches considerably.
+//
+// Records are told apart by their data section offset, so two records
+// holding equal data at different offsets are both kept. Results must not be
+// modified in a way that makes records of the same offset differ, e.g. by
+// filtering them differently, before deduplicating.
+func (r *IterationResult) DedupeRecords() {
+	indexes := make(map[uintptr]int)
+	r.Records = make([]map[string]any, 0)
+	for i := range r.Results {
+		result := &r.Results[i]
+		index, ok := indexes[result.offset]
+		if !ok {
+			index = len(r.Records)
+			indexes[result.offset] = index
+			r.Records = append(r.Records, result.Data)
+		}
+		result.Record = &index
+		result.Data = nil
+	}
+}
@@ -0,0 +1,79 @@
+package iterator
+
+import (
+	"encoding/json"
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang/v2"
+)
+
+func TestDedupeRecords(t *testing.T) {
+	manager := New(30*time.Minute, 5*time.Minute)
+
+	reader, err := maxminddb.Open(testCityDBPath)
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	// The IPv4 networks of 81.2.69.0/24 are also reachable through the
+	// ::ffff:0:0/96 and 2002::/16 aliases, which share their records.
+	iter, err := manager.CreateIteratorWithOptions(
+		reader,
+		"city-test",
+		netip.MustParsePrefix("81.2.69.0/24"),
+		nil,
+		"",
+		Options{ExtraNetworks: []netip.Prefix{
+			netip.MustParsePrefix("::ffff:81.2.69.0/120"),
+			netip.MustParsePrefix("2002:5102:4500::/40"),
+		}},
+	)
+	if err != nil {
+		t.Fatalf("Failed to create iterator: %v", err)
+	}
+	result, err := manager.Iterate(iter, 1000)
+	if err != nil {
+		t.Fatalf("Failed to iterate: %v", err)
+	}
+	if len(result.Results) < 3 || len(result.Results)%3 != 0 {
+		t.Fatalf("Expected each network three times, got %d results", len(result.Results))
+	}
+
+	original := make([]map[string]any, 0, len(result.Results))
+	for _, r := range result.Results {
+		original = append(original, r.Data)
+	}
+	plain, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("Failed to marshal result: %v", err)
+	}
+
+	result.DedupeRecords()
+
+	if len(result.Records) > len(result.Results)/3 {
+		t.Errorf("Expected at most %d records, got %d", len(result.Results)/3, len(result.Records))
+	}
+	for i, r := range result.Results {
+		if r.Data != nil {
+			t.Errorf("Expected no data for %s, got %v", r.Network, r.Data)
+		}
+		if r.Record == nil || *r.Record >= len(result.Records) {
+			t.Fatalf("Expected a record index for %s, got %v", r.Network, r.Record)
+		}
+		if !reflect.DeepEqual(result.Records[*r.Record], original[i]) {
+			t.Errorf("Expected record %d of %s to be %v, got %v", *r.Record, r.Network, original[i], result.Records[*r.Record])
+		}
+	}
+
+	deduped, err := json.Marshal(result)
+	if err != nil {
+		t.Fatalf("Failed to marshal result: %v", err)
+	}
+	if len(deduped) >= len(plain) {
+		t.Errorf("Expected deduplicated payload smaller than %d bytes, got %d", len(plain), len(deduped))
+	}
+}
@@ -0,0 +1,57 @@
+package iterator
+
+import "net/netip"
+
+// bogonNetworks lists private, reserved, and documentation networks that
+// are not routed on the public internet. ::/8 is deliberately missing as it
+// holds the IPv4 subtree of IPv6 databases.
+var bogonNetworks = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("10.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("127.0.0.0/8"),
+	netip.MustParsePrefix("169.254.0.0/16"),
+	netip.MustParsePrefix("172.16.0.0/12"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("192.168.0.0/16"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("224.0.0.0/4"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("::/128"),
+	netip.MustParsePrefix("::1/128"),
+	netip.MustParsePrefix("100::/64"),
+	netip.MustParsePrefix("2001:10::/28"),
+	netip.MustParsePrefix("2001:db8::/32"),
+	netip.MustParsePrefix("3fff::/20"),
+	netip.MustParsePrefix("fc00::/7"),
+	netip.MustParsePrefix("fe80::/10"),
+	netip.MustParsePrefix("fec0::/10"),
+	netip.MustParsePrefix("ff00::/8"),
+}
+
+// BogonNetworks returns the built-in list of private and reserved networks,
+// e.g. for Options.ExcludeNetworks.
+func BogonNetworks() []netip.Prefix {
+	return append([]netip.Prefix(nil), bogonNetworks...)
+}
+
+// excluded reports whether network lies within one of the iterator's
+// excluded networks. IPv4-mapped networks, which are returned when aliased
+// networks are included, are compared as IPv4 networks.
+func (iter *ManagedIterator) excluded(network netip.Prefix) bool {
+	if len(iter.ExcludeNetworks) == 0 {
+		return false
+	}
+	if network.Addr().Is4In6() && network.Bits() >= 96 {
+		network = netip.PrefixFrom(network.Addr().Unmap(), network.Bits()-96)
+	}
+	for _, excluded := range iter.ExcludeNetworks {
+		if excluded.Bits() <= network.Bits() && excluded.Contains(network.Addr()) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package iterator
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang/v2"
+)
+
+func TestExcluded(t *testing.T) {
+	iter := &ManagedIterator{ExcludeNetworks: BogonNetworks()}
+
+	tests := []struct {
+		network  string
+		excluded bool
+	}{
+		{"10.0.0.0/8", true},
+		{"10.1.2.0/24", true},
+		{"192.168.1.1/32", true},
+		{"::ffff:10.1.2.0/120", true}, // aliased IPv4 network
+		{"fd00::/8", true},
+		{"2001:db8:1::/48", true},
+		// Networks that only partly overlap a bogon network are kept.
+		{"8.0.0.0/6", false},
+		{"8.8.8.0/24", false},
+		{"81.2.69.0/24", false},
+		// The IPv4 subtree of IPv6 databases is not excluded.
+		{"::/96", false},
+		{"2a02:ff80::/29", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.network, func(t *testing.T) {
+			if got := iter.excluded(netip.MustParsePrefix(test.network)); got != test.excluded {
+				t.Errorf("excluded(%s) = %v, expected %v", test.network, got, test.excluded)
+			}
+		})
+	}
+
+	if (&ManagedIterator{}).excluded(netip.MustParsePrefix("10.0.0.0/8")) {
+		t.Error("Expected nothing to be excluded without ExcludeNetworks")
+	}
+}
+
+func TestIterateExcludeNetworks(t *testing.T) {
+	reader, err := maxminddb.Open("../../testdata/test-data/GeoLite2-City-Test.mmdb")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer reader.Close()
+
+	manager := New(30*time.Minute, 5*time.Minute)
+	excludedNetwork := netip.MustParsePrefix("81.2.69.160/27")
+	iter, err := manager.CreateIteratorWithOptions(
+		reader,
+		"GeoLite2-City-Test.mmdb",
+		netip.MustParsePrefix("81.2.69.0/24"),
+		nil,
+		filterModeAnd,
+		Options{ExcludeNetworks: []netip.Prefix{excludedNetwork}},
+	)
+	if err != nil {
+		t.Fatalf("Failed to create iterator: %v", err)
+	}
+
+	result, err := manager.Iterate(iter, 1000)
+	if err != nil {
+		t.Fatalf("Failed to iterate: %v", err)
+	}
+	networks := make([]netip.Prefix, 0, len(result.Results))
+	for _, r := range result.Results {
+		networks = append(networks, r.Network)
+	}
+	if len(networks) != 3 || slices.Contains(networks, excludedNetwork) {
+		t.Errorf("Expected the 3 networks outside %s, got %v", excludedNetwork, networks)
+	}
+	if result.TotalProcessed != 3 {
+		t.Errorf("Expected excluded networks not to be processed, got %d", result.TotalProcessed)
+	}
+
+	// The excluded networks survive a resume token round trip.
+	resumed, err := manager.ResumeIterator(reader, result.ResumeToken)
+	if err != nil {
+		t.Fatalf("Failed to resume iterator: %v", err)
+	}
+	if !slices.Equal(resumed.ExcludeNetworks, []netip.Prefix{excludedNetwork}) {
+		t.Errorf("Expected ExcludeNetworks to be restored, got %v", resumed.ExcludeNetworks)
+	}
+
+	// A scan of a bogon network yields nothing.
+	iter, err = manager.CreateIteratorWithOptions(
+		reader,
+		"GeoLite2-City-Test.mmdb",
+		netip.MustParsePrefix("10.0.0.0/8"),
+		nil,
+		filterModeAnd,
+		Options{ExcludeNetworks: BogonNetworks()},
+	)
+	if err != nil {
+		t.Fatalf("Failed to create iterator: %v", err)
+	}
+	result, err = manager.Iterate(iter, 1000)
+	if err != nil {
+		t.Fatalf("Failed to iterate: %v", err)
+	}
+	if len(result.Results) != 0 || result.TotalProcessed != 0 || result.HasMore {
+		t.Errorf("Expected no networks within 10.0.0.0/8, got %+v", result)
+	}
+}
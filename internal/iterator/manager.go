@@ -2,14 +2,18 @@
 package iterator
 
 import (
+	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/netip"
+	"slices"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/oschwald/maxminddb-mcp/internal/filter"
@@ -24,14 +28,103 @@ type ManagedIterator struct {
 	FilterEngine *filter.Engine
 	Reader       *maxminddb.Reader
 	Network      netip.Prefix
-	LastNetwork  netip.Prefix
-	FilterMode   string
-	Database     string
-	ID           string
-	Filters      []filter.Filter
-	Processed    int64
-	Matched      int64
-	mu           sync.RWMutex
+	// LastNetwork is the last network processed. The next batch starts
+	// after it.
+	LastNetwork netip.Prefix
+	// LastAggregate is the last network returned with MaxPrefixLength
+	// aggregation. Later networks inside it are skipped, including in the
+	// following batches.
+	LastAggregate netip.Prefix
+	FilterMode    string
+	Database      string
+	ID            string
+	Filters       []filter.Filter
+	// ExtraNetworks lists networks scanned after Network, e.g. the
+	// remaining prefixes of an address range.
+	ExtraNetworks []netip.Prefix
+	// ExcludeNetworks lists networks whose subnetworks are skipped.
+	ExcludeNetworks []netip.Prefix
+	// decodePaths holds the split filter field paths when the filters touch
+	// few enough fields to be evaluated with DecodePath. It is nil when
+	// records must be fully decoded for matching.
+	decodePaths [][]any
+	Processed   int64
+	Matched     int64
+	// Batches is the number of batches served, including those served
+	// before the iterator was resumed from a token.
+	Batches int64
+	// MaxPrefixLength aggregates networks longer than this prefix length
+	// into their enclosing network. Zero disables aggregation.
+	MaxPrefixLength int
+	mu              sync.RWMutex
+	// IncludeAliasedNetworks reports whether networks reachable through
+	// IPv4-in-IPv6 aliases such as ::ffff:0:0/96 are iterated.
+	IncludeAliasedNetworks bool
+	// TypedValues reports whether records are decoded with DecodeTyped.
+	TypedValues bool
+	// pseudoFields reports whether the filters reference pseudo-fields such
+	// as __prefix_length__, which are added to records before matching.
+	pseudoFields bool
+}
+
+// Options holds optional iteration settings.
+type Options struct {
+	// IncludeAliasedNetworks iterates networks reachable through IPv4-in-IPv6
+	// aliases. By default they are skipped so that IPv4 data in IPv6
+	// databases is only returned once.
+	IncludeAliasedNetworks bool
+	// MaxPrefixLength limits the granularity of the results. Networks with
+	// a longer prefix are returned once as their enclosing network of this
+	// length, with the data of the first matching network inside it. The
+	// length applies to each network's own address family. Zero returns
+	// networks at full granularity.
+	MaxPrefixLength int
+	// ExtraNetworks lists networks to scan after the main network, e.g. the
+	// remaining prefixes of an address range. They must follow the main
+	// network in address order and must not overlap it or each other.
+	ExtraNetworks []netip.Prefix
+	// ExcludeNetworks lists networks to skip, e.g. BogonNetworks. Database
+	// networks within one of them are neither returned nor counted as
+	// processed. Database networks that only partly overlap them are kept.
+	ExcludeNetworks []netip.Prefix
+	// TypedValues returns records decoded with DecodeTyped, annotating
+	// numbers with their MMDB type. Filters are still matched against the
+	// plain record.
+	TypedValues bool
+}
+
+// maxDecodePathFields is the maximum number of distinct filter fields for
+// which records are matched using DecodePath rather than a full decode.
+const maxDecodePathFields = 4
+
+// getReader safely gets the Reader field.
+func (iter *ManagedIterator) getReader() *maxminddb.Reader {
+	iter.mu.RLock()
+	defer iter.mu.RUnlock()
+	return iter.Reader
+}
+
+// SetReader replaces the reader the iterator scans, e.g. with a reader of
+// the reopened file after a stale file handle. Batches already running keep
+// the reader they started with.
+func (iter *ManagedIterator) SetReader(reader *maxminddb.Reader) {
+	iter.mu.Lock()
+	defer iter.mu.Unlock()
+	iter.Reader = reader
+}
+
+// getLastAccess safely gets the LastAccess field.
+func (iter *ManagedIterator) getLastAccess() time.Time {
+	iter.mu.RLock()
+	defer iter.mu.RUnlock()
+	return iter.LastAccess
+}
+
+// touch safely sets the LastAccess field to now.
+func (iter *ManagedIterator) touch() {
+	iter.mu.Lock()
+	defer iter.mu.Unlock()
+	iter.LastAccess = time.Now()
 }
 
 // getLastNetwork safely gets the LastNetwork field.
@@ -48,6 +141,20 @@ func (iter *ManagedIterator) setLastNetwork(network netip.Prefix) {
 	iter.LastNetwork = network
 }
 
+// getLastAggregate safely gets the LastAggregate field.
+func (iter *ManagedIterator) getLastAggregate() netip.Prefix {
+	iter.mu.RLock()
+	defer iter.mu.RUnlock()
+	return iter.LastAggregate
+}
+
+// setLastAggregate safely sets the LastAggregate field.
+func (iter *ManagedIterator) setLastAggregate(network netip.Prefix) {
+	iter.mu.Lock()
+	defer iter.mu.Unlock()
+	iter.LastAggregate = network
+}
+
 // getProcessedMatched safely gets the Processed and Matched counters.
 func (iter *ManagedIterator) getProcessedMatched() (processed, matched int64) {
 	iter.mu.RLock()
@@ -70,6 +177,27 @@ func (iter *ManagedIterator) incrementProcessed() {
 	iter.Processed++
 }
 
+// getBatches safely gets the Batches counter.
+func (iter *ManagedIterator) getBatches() int64 {
+	iter.mu.RLock()
+	defer iter.mu.RUnlock()
+	return iter.Batches
+}
+
+// setBatches safely sets the Batches counter.
+func (iter *ManagedIterator) setBatches(batches int64) {
+	iter.mu.Lock()
+	defer iter.mu.Unlock()
+	iter.Batches = batches
+}
+
+// incrementBatches safely increments the Batches counter.
+func (iter *ManagedIterator) incrementBatches() {
+	iter.mu.Lock()
+	defer iter.mu.Unlock()
+	iter.Batches++
+}
+
 // incrementMatched safely increments the Matched counter.
 func (iter *ManagedIterator) incrementMatched() {
 	iter.mu.Lock()
@@ -79,51 +207,153 @@ func (iter *ManagedIterator) incrementMatched() {
 
 // ResumeToken contains information needed to resume iteration.
 type ResumeToken struct {
-	LastNetwork string          `json:"last_network"`
-	Database    string          `json:"database"`
-	Network     string          `json:"network"`
-	FilterMode  string          `json:"filter_mode"`
-	Filters     []filter.Filter `json:"filters"`
-	Processed   int64           `json:"processed"`
-	Matched     int64           `json:"matched"`
+	LastNetwork     string          `json:"last_network"`
+	Database        string          `json:"database"`
+	Network         string          `json:"network"`
+	FilterMode      string          `json:"filter_mode"`
+	Filters         []filter.Filter `json:"filters"`
+	ExtraNetworks   []string        `json:"extra_networks,omitempty"`
+	ExcludeNetworks []string        `json:"exclude_networks,omitempty"`
+	Processed       int64           `json:"processed"`
+	Matched         int64           `json:"matched"`
+	// The fields below are omitted when unset so that tokens created before
+	// they existed resume with the default behavior.
+	LastAggregate          string `json:"last_aggregate,omitempty"`
+	MaxPrefixLength        int    `json:"max_prefix_length,omitempty"`
+	Batches                int64  `json:"batches,omitempty"`
+	IncludeAliasedNetworks bool   `json:"include_aliased_networks,omitempty"`
+	TypedValues            bool   `json:"typed_values,omitempty"`
 }
 
 // NetworkResult represents a single network result.
 type NetworkResult struct {
-	Data    map[string]any `json:"data"`
-	Network netip.Prefix   `json:"network"`
+	// Data is the record of the network. After DedupeRecords, it is nil and
+	// Record refers to the record instead.
+	Data map[string]any `json:"data,omitzero"`
+	// Record is the index of the record of the network in the Records of
+	// the batch. It is only set by DedupeRecords.
+	Record  *int         `json:"record,omitempty"`
+	Network netip.Prefix `json:"network"`
+	// offset is the data section offset of the record, identifying it
+	// within the database.
+	offset uintptr
 }
 
 // IterationResult contains the results of an iteration batch.
 type IterationResult struct {
-	IteratorID         string          `json:"iterator_id"`
-	ResumeToken        string          `json:"resume_token"`
-	Results            []NetworkResult `json:"results"`
-	TotalProcessed     int64           `json:"total_processed"`
-	TotalMatched       int64           `json:"total_matched"`
-	EstimatedRemaining int64           `json:"estimated_remaining,omitempty"`
-	HasMore            bool            `json:"has_more"`
+	IteratorID  string `json:"iterator_id"`
+	ResumeToken string `json:"resume_token"`
+	// ResumeSource tells how the iterator of the batch was obtained. It is
+	// set by the caller, e.g. to "fresh", "iterator_id", or "resume_token".
+	ResumeSource string          `json:"resume_source,omitempty"`
+	Results      []NetworkResult `json:"results"`
+	// Records holds the unique records of the batch after DedupeRecords.
+	Records            []map[string]any `json:"records,omitempty"`
+	TotalProcessed     int64            `json:"total_processed"`
+	TotalMatched       int64            `json:"total_matched"`
+	EstimatedRemaining int64            `json:"estimated_remaining,omitempty"`
+	HasMore            bool             `json:"has_more"`
+	// Canceled reports whether the batch was cut short by CancelIteration.
+	// The iterator can continue after the last processed network.
+	Canceled bool `json:"canceled,omitempty"`
 }
 
 // Manager manages stateful network iterators.
 type Manager struct {
-	iterators       map[string]*ManagedIterator
-	stopCleanup     chan struct{}
-	ttl             time.Duration
-	cleanupInterval time.Duration
-	mu              sync.RWMutex
+	iterators        map[string]*ManagedIterator
+	running          map[string]*runningIteration
+	stopCleanup      chan struct{}
+	ttl              time.Duration
+	cleanupInterval  time.Duration
+	gracePeriod      time.Duration
+	maxResponseBytes int
+	maxBatches       int
+	maxRegexLength   int
+	mu               sync.RWMutex
+	deterministicIDs bool
 }
 
-// New creates a new iterator manager.
+// MinCleanupInterval is the shortest cleanup interval used by a Manager.
+const MinCleanupInterval = time.Second
+
+// New creates a new iterator manager. Cleanup intervals shorter than
+// MinCleanupInterval, including zero and negative ones, are raised to it.
 func New(ttl, cleanupInterval time.Duration) *Manager {
 	return &Manager{
 		iterators:       make(map[string]*ManagedIterator),
+		running:         make(map[string]*runningIteration),
 		ttl:             ttl,
-		cleanupInterval: cleanupInterval,
+		cleanupInterval: max(cleanupInterval, MinCleanupInterval),
 		stopCleanup:     make(chan struct{}),
 	}
 }
 
+// SetMaxResponseBytes sets the approximate serialized size at which an
+// iteration batch is cut short. Zero or less disables the budget.
+func (m *Manager) SetMaxResponseBytes(maxBytes int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.maxResponseBytes = maxBytes
+}
+
+// SetMaxRegexLength sets the maximum length of regex patterns in the
+// filters of resume tokens, which clients can craft, matching the
+// max_regex_length limit applied to request filters. Zero or less keeps
+// filter.DefaultMaxRegexLength.
+func (m *Manager) SetMaxRegexLength(maxLength int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.maxRegexLength = maxLength
+}
+
+// filterLimits returns the limits applied to resume token filters.
+func (m *Manager) filterLimits() filter.Limits {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	limits := filter.DefaultLimits()
+	if m.maxRegexLength > 0 {
+		limits.MaxRegexLength = m.maxRegexLength
+	}
+	return limits
+}
+
+// ErrBatchBudgetExhausted is returned by Iterate when an iterator has served
+// the maximum number of batches set with SetMaxBatches. The iterator is
+// removed.
+var ErrBatchBudgetExhausted = errors.New("iterator has exhausted its batch budget")
+
+// SetMaxBatches sets the maximum number of batches a single iterator may
+// serve, counting batches served before it was resumed from a token, so
+// that a client cannot paginate a huge range forever. Once an iterator has
+// served maxBatches batches, the next Iterate call removes it and returns
+// ErrBatchBudgetExhausted. Zero or less disables the limit.
+func (m *Manager) SetMaxBatches(maxBatches int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.maxBatches = maxBatches
+}
+
+// SetGracePeriod sets a minimum lifetime for iterators. Iterators created
+// less than gracePeriod ago are not evicted, even if they have not been
+// accessed within the TTL. Zero or less disables the grace period.
+func (m *Manager) SetGracePeriod(gracePeriod time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.gracePeriod = gracePeriod
+}
+
+// SetDeterministicIDs controls how iterator IDs are generated. When enabled,
+// the ID is derived from the query, so repeating a query while its iterator
+// is still alive returns that iterator instead of creating a new one. IDs
+// are random by default so that clients cannot collide on each other's
+// iterators.
+func (m *Manager) SetDeterministicIDs(enabled bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deterministicIDs = enabled
+}
+
 // StartCleanup starts the cleanup goroutine.
 func (m *Manager) StartCleanup() {
 	go func() {
@@ -146,6 +376,13 @@ func (m *Manager) StopCleanup() {
 	close(m.stopCleanup)
 }
 
+// CleanupNow removes expired iterators immediately, e.g. in tests or
+// short-lived CLI invocations. It is safe to call whether or not the cleanup
+// goroutine started by StartCleanup is running.
+func (m *Manager) CleanupNow() {
+	m.cleanupExpired()
+}
+
 // CreateIterator creates a new iterator for a network range.
 func (m *Manager) CreateIterator(
 	reader *maxminddb.Reader,
@@ -154,52 +391,150 @@ func (m *Manager) CreateIterator(
 	filters []filter.Filter,
 	filterMode string,
 ) (*ManagedIterator, error) {
-	iterator, err := m.createIteratorNoStart(reader, database, network, filters, filterMode)
+	return m.CreateIteratorWithOptions(reader, database, network, filters, filterMode, Options{})
+}
+
+// CreateIteratorWithOptions creates a new iterator for a network range using
+// the given iteration options.
+func (m *Manager) CreateIteratorWithOptions(
+	reader *maxminddb.Reader,
+	database string,
+	network netip.Prefix,
+	filters []filter.Filter,
+	filterMode string,
+	opts Options,
+) (*ManagedIterator, error) {
+	iterator, err := m.createIteratorNoStart(reader, database, network, filters, filterMode, opts, nil)
 	if err != nil {
 		return nil, err
 	}
 	return iterator, nil
 }
 
-// ResumeIterator creates a new iterator from a resume token.
+// ResumeIterator creates a new iterator from a resume token. The iterator
+// gets a new ID and is stored like any other iterator, so it can be
+// retrieved with GetIterator right away. Callers should hand the new ID to
+// clients so that they stop resuming from the token.
+//
+// With deterministic IDs, an iterator for the same query may still be
+// alive. It is then returned untouched rather than moved to the token's
+// position, which would rewind or advance the scan of whoever else uses it.
 func (m *Manager) ResumeIterator(reader *maxminddb.Reader, token string) (*ManagedIterator, error) {
+	decoded, err := m.decodeResumeToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create iterator from resume token state
+	iterator, err := m.createIteratorNoStart(
+		reader,
+		decoded.Database,
+		decoded.network,
+		decoded.Filters,
+		decoded.FilterMode,
+		Options{
+			IncludeAliasedNetworks: decoded.IncludeAliasedNetworks,
+			MaxPrefixLength:        decoded.MaxPrefixLength,
+			ExtraNetworks:          decoded.extraNetworks,
+			ExcludeNetworks:        decoded.excludeNetworks,
+			TypedValues:            decoded.TypedValues,
+		},
+		decoded,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return iterator, nil
+}
+
+// restore sets the state of a new iterator from a resume token.
+func (iter *ManagedIterator) restore(decoded *decodedResumeToken) {
+	iter.updateCounters(decoded.Processed, decoded.Matched)
+	iter.setBatches(decoded.Batches)
+
+	// Restore last network if available for resume point
+	if decoded.lastNetwork.IsValid() {
+		iter.setLastNetwork(decoded.lastNetwork)
+	}
+	if decoded.lastAggregate.IsValid() {
+		iter.setLastAggregate(decoded.lastAggregate)
+	}
+}
+
+// DecodeResumeToken decodes a resume token and checks that it can be
+// resumed, without creating an iterator. It does not check that the
+// token's database is loaded.
+func (m *Manager) DecodeResumeToken(token string) (*ResumeToken, error) {
+	decoded, err := m.decodeResumeToken(token)
+	if err != nil {
+		return nil, err
+	}
+	return decoded.ResumeToken, nil
+}
+
+// decodedResumeToken is a resume token with its networks parsed.
+type decodedResumeToken struct {
+	*ResumeToken
+	extraNetworks   []netip.Prefix
+	excludeNetworks []netip.Prefix
+	network         netip.Prefix
+	lastNetwork     netip.Prefix
+	lastAggregate   netip.Prefix
+}
+
+// decodeResumeToken parses a resume token and validates its contents.
+func (m *Manager) decodeResumeToken(token string) (*decodedResumeToken, error) {
 	// Parse resume token
 	resumeToken, err := m.parseResumeToken(token)
 	if err != nil {
 		return nil, fmt.Errorf("invalid resume token: %w", err)
 	}
+	decoded := &decodedResumeToken{ResumeToken: resumeToken}
 
 	// Parse network
-	network, err := netip.ParsePrefix(resumeToken.Network)
+	decoded.network, err = netip.ParsePrefix(resumeToken.Network)
 	if err != nil {
 		return nil, fmt.Errorf("invalid network in resume token: %w", err)
 	}
 
-	// Create iterator from resume token state
-	iterator, err := m.createIteratorNoStart(
-		reader,
-		resumeToken.Database,
-		network,
-		resumeToken.Filters,
-		resumeToken.FilterMode,
-	)
-	if err != nil {
-		return nil, err
+	// Tokens are client-supplied, so hold their filters to the same limits
+	// as request filters.
+	if err := filter.ValidateWithLimits(resumeToken.Filters, m.filterLimits()); err != nil {
+		return nil, fmt.Errorf("invalid filters in resume token: %w", err)
 	}
 
-	// Restore state from token
-	iterator.updateCounters(resumeToken.Processed, resumeToken.Matched)
+	decoded.extraNetworks = make([]netip.Prefix, 0, len(resumeToken.ExtraNetworks))
+	for _, extra := range resumeToken.ExtraNetworks {
+		prefix, err := netip.ParsePrefix(extra)
+		if err != nil {
+			return nil, fmt.Errorf("invalid extra network in resume token: %w", err)
+		}
+		decoded.extraNetworks = append(decoded.extraNetworks, prefix)
+	}
+
+	for _, excluded := range resumeToken.ExcludeNetworks {
+		prefix, err := netip.ParsePrefix(excluded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid excluded network in resume token: %w", err)
+		}
+		decoded.excludeNetworks = append(decoded.excludeNetworks, prefix)
+	}
 
-	// Restore last network if available for resume point
 	if resumeToken.LastNetwork != "" {
-		lastNetwork, err := netip.ParsePrefix(resumeToken.LastNetwork)
+		decoded.lastNetwork, err = netip.ParsePrefix(resumeToken.LastNetwork)
 		if err != nil {
 			return nil, fmt.Errorf("invalid last network in resume token: %w", err)
 		}
-		iterator.setLastNetwork(lastNetwork)
 	}
 
-	return iterator, nil
+	if resumeToken.LastAggregate != "" {
+		decoded.lastAggregate, err = netip.ParsePrefix(resumeToken.LastAggregate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid last aggregate in resume token: %w", err)
+		}
+	}
+
+	return decoded, nil
 }
 
 // GetIterator retrieves an existing iterator by ID.
@@ -209,72 +544,269 @@ func (m *Manager) GetIterator(id string) (*ManagedIterator, bool) {
 
 	iterator, exists := m.iterators[id]
 	if exists {
-		iterator.LastAccess = time.Now()
+		iterator.touch()
 	}
 
 	return iterator, exists
 }
 
-// Iterate performs one iteration batch over the reader.
+// runningIteration is an iteration batch in progress.
+type runningIteration struct {
+	cancel context.CancelFunc
+}
+
+// CancelIteration interrupts the iteration batch in progress for the
+// iterator with the given ID. The batch returns promptly with the results so
+// far and Canceled set. It reports whether a batch was running.
+func (m *Manager) CancelIteration(id string) bool {
+	m.mu.RLock()
+	running, ok := m.running[id]
+	m.mu.RUnlock()
+	if !ok {
+		return false
+	}
+	running.cancel()
+	return true
+}
+
+// startIteration registers an iteration batch for the iterator so that it
+// can be canceled. The returned function unregisters it.
+func (m *Manager) startIteration(id string) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	running := &runningIteration{cancel: cancel}
+
+	m.mu.Lock()
+	m.running[id] = running
+	m.mu.Unlock()
+
+	return ctx, func() {
+		cancel()
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		// A concurrent batch of the same iterator may have replaced it.
+		if m.running[id] == running {
+			delete(m.running, id)
+		}
+	}
+}
+
+// Iterate performs one iteration batch over the reader. Networks are read
+// from the reader only as the batch needs them, with no lookahead, so a batch
+// holds at most maxResults results and the next batch resumes after the last
+// network processed.
 func (m *Manager) Iterate(iterator *ManagedIterator, maxResults int) (*IterationResult, error) {
+	return m.iterate(iterator, maxResults, 0)
+}
+
+// scanCheckInterval is the number of records Scan processes between
+// checks of its context.
+const scanCheckInterval = 1024
+
+// Scan walks network once and calls yield with each network whose record
+// matches the filters, stopping early when yield returns false. Unlike an
+// iterator, it keeps no state to resume from, so it suits scans that are
+// consumed in one go. It returns ctx's error when ctx is done before the
+// scan completes. Records that cannot be decoded are skipped, as by Iterate.
+func Scan(
+	ctx context.Context,
+	reader *maxminddb.Reader,
+	network netip.Prefix,
+	filters []filter.Filter,
+	filterMode string,
+	yield func(netip.Prefix) bool,
+) error {
+	// A private manager keeps the scan apart from stored iterators.
+	iter, err := New(0, 0).createIteratorNoStart(reader, "", network, filters, filterMode, Options{}, nil)
+	if err != nil {
+		return err
+	}
+
+	records := 0
+	for result := range reader.NetworksWithin(network, iter.networksOptions()...) {
+		if records%scanCheckInterval == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		records++
+
+		_, matched, err := iter.decodeMatching(result)
+		if errors.Is(err, syscall.ESTALE) {
+			return fmt.Errorf("failed to decode %s: %w", result.Prefix(), err)
+		}
+		if err != nil || !matched {
+			continue
+		}
+		if !yield(result.Prefix()) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// Sample performs one iteration batch like Iterate, but also stops once
+// maxRecords records have been processed, whether or not they matched. It is
+// used to estimate the cost of a scan from a prefix of it.
+func (m *Manager) Sample(iterator *ManagedIterator, maxResults, maxRecords int) (*IterationResult, error) {
+	return m.iterate(iterator, maxResults, maxRecords)
+}
+
+// iterate performs one iteration batch, processing at most maxRecords
+// records if it is positive.
+func (m *Manager) iterate(
+	iterator *ManagedIterator,
+	maxResults int,
+	maxRecords int,
+) (*IterationResult, error) {
 	if iterator == nil {
 		return nil, errors.New("iterator cannot be nil")
 	}
 
-	iterator.LastAccess = time.Now()
+	ctx, done := m.startIteration(iterator.ID)
+	defer done()
+
+	iterator.touch()
+	reader := iterator.getReader()
+
+	m.mu.RLock()
+	maxBytes := m.maxResponseBytes
+	maxBatches := m.maxBatches
+	m.mu.RUnlock()
+
+	if maxBatches > 0 && iterator.getBatches() >= int64(maxBatches) {
+		m.RemoveIterator(iterator.ID)
+		return nil, ErrBatchBudgetExhausted
+	}
 
 	results := make([]NetworkResult, 0, maxResults)
+	responseBytes := 0
 
 	// Pull results directly from the reader, supporting resume via LastNetwork
 	skipUntil := iterator.getLastNetwork()
 	skipping := skipUntil.IsValid()
 	hasMore := false
+	canceled := false
+	startProcessed, startMatched := iterator.getProcessedMatched()
+
+	// With MaxPrefixLength, lastAggregate is the most recently returned
+	// enclosing network, possibly by an earlier batch. Later networks
+	// inside it are skipped.
+	startAggregate := iterator.getLastAggregate()
+	lastAggregate := startAggregate
+
+	// lastSeen is the most recently processed network. When a database
+	// network is larger than the scanned networks, each of them yields it,
+	// so it is only processed once.
+	var lastSeen netip.Prefix
+
+	records := 0
+
+scan:
+	for _, scanNetwork := range iterator.networks() {
+		// IPv6 databases return networks of the IPv4 subtree as IPv4
+		// prefixes, which never overlap an IPv6 scan network, so only scan
+		// networks of the same family are skipped.
+		if skipping && scanNetwork.Addr().Is4() == skipUntil.Addr().Is4() && !scanNetwork.Overlaps(skipUntil) {
+			continue
+		}
+		if iterator.excluded(scanNetwork) {
+			continue
+		}
 
-	for result := range iterator.Reader.NetworksWithin(iterator.Network) {
-		// Resume point handling: include LastNetwork again for continuity
-		if skipping {
-			if result.Prefix() != skipUntil {
+		for result := range reader.NetworksWithin(scanNetwork, iterator.networksOptions()...) {
+			// Resume after LastNetwork. It was processed by the previous
+			// batch, and returned if it matched, so it is skipped too.
+			if skipping {
+				if result.Prefix() == skipUntil {
+					skipping = false
+					lastSeen = skipUntil
+				}
+				continue
+			} else if result.Prefix() == lastSeen {
 				continue
 			}
-			skipping = false
-		}
+			lastSeen = result.Prefix()
 
-		iterator.incrementProcessed()
+			if iterator.excluded(result.Prefix()) {
+				continue
+			}
 
-		// Decode the result data
-		var record map[string]any
-		if err := result.Decode(&record); err != nil {
-			// Skip records that can't be decoded
-			iterator.setLastNetwork(result.Prefix())
-			continue
-		}
+			if maxRecords > 0 && records >= maxRecords {
+				hasMore = true
+				break scan
+			}
 
-		iterator.setLastNetwork(result.Prefix())
+			select {
+			case <-ctx.Done():
+				hasMore = true
+				canceled = true
+				break scan
+			default:
+			}
+			records++
+
+			iterator.incrementProcessed()
+
+			// Decode the result data and apply filters if present
+			record, matched, err := iterator.decodeMatching(result)
+			if errors.Is(err, syscall.ESTALE) {
+				// Undo the batch so that it can be retried with a fresh
+				// reader.
+				iterator.setLastNetwork(skipUntil)
+				iterator.setLastAggregate(startAggregate)
+				iterator.updateCounters(startProcessed, startMatched)
+				return nil, fmt.Errorf("failed to decode %s: %w", result.Prefix(), err)
+			}
+			iterator.setLastNetwork(result.Prefix())
+			if err != nil {
+				// Skip records that can't be decoded
+				continue
+			}
 
-		// Apply filters if present
-		if iterator.FilterEngine != nil {
-			if !iterator.FilterEngine.Matches(record) {
+			if !matched {
 				if len(results) >= maxResults {
 					hasMore = true
-					break
+					break scan
 				}
 				continue // Skip non-matching records
 			}
-		}
 
-		iterator.incrementMatched()
+			network := result.Prefix()
+			if iterator.MaxPrefixLength > 0 && network.Bits() > iterator.MaxPrefixLength {
+				network, _ = network.Addr().Prefix(iterator.MaxPrefixLength)
+				if network == lastAggregate {
+					continue
+				}
+				lastAggregate = network
+				iterator.setLastAggregate(network)
+			}
+
+			iterator.incrementMatched()
+
+			networkResult := NetworkResult{
+				Network: network,
+				Data:    record,
+				offset:  result.Offset(),
+			}
+			results = append(results, networkResult)
 
-		results = append(results, NetworkResult{
-			Network: result.Prefix(),
-			Data:    record,
-		})
+			if len(results) >= maxResults {
+				hasMore = true
+				break scan
+			}
 
-		if len(results) >= maxResults {
-			hasMore = true
-			break
+			// Stop early once the response budget is used up. The batch always
+			// includes at least one result so that iteration makes progress.
+			if maxBytes > 0 {
+				responseBytes += estimateSize(networkResult)
+				if responseBytes >= maxBytes {
+					hasMore = true
+					break scan
+				}
+			}
 		}
 	}
 
+	iterator.incrementBatches()
+
 	// Generate resume token
 	resumeToken, err := m.generateResumeToken(iterator)
 	if err != nil {
@@ -291,9 +823,157 @@ func (m *Manager) Iterate(iterator *ManagedIterator, maxResults int) (*Iteration
 		TotalProcessed:     totalProcessed,
 		TotalMatched:       totalMatched,
 		EstimatedRemaining: 0,
+		Canceled:           canceled,
 	}, nil
 }
 
+// networks returns the networks to scan, in order.
+func (iter *ManagedIterator) networks() []netip.Prefix {
+	return append([]netip.Prefix{iter.Network}, iter.ExtraNetworks...)
+}
+
+// networksOptions returns the NetworksWithin options for the iterator.
+func (iter *ManagedIterator) networksOptions() []maxminddb.NetworksOption {
+	var options []maxminddb.NetworksOption
+	if iter.IncludeAliasedNetworks {
+		options = append(options, maxminddb.IncludeAliasedNetworks())
+	}
+	return options
+}
+
+// decodeMatching decodes a record and reports whether it matches the
+// iterator's filters. When the filters only reference a few fields, those
+// fields are decoded first with DecodePath and the full record is only
+// decoded for matches. Non-matching records are returned as nil.
+func (iter *ManagedIterator) decodeMatching(
+	result maxminddb.Result,
+) (map[string]any, bool, error) {
+	if iter.FilterEngine == nil {
+		record, err := iter.decodeRecord(result)
+		return record, err == nil, err
+	}
+
+	if iter.decodePaths != nil {
+		// On error, fall back to matching against the full record.
+		if partial, err := decodePartial(result, iter.decodePaths); err == nil {
+			if iter.pseudoFields {
+				setPseudoFields(partial, result.Prefix())
+			}
+			if !iter.FilterEngine.Matches(partial) {
+				return nil, false, nil
+			}
+			record, err := iter.decodeRecord(result)
+			if err != nil {
+				return nil, false, err
+			}
+			return record, true, nil
+		}
+	}
+
+	var record map[string]any
+	if err := result.Decode(&record); err != nil {
+		return nil, false, err
+	}
+	if iter.pseudoFields {
+		if record == nil {
+			record = make(map[string]any, 2)
+		}
+		setPseudoFields(record, result.Prefix())
+		defer deletePseudoFields(record)
+	}
+	if !iter.FilterEngine.Matches(record) {
+		return nil, false, nil
+	}
+	if iter.TypedValues {
+		typed, err := DecodeTyped(result)
+		if err != nil {
+			return nil, false, err
+		}
+		return typed, true, nil
+	}
+	return record, true, nil
+}
+
+// decodeRecord decodes a full record for the results, with DecodeTyped if
+// the iterator returns typed values. Filters are always matched against
+// plain records.
+func (iter *ManagedIterator) decodeRecord(result maxminddb.Result) (map[string]any, error) {
+	if iter.TypedValues {
+		return DecodeTyped(result)
+	}
+	var record map[string]any
+	if err := result.Decode(&record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// setPseudoFields adds the pseudo-fields of network to a record. The prefix
+// length is a float64 so that it compares equal to JSON numbers.
+func setPseudoFields(record map[string]any, network netip.Prefix) {
+	record[filter.PrefixField] = network.String()
+	record[filter.PrefixLengthField] = float64(network.Bits())
+}
+
+// deletePseudoFields removes the pseudo-fields added by setPseudoFields.
+func deletePseudoFields(record map[string]any) {
+	delete(record, filter.PrefixField)
+	delete(record, filter.PrefixLengthField)
+}
+
+// decodePartial decodes only the given paths of a record into a nested map
+// with the same shape as a full decode. Missing paths are left out.
+func decodePartial(result maxminddb.Result, paths [][]any) (map[string]any, error) {
+	partial := make(map[string]any, len(paths))
+	for _, path := range paths {
+		var value any
+		if err := result.DecodePath(&value, path...); err != nil {
+			return nil, err
+		}
+		if value == nil {
+			continue
+		}
+
+		current := partial
+		for i, part := range path {
+			key, _ := part.(string)
+			if i == len(path)-1 {
+				current[key] = value
+				break
+			}
+			next, ok := current[key].(map[string]any)
+			if !ok {
+				next = make(map[string]any)
+				current[key] = next
+			}
+			current = next
+		}
+	}
+	return partial, nil
+}
+
+// filterDecodePaths returns the DecodePath arguments for the engine's
+// fields, or nil if there are too many fields for DecodePath to pay off.
+func filterDecodePaths(engine *filter.Engine) [][]any {
+	fields := slices.DeleteFunc(engine.Fields(), filter.IsPseudoField)
+	if len(fields) > maxDecodePathFields {
+		return nil
+	}
+
+	// Pseudo-fields are not decoded, so filters on nothing else match
+	// against an empty partial record.
+	paths := make([][]any, 0, len(fields))
+	for _, field := range fields {
+		parts := strings.Split(field, ".")
+		path := make([]any, len(parts))
+		for i, part := range parts {
+			path[i] = part
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
 // RemoveIterator removes an iterator.
 func (m *Manager) RemoveIterator(id string) {
 	m.mu.Lock()
@@ -302,14 +982,27 @@ func (m *Manager) RemoveIterator(id string) {
 	delete(m.iterators, id)
 }
 
-// cleanupExpired removes expired iterators.
+// Clear removes all iterators and returns the number removed.
+func (m *Manager) Clear() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	count := len(m.iterators)
+	m.iterators = make(map[string]*ManagedIterator)
+	return count
+}
+
+// cleanupExpired removes expired iterators that are past the grace period.
 func (m *Manager) cleanupExpired() {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	now := time.Now()
 	for id, iterator := range m.iterators {
-		if now.Sub(iterator.LastAccess) > m.ttl {
+		if now.Sub(iterator.Created) < m.gracePeriod {
+			continue
+		}
+		if now.Sub(iterator.getLastAccess()) > m.ttl {
 			delete(m.iterators, id)
 		}
 	}
@@ -327,11 +1020,25 @@ func (*Manager) generateResumeToken(iterator *ManagedIterator) (string, error) {
 		FilterMode: iterator.FilterMode,
 		Processed:  processed,
 		Matched:    matched,
+
+		Batches:                iterator.getBatches(),
+		IncludeAliasedNetworks: iterator.IncludeAliasedNetworks,
+		MaxPrefixLength:        iterator.MaxPrefixLength,
+		TypedValues:            iterator.TypedValues,
+	}
+	for _, extra := range iterator.ExtraNetworks {
+		token.ExtraNetworks = append(token.ExtraNetworks, extra.String())
+	}
+	for _, excluded := range iterator.ExcludeNetworks {
+		token.ExcludeNetworks = append(token.ExcludeNetworks, excluded.String())
 	}
 
 	if lastNetwork.IsValid() {
 		token.LastNetwork = lastNetwork.String()
 	}
+	if lastAggregate := iterator.getLastAggregate(); lastAggregate.IsValid() {
+		token.LastAggregate = lastAggregate.String()
+	}
 
 	data, err := json.Marshal(token)
 	if err != nil {
@@ -356,19 +1063,25 @@ func (*Manager) parseResumeToken(tokenStr string) (*ResumeToken, error) {
 	return &token, nil
 }
 
-// createIteratorNoStart creates a new iterator without any background goroutines.
+// createIteratorNoStart creates a new iterator without any background
+// goroutines. If resume is not nil, the new iterator starts from its state.
+// An existing iterator returned for a deterministic ID is left as it is.
 func (m *Manager) createIteratorNoStart(
 	reader *maxminddb.Reader,
 	database string,
 	network netip.Prefix,
 	filters []filter.Filter,
 	filterMode string,
+	opts Options,
+	resume *decodedResumeToken,
 ) (*ManagedIterator, error) {
 	// Normalize filter mode to "and" or "or", default to "and"
 	normalizedMode := normalizeFilterMode(filterMode)
 
 	// Create filter engine
 	var filterEngine *filter.Engine
+	var decodePaths [][]any
+	var pseudoFields bool
 	if len(filters) > 0 {
 		// Normalize operator aliases (e.g., eq -> equals)
 		norm := make([]filter.Filter, 0, len(filters))
@@ -377,11 +1090,22 @@ func (m *Manager) createIteratorNoStart(
 			norm = append(norm, f)
 		}
 		filterEngine = filter.New(norm, filter.Mode(normalizedMode))
+		decodePaths = filterDecodePaths(filterEngine)
+		pseudoFields = slices.ContainsFunc(filterEngine.Fields(), filter.IsPseudoField)
 		filters = norm
 	}
 
-	// Generate unique ID
-	id, err := generateID()
+	m.mu.RLock()
+	deterministic := m.deterministicIDs
+	m.mu.RUnlock()
+
+	var id string
+	var err error
+	if deterministic {
+		id, err = queryID(database, network, filters, normalizedMode, opts)
+	} else {
+		id, err = generateID()
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate iterator ID: %w", err)
 	}
@@ -394,17 +1118,41 @@ func (m *Manager) createIteratorNoStart(
 		Filters:      filters,
 		FilterMode:   normalizedMode,
 		FilterEngine: filterEngine,
+		decodePaths:  decodePaths,
+		pseudoFields: pseudoFields,
 		Created:      time.Now(),
 		LastAccess:   time.Now(),
+
+		IncludeAliasedNetworks: opts.IncludeAliasedNetworks,
+		MaxPrefixLength:        opts.MaxPrefixLength,
+		ExtraNetworks:          opts.ExtraNetworks,
+		ExcludeNetworks:        opts.ExcludeNetworks,
+		TypedValues:            opts.TypedValues,
+	}
+	if resume != nil {
+		iterator.restore(resume)
 	}
 
 	m.mu.Lock()
+	defer m.mu.Unlock()
+	if existing, exists := m.iterators[id]; exists && deterministic {
+		existing.touch()
+		return existing, nil
+	}
 	m.iterators[id] = iterator
-	m.mu.Unlock()
 
 	return iterator, nil
 }
 
+// estimateSize returns the approximate serialized size of a result in bytes.
+func estimateSize(result NetworkResult) int {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return 0
+	}
+	return len(data)
+}
+
 // generateID generates a random iterator ID.
 func generateID() (string, error) {
 	bytes := make([]byte, 16)
@@ -414,6 +1162,44 @@ func generateID() (string, error) {
 	return base64.URLEncoding.EncodeToString(bytes), nil
 }
 
+// queryID derives an iterator ID from the query, so identical queries get
+// identical IDs. filters and mode must already be normalized.
+func queryID(
+	database string,
+	network netip.Prefix,
+	filters []filter.Filter,
+	mode string,
+	opts Options,
+) (string, error) {
+	query := struct {
+		Database               string          `json:"database"`
+		Network                string          `json:"network"`
+		ExtraNetworks          []netip.Prefix  `json:"extra_networks,omitempty"`
+		ExcludeNetworks        []netip.Prefix  `json:"exclude_networks,omitempty"`
+		Filters                []filter.Filter `json:"filters"`
+		Mode                   string          `json:"mode"`
+		MaxPrefixLength        int             `json:"max_prefix_length"`
+		IncludeAliasedNetworks bool            `json:"include_aliased_networks"`
+		TypedValues            bool            `json:"typed_values,omitempty"`
+	}{
+		Database:               database,
+		Network:                network.String(),
+		ExtraNetworks:          opts.ExtraNetworks,
+		ExcludeNetworks:        opts.ExcludeNetworks,
+		Filters:                filters,
+		Mode:                   mode,
+		MaxPrefixLength:        opts.MaxPrefixLength,
+		IncludeAliasedNetworks: opts.IncludeAliasedNetworks,
+		TypedValues:            opts.TypedValues,
+	}
+	data, err := json.Marshal(query)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return base64.URLEncoding.EncodeToString(sum[:16]), nil
+}
+
 // normalizeFilterMode normalizes filter mode to "and" or "or", defaulting to "and".
 func normalizeFilterMode(mode string) string {
 	switch strings.ToLower(mode) {
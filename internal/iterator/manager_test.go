@@ -1,9 +1,14 @@
 package iterator
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"net/netip"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -230,6 +235,437 @@ func TestIterate(t *testing.T) {
 	}
 }
 
+func TestIterateBatchesDoNotRepeatNetworks(t *testing.T) {
+	manager := New(30*time.Minute, 5*time.Minute)
+
+	reader, err := maxminddb.Open(testCityDBPath)
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	network := netip.MustParsePrefix("81.2.69.0/24")
+	full, err := manager.CreateIterator(reader, "city-test", network, nil, "")
+	if err != nil {
+		t.Fatalf("Failed to create iterator: %v", err)
+	}
+	all, err := manager.Iterate(full, 1000)
+	if err != nil {
+		t.Fatalf("Failed to iterate: %v", err)
+	}
+	if len(all.Results) < 3 {
+		t.Fatalf("Expected at least 3 networks, got %d", len(all.Results))
+	}
+	var expected []netip.Prefix
+	for _, r := range all.Results {
+		expected = append(expected, r.Network)
+	}
+
+	// Batches smaller and larger than the number of networks, both from the
+	// same iterator and resumed from tokens, return every network exactly once.
+	for _, batchSize := range []int{1, 2, len(expected) - 1, len(expected), len(expected) + 1} {
+		for _, resume := range []bool{false, true} {
+			testPagedIteration(t, manager, reader, network, batchSize, resume, expected, all.TotalProcessed)
+		}
+	}
+}
+
+func TestIterateBatchesResumeIPv4NetworksInIPv6Scan(t *testing.T) {
+	manager := New(30*time.Minute, 5*time.Minute)
+
+	reader, err := maxminddb.Open(testCityDBPath)
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	// Scanning ::/0 returns the IPv4 networks of the database as IPv4
+	// prefixes, and later batches must resume after them.
+	network := netip.MustParsePrefix("::/0")
+	full, err := manager.CreateIterator(reader, "city-test", network, nil, "")
+	if err != nil {
+		t.Fatalf("Failed to create iterator: %v", err)
+	}
+	all, err := manager.Iterate(full, 100000)
+	if err != nil {
+		t.Fatalf("Failed to iterate: %v", err)
+	}
+	var expected []netip.Prefix
+	for _, r := range all.Results {
+		expected = append(expected, r.Network)
+	}
+	if len(expected) < 3 || !expected[0].Addr().Is4() {
+		t.Fatalf("Expected at least 3 networks starting with an IPv4 one, got %v", expected)
+	}
+
+	for _, resume := range []bool{false, true} {
+		testPagedIteration(t, manager, reader, network, len(expected)/3+1, resume, expected, all.TotalProcessed)
+	}
+}
+
+func TestScan(t *testing.T) {
+	manager := New(30*time.Minute, 5*time.Minute)
+
+	reader, err := maxminddb.Open(testCityDBPath)
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	network := netip.MustParsePrefix("81.2.69.0/24")
+	filters := []filter.Filter{{Field: "city.names.en", Operator: "eq", Value: "London"}}
+
+	iter, err := manager.CreateIterator(reader, testDB, network, filters, filterModeAnd)
+	if err != nil {
+		t.Fatalf("Failed to create iterator: %v", err)
+	}
+	batch, err := manager.Iterate(iter, 1000)
+	if err != nil {
+		t.Fatalf("Failed to iterate: %v", err)
+	}
+	var expected []netip.Prefix
+	for _, r := range batch.Results {
+		expected = append(expected, r.Network)
+	}
+	if len(expected) < 2 {
+		t.Fatalf("Expected at least 2 matches, got %v", expected)
+	}
+
+	// Scan matches the same networks as an iterator.
+	var scanned []netip.Prefix
+	err = Scan(t.Context(), reader, network, filters, filterModeAnd, func(prefix netip.Prefix) bool {
+		scanned = append(scanned, prefix)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("Scan failed: %v", err)
+	}
+	if !slices.Equal(scanned, expected) {
+		t.Errorf("Expected %v, got %v", expected, scanned)
+	}
+
+	// Returning false stops the scan.
+	scanned = nil
+	err = Scan(t.Context(), reader, network, filters, filterModeAnd, func(prefix netip.Prefix) bool {
+		scanned = append(scanned, prefix)
+		return false
+	})
+	if err != nil || len(scanned) != 1 {
+		t.Errorf("Expected the scan to stop after one match, got %v (%v)", scanned, err)
+	}
+
+	// A done context stops the scan with its error.
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	err = Scan(ctx, reader, network, filters, filterModeAnd, func(netip.Prefix) bool {
+		t.Error("Expected no matches after cancellation")
+		return true
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func testPagedIteration(
+	t *testing.T,
+	manager *Manager,
+	reader *maxminddb.Reader,
+	network netip.Prefix,
+	batchSize int,
+	resume bool,
+	expected []netip.Prefix,
+	expectedProcessed int64,
+) {
+	t.Helper()
+
+	iter, err := manager.CreateIterator(reader, "city-test", network, nil, "")
+	if err != nil {
+		t.Fatalf("Failed to create iterator: %v", err)
+	}
+	var paged []netip.Prefix
+	var processed int64
+	for range 100 {
+		batch, err := manager.Iterate(iter, batchSize)
+		if err != nil {
+			t.Fatalf("Failed to iterate: %v", err)
+		}
+		if len(batch.Results) > batchSize {
+			t.Fatalf("batch size %d: got %d results", batchSize, len(batch.Results))
+		}
+		for _, r := range batch.Results {
+			paged = append(paged, r.Network)
+		}
+		processed = batch.TotalProcessed
+		if !batch.HasMore {
+			break
+		}
+		if resume {
+			iter, err = manager.ResumeIterator(reader, batch.ResumeToken)
+			if err != nil {
+				t.Fatalf("Failed to resume iterator: %v", err)
+			}
+		}
+	}
+	if !slices.Equal(paged, expected) {
+		t.Errorf("batch size %d, resume=%v: expected networks %v, got %v", batchSize, resume, expected, paged)
+	}
+	if processed != expectedProcessed {
+		t.Errorf("batch size %d, resume=%v: expected %d networks processed, got %d",
+			batchSize, resume, expectedProcessed, processed)
+	}
+}
+
+func TestCancelIteration(t *testing.T) {
+	manager := New(30*time.Minute, 5*time.Minute)
+
+	reader, err := maxminddb.Open(testCityDBPath)
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	network := netip.MustParsePrefix("::/0")
+	iter, err := manager.CreateIterator(reader, "city-test", network, nil, "")
+	if err != nil {
+		t.Fatalf("Failed to create iterator: %v", err)
+	}
+
+	if manager.CancelIteration(iter.ID) {
+		t.Error("Expected no batch to cancel before iterating")
+	}
+
+	// Holding the iterator's lock stalls the batch once it has started, so
+	// that it is still running when it is canceled.
+	iter.mu.Lock()
+	type batchResult struct {
+		result *IterationResult
+		err    error
+	}
+	batches := make(chan batchResult, 1)
+	go func() {
+		result, err := manager.Iterate(iter, 1000000)
+		batches <- batchResult{result, err}
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !manager.CancelIteration(iter.ID) {
+		if time.Now().After(deadline) {
+			iter.mu.Unlock()
+			t.Fatal("Timed out waiting for the batch to start")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	iter.mu.Unlock()
+
+	var batch batchResult
+	select {
+	case batch = <-batches:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Canceled batch did not return")
+	}
+	if batch.err != nil {
+		t.Fatalf("Failed to iterate: %v", batch.err)
+	}
+	if !batch.result.Canceled || !batch.result.HasMore {
+		t.Errorf("Expected a canceled batch with more results, got %+v", batch.result)
+	}
+	if manager.CancelIteration(iter.ID) {
+		t.Error("Expected no batch to cancel after it returned")
+	}
+
+	// The next batch continues the scan and returns every network.
+	rest, err := manager.Iterate(iter, 1000000)
+	if err != nil {
+		t.Fatalf("Failed to iterate: %v", err)
+	}
+	if rest.Canceled || rest.HasMore {
+		t.Errorf("Expected the remaining results in one batch, got %+v", rest)
+	}
+
+	full, err := manager.CreateIterator(reader, "city-test", network, nil, "")
+	if err != nil {
+		t.Fatalf("Failed to create iterator: %v", err)
+	}
+	all, err := manager.Iterate(full, 1000000)
+	if err != nil {
+		t.Fatalf("Failed to iterate: %v", err)
+	}
+	if got := len(batch.result.Results) + len(rest.Results); got != len(all.Results) {
+		t.Errorf("Expected %d networks in total, got %d", len(all.Results), got)
+	}
+}
+
+func TestIterateWithResponseBudget(t *testing.T) {
+	manager := New(30*time.Minute, 5*time.Minute)
+
+	reader, err := maxminddb.Open("../../testdata/test-data/GeoLite2-City-Test.mmdb")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+
+	network := netip.MustParsePrefix("81.2.69.0/24")
+
+	// Without a budget, the count cap is not reached.
+	iterator, err := manager.CreateIterator(reader, testDB, network, nil, filterModeAnd)
+	if err != nil {
+		t.Fatalf("Failed to create iterator: %v", err)
+	}
+	unlimited, err := manager.Iterate(iterator, 100)
+	if err != nil {
+		t.Fatalf("Failed to iterate: %v", err)
+	}
+	if len(unlimited.Results) < 2 {
+		t.Fatalf("Expected multiple City records in %s, got %d", network, len(unlimited.Results))
+	}
+	if unlimited.HasMore {
+		t.Error("Expected no more results without a budget")
+	}
+
+	// A budget just above the size of the first record stops after the
+	// second record, well before the count cap.
+	manager.SetMaxResponseBytes(estimateSize(unlimited.Results[0]) + 1)
+
+	iterator, err = manager.CreateIterator(reader, testDB, network, nil, filterModeAnd)
+	if err != nil {
+		t.Fatalf("Failed to create iterator: %v", err)
+	}
+	limited, err := manager.Iterate(iterator, 100)
+	if err != nil {
+		t.Fatalf("Failed to iterate: %v", err)
+	}
+	if len(limited.Results) != 2 {
+		t.Errorf("Expected budget to stop after 2 results, got %d", len(limited.Results))
+	}
+	if !limited.HasMore {
+		t.Error("Expected HasMore when the budget is hit")
+	}
+
+	// A tiny budget still returns one result so iteration makes progress.
+	manager.SetMaxResponseBytes(1)
+
+	iterator, err = manager.CreateIterator(reader, testDB, network, nil, filterModeAnd)
+	if err != nil {
+		t.Fatalf("Failed to create iterator: %v", err)
+	}
+	limited, err = manager.Iterate(iterator, 100)
+	if err != nil {
+		t.Fatalf("Failed to iterate: %v", err)
+	}
+	if len(limited.Results) != 1 || !limited.HasMore {
+		t.Errorf(
+			"Expected 1 result with more remaining, got %d (has_more=%v)",
+			len(limited.Results),
+			limited.HasMore,
+		)
+	}
+}
+
+func TestIterateBatchBudget(t *testing.T) {
+	manager := New(30*time.Minute, 5*time.Minute)
+	manager.SetMaxBatches(2)
+
+	reader, err := maxminddb.Open("../../testdata/test-data/GeoLite2-City-Test.mmdb")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+
+	network := netip.MustParsePrefix("81.2.69.0/24")
+	iterator, err := manager.CreateIterator(reader, testDB, network, nil, filterModeAnd)
+	if err != nil {
+		t.Fatalf("Failed to create iterator: %v", err)
+	}
+
+	var result *IterationResult
+	for i := range 2 {
+		result, err = manager.Iterate(iterator, 1)
+		if err != nil {
+			t.Fatalf("Batch %d failed: %v", i+1, err)
+		}
+		if !result.HasMore {
+			t.Fatalf("Expected more results after batch %d", i+1)
+		}
+	}
+	if iterator.Batches != 2 {
+		t.Errorf("Expected 2 batches, got %d", iterator.Batches)
+	}
+
+	// The third batch exceeds the budget and closes the iterator.
+	_, err = manager.Iterate(iterator, 1)
+	if !errors.Is(err, ErrBatchBudgetExhausted) {
+		t.Fatalf("Expected ErrBatchBudgetExhausted, got %v", err)
+	}
+	if _, exists := manager.GetIterator(iterator.ID); exists {
+		t.Error("Expected the iterator to be removed")
+	}
+
+	// Resuming from a token does not reset the budget.
+	resumed, err := manager.ResumeIterator(reader, result.ResumeToken)
+	if err != nil {
+		t.Fatalf("Failed to resume iterator: %v", err)
+	}
+	if _, err := manager.Iterate(resumed, 1); !errors.Is(err, ErrBatchBudgetExhausted) {
+		t.Errorf("Expected the resumed iterator to have exhausted its budget, got %v", err)
+	}
+
+	// Without a limit, the iterator keeps going.
+	manager.SetMaxBatches(0)
+	resumed, err = manager.ResumeIterator(reader, result.ResumeToken)
+	if err != nil {
+		t.Fatalf("Failed to resume iterator: %v", err)
+	}
+	if _, err := manager.Iterate(resumed, 1); err != nil {
+		t.Errorf("Expected iteration without a limit, got %v", err)
+	}
+}
+
+func TestSample(t *testing.T) {
+	manager := New(30*time.Minute, 5*time.Minute)
+
+	reader, err := maxminddb.Open("../../testdata/test-data/GeoLite2-City-Test.mmdb")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+
+	// The sample stops after two records, whether or not they match.
+	iterator, err := manager.CreateIterator(
+		reader,
+		testDB,
+		netip.MustParsePrefix("81.2.69.0/24"),
+		[]filter.Filter{{Field: "location.accuracy_radius", Operator: "exists", Value: true}},
+		filterModeAnd,
+	)
+	if err != nil {
+		t.Fatalf("Failed to create iterator: %v", err)
+	}
+
+	result, err := manager.Sample(iterator, 10, 2)
+	if err != nil {
+		t.Fatalf("Failed to sample: %v", err)
+	}
+	if result.TotalProcessed != 2 {
+		t.Errorf("Expected 2 processed records, got %d", result.TotalProcessed)
+	}
+	if !result.HasMore {
+		t.Error("Expected more records after the sample")
+	}
+	if iterator.LastNetwork != netip.MustParsePrefix("81.2.69.144/28") {
+		t.Errorf("Expected sample to end at 81.2.69.144/28, got %s", iterator.LastNetwork)
+	}
+
+	// A sample larger than the network processes every record.
+	iterator, err = manager.CreateIterator(reader, testDB, netip.MustParsePrefix("81.2.69.0/24"), nil, filterModeAnd)
+	if err != nil {
+		t.Fatalf("Failed to create iterator: %v", err)
+	}
+	result, err = manager.Sample(iterator, 100, 100)
+	if err != nil {
+		t.Fatalf("Failed to sample: %v", err)
+	}
+	if result.TotalProcessed != 4 || result.HasMore {
+		t.Errorf("Expected all 4 records without more, got %d (has_more=%v)", result.TotalProcessed, result.HasMore)
+	}
+}
+
 func TestIterateWithFilters(t *testing.T) {
 	manager := New(30*time.Minute, 5*time.Minute)
 
@@ -309,6 +745,56 @@ func TestRemoveIterator(t *testing.T) {
 	manager.RemoveIterator("nonexistent")
 }
 
+func TestClear(t *testing.T) {
+	manager := New(30*time.Minute, 5*time.Minute)
+
+	reader, err := maxminddb.Open("../../testdata/test-data/GeoLite2-City-Test.mmdb")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+
+	network := netip.MustParsePrefix(testNetwork)
+	ids := make([]string, 0, 3)
+	for range 3 {
+		iterator, err := manager.CreateIterator(reader, testDB, network, nil, filterModeAnd)
+		if err != nil {
+			t.Fatalf("Failed to create iterator: %v", err)
+		}
+		ids = append(ids, iterator.ID)
+	}
+
+	if cleared := manager.Clear(); cleared != 3 {
+		t.Errorf("Expected 3 iterators cleared, got %d", cleared)
+	}
+	for _, id := range ids {
+		if _, exists := manager.GetIterator(id); exists {
+			t.Errorf("Iterator %s should have been cleared", id)
+		}
+	}
+
+	if cleared := manager.Clear(); cleared != 0 {
+		t.Errorf("Expected 0 iterators cleared on empty manager, got %d", cleared)
+	}
+}
+
+func TestNewClampsCleanupInterval(t *testing.T) {
+	for _, interval := range []time.Duration{0, -time.Second, time.Millisecond} {
+		manager := New(30*time.Minute, interval)
+		if manager.cleanupInterval != MinCleanupInterval {
+			t.Errorf(
+				"Expected cleanup interval %v clamped to %v, got %v",
+				interval,
+				MinCleanupInterval,
+				manager.cleanupInterval,
+			)
+		}
+
+		// Starting cleanup must not panic on the clamped interval.
+		manager.StartCleanup()
+		manager.StopCleanup()
+	}
+}
+
 func TestStartStopCleanup(_ *testing.T) {
 	manager := New(10*time.Millisecond, 5*time.Millisecond) // Very short intervals
 
@@ -363,6 +849,70 @@ func TestCleanupExpiredIterators(t *testing.T) {
 	}
 }
 
+func TestCleanupNow(t *testing.T) {
+	manager := New(30*time.Minute, 5*time.Minute)
+
+	reader, err := maxminddb.Open("../../testdata/test-data/GeoLite2-City-Test.mmdb")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	network := netip.MustParsePrefix("1.0.0.0/8")
+	expired, err := manager.CreateIterator(reader, testDB, network, nil, "")
+	if err != nil {
+		t.Fatalf("Failed to create iterator: %v", err)
+	}
+	active, err := manager.CreateIterator(reader, testDB, network, nil, "")
+	if err != nil {
+		t.Fatalf("Failed to create iterator: %v", err)
+	}
+
+	// Expire one iterator without waiting for the TTL. The cleanup
+	// goroutine is never started.
+	expired.LastAccess = time.Now().Add(-time.Hour)
+	manager.CleanupNow()
+
+	if _, exists := manager.GetIterator(expired.ID); exists {
+		t.Error("Expired iterator should be removed")
+	}
+	if _, exists := manager.GetIterator(active.ID); !exists {
+		t.Error("Active iterator should be kept")
+	}
+}
+
+func TestCleanupGracePeriod(t *testing.T) {
+	manager := New(time.Nanosecond, 5*time.Minute)
+	manager.SetGracePeriod(time.Hour)
+
+	reader, err := maxminddb.Open("../../testdata/test-data/GeoLite2-City-Test.mmdb")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	iterator, err := manager.CreateIterator(reader, testDB, netip.MustParsePrefix("1.0.0.0/8"), nil, "")
+	if err != nil {
+		t.Fatalf("Failed to create iterator: %v", err)
+	}
+
+	// The iterator is well past the TTL, but was created within the grace
+	// period.
+	iterator.LastAccess = time.Now().Add(-time.Minute)
+	manager.CleanupNow()
+	if _, exists := manager.GetIterator(iterator.ID); !exists {
+		t.Fatal("Iterator within the grace period should be kept")
+	}
+
+	// Once the grace period is over, the TTL applies again.
+	iterator.Created = time.Now().Add(-2 * time.Hour)
+	iterator.LastAccess = time.Now().Add(-time.Minute)
+	manager.CleanupNow()
+	if _, exists := manager.GetIterator(iterator.ID); exists {
+		t.Error("Iterator past the grace period and TTL should be removed")
+	}
+}
+
 func TestGenerateResumeToken(t *testing.T) {
 	manager := New(30*time.Minute, 5*time.Minute)
 
@@ -441,6 +991,70 @@ func TestGenerateResumeToken(t *testing.T) {
 	}
 }
 
+func TestResumeIteratorRejectsUnknownOperator(t *testing.T) {
+	manager := New(30*time.Minute, 5*time.Minute)
+
+	reader, err := maxminddb.Open("../../testdata/test-data/GeoLite2-City-Test.mmdb")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+
+	data, err := json.Marshal(ResumeToken{
+		Database:   testDB,
+		Network:    testNetwork,
+		FilterMode: filterModeAnd,
+		Filters:    []filter.Filter{{Field: "country.iso_code", Operator: "equls", Value: "GB"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal token: %v", err)
+	}
+
+	_, err = manager.ResumeIterator(reader, base64.StdEncoding.EncodeToString(data))
+	if err == nil || !strings.Contains(err.Error(), "unsupported operator 'equls'") {
+		t.Errorf("Expected unsupported operator error, got %v", err)
+	}
+}
+
+func TestResumeIteratorRejectsLongRegex(t *testing.T) {
+	manager := New(30*time.Minute, 5*time.Minute)
+
+	reader, err := maxminddb.Open("../../testdata/test-data/GeoLite2-City-Test.mmdb")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+
+	pattern := strings.Repeat("a", filter.DefaultMaxRegexLength+1)
+	data, err := json.Marshal(ResumeToken{
+		Database:   testDB,
+		Network:    testNetwork,
+		FilterMode: filterModeAnd,
+		Filters:    []filter.Filter{{Field: "city.names.en", Operator: "regex", Value: pattern}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to marshal token: %v", err)
+	}
+	token := base64.StdEncoding.EncodeToString(data)
+
+	_, err = manager.ResumeIterator(reader, token)
+	if err == nil || !strings.Contains(err.Error(), "exceeding the limit of 256") {
+		t.Errorf("Expected regex length error, got %v", err)
+	}
+	if _, err := manager.DecodeResumeToken(token); err == nil {
+		t.Error("Expected DecodeResumeToken to reject the regex too")
+	}
+
+	// The configured limit applies instead of the default.
+	manager.SetMaxRegexLength(len(pattern))
+	if _, err := manager.ResumeIterator(reader, token); err != nil {
+		t.Errorf("Expected the regex to be within the configured limit, got %v", err)
+	}
+	manager.SetMaxRegexLength(10)
+	_, err = manager.ResumeIterator(reader, token)
+	if err == nil || !strings.Contains(err.Error(), "exceeding the limit of 10") {
+		t.Errorf("Expected regex length error, got %v", err)
+	}
+}
+
 func TestGenerateID(t *testing.T) {
 	// Test generating multiple IDs
 	ids := make(map[string]bool)
@@ -463,6 +1077,162 @@ func TestGenerateID(t *testing.T) {
 	}
 }
 
+func TestDeterministicIDs(t *testing.T) {
+	reader, err := maxminddb.Open("../../testdata/test-data/GeoLite2-City-Test.mmdb")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+
+	network := netip.MustParsePrefix(testNetwork)
+	filters := []filter.Filter{{Field: "country.iso_code", Operator: "eq", Value: "GB"}}
+
+	manager := New(30*time.Minute, 5*time.Minute)
+	manager.SetDeterministicIDs(true)
+
+	first, err := manager.CreateIterator(reader, testDB, network, filters, filterModeAnd)
+	if err != nil {
+		t.Fatalf("Failed to create iterator: %v", err)
+	}
+	// Operator aliases and filter mode case normalize to the same query.
+	second, err := manager.CreateIterator(
+		reader,
+		testDB,
+		network,
+		[]filter.Filter{{Field: "country.iso_code", Operator: "equals", Value: "GB"}},
+		"AND",
+	)
+	if err != nil {
+		t.Fatalf("Failed to create iterator: %v", err)
+	}
+	if first.ID != second.ID {
+		t.Errorf("Expected identical IDs for identical queries, got %s and %s", first.ID, second.ID)
+	}
+	if first != second {
+		t.Error("Expected the live iterator to be reused")
+	}
+
+	different := []struct {
+		name    string
+		network netip.Prefix
+		filters []filter.Filter
+		mode    string
+		opts    Options
+	}{
+		{name: "network", network: netip.MustParsePrefix("81.2.69.0/25"), filters: filters, mode: filterModeAnd},
+		{name: "filters", network: network, mode: filterModeAnd},
+		{name: "mode", network: network, filters: filters, mode: "or"},
+		{
+			name:    "options",
+			network: network,
+			filters: filters,
+			mode:    filterModeAnd,
+			opts:    Options{MaxPrefixLength: 24},
+		},
+	}
+	for _, tt := range different {
+		iterator, err := manager.CreateIteratorWithOptions(reader, testDB, tt.network, tt.filters, tt.mode, tt.opts)
+		if err != nil {
+			t.Fatalf("Failed to create iterator: %v", err)
+		}
+		if iterator.ID == first.ID {
+			t.Errorf("Expected a different ID when the %s differs", tt.name)
+		}
+	}
+
+	// Once the iterator is gone, the same query gets the same ID again.
+	manager.RemoveIterator(first.ID)
+	recreated, err := manager.CreateIterator(reader, testDB, network, filters, filterModeAnd)
+	if err != nil {
+		t.Fatalf("Failed to create iterator: %v", err)
+	}
+	if recreated.ID != first.ID {
+		t.Errorf("Expected recreated iterator to have ID %s, got %s", first.ID, recreated.ID)
+	}
+	if recreated == first {
+		t.Error("Expected a new iterator after removal")
+	}
+
+	// Random IDs remain the default.
+	random := New(30*time.Minute, 5*time.Minute)
+	a, err := random.CreateIterator(reader, testDB, network, filters, filterModeAnd)
+	if err != nil {
+		t.Fatalf("Failed to create iterator: %v", err)
+	}
+	b, err := random.CreateIterator(reader, testDB, network, filters, filterModeAnd)
+	if err != nil {
+		t.Fatalf("Failed to create iterator: %v", err)
+	}
+	if a.ID == b.ID {
+		t.Error("Expected random IDs by default")
+	}
+}
+
+func TestDeterministicIDsResume(t *testing.T) {
+	reader, err := maxminddb.Open(testCityDBPath)
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+
+	manager := New(30*time.Minute, 5*time.Minute)
+	manager.SetDeterministicIDs(true)
+
+	live, err := manager.CreateIterator(reader, testDB, netip.MustParsePrefix("::/0"), nil, filterModeAnd)
+	if err != nil {
+		t.Fatalf("Failed to create iterator: %v", err)
+	}
+	first, err := manager.Iterate(live, 1)
+	if err != nil {
+		t.Fatalf("Failed to iterate: %v", err)
+	}
+	second, err := manager.Iterate(live, 1)
+	if err != nil {
+		t.Fatalf("Failed to iterate: %v", err)
+	}
+	position := live.getLastNetwork()
+
+	// Resuming the same query from an earlier position returns the live
+	// iterator without rewinding it.
+	resumed, err := manager.ResumeIterator(reader, first.ResumeToken)
+	if err != nil {
+		t.Fatalf("Failed to resume iterator: %v", err)
+	}
+	if resumed != live {
+		t.Fatal("Expected the live iterator to be returned")
+	}
+	if got := live.getLastNetwork(); got != position {
+		t.Errorf("Expected the live iterator to stay at %s, got %s", position, got)
+	}
+	if batches := live.getBatches(); batches != 2 {
+		t.Errorf("Expected the live iterator to keep its 2 batches, got %d", batches)
+	}
+
+	// Once it is gone, each token resumes into a new iterator at its own
+	// position, which a later resume from another position leaves alone.
+	manager.RemoveIterator(live.ID)
+	fromFirst, err := manager.ResumeIterator(reader, first.ResumeToken)
+	if err != nil {
+		t.Fatalf("Failed to resume iterator: %v", err)
+	}
+	if fromFirst == live {
+		t.Fatal("Expected a new iterator after removal")
+	}
+	firstPosition := fromFirst.getLastNetwork()
+	if firstPosition == position {
+		t.Fatalf("Expected the first token to resume before %s", position)
+	}
+
+	fromSecond, err := manager.ResumeIterator(reader, second.ResumeToken)
+	if err != nil {
+		t.Fatalf("Failed to resume iterator: %v", err)
+	}
+	if fromSecond != fromFirst {
+		t.Fatal("Expected the live iterator to be returned")
+	}
+	if got := fromFirst.getLastNetwork(); got != firstPosition {
+		t.Errorf("Expected the resumed iterator to stay at %s, got %s", firstPosition, got)
+	}
+}
+
 func TestIteratorConcurrency(t *testing.T) {
 	manager := New(30*time.Minute, 5*time.Minute)
 
@@ -512,6 +1282,54 @@ func TestIteratorConcurrency(t *testing.T) {
 	// Should not deadlock or panic
 }
 
+func TestIterateConcurrentlyAcrossReload(t *testing.T) {
+	manager := New(30*time.Minute, 5*time.Minute)
+
+	reader, err := maxminddb.Open(testCityDBPath)
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	iter, err := manager.CreateIterator(reader, testDB, netip.MustParsePrefix("::/0"), nil, filterModeAnd)
+	if err != nil {
+		t.Fatalf("Failed to create iterator: %v", err)
+	}
+
+	// Batches of the same iterator run while its reader is replaced, as on
+	// a stale file handle retry. Run with -race.
+	fresh := make([]*maxminddb.Reader, 5)
+	for i := range fresh {
+		fresh[i], err = maxminddb.Open(testCityDBPath)
+		if err != nil {
+			t.Fatalf("Failed to reopen test database: %v", err)
+		}
+		defer func() { _ = fresh[i].Close() }()
+	}
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 20 {
+				if _, err := manager.Iterate(iter, 2); err != nil {
+					t.Errorf("Iterate failed: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for _, reader := range fresh {
+			iter.SetReader(reader)
+		}
+	}()
+	wg.Wait()
+}
+
 func TestMultipleIterators(t *testing.T) {
 	manager := New(30*time.Minute, 5*time.Minute)
 
@@ -685,3 +1503,321 @@ func TestNetworkResultFields(t *testing.T) {
 		t.Error("Data field not set correctly")
 	}
 }
+
+func TestIterateAliasedNetworks(t *testing.T) {
+	reader, err := maxminddb.Open("../../testdata/test-data/MaxMind-DB-test-mixed-24.mmdb")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer reader.Close()
+
+	aliases := []netip.Prefix{
+		netip.MustParsePrefix("::ffff:0:0/96"),
+		netip.MustParsePrefix("2001::/32"),
+		netip.MustParsePrefix("2002::/16"),
+	}
+	isAliased := func(network netip.Prefix) bool {
+		for _, alias := range aliases {
+			if alias.Overlaps(network) {
+				return true
+			}
+		}
+		return false
+	}
+
+	network := netip.MustParsePrefix("::/0")
+
+	t.Run("skipped by default", func(t *testing.T) {
+		manager := New(30*time.Minute, 5*time.Minute)
+		iter, err := manager.CreateIterator(reader, "mixed", network, nil, filterModeAnd)
+		if err != nil {
+			t.Fatalf("Failed to create iterator: %v", err)
+		}
+
+		result, err := manager.Iterate(iter, 1000)
+		if err != nil {
+			t.Fatalf("Failed to iterate: %v", err)
+		}
+
+		var ipv4 []netip.Prefix
+		for _, r := range result.Results {
+			if isAliased(r.Network) {
+				t.Errorf("Unexpected aliased network %s", r.Network)
+			}
+			if r.Network.Addr().Is4() {
+				for _, seen := range ipv4 {
+					if seen.Overlaps(r.Network) {
+						t.Errorf("IPv4 network %s overlaps %s", r.Network, seen)
+					}
+				}
+				ipv4 = append(ipv4, r.Network)
+			}
+		}
+		if len(ipv4) == 0 {
+			t.Error("Expected IPv4 networks in the mixed database")
+		}
+	})
+
+	t.Run("included when requested", func(t *testing.T) {
+		manager := New(30*time.Minute, 5*time.Minute)
+		iter, err := manager.CreateIteratorWithOptions(
+			reader,
+			"mixed",
+			network,
+			nil,
+			filterModeAnd,
+			Options{IncludeAliasedNetworks: true},
+		)
+		if err != nil {
+			t.Fatalf("Failed to create iterator: %v", err)
+		}
+
+		result, err := manager.Iterate(iter, 1000)
+		if err != nil {
+			t.Fatalf("Failed to iterate: %v", err)
+		}
+
+		aliased := 0
+		for _, r := range result.Results {
+			if isAliased(r.Network) {
+				aliased++
+			}
+		}
+		if aliased == 0 {
+			t.Error("Expected aliased networks when IncludeAliasedNetworks is set")
+		}
+
+		// The option survives a resume token round trip.
+		resumed, err := manager.ResumeIterator(reader, result.ResumeToken)
+		if err != nil {
+			t.Fatalf("Failed to resume iterator: %v", err)
+		}
+		if !resumed.IncludeAliasedNetworks {
+			t.Error("Expected IncludeAliasedNetworks to be restored from resume token")
+		}
+	})
+}
+
+func TestIterateMaxPrefixLength(t *testing.T) {
+	reader, err := maxminddb.Open("../../testdata/test-data/GeoIP2-City-Test.mmdb")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer reader.Close()
+
+	manager := New(30*time.Minute, 5*time.Minute)
+	network := netip.MustParsePrefix("81.2.69.0/24")
+
+	full, err := manager.CreateIterator(reader, testDB, network, nil, filterModeAnd)
+	if err != nil {
+		t.Fatalf("Failed to create iterator: %v", err)
+	}
+	fullResult, err := manager.Iterate(full, 1000)
+	if err != nil {
+		t.Fatalf("Failed to iterate: %v", err)
+	}
+
+	const maxPrefixLength = 26
+	iter, err := manager.CreateIteratorWithOptions(
+		reader,
+		testDB,
+		network,
+		nil,
+		filterModeAnd,
+		Options{MaxPrefixLength: maxPrefixLength},
+	)
+	if err != nil {
+		t.Fatalf("Failed to create iterator: %v", err)
+	}
+	result, err := manager.Iterate(iter, 1000)
+	if err != nil {
+		t.Fatalf("Failed to iterate: %v", err)
+	}
+
+	if len(result.Results) == 0 {
+		t.Fatal("Expected aggregated results")
+	}
+	if len(result.Results) >= len(fullResult.Results) {
+		t.Errorf(
+			"Expected fewer aggregated results than the %d full results, got %d",
+			len(fullResult.Results),
+			len(result.Results),
+		)
+	}
+
+	seen := make(map[netip.Prefix]bool)
+	for _, r := range result.Results {
+		if r.Network.Bits() > maxPrefixLength {
+			t.Errorf("Network %s is longer than /%d", r.Network, maxPrefixLength)
+		}
+		if seen[r.Network] {
+			t.Errorf("Network %s returned more than once", r.Network)
+		}
+		seen[r.Network] = true
+	}
+
+	// Every full-granularity network is covered by an aggregated one.
+	for _, r := range fullResult.Results {
+		covered := false
+		for aggregate := range seen {
+			if aggregate.Overlaps(r.Network) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			t.Errorf("Network %s is not covered by the aggregated results", r.Network)
+		}
+	}
+}
+
+func TestIterateMaxPrefixLengthPaged(t *testing.T) {
+	reader, err := maxminddb.Open("../../testdata/test-data/GeoIP2-City-Test.mmdb")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer reader.Close()
+
+	manager := New(30*time.Minute, 5*time.Minute)
+	network := netip.MustParsePrefix("81.2.69.0/24")
+	opts := Options{MaxPrefixLength: 26}
+
+	full, err := manager.CreateIteratorWithOptions(reader, testDB, network, nil, filterModeAnd, opts)
+	if err != nil {
+		t.Fatalf("Failed to create iterator: %v", err)
+	}
+	all, err := manager.Iterate(full, 1000)
+	if err != nil {
+		t.Fatalf("Failed to iterate: %v", err)
+	}
+	var expected []netip.Prefix
+	for _, r := range all.Results {
+		expected = append(expected, r.Network)
+	}
+	if len(expected) < 2 {
+		t.Fatalf("Expected at least 2 aggregates, got %v", expected)
+	}
+
+	// Batches of one, from the same iterator and resumed from tokens,
+	// return each aggregate once even when its networks span batches.
+	for _, resume := range []bool{false, true} {
+		iter, err := manager.CreateIteratorWithOptions(reader, testDB, network, nil, filterModeAnd, opts)
+		if err != nil {
+			t.Fatalf("Failed to create iterator: %v", err)
+		}
+		var paged []netip.Prefix
+		var matched int64
+		for range 100 {
+			batch, err := manager.Iterate(iter, 1)
+			if err != nil {
+				t.Fatalf("Failed to iterate: %v", err)
+			}
+			for _, r := range batch.Results {
+				paged = append(paged, r.Network)
+			}
+			matched = batch.TotalMatched
+			if !batch.HasMore {
+				break
+			}
+			if resume {
+				iter, err = manager.ResumeIterator(reader, batch.ResumeToken)
+				if err != nil {
+					t.Fatalf("Failed to resume iterator: %v", err)
+				}
+			}
+		}
+		if !slices.Equal(paged, expected) {
+			t.Errorf("resume=%v: expected aggregates %v, got %v", resume, expected, paged)
+		}
+		if matched != all.TotalMatched {
+			t.Errorf("resume=%v: expected %d matched, got %d", resume, all.TotalMatched, matched)
+		}
+	}
+}
+
+func TestIteratePrefixPseudoFields(t *testing.T) {
+	reader, err := maxminddb.Open("../../testdata/test-data/GeoIP2-City-Test.mmdb")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer reader.Close()
+
+	manager := New(30*time.Minute, 5*time.Minute)
+	network := netip.MustParsePrefix("81.2.69.0/24")
+
+	tests := []struct {
+		name    string
+		filters []filter.Filter
+		want    func(netip.Prefix) bool
+	}{
+		{
+			name:    "prefix length",
+			filters: []filter.Filter{{Field: filter.PrefixLengthField, Operator: "equals", Value: float64(28)}},
+			want:    func(p netip.Prefix) bool { return p.Bits() == 28 },
+		},
+		{
+			name: "prefix length with record field",
+			filters: []filter.Filter{
+				{Field: filter.PrefixLengthField, Operator: "less_than_or_equal", Value: float64(28)},
+				{Field: "country.iso_code", Operator: "exists", Value: true},
+			},
+			want: func(p netip.Prefix) bool { return p.Bits() <= 28 },
+		},
+		{
+			name:    "prefix within",
+			filters: []filter.Filter{{Field: filter.PrefixField, Operator: "within", Value: "81.2.69.128/25"}},
+			want: func(p netip.Prefix) bool {
+				return netip.MustParsePrefix("81.2.69.128/25").Contains(p.Addr())
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			full, err := manager.CreateIterator(reader, testDB, network, nil, filterModeAnd)
+			if err != nil {
+				t.Fatalf("Failed to create iterator: %v", err)
+			}
+			fullResult, err := manager.Iterate(full, 1000)
+			if err != nil {
+				t.Fatalf("Failed to iterate: %v", err)
+			}
+			expected := 0
+			for _, r := range fullResult.Results {
+				if test.want(r.Network) {
+					expected++
+				}
+			}
+			if expected == 0 {
+				t.Fatal("Expected test data with matching networks")
+			}
+
+			iter, err := manager.CreateIterator(reader, testDB, network, test.filters, filterModeAnd)
+			if err != nil {
+				t.Fatalf("Failed to create iterator: %v", err)
+			}
+			result, err := manager.Iterate(iter, 1000)
+			if err != nil {
+				t.Fatalf("Failed to iterate: %v", err)
+			}
+
+			if len(result.Results) != expected {
+				t.Errorf("Expected %d results, got %d", expected, len(result.Results))
+			}
+			for _, r := range result.Results {
+				if !test.want(r.Network) {
+					t.Errorf("Network %s should have been filtered out", r.Network)
+				}
+				if _, ok := r.Data[filter.PrefixField]; ok {
+					t.Errorf("Pseudo-field %s leaked into the data of %s", filter.PrefixField, r.Network)
+				}
+				if _, ok := r.Data[filter.PrefixLengthField]; ok {
+					t.Errorf("Pseudo-field %s leaked into the data of %s", filter.PrefixLengthField, r.Network)
+				}
+				if len(r.Data) == 0 {
+					t.Errorf("Expected full record data for %s", r.Network)
+				}
+			}
+		})
+	}
+}
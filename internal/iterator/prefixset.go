@@ -0,0 +1,135 @@
+package iterator
+
+import (
+	"net/netip"
+	"slices"
+)
+
+// addrRange is an inclusive range of addresses in one address family.
+type addrRange struct {
+	start netip.Addr
+	end   netip.Addr
+}
+
+// PrefixSet is a set of addresses, such as the networks returned by a scan,
+// that supports set operations. Its zero value is the empty set.
+type PrefixSet struct {
+	// ranges is sorted, and its ranges neither overlap nor touch.
+	ranges []addrRange
+}
+
+// NewPrefixSet returns the set of addresses covered by prefixes.
+func NewPrefixSet(prefixes []netip.Prefix) PrefixSet {
+	ranges := make([]addrRange, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		prefix = prefix.Masked()
+		ranges = append(ranges, addrRange{start: prefix.Addr(), end: lastAddr(prefix)})
+	}
+	return PrefixSet{ranges: mergeRanges(ranges)}
+}
+
+// Union returns the addresses in s or other.
+func (s PrefixSet) Union(other PrefixSet) PrefixSet {
+	return PrefixSet{ranges: mergeRanges(slices.Concat(s.ranges, other.ranges))}
+}
+
+// Intersection returns the addresses in both s and other.
+func (s PrefixSet) Intersection(other PrefixSet) PrefixSet {
+	var ranges []addrRange
+	for i, j := 0, 0; i < len(s.ranges) && j < len(other.ranges); {
+		a, b := s.ranges[i], other.ranges[j]
+		start := maxAddr(a.start, b.start)
+		end := minAddr(a.end, b.end)
+		if start.Compare(end) <= 0 {
+			ranges = append(ranges, addrRange{start: start, end: end})
+		}
+		if a.end.Compare(b.end) < 0 {
+			i++
+		} else {
+			j++
+		}
+	}
+	return PrefixSet{ranges: ranges}
+}
+
+// Difference returns the addresses in s but not in other.
+func (s PrefixSet) Difference(other PrefixSet) PrefixSet {
+	var ranges []addrRange
+	j := 0
+	for _, a := range s.ranges {
+		// Skip the ranges of other that end before a.
+		for j < len(other.ranges) && other.ranges[j].end.Compare(a.start) < 0 {
+			j++
+		}
+
+		current := a.start
+		covered := false
+		for k := j; k < len(other.ranges) && other.ranges[k].start.Compare(a.end) <= 0; k++ {
+			b := other.ranges[k]
+			if b.start.Compare(current) > 0 {
+				ranges = append(ranges, addrRange{start: current, end: b.start.Prev()})
+			}
+			if b.end.Compare(a.end) >= 0 {
+				covered = true
+				break
+			}
+			current = b.end.Next()
+		}
+		if !covered {
+			ranges = append(ranges, addrRange{start: current, end: a.end})
+		}
+	}
+	return PrefixSet{ranges: ranges}
+}
+
+// Prefixes returns the smallest set of prefixes that exactly covers s, in
+// address order.
+func (s PrefixSet) Prefixes() []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, r := range s.ranges {
+		// The range addresses are valid and ordered, so this cannot fail.
+		rangePrefixes, _ := RangePrefixes(r.start, r.end)
+		prefixes = append(prefixes, rangePrefixes...)
+	}
+	return prefixes
+}
+
+// mergeRanges sorts ranges and merges those that overlap or touch.
+func mergeRanges(ranges []addrRange) []addrRange {
+	slices.SortFunc(ranges, func(a, b addrRange) int {
+		return a.start.Compare(b.start)
+	})
+
+	merged := ranges[:0]
+	for _, r := range ranges {
+		if n := len(merged); n > 0 {
+			last := &merged[n-1]
+			// Ranges in different families never merge, as Next of the last
+			// IPv4 address is invalid.
+			if r.start.Compare(last.end) <= 0 || (last.end.Next().IsValid() && last.end.Next() == r.start) {
+				if r.end.Compare(last.end) > 0 {
+					last.end = r.end
+				}
+				continue
+			}
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// maxAddr returns the later of a and b.
+func maxAddr(a, b netip.Addr) netip.Addr {
+	if a.Compare(b) >= 0 {
+		return a
+	}
+	return b
+}
+
+// minAddr returns the earlier of a and b.
+func minAddr(a, b netip.Addr) netip.Addr {
+	if a.Compare(b) <= 0 {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,81 @@
+package iterator
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+)
+
+func TestPrefixSet(t *testing.T) {
+	set := func(prefixes ...string) PrefixSet {
+		parsed := make([]netip.Prefix, 0, len(prefixes))
+		for _, prefix := range prefixes {
+			parsed = append(parsed, netip.MustParsePrefix(prefix))
+		}
+		return NewPrefixSet(parsed)
+	}
+
+	tests := []struct {
+		name     string
+		set      PrefixSet
+		expected []string
+	}{
+		{
+			name:     "merges adjacent and overlapping prefixes",
+			set:      set("10.0.0.128/25", "10.0.0.0/25", "10.0.0.0/26", "10.0.1.0/24"),
+			expected: []string{"10.0.0.0/23"},
+		},
+		{
+			name:     "keeps families apart",
+			set:      set("::/96", "255.255.255.255/32", "0.0.0.0/32"),
+			expected: []string{"0.0.0.0/32", "255.255.255.255/32", "::/96"},
+		},
+		{
+			name:     "union",
+			set:      set("10.0.0.0/24").Union(set("10.0.1.0/24", "2001:db8::/32")),
+			expected: []string{"10.0.0.0/23", "2001:db8::/32"},
+		},
+		{
+			name:     "intersection",
+			set:      set("10.0.0.0/16", "10.2.0.0/16").Intersection(set("10.0.1.0/24", "10.2.128.0/17", "10.3.0.0/16")),
+			expected: []string{"10.0.1.0/24", "10.2.128.0/17"},
+		},
+		{
+			name:     "intersection across families",
+			set:      set("10.0.0.0/8").Intersection(set("::/0")),
+			expected: nil,
+		},
+		{
+			name:     "difference",
+			set:      set("10.0.0.0/24").Difference(set("10.0.0.16/28", "10.0.0.128/25")),
+			expected: []string{"10.0.0.0/28", "10.0.0.32/27", "10.0.0.64/26"},
+		},
+		{
+			name:     "difference of covering set",
+			set:      set("10.0.0.0/24").Difference(set("10.0.0.0/8")),
+			expected: nil,
+		},
+		{
+			name:     "difference at the end of the address space",
+			set:      set("255.255.255.0/24").Difference(set("255.255.255.0/25")),
+			expected: []string{"255.255.255.128/25"},
+		},
+		{
+			name:     "empty set",
+			set:      PrefixSet{}.Union(PrefixSet{}),
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, prefix := range tt.set.Prefixes() {
+				got = append(got, prefix.String())
+			}
+			if !slices.Equal(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
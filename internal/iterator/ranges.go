@@ -0,0 +1,87 @@
+package iterator
+
+import (
+	"errors"
+	"math/big"
+	"net/netip"
+)
+
+// RangePrefixes returns the smallest set of prefixes that exactly covers
+// the addresses from start to end, inclusive, in address order. start and
+// end must be in the same address family and start must not be after end.
+func RangePrefixes(start, end netip.Addr) ([]netip.Prefix, error) {
+	if !start.IsValid() || !end.IsValid() {
+		return nil, errors.New("invalid range address")
+	}
+	start, end = start.WithZone(""), end.WithZone("")
+	if start.Is4() != end.Is4() {
+		return nil, errors.New("range start and end must be in the same address family")
+	}
+	if start.Compare(end) > 0 {
+		return nil, errors.New("range start must not be after range end")
+	}
+
+	var prefixes []netip.Prefix
+	for current := start; ; {
+		// Use the largest prefix that starts at current and ends within
+		// the range.
+		var prefix netip.Prefix
+		for bits := 0; bits <= current.BitLen(); bits++ {
+			candidate := netip.PrefixFrom(current, bits)
+			if candidate.Masked().Addr() == current && lastAddr(candidate).Compare(end) <= 0 {
+				prefix = candidate
+				break
+			}
+		}
+		prefixes = append(prefixes, prefix)
+
+		last := lastAddr(prefix)
+		if last == end {
+			return prefixes, nil
+		}
+		current = last.Next()
+	}
+}
+
+// ScannedFraction returns the fraction of the addresses in network that are
+// at or before the end of last, e.g. 0.5 if last is the first half of
+// network. It returns 0 if last is invalid or before network and 1 if it is
+// after network.
+func ScannedFraction(network, last netip.Prefix) float64 {
+	network = network.Masked()
+	if !last.IsValid() || last.Addr().Is4() != network.Addr().Is4() {
+		return 0
+	}
+
+	first := addrInt(network.Addr())
+	end := addrInt(lastAddr(last))
+	if end.Cmp(first) < 0 {
+		return 0
+	}
+	scanned := new(big.Int).Sub(end, first)
+	scanned.Add(scanned, big.NewInt(1))
+
+	size := new(big.Int).Lsh(big.NewInt(1), uint(network.Addr().BitLen()-network.Bits()))
+	if scanned.Cmp(size) >= 0 {
+		return 1
+	}
+	fraction, _ := new(big.Rat).SetFrac(scanned, size).Float64()
+	return fraction
+}
+
+// addrInt returns addr as an integer.
+func addrInt(addr netip.Addr) *big.Int {
+	return new(big.Int).SetBytes(addr.AsSlice())
+}
+
+// lastAddr returns the last address in prefix.
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	bytes := prefix.Masked().Addr().AsSlice()
+	for i := range bytes {
+		// Number of host bits in this byte.
+		hostBits := min(max((i+1)*8-prefix.Bits(), 0), 8)
+		bytes[i] |= byte(1<<hostBits - 1)
+	}
+	addr, _ := netip.AddrFromSlice(bytes)
+	return addr
+}
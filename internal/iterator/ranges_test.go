@@ -0,0 +1,209 @@
+package iterator
+
+import (
+	"net/netip"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang/v2"
+)
+
+func TestRangePrefixes(t *testing.T) {
+	tests := []struct {
+		name     string
+		start    string
+		end      string
+		expected []string
+	}{
+		{
+			name:     "aligned",
+			start:    "1.0.0.0",
+			end:      "1.0.255.255",
+			expected: []string{"1.0.0.0/16"},
+		},
+		{
+			name:     "single address",
+			start:    "10.0.0.1",
+			end:      "10.0.0.1",
+			expected: []string{"10.0.0.1/32"},
+		},
+		{
+			name:  "non-aligned",
+			start: "10.0.0.1",
+			end:   "10.0.0.10",
+			expected: []string{
+				"10.0.0.1/32",
+				"10.0.0.2/31",
+				"10.0.0.4/30",
+				"10.0.0.8/31",
+				"10.0.0.10/32",
+			},
+		},
+		{
+			name:     "whole address space",
+			start:    "0.0.0.0",
+			end:      "255.255.255.255",
+			expected: []string{"0.0.0.0/0"},
+		},
+		{
+			name:     "ipv6",
+			start:    "2001:db8::",
+			end:      "2001:db8::1:ffff",
+			expected: []string{"2001:db8::/111"},
+		},
+		{
+			name:     "ipv6 to end of address space",
+			start:    "ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffe",
+			end:      "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff",
+			expected: []string{"ffff:ffff:ffff:ffff:ffff:ffff:ffff:fffe/127"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prefixes, err := RangePrefixes(netip.MustParseAddr(tt.start), netip.MustParseAddr(tt.end))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			got := make([]string, len(prefixes))
+			for i, prefix := range prefixes {
+				got[i] = prefix.String()
+			}
+			if !slices.Equal(got, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRangePrefixesErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		start string
+		end   string
+	}{
+		{"start after end", "10.0.0.10", "10.0.0.1"},
+		{"mixed address families", "10.0.0.1", "2001:db8::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := RangePrefixes(netip.MustParseAddr(tt.start), netip.MustParseAddr(tt.end))
+			if err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+}
+
+func TestScannedFraction(t *testing.T) {
+	tests := []struct {
+		network  string
+		last     string
+		expected float64
+	}{
+		{network: "10.0.0.0/24", last: "10.0.0.0/26", expected: 0.25},
+		{network: "10.0.0.0/23", last: "10.0.0.0/26", expected: 0.125},
+		{network: "10.0.0.0/24", last: "10.0.0.128/25", expected: 1},
+		{network: "10.0.0.0/24", last: "10.0.0.127/32", expected: 0.5},
+		{network: "10.0.0.0/24", last: "10.0.1.0/24", expected: 1},
+		{network: "10.0.0.0/24", last: "9.0.0.0/8", expected: 0},
+		{network: "::/0", last: "::/1", expected: 0.5},
+		{network: "10.0.0.0/24", last: "::/1", expected: 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.network+" "+test.last, func(t *testing.T) {
+			got := ScannedFraction(netip.MustParsePrefix(test.network), netip.MustParsePrefix(test.last))
+			if got != test.expected {
+				t.Errorf("Expected %v, got %v", test.expected, got)
+			}
+		})
+	}
+
+	if got := ScannedFraction(netip.MustParsePrefix("10.0.0.0/24"), netip.Prefix{}); got != 0 {
+		t.Errorf("Expected 0 for an invalid last network, got %v", got)
+	}
+}
+
+func TestIterateExtraNetworks(t *testing.T) {
+	reader, err := maxminddb.Open(testCityDBPath)
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	manager := New(30*time.Minute, 5*time.Minute)
+
+	// The range is not aligned, so it is split into several prefixes, and
+	// the database network 81.2.69.160/27 covers five of them.
+	prefixes, err := RangePrefixes(
+		netip.MustParseAddr("81.2.69.143"),
+		netip.MustParseAddr("81.2.69.190"),
+	)
+	if err != nil {
+		t.Fatalf("Failed to compute range prefixes: %v", err)
+	}
+
+	iter, err := manager.CreateIteratorWithOptions(
+		reader,
+		testDB,
+		prefixes[0],
+		nil,
+		filterModeAnd,
+		Options{ExtraNetworks: prefixes[1:]},
+	)
+	if err != nil {
+		t.Fatalf("Failed to create iterator: %v", err)
+	}
+	all, err := manager.Iterate(iter, 1000)
+	if err != nil {
+		t.Fatalf("Failed to iterate: %v", err)
+	}
+	expected := []string{"81.2.69.142/31", "81.2.69.144/28", "81.2.69.160/27"}
+	got := make([]string, len(all.Results))
+	for i, r := range all.Results {
+		got[i] = r.Network.String()
+	}
+	if !slices.Equal(got, expected) {
+		t.Errorf("Expected networks %v, got %v", expected, got)
+	}
+
+	// Paging through the range with resume tokens yields the same networks,
+	// each exactly once.
+	var paged []string
+	token := ""
+	for range 100 {
+		var batchIter *ManagedIterator
+		if token == "" {
+			batchIter, err = manager.CreateIteratorWithOptions(
+				reader,
+				testDB,
+				prefixes[0],
+				nil,
+				filterModeAnd,
+				Options{ExtraNetworks: prefixes[1:]},
+			)
+		} else {
+			batchIter, err = manager.ResumeIterator(reader, token)
+		}
+		if err != nil {
+			t.Fatalf("Failed to create iterator: %v", err)
+		}
+		batch, err := manager.Iterate(batchIter, 1)
+		if err != nil {
+			t.Fatalf("Failed to iterate: %v", err)
+		}
+		for _, r := range batch.Results {
+			paged = append(paged, r.Network.String())
+		}
+		if !batch.HasMore {
+			break
+		}
+		token = batch.ResumeToken
+	}
+	if !slices.Equal(paged, expected) {
+		t.Errorf("Expected networks %v when paging, got %v", expected, paged)
+	}
+}
@@ -0,0 +1,140 @@
+package iterator
+
+import (
+	"bytes"
+	"fmt"
+	"math/big"
+
+	"github.com/oschwald/maxminddb-golang/v2"
+	"github.com/oschwald/maxminddb-golang/v2/mmdbdata"
+)
+
+// TypedNumber is a number annotated with its MMDB data type, e.g. "uint32"
+// or "double". A plain decode widens numbers so that, once encoded as JSON,
+// a uint128 cannot be told apart from a double.
+type TypedNumber struct {
+	Value any    `json:"value"`
+	Type  string `json:"type"`
+}
+
+// DecodeTyped decodes a record like Result.Decode, except that numbers are
+// returned as TypedNumber values. uint64 values are kept as uint64 and
+// uint128 values as *big.Int, so neither loses precision when encoded.
+func DecodeTyped(result maxminddb.Result) (map[string]any, error) {
+	var typed typedValue
+	if err := result.Decode(&typed); err != nil {
+		return nil, err
+	}
+	if typed.value == nil {
+		return nil, nil
+	}
+	record, ok := typed.value.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("expected a map record, got %T", typed.value)
+	}
+	return record, nil
+}
+
+// typedValue implements mmdbdata.Unmarshaler to decode any value with
+// TypedNumber numbers.
+type typedValue struct {
+	value any
+}
+
+// UnmarshalMaxMindDB implements mmdbdata.Unmarshaler.
+func (v *typedValue) UnmarshalMaxMindDB(d *mmdbdata.Decoder) error {
+	value, err := decodeTypedValue(d)
+	if err != nil {
+		return err
+	}
+	v.value = value
+	return nil
+}
+
+// decodeTypedValue decodes the value at the decoder's position.
+func decodeTypedValue(d *mmdbdata.Decoder) (any, error) {
+	kind, err := d.PeekKind()
+	if err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case mmdbdata.KindMap:
+		return decodeTypedMap(d)
+	case mmdbdata.KindSlice:
+		return decodeTypedSlice(d)
+	case mmdbdata.KindString:
+		return d.ReadString()
+	case mmdbdata.KindBytes:
+		value, err := d.ReadBytes()
+		return bytes.Clone(value), err
+	case mmdbdata.KindBool:
+		return d.ReadBool()
+	case mmdbdata.KindUint16:
+		value, err := d.ReadUint16()
+		return TypedNumber{Type: "uint16", Value: value}, err
+	case mmdbdata.KindUint32:
+		value, err := d.ReadUint32()
+		return TypedNumber{Type: "uint32", Value: value}, err
+	case mmdbdata.KindInt32:
+		value, err := d.ReadInt32()
+		return TypedNumber{Type: "int32", Value: value}, err
+	case mmdbdata.KindUint64:
+		value, err := d.ReadUint64()
+		return TypedNumber{Type: "uint64", Value: value}, err
+	case mmdbdata.KindUint128:
+		hi, lo, err := d.ReadUint128()
+		value := new(big.Int).Lsh(new(big.Int).SetUint64(hi), 64)
+		value.Or(value, new(big.Int).SetUint64(lo))
+		return TypedNumber{Type: "uint128", Value: value}, err
+	case mmdbdata.KindFloat32:
+		value, err := d.ReadFloat32()
+		return TypedNumber{Type: "float", Value: value}, err
+	case mmdbdata.KindFloat64:
+		value, err := d.ReadFloat64()
+		return TypedNumber{Type: "double", Value: value}, err
+	default:
+		return nil, fmt.Errorf("unexpected data type %s", kind)
+	}
+}
+
+// decodeTypedMap decodes the map at the decoder's position.
+func decodeTypedMap(d *mmdbdata.Decoder) (map[string]any, error) {
+	entries, size, err := d.ReadMap()
+	if err != nil {
+		return nil, err
+	}
+	record := make(map[string]any, size)
+	for key, err := range entries {
+		if err != nil {
+			return nil, err
+		}
+		name := string(key) // The key is only valid during the iteration
+		value, err := decodeTypedValue(d)
+		if err != nil {
+			return nil, err
+		}
+		record[name] = value
+	}
+	return record, nil
+}
+
+// decodeTypedSlice decodes the array at the decoder's position.
+func decodeTypedSlice(d *mmdbdata.Decoder) ([]any, error) {
+	elements, size, err := d.ReadSlice()
+	if err != nil {
+		return nil, err
+	}
+	values := make([]any, 0, size)
+	for err := range elements {
+		if err != nil {
+			return nil, err
+		}
+		value, err := decodeTypedValue(d)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
@@ -0,0 +1,146 @@
+package iterator
+
+import (
+	"encoding/json"
+	"math/big"
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/oschwald/maxminddb-mcp/internal/filter"
+
+	"github.com/oschwald/maxminddb-golang/v2"
+)
+
+func TestDecodeTyped(t *testing.T) {
+	reader, err := maxminddb.Open("../../testdata/test-data/MaxMind-DB-test-decoder.mmdb")
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	record, err := DecodeTyped(reader.Lookup(netip.MustParseAddr("1.1.1.1")))
+	if err != nil {
+		t.Fatalf("Failed to decode record: %v", err)
+	}
+
+	uint128, _ := new(big.Int).SetString("1329227995784915872903807060280344576", 10)
+	expected := map[string]TypedNumber{
+		"uint16":  {Type: "uint16", Value: uint16(100)},
+		"uint32":  {Type: "uint32", Value: uint32(268435456)},
+		"uint64":  {Type: "uint64", Value: uint64(1152921504606846976)},
+		"uint128": {Type: "uint128", Value: uint128},
+		"int32":   {Type: "int32", Value: int32(-268435456)},
+		"float":   {Type: "float", Value: float32(1.1)},
+		"double":  {Type: "double", Value: 42.123456},
+	}
+	for field, want := range expected {
+		if got := record[field]; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s = %#v, expected %#v", field, got, want)
+		}
+	}
+
+	// Non-numeric values are decoded as usual.
+	if record["utf8_string"] != "unicode! ☯ - ♫" || record["boolean"] != true {
+		t.Errorf("Unexpected string or boolean values: %v", record)
+	}
+	if got := record["bytes"]; !reflect.DeepEqual(got, []byte{0, 0, 0, 42}) {
+		t.Errorf("bytes = %v, expected [0 0 0 42]", got)
+	}
+	array, _ := record["array"].([]any)
+	if len(array) != 3 {
+		t.Fatalf("Expected an array of 3 elements, got %v", record["array"])
+	}
+	if _, ok := array[0].(TypedNumber); !ok {
+		t.Errorf("Expected typed array elements, got %T", array[0])
+	}
+
+	// Large integers keep their precision when encoded as JSON.
+	data, err := json.Marshal(map[string]any{"uint64": record["uint64"], "uint128": record["uint128"]})
+	if err != nil {
+		t.Fatalf("Failed to encode record: %v", err)
+	}
+	want := `{"uint128":{"value":1329227995784915872903807060280344576,"type":"uint128"},` +
+		`"uint64":{"value":1152921504606846976,"type":"uint64"}}`
+	if string(data) != want {
+		t.Errorf("Encoded %s, expected %s", data, want)
+	}
+
+	// A lookup without a record decodes to nil.
+	cityReader, err := maxminddb.Open(testCityDBPath)
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer func() { _ = cityReader.Close() }()
+	record, err = DecodeTyped(cityReader.Lookup(netip.MustParseAddr("10.0.0.1")))
+	if err != nil || record != nil {
+		t.Errorf("Expected no record, got %v, %v", record, err)
+	}
+}
+
+func TestIterateTypedValues(t *testing.T) {
+	reader, err := maxminddb.Open(testCityDBPath)
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	manager := New(30*time.Minute, 5*time.Minute)
+	network := netip.MustParsePrefix("81.2.69.0/24")
+	tests := []struct {
+		name    string
+		filters []filter.Filter
+	}{
+		{name: "no filters"},
+		{
+			// Filters are matched against plain records, so numeric filter
+			// values still compare against the decoded numbers.
+			name:    "numeric filter",
+			filters: []filter.Filter{{Field: "city.geoname_id", Operator: "greater_than", Value: 0}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			iter, err := manager.CreateIteratorWithOptions(
+				reader,
+				testDB,
+				network,
+				test.filters,
+				filterModeAnd,
+				Options{TypedValues: true},
+			)
+			if err != nil {
+				t.Fatalf("Failed to create iterator: %v", err)
+			}
+
+			result, err := manager.Iterate(iter, 1000)
+			if err != nil {
+				t.Fatalf("Failed to iterate: %v", err)
+			}
+			if len(result.Results) == 0 {
+				t.Fatal("Expected results within 81.2.69.0/24")
+			}
+			for _, r := range result.Results {
+				city, _ := r.Data["city"].(map[string]any)
+				if id, ok := city["geoname_id"].(TypedNumber); ok && id.Type != "uint32" {
+					t.Errorf("Expected a uint32 geoname_id for %s, got %#v", r.Network, id)
+				}
+				location, _ := r.Data["location"].(map[string]any)
+				latitude, ok := location["latitude"].(TypedNumber)
+				if !ok || latitude.Type != "double" {
+					t.Errorf("Expected a double latitude for %s, got %#v", r.Network, location["latitude"])
+				}
+			}
+
+			resumed, err := manager.ResumeIterator(reader, result.ResumeToken)
+			if err != nil {
+				t.Fatalf("Failed to resume iterator: %v", err)
+			}
+			if !resumed.TypedValues {
+				t.Error("Expected TypedValues to survive a resume token round trip")
+			}
+		})
+	}
+}
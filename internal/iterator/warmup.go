@@ -0,0 +1,42 @@
+package iterator
+
+import (
+	"context"
+	"net/netip"
+
+	"github.com/oschwald/maxminddb-golang/v2"
+)
+
+// WarmupResult summarizes a Warmup run.
+type WarmupResult struct {
+	// Records is the number of records read.
+	Records int64
+	// Complete reports whether every network was read before the context
+	// was done.
+	Complete bool
+}
+
+// Warmup reads every record within the given networks of a database, so
+// that the pages holding them are in the page cache when the first queries
+// arrive. It stops once ctx is done, e.g. when the warmup time budget runs
+// out. Networks outside the database's address space are skipped.
+func Warmup(ctx context.Context, reader *maxminddb.Reader, networks []netip.Prefix) WarmupResult {
+	var result WarmupResult
+	for _, network := range networks {
+		for record := range reader.NetworksWithin(network) {
+			if ctx.Err() != nil {
+				return result
+			}
+			if record.Err() != nil {
+				break
+			}
+			var data any
+			if err := record.Decode(&data); err != nil {
+				continue
+			}
+			result.Records++
+		}
+	}
+	result.Complete = ctx.Err() == nil
+	return result
+}
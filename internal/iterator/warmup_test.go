@@ -0,0 +1,41 @@
+package iterator
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"github.com/oschwald/maxminddb-golang/v2"
+)
+
+func TestWarmup(t *testing.T) {
+	reader, err := maxminddb.Open(testCityDBPath)
+	if err != nil {
+		t.Fatalf("Failed to open test database: %v", err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	networks := []netip.Prefix{
+		netip.MustParsePrefix("81.2.69.0/24"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	}
+
+	result := Warmup(context.Background(), reader, networks)
+	if !result.Complete {
+		t.Error("Expected warmup to complete")
+	}
+	if result.Records < 3 {
+		t.Errorf("Expected at least 3 records read, got %d", result.Records)
+	}
+
+	// An exhausted budget stops the warmup before it reads anything.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result = Warmup(ctx, reader, networks)
+	if result.Complete {
+		t.Error("Expected warmup with an exhausted budget not to complete")
+	}
+	if result.Records != 0 {
+		t.Errorf("Expected no records read, got %d", result.Records)
+	}
+}
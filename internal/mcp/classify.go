@@ -0,0 +1,158 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// Limits of the classify_networks tool.
+const (
+	// maxClassifyNetworks is the maximum number of networks classified per
+	// call.
+	maxClassifyNetworks = 100
+	// classifyMaxRecords bounds the records scanned per network. Larger
+	// networks are classified from their first records, with complete set
+	// to false.
+	classifyMaxRecords = 10000
+	// classifyTimeout bounds the time spent scanning all networks. Networks
+	// not fully scanned when it runs out are returned with complete set to
+	// false.
+	classifyTimeout = 30 * time.Second
+)
+
+// networkClass is the dominant value of a field in one network.
+type networkClass struct {
+	Value    any     `json:"value"`
+	Network  string  `json:"network"`
+	Count    int64   `json:"count"`
+	Share    float64 `json:"share"`
+	Records  int64   `json:"records"`
+	Missing  int64   `json:"missing"`
+	Complete bool    `json:"complete"`
+}
+
+// classifyResult is the result of classify_networks.
+type classifyResult struct {
+	Database string         `json:"database"`
+	Field    string         `json:"field"`
+	Networks []networkClass `json:"networks"`
+}
+
+// handleClassifyNetworks handles the classify_networks tool. For each of a
+// list of networks, it scans the records within it and returns the most
+// common value of a field, such as the country or ASN, with the share of
+// scanned records holding it. Each scan is bounded by classifyMaxRecords and
+// all of them by classifyTimeout.
+func (s *Server) handleClassifyNetworks(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	dbName, err := request.RequireString("database")
+	if err != nil {
+		//nolint:nilerr // MCP protocol expects error result, not Go error
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "missing_parameter",
+				"message": "Missing required parameter: database",
+			},
+		}), nil
+	}
+
+	field, err := request.RequireString("field")
+	if err != nil || strings.TrimSpace(field) == "" {
+		//nolint:nilerr // MCP protocol expects error result, not Go error
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "missing_parameter",
+				"message": "Missing required parameter: field",
+			},
+		}), nil
+	}
+
+	networkStrs, err := request.RequireStringSlice("networks")
+	if err != nil || len(networkStrs) == 0 {
+		//nolint:nilerr // MCP protocol expects error result, not Go error
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "missing_parameter",
+				"message": "Missing required parameter: networks (an array of CIDR networks)",
+			},
+		}), nil
+	}
+	if len(networkStrs) > maxClassifyNetworks {
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code": "invalid_parameter",
+				"message": fmt.Sprintf(
+					"Too many networks: %d (at most %d per call)",
+					len(networkStrs),
+					maxClassifyNetworks,
+				),
+			},
+		}), nil
+	}
+
+	networks := make([]netip.Prefix, 0, len(networkStrs))
+	for _, networkStr := range networkStrs {
+		network, err := netip.ParsePrefix(networkStr)
+		if err != nil {
+			//nolint:nilerr // MCP protocol expects error result, not Go error
+			return mcp.NewToolResultStructuredOnly(map[string]any{
+				"error": map[string]any{
+					"code":    "invalid_network",
+					"message": "Invalid network: " + networkStr,
+				},
+			}), nil
+		}
+		networks = append(networks, network)
+	}
+
+	dbName, errResult := s.resolveDatabase(dbName)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	reader, exists := s.dbManager.GetReader(dbName)
+	if !exists {
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "db_not_found",
+				"message": "Database not found: " + dbName,
+			},
+		}), nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, classifyTimeout)
+	defer cancel()
+
+	result := classifyResult{
+		Database: dbName,
+		Field:    field,
+		Networks: make([]networkClass, 0, len(networks)),
+	}
+	path := fieldPath(field)
+
+	for _, network := range networks {
+		counted := countValues(ctx, reader, network, path, classifyMaxRecords)
+		class := networkClass{
+			Network:  network.String(),
+			Records:  counted.records,
+			Missing:  counted.missing,
+			Complete: counted.complete,
+		}
+
+		if top := topValueCounts(counted.counts, 1); len(top) == 1 {
+			class.Value = top[0].Value
+			class.Count = top[0].Count
+			class.Share = float64(top[0].Count) / float64(class.Records)
+		}
+		result.Networks = append(result.Networks, class)
+	}
+
+	return mcp.NewToolResultStructuredOnly(result), nil
+}
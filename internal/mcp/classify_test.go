@@ -0,0 +1,138 @@
+package mcp
+
+import "testing"
+
+func TestClassifyNetworks(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	structured := callTool(t, server.handleClassifyNetworks, "classify_networks", map[string]any{
+		"database": "GeoLite2-City-Test.mmdb",
+		"field":    "country.iso_code",
+		"networks": []any{"81.2.69.0/24", "89.160.20.0/24", "10.0.0.0/8"},
+	})
+	result, ok := structured.(classifyResult)
+	if !ok {
+		t.Fatalf("Unexpected result: %v", structured)
+	}
+	if len(result.Networks) != 3 {
+		t.Fatalf("Expected 3 networks, got %+v", result.Networks)
+	}
+
+	// Networks are classified in the order requested.
+	expected := []struct {
+		value   any
+		network string
+	}{
+		{network: "81.2.69.0/24", value: "GB"},
+		{network: "89.160.20.0/24", value: "SE"},
+	}
+	for i, want := range expected {
+		class := result.Networks[i]
+		if class.Network != want.network || class.Value != want.value {
+			t.Errorf("Expected %s to be classified as %v, got %+v", want.network, want.value, class)
+		}
+		if !class.Complete || class.Records == 0 || class.Count != class.Records || class.Share != 1 {
+			t.Errorf("Expected every record of %s to hold %v, got %+v", want.network, want.value, class)
+		}
+	}
+
+	// A network without records has no value.
+	empty := result.Networks[2]
+	if empty.Network != "10.0.0.0/8" || empty.Value != nil || empty.Records != 0 || empty.Share != 0 {
+		t.Errorf("Expected 10.0.0.0/8 to have no records, got %+v", empty)
+	}
+}
+
+func TestClassifyNetworksMissingField(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	structured := callTool(t, server.handleClassifyNetworks, "classify_networks", map[string]any{
+		"database": "GeoLite2-City-Test.mmdb",
+		"field":    "autonomous_system_number",
+		"networks": []any{"81.2.69.0/24"},
+	})
+	result, ok := structured.(classifyResult)
+	if !ok || len(result.Networks) != 1 {
+		t.Fatalf("Unexpected result: %v", structured)
+	}
+	class := result.Networks[0]
+	if class.Value != nil || class.Records == 0 || class.Missing != class.Records {
+		t.Errorf("Expected every record to miss the field, got %+v", class)
+	}
+}
+
+func TestClassifyNetworksErrors(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	tooMany := make([]any, maxClassifyNetworks+1)
+	for i := range tooMany {
+		tooMany[i] = "81.2.69.0/24"
+	}
+
+	tests := []struct {
+		args map[string]any
+		name string
+		code string
+	}{
+		{
+			name: "missing database",
+			args: map[string]any{"field": "country.iso_code", "networks": []any{"81.2.69.0/24"}},
+			code: "missing_parameter",
+		},
+		{
+			name: "missing field",
+			args: map[string]any{"database": "GeoLite2-City-Test.mmdb", "networks": []any{"81.2.69.0/24"}},
+			code: "missing_parameter",
+		},
+		{
+			name: "missing networks",
+			args: map[string]any{"database": "GeoLite2-City-Test.mmdb", "field": "country.iso_code"},
+			code: "missing_parameter",
+		},
+		{
+			name: "empty networks",
+			args: map[string]any{
+				"database": "GeoLite2-City-Test.mmdb",
+				"field":    "country.iso_code",
+				"networks": []any{},
+			},
+			code: "missing_parameter",
+		},
+		{
+			name: "too many networks",
+			args: map[string]any{
+				"database": "GeoLite2-City-Test.mmdb",
+				"field":    "country.iso_code",
+				"networks": tooMany,
+			},
+			code: "invalid_parameter",
+		},
+		{
+			name: "invalid network",
+			args: map[string]any{
+				"database": "GeoLite2-City-Test.mmdb",
+				"field":    "country.iso_code",
+				"networks": []any{"81.2.69.0/24", "bogus"},
+			},
+			code: "invalid_network",
+		},
+		{
+			name: "unknown database",
+			args: map[string]any{
+				"database": "missing.mmdb",
+				"field":    "country.iso_code",
+				"networks": []any{"81.2.69.0/24"},
+			},
+			code: "db_not_found",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			structured := callTool(t, server.handleClassifyNetworks, "classify_networks", test.args)
+			if code := errorCode(structured); code != test.code {
+				t.Errorf("Expected %s, got %v", test.code, structured)
+			}
+		})
+	}
+}
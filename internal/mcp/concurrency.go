@@ -0,0 +1,63 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// limitConcurrency is a tool handler middleware that runs at most
+// max_concurrent_tools tool calls at a time. Calls beyond the limit wait up
+// to tool_queue_timeout for a slot and then fail with server_busy.
+func (s *Server) limitConcurrency(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		if s.toolSlots == nil {
+			return next(ctx, request)
+		}
+
+		if !s.acquireToolSlot(ctx) {
+			return mcp.NewToolResultStructuredOnly(map[string]any{
+				"error": map[string]any{
+					"code": "server_busy",
+					"message": fmt.Sprintf(
+						"Server busy: %d tool calls already running, try again later",
+						cap(s.toolSlots),
+					),
+				},
+			}), nil
+		}
+		defer func() { <-s.toolSlots }()
+
+		return next(ctx, request)
+	}
+}
+
+// acquireToolSlot takes a tool call slot, waiting up to tool_queue_timeout
+// for one to free up. It reports false if no slot became available in time
+// or ctx was canceled first.
+func (s *Server) acquireToolSlot(ctx context.Context) bool {
+	select {
+	case s.toolSlots <- struct{}{}:
+		return true
+	default:
+	}
+
+	timeout := s.config.ToolQueueTimeoutDuration
+	if timeout <= 0 {
+		return false
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case s.toolSlots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,147 @@
+package mcp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/oschwald/maxminddb-mcp/internal/database"
+	"github.com/oschwald/maxminddb-mcp/internal/iterator"
+)
+
+// newTestServerWithToolLimit returns a server that runs at most limit tool
+// calls at a time, queueing others for queueTimeout.
+func newTestServerWithToolLimit(t *testing.T, limit int, queueTimeout time.Duration) *Server {
+	t.Helper()
+
+	cfg := createTestMCPConfig(t)
+	cfg.MaxConcurrentTools = limit
+	cfg.ToolQueueTimeoutDuration = queueTimeout
+
+	dbManager, err := database.New()
+	if err != nil {
+		t.Fatalf("Failed to create database manager: %v", err)
+	}
+	t.Cleanup(func() { _ = dbManager.Close() })
+
+	iterMgr := iterator.New(30*time.Minute, 5*time.Minute)
+	t.Cleanup(iterMgr.StopCleanup)
+
+	return New(cfg, dbManager, nil, iterMgr, BuildInfo{})
+}
+
+// blockingHandler returns a tool handler that signals started when called
+// and returns once release is closed.
+func blockingHandler(started chan<- struct{}, release <-chan struct{}) func(
+	context.Context,
+	mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	return func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		started <- struct{}{}
+		<-release
+		return mcp.NewToolResultStructuredOnly(map[string]any{"ok": true}), nil
+	}
+}
+
+func TestLimitConcurrencyRejectsBeyondLimit(t *testing.T) {
+	server := newTestServerWithToolLimit(t, 1, 0)
+
+	started := make(chan struct{}, 1)
+	release := make(chan struct{})
+	handler := server.limitConcurrency(blockingHandler(started, release))
+
+	done := make(chan any)
+	go func() {
+		done <- callTool(t, handler, "lookup_network", nil)
+	}()
+	<-started
+
+	if code := errorCode(callTool(t, handler, "lookup_network", nil)); code != "server_busy" {
+		t.Errorf("Expected server_busy while the limit is reached, got %q", code)
+	}
+
+	close(release)
+	if result, _ := (<-done).(map[string]any); result["ok"] != true {
+		t.Errorf("Expected the running call to finish, got %v", result)
+	}
+
+	// The slot is free again.
+	go func() { <-started }()
+	if result, _ := callTool(t, handler, "lookup_network", nil).(map[string]any); result["ok"] != true {
+		t.Errorf("Expected a call after the limit cleared to run, got %v", result)
+	}
+}
+
+func TestLimitConcurrencyQueues(t *testing.T) {
+	server := newTestServerWithToolLimit(t, 1, 5*time.Second)
+
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	handler := server.limitConcurrency(blockingHandler(started, release))
+
+	first := make(chan any)
+	go func() {
+		first <- callTool(t, handler, "lookup_network", nil)
+	}()
+	<-started
+
+	second := make(chan any)
+	go func() {
+		second <- callTool(t, handler, "lookup_network", nil)
+	}()
+
+	// The second call waits for the first one instead of running.
+	select {
+	case <-started:
+		t.Fatal("Expected the second call to wait for a free slot")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	for _, done := range []chan any{first, second} {
+		if result, _ := (<-done).(map[string]any); result["ok"] != true {
+			t.Errorf("Expected the call to finish, got %v", result)
+		}
+	}
+}
+
+func TestLimitConcurrencyQueueTimeout(t *testing.T) {
+	server := newTestServerWithToolLimit(t, 1, 10*time.Millisecond)
+	server.toolSlots <- struct{}{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	handler := server.limitConcurrency(blockingHandler(nil, nil))
+	result, err := handler(ctx, mcp.CallToolRequest{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if code := errorCode(result.StructuredContent); code != "server_busy" {
+		t.Errorf("Expected server_busy for a canceled call, got %v", result.StructuredContent)
+	}
+
+	if code := errorCode(callTool(t, handler, "lookup_network", nil)); code != "server_busy" {
+		t.Errorf("Expected server_busy after the queue timeout, got %q", code)
+	}
+}
+
+func TestLimitConcurrencyThroughServer(t *testing.T) {
+	server := newTestServerWithToolLimit(t, 1, 0)
+	server.toolSlots <- struct{}{}
+
+	// Busy results carry the schema version like other errors.
+	structured, _ := callToolMessage(t, server, "list_databases", map[string]any{})
+	if code := errorCode(structured); code != "server_busy" {
+		t.Fatalf("Expected server_busy, got %v", structured)
+	}
+	if structured[schemaVersionField] != float64(SchemaVersion) {
+		t.Errorf("Expected schema_version in the busy result, got %v", structured)
+	}
+
+	<-server.toolSlots
+	structured, _ = callToolMessage(t, server, "list_databases", map[string]any{})
+	if code := errorCode(structured); code != "" {
+		t.Errorf("Expected list_databases to run once a slot is free, got %v", structured)
+	}
+}
@@ -0,0 +1,106 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/oschwald/maxminddb-golang/v2"
+)
+
+// coveringNetworkResult is the result of covering_network. When the IP is
+// covered, Network is the most specific network holding it and Data is its
+// record, which is empty rather than nil for networks with an empty record.
+// Otherwise UncoveredNetwork is the largest network around the IP without
+// data.
+type coveringNetworkResult struct {
+	Data             map[string]any `json:"data"`
+	IP               string         `json:"ip"`
+	Database         string         `json:"database"`
+	Network          string         `json:"network,omitempty"`
+	UncoveredNetwork string         `json:"uncovered_network,omitempty"`
+	Found            bool           `json:"found"`
+}
+
+// handleCoveringNetwork handles the covering_network tool. It returns the
+// most specific network of a database covering an IP, with its data, as
+// used when building rules from aggregated networks.
+func (s *Server) handleCoveringNetwork(
+	_ context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	ipStr, err := request.RequireString("ip")
+	if err != nil {
+		//nolint:nilerr // MCP protocol expects error result, not Go error
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "missing_parameter",
+				"message": "Missing required parameter: ip",
+			},
+		}), nil
+	}
+
+	ip, err := netip.ParseAddr(ipStr)
+	if err != nil {
+		//nolint:nilerr // MCP protocol expects error result, not Go error
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "invalid_ip",
+				"message": "Invalid IP address: " + ipStr,
+			},
+		}), nil
+	}
+
+	dbName, err := request.RequireString("database")
+	if err != nil {
+		//nolint:nilerr // MCP protocol expects error result, not Go error
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "missing_parameter",
+				"message": "Missing required parameter: database",
+			},
+		}), nil
+	}
+
+	dbName, errResult := s.resolveDatabase(dbName)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	if _, exists := s.dbManager.GetReader(dbName); !exists {
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "db_not_found",
+				"message": "Database not found: " + dbName,
+			},
+		}), nil
+	}
+
+	result := coveringNetworkResult{IP: ipStr, Database: dbName}
+	err = s.dbManager.WithReader(dbName, func(reader *maxminddb.Reader) error {
+		lookup := reader.Lookup(ip)
+		if err := lookup.Err(); err != nil {
+			return err
+		}
+		result.Found = lookup.Found()
+		if !result.Found {
+			result.UncoveredNetwork = lookup.Prefix().String()
+			return nil
+		}
+		result.Network = lookup.Prefix().String()
+		result.Data = map[string]any{}
+		return lookup.Decode(&result.Data)
+	})
+	if err != nil {
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "lookup_failed",
+				"message": fmt.Sprintf("Lookup failed: %v", err),
+			},
+		}), nil
+	}
+
+	return mcp.NewToolResultStructuredOnly(result), nil
+}
@@ -0,0 +1,86 @@
+package mcp
+
+import "testing"
+
+func TestCoveringNetwork(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	structured := callTool(t, server.handleCoveringNetwork, "covering_network", map[string]any{
+		"ip":       "81.2.69.142",
+		"database": "type:City",
+	})
+	result, ok := structured.(coveringNetworkResult)
+	if !ok {
+		t.Fatalf("Unexpected result: %v", structured)
+	}
+	if !result.Found || result.Network != "81.2.69.142/31" || result.UncoveredNetwork != "" {
+		t.Errorf("Expected 81.2.69.142/31 to cover the IP, got %+v", result)
+	}
+	if result.Database != "GeoLite2-City-Test.mmdb" {
+		t.Errorf("Expected the City test database, got %s", result.Database)
+	}
+	city, _ := result.Data["city"].(map[string]any)
+	names, _ := city["names"].(map[string]any)
+	if names["en"] != "London" {
+		t.Errorf("Expected London, got %v", result.Data)
+	}
+}
+
+func TestCoveringNetworkUncovered(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	structured := callTool(t, server.handleCoveringNetwork, "covering_network", map[string]any{
+		"ip":       "10.0.0.1",
+		"database": "GeoLite2-City-Test.mmdb",
+	})
+	result, ok := structured.(coveringNetworkResult)
+	if !ok {
+		t.Fatalf("Unexpected result: %v", structured)
+	}
+	if result.Found || result.Network != "" || result.Data != nil {
+		t.Errorf("Expected no covering network, got %+v", result)
+	}
+	if result.UncoveredNetwork == "" {
+		t.Errorf("Expected the uncovered network, got %+v", result)
+	}
+}
+
+func TestCoveringNetworkErrors(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	tests := []struct {
+		args map[string]any
+		name string
+		code string
+	}{
+		{
+			name: "missing ip",
+			args: map[string]any{"database": "GeoLite2-City-Test.mmdb"},
+			code: "missing_parameter",
+		},
+		{
+			name: "invalid ip",
+			args: map[string]any{"ip": "bogus", "database": "GeoLite2-City-Test.mmdb"},
+			code: "invalid_ip",
+		},
+		{
+			name: "missing database",
+			args: map[string]any{"ip": "81.2.69.142"},
+			code: "missing_parameter",
+		},
+		{
+			name: "unknown database",
+			args: map[string]any{"ip": "81.2.69.142", "database": "missing.mmdb"},
+			code: "db_not_found",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			structured := callTool(t, server.handleCoveringNetwork, "covering_network", test.args)
+			if code := errorCode(structured); code != test.code {
+				t.Errorf("Expected %s, got %v", test.code, structured)
+			}
+		})
+	}
+}
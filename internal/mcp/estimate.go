@@ -0,0 +1,180 @@
+package mcp
+
+import (
+	"context"
+	"net/netip"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/oschwald/maxminddb-mcp/internal/config"
+	"github.com/oschwald/maxminddb-mcp/internal/iterator"
+)
+
+// defaultEstimateSampleSize is the default number of records scanned by
+// estimate_scan.
+const defaultEstimateSampleSize = 1000
+
+// scanEstimate is the result of estimate_scan.
+type scanEstimate struct {
+	Database            string  `json:"database"`
+	Network             string  `json:"network"`
+	SampledRecords      int64   `json:"sampled_records"`
+	SampledMatches      int64   `json:"sampled_matches"`
+	MatchRate           float64 `json:"match_rate"`
+	SampledFraction     float64 `json:"sampled_fraction"`
+	SampleDurationMS    float64 `json:"sample_duration_ms"`
+	EstimatedRecords    int64   `json:"estimated_records"`
+	EstimatedResults    int64   `json:"estimated_results"`
+	EstimatedDurationMS float64 `json:"estimated_duration_ms"`
+	Exact               bool    `json:"exact"`
+}
+
+// handleEstimateScan handles the estimate_scan tool. It scans the first
+// sample_size records of the network with the given filters and extrapolates
+// the record count, result count, and duration of the full scan from the
+// share of the network's address space the sample covered. Records are
+// rarely spread evenly, so the estimate is rough unless exact is set.
+func (s *Server) handleEstimateScan(
+	_ context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	networkStr, err := request.RequireString("network")
+	if err != nil {
+		//nolint:nilerr // MCP protocol expects error result, not Go error
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "missing_parameter",
+				"message": "Missing required parameter: network",
+			},
+		}), nil
+	}
+	network, err := netip.ParsePrefix(networkStr)
+	if err != nil {
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "invalid_network",
+				"message": "Invalid network: " + networkStr,
+			},
+		}), nil
+	}
+
+	dbName, err := request.RequireString("database")
+	if err != nil {
+		//nolint:nilerr // MCP protocol expects error result, not Go error
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "missing_parameter",
+				"message": "Missing required parameter: database",
+			},
+		}), nil
+	}
+
+	dbName, errResult := s.resolveDatabase(dbName)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	reader, exists := s.dbManager.GetReader(dbName)
+	if !exists {
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "db_not_found",
+				"message": "Database not found: " + dbName,
+			},
+		}), nil
+	}
+
+	filters, errResult := s.requestFilters(request)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	defaultFilterMode := s.config.DefaultFilterMode
+	if defaultFilterMode == "" {
+		defaultFilterMode = config.FilterModeAnd
+	}
+	filterMode := request.GetString("filter_mode", defaultFilterMode)
+
+	sampleSize := request.GetInt("sample_size", defaultEstimateSampleSize)
+	if sampleSize <= 0 {
+		sampleSize = defaultEstimateSampleSize
+	}
+
+	// Sample with a private manager so that the sample never shares state
+	// with lookup_network iterators, e.g. with deterministic iterator IDs.
+	sampler := iterator.New(0, 0)
+	iter, err := sampler.CreateIterator(reader, dbName, network, filters, filterMode)
+	if err != nil {
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "iterator_creation_failed",
+				"message": "Failed to create iterator: " + err.Error(),
+			},
+		}), nil
+	}
+
+	start := time.Now()
+	result, err := sampler.Sample(iter, sampleSize, sampleSize)
+	if err != nil {
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "iteration_failed",
+				"message": "Iteration failed: " + err.Error(),
+			},
+		}), nil
+	}
+	elapsed := time.Since(start)
+
+	return mcp.NewToolResultStructuredOnly(newScanEstimate(
+		dbName,
+		network,
+		result,
+		iter.LastNetwork,
+		elapsed,
+	)), nil
+}
+
+// newScanEstimate extrapolates a sample of a scan of network that ended at
+// last to the full scan.
+func newScanEstimate(
+	dbName string,
+	network netip.Prefix,
+	result *iterator.IterationResult,
+	last netip.Prefix,
+	elapsed time.Duration,
+) scanEstimate {
+	estimate := scanEstimate{
+		Database:         dbName,
+		Network:          network.String(),
+		SampledRecords:   result.TotalProcessed,
+		SampledMatches:   result.TotalMatched,
+		SampleDurationMS: durationMS(elapsed),
+		Exact:            !result.HasMore,
+	}
+	if estimate.SampledRecords > 0 {
+		estimate.MatchRate = float64(estimate.SampledMatches) / float64(estimate.SampledRecords)
+	}
+
+	if estimate.Exact {
+		estimate.SampledFraction = 1
+		estimate.EstimatedRecords = estimate.SampledRecords
+		estimate.EstimatedResults = estimate.SampledMatches
+		estimate.EstimatedDurationMS = estimate.SampleDurationMS
+		return estimate
+	}
+
+	estimate.SampledFraction = iterator.ScannedFraction(network, last)
+	if estimate.SampledFraction <= 0 {
+		return estimate
+	}
+	scale := 1 / estimate.SampledFraction
+	estimate.EstimatedRecords = int64(float64(estimate.SampledRecords) * scale)
+	estimate.EstimatedResults = int64(float64(estimate.SampledMatches) * scale)
+	estimate.EstimatedDurationMS = estimate.SampleDurationMS * scale
+	return estimate
+}
+
+// durationMS converts a duration to fractional milliseconds.
+func durationMS(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
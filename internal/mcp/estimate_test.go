@@ -0,0 +1,123 @@
+package mcp
+
+import (
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/oschwald/maxminddb-mcp/internal/iterator"
+)
+
+func TestEstimateScan(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	// A sample covering the whole network is exact.
+	structured := callTool(t, server.handleEstimateScan, "estimate_scan", map[string]any{
+		"network":  "81.2.69.0/24",
+		"database": "GeoLite2-City-Test.mmdb",
+		"filters": []any{
+			map[string]any{"field": "country.iso_code", "operator": "equals", "value": "GB"},
+		},
+	})
+	estimate, ok := structured.(scanEstimate)
+	if !ok {
+		t.Fatalf("Expected scan estimate, got %v", structured)
+	}
+	if !estimate.Exact || estimate.SampledFraction != 1 {
+		t.Errorf("Expected exact estimate, got %+v", estimate)
+	}
+	if estimate.SampledRecords != 4 || estimate.EstimatedRecords != 4 {
+		t.Errorf("Expected 4 records, got %+v", estimate)
+	}
+	if estimate.EstimatedResults != estimate.SampledMatches || estimate.MatchRate <= 0 {
+		t.Errorf("Expected estimated results to equal sampled matches, got %+v", estimate)
+	}
+
+	// A partial sample is extrapolated.
+	structured = callTool(t, server.handleEstimateScan, "estimate_scan", map[string]any{
+		"network":     "81.2.69.0/24",
+		"database":    "GeoLite2-City-Test.mmdb",
+		"sample_size": 2,
+	})
+	estimate, ok = structured.(scanEstimate)
+	if !ok {
+		t.Fatalf("Expected scan estimate, got %v", structured)
+	}
+	if estimate.Exact {
+		t.Error("Expected an inexact estimate for a partial sample")
+	}
+	if estimate.SampledRecords != 2 {
+		t.Errorf("Expected 2 sampled records, got %d", estimate.SampledRecords)
+	}
+	if estimate.SampledFraction <= 0 || estimate.SampledFraction >= 1 {
+		t.Errorf("Expected a partial sampled fraction, got %v", estimate.SampledFraction)
+	}
+	if estimate.EstimatedRecords < estimate.SampledRecords {
+		t.Errorf("Expected at least the sampled records, got %+v", estimate)
+	}
+}
+
+func TestEstimateScanErrors(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	tests := []struct {
+		name string
+		args map[string]any
+		code string
+	}{
+		{
+			name: "missing network",
+			args: map[string]any{"database": "GeoLite2-City-Test.mmdb"},
+			code: "missing_parameter",
+		},
+		{
+			name: "invalid network",
+			args: map[string]any{"network": "81.2.69.0", "database": "GeoLite2-City-Test.mmdb"},
+			code: "invalid_network",
+		},
+		{
+			name: "missing database",
+			args: map[string]any{"network": "81.2.69.0/24"},
+			code: "missing_parameter",
+		},
+		{
+			name: "invalid filter",
+			args: map[string]any{
+				"network":  "81.2.69.0/24",
+				"database": "GeoLite2-City-Test.mmdb",
+				"filters":  []any{map[string]any{"field": "x", "operator": "in", "value": "y"}},
+			},
+			code: "invalid_filter",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			structured := callTool(t, server.handleEstimateScan, "estimate_scan", test.args)
+			if code := errorCode(structured); code != test.code {
+				t.Errorf("Expected error code %s, got %v", test.code, structured)
+			}
+		})
+	}
+}
+
+func TestNewScanEstimateProportionalToRangeSize(t *testing.T) {
+	// The same sample covers a quarter of a /24 but only an eighth of a /23.
+	result := &iterator.IterationResult{TotalProcessed: 100, TotalMatched: 10, HasMore: true}
+	last := netip.MustParsePrefix("10.0.0.0/26")
+
+	small := newScanEstimate("db", netip.MustParsePrefix("10.0.0.0/24"), result, last, 4*time.Millisecond)
+	large := newScanEstimate("db", netip.MustParsePrefix("10.0.0.0/23"), result, last, 4*time.Millisecond)
+
+	if small.EstimatedRecords != 400 || small.EstimatedResults != 40 || small.EstimatedDurationMS != 16 {
+		t.Errorf("Unexpected estimate for /24: %+v", small)
+	}
+	if large.EstimatedRecords != 2*small.EstimatedRecords ||
+		large.EstimatedResults != 2*small.EstimatedResults ||
+		large.EstimatedDurationMS != 2*small.EstimatedDurationMS {
+		t.Errorf("Expected /23 estimate to double the /24 estimate, got %+v and %+v", small, large)
+	}
+	if small.MatchRate != 0.1 {
+		t.Errorf("Expected match rate 0.1, got %v", small.MatchRate)
+	}
+}
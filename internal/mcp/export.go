@@ -0,0 +1,145 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/oschwald/maxminddb-mcp/internal/iterator"
+)
+
+// defaultExportMaxRecords is the maximum number of records exported by
+// export_database unless the export max_records config setting is set.
+const defaultExportMaxRecords = 1000000
+
+// exportResult is the result of export_database.
+type exportResult struct {
+	Database string `json:"database"`
+	Path     string `json:"path"`
+	Records  int64  `json:"records"`
+}
+
+// handleExportDatabase handles the export_database tool. It writes every
+// network of a database and its record to a new NDJSON file in the export
+// directory, one {network, data} object per line. The file is removed if the
+// export fails, e.g. because the database has more than the maximum number of
+// records.
+func (s *Server) handleExportDatabase(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	dbName, err := request.RequireString("database")
+	if err != nil {
+		//nolint:nilerr // MCP protocol expects error result, not Go error
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "missing_parameter",
+				"message": "Missing required parameter: database",
+			},
+		}), nil
+	}
+
+	dbName, errResult := s.resolveDatabase(dbName)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	reader, exists := s.dbManager.GetReader(dbName)
+	if !exists {
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "db_not_found",
+				"message": "Database not found: " + dbName,
+			},
+		}), nil
+	}
+
+	if errResult := checkFullScan(request, fullAddressSpace(reader)); errResult != nil {
+		return errResult, nil
+	}
+
+	fileName := request.GetString("file", strings.TrimSuffix(dbName, ".mmdb")+".ndjson")
+	if fileName != filepath.Base(fileName) || fileName == "." || fileName == ".." {
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "invalid_parameter",
+				"message": "Invalid file: " + fileName + " (must be a file name within the export directory)",
+			},
+		}), nil
+	}
+	path := filepath.Join(s.config.Export.Dir, fileName)
+	if err := s.config.CheckPathAllowed(path); err != nil {
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "path_not_allowed",
+				"message": fmt.Sprintf("Cannot export to %s: %v", path, err),
+			},
+		}), nil
+	}
+
+	maxRecords := int64(defaultExportMaxRecords)
+	if s.config.Export.MaxRecords > 0 {
+		maxRecords = int64(s.config.Export.MaxRecords)
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		code := "export_failed"
+		if errors.Is(err, os.ErrExist) {
+			code = "file_exists"
+		}
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    code,
+				"message": fmt.Sprintf("Failed to create %s: %v", path, err),
+			},
+		}), nil
+	}
+
+	result := exportResult{Database: dbName, Path: path}
+	writer := bufio.NewWriter(file)
+	encoder := json.NewEncoder(writer)
+	for network := range reader.Networks() {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+			break
+		}
+		if result.Records >= maxRecords {
+			err = fmt.Errorf("database has more than %d records", maxRecords)
+			break
+		}
+
+		var record map[string]any
+		if err = network.Decode(&record); err != nil {
+			err = fmt.Errorf("failed to decode %s: %w", network.Prefix(), err)
+			break
+		}
+		if err = encoder.Encode(iterator.NetworkResult{Network: network.Prefix(), Data: record}); err != nil {
+			break
+		}
+		result.Records++
+	}
+	if err == nil {
+		err = writer.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "export_failed",
+				"message": fmt.Sprintf("Failed to export %s: %v", dbName, err),
+			},
+		}), nil
+	}
+
+	return mcp.NewToolResultStructuredOnly(result), nil
+}
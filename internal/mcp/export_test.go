@@ -0,0 +1,170 @@
+package mcp
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExportDatabase(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+	const asnDB = "GeoLite2-ASN-Test.mmdb"
+	if err := server.dbManager.LoadDatabase("../../testdata/test-data/" + asnDB); err != nil {
+		t.Fatalf("Failed to load database: %v", err)
+	}
+	server.config.Export.Dir = t.TempDir()
+
+	reader, exists := server.dbManager.GetReader(asnDB)
+	if !exists {
+		t.Fatal("Expected the ASN test database to be loaded")
+	}
+	expected := 0
+	for range reader.Networks() {
+		expected++
+	}
+
+	structured := callTool(t, server.handleExportDatabase, "export_database", map[string]any{
+		"database":          asnDB,
+		"confirm_full_scan": true,
+	})
+	result, ok := structured.(exportResult)
+	if !ok {
+		t.Fatalf("Expected export result, got %v", structured)
+	}
+	if result.Path != filepath.Join(server.config.Export.Dir, "GeoLite2-ASN-Test.ndjson") {
+		t.Errorf("Unexpected path %s", result.Path)
+	}
+	if result.Records != int64(expected) {
+		t.Errorf("Expected %d records, got %d", expected, result.Records)
+	}
+
+	file, err := os.Open(result.Path)
+	if err != nil {
+		t.Fatalf("Failed to open export: %v", err)
+	}
+	defer func() { _ = file.Close() }()
+	lines := 0
+	telstra := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var line struct {
+			Data    map[string]any `json:"data"`
+			Network string         `json:"network"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			t.Fatalf("Failed to decode line %d: %v", lines+1, err)
+		}
+		if line.Network == "1.128.0.0/11" && line.Data["autonomous_system_number"] == float64(1221) {
+			telstra = true
+		}
+		lines++
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("Failed to read export: %v", err)
+	}
+	if lines != expected {
+		t.Errorf("Expected %d lines, got %d", expected, lines)
+	}
+	if !telstra {
+		t.Error("Expected 1.128.0.0/11 with ASN 1221 in the export")
+	}
+
+	// Existing files are not overwritten.
+	structured = callTool(t, server.handleExportDatabase, "export_database", map[string]any{
+		"database":          asnDB,
+		"confirm_full_scan": true,
+	})
+	if code := errorCode(structured); code != "file_exists" {
+		t.Errorf("Expected file_exists error, got %v", structured)
+	}
+}
+
+func TestExportDatabaseErrors(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+	server.config.Export.Dir = t.TempDir()
+
+	tests := []struct {
+		args map[string]any
+		name string
+		code string
+	}{
+		{
+			name: "full scan not confirmed",
+			args: map[string]any{"database": "GeoLite2-City-Test.mmdb"},
+			code: "full_scan_not_confirmed",
+		},
+		{
+			name: "path outside the export directory",
+			args: map[string]any{
+				"database":          "GeoLite2-City-Test.mmdb",
+				"file":              "../city.ndjson",
+				"confirm_full_scan": true,
+			},
+			code: "invalid_parameter",
+		},
+		{
+			name: "unknown database",
+			args: map[string]any{"database": "missing.mmdb", "confirm_full_scan": true},
+			code: "db_not_found",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			structured := callTool(t, server.handleExportDatabase, "export_database", test.args)
+			if code := errorCode(structured); code != test.code {
+				t.Errorf("Expected %s error, got %v", test.code, structured)
+			}
+		})
+	}
+
+	// An export beyond the record limit fails and leaves no file.
+	server.config.Export.MaxRecords = 1
+	structured := callTool(t, server.handleExportDatabase, "export_database", map[string]any{
+		"database":          "GeoLite2-City-Test.mmdb",
+		"confirm_full_scan": true,
+	})
+	if code := errorCode(structured); code != "export_failed" {
+		t.Errorf("Expected export_failed error, got %v", structured)
+	}
+	entries, err := os.ReadDir(server.config.Export.Dir)
+	if err != nil {
+		t.Fatalf("Failed to read export directory: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected no files left behind, got %v", entries)
+	}
+}
+
+func TestExportDatabaseAllowedPaths(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+	server.config.Export.Dir = t.TempDir()
+	args := map[string]any{
+		"database":          "GeoLite2-City-Test.mmdb",
+		"confirm_full_scan": true,
+	}
+
+	// An export directory outside allowed_paths is rejected without
+	// creating a file.
+	server.config.AllowedPaths = []string{t.TempDir()}
+	structured := callTool(t, server.handleExportDatabase, "export_database", args)
+	if code := errorCode(structured); code != "path_not_allowed" {
+		t.Fatalf("Expected path_not_allowed error, got %v", structured)
+	}
+	entries, err := os.ReadDir(server.config.Export.Dir)
+	if err != nil {
+		t.Fatalf("Failed to read export directory: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected no files to be created, got %v", entries)
+	}
+
+	// Within allowed_paths, the export works.
+	server.config.AllowedPaths = []string{filepath.Dir(server.config.Export.Dir)}
+	structured = callTool(t, server.handleExportDatabase, "export_database", args)
+	if result, ok := structured.(exportResult); !ok || result.Records == 0 {
+		t.Errorf("Expected a successful export, got %v", structured)
+	}
+}
@@ -0,0 +1,110 @@
+package mcp
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/oschwald/maxminddb-golang/v2"
+)
+
+// defaultFieldSampleSize is the default number of records probed per
+// database by find_databases_with_field.
+const defaultFieldSampleSize = 100
+
+// databaseFieldResult reports whether a database contains a field.
+type databaseFieldResult struct {
+	Name           string `json:"name"`
+	Type           string `json:"type"`
+	ExampleNetwork string `json:"example_network,omitempty"`
+	Sampled        int    `json:"sampled"`
+	Found          bool   `json:"found"`
+}
+
+// handleFindDatabasesWithField handles the find_databases_with_field tool.
+// Each loaded database is probed by sampling its first records, so a field
+// that only appears in a few records may be missed.
+func (s *Server) handleFindDatabasesWithField(
+	_ context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	field, err := request.RequireString("field")
+	if err != nil || strings.TrimSpace(field) == "" {
+		//nolint:nilerr // MCP protocol expects error result, not Go error
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "missing_parameter",
+				"message": "Missing required parameter: field",
+			},
+		}), nil
+	}
+
+	sampleSize := request.GetInt("sample_size", defaultFieldSampleSize)
+	if sampleSize <= 0 {
+		sampleSize = defaultFieldSampleSize
+	}
+
+	path := fieldPath(field)
+	databases := s.dbManager.ListDatabases()
+	results := make([]databaseFieldResult, 0, len(databases))
+	matching := make([]string, 0, len(databases))
+
+	for _, dbInfo := range databases {
+		reader, exists := s.dbManager.GetReader(dbInfo.Name)
+		if !exists {
+			continue
+		}
+
+		result := probeField(reader, path, sampleSize)
+		result.Name = dbInfo.Name
+		result.Type = dbInfo.Type
+		if result.Found {
+			matching = append(matching, dbInfo.Name)
+		}
+		results = append(results, result)
+	}
+
+	return mcp.NewToolResultStructuredOnly(map[string]any{
+		"field":     field,
+		"matching":  matching,
+		"databases": results,
+	}), nil
+}
+
+// probeField samples up to sampleSize records of a database and reports the
+// first network whose record contains the path.
+func probeField(reader *maxminddb.Reader, path []any, sampleSize int) databaseFieldResult {
+	var result databaseFieldResult
+	for network := range reader.NetworksWithin(fullAddressSpace(reader)) {
+		if result.Sampled >= sampleSize {
+			break
+		}
+		result.Sampled++
+
+		var value any
+		if err := network.DecodePath(&value, path...); err != nil || value == nil {
+			continue
+		}
+		result.Found = true
+		result.ExampleNetwork = network.Prefix().String()
+		break
+	}
+	return result
+}
+
+// fieldPath splits a dotted field path into DecodePath arguments. Numeric
+// segments are treated as array indexes.
+func fieldPath(field string) []any {
+	parts := strings.Split(field, ".")
+	path := make([]any, len(parts))
+	for i, part := range parts {
+		if index, err := strconv.Atoi(part); err == nil {
+			path[i] = index
+		} else {
+			path[i] = part
+		}
+	}
+	return path
+}
@@ -0,0 +1,78 @@
+package mcp
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/oschwald/maxminddb-mcp/internal/database"
+	"github.com/oschwald/maxminddb-mcp/internal/iterator"
+)
+
+func TestFindDatabasesWithField(t *testing.T) {
+	dbManager, err := database.New()
+	if err != nil {
+		t.Fatalf("Failed to create database manager: %v", err)
+	}
+	defer func() { _ = dbManager.Close() }()
+
+	for _, path := range []string{
+		"../../testdata/test-data/GeoLite2-ASN-Test.mmdb",
+		"../../testdata/test-data/GeoLite2-Country-Test.mmdb",
+	} {
+		if err := dbManager.LoadDatabase(path); err != nil {
+			t.Fatalf("Failed to load test database: %v", err)
+		}
+	}
+
+	iterMgr := iterator.New(30*time.Minute, 5*time.Minute)
+	defer iterMgr.StopCleanup()
+
+	server := New(createTestMCPConfig(t), dbManager, nil, iterMgr, BuildInfo{})
+
+	tests := []struct {
+		field    string
+		expected []string
+	}{
+		{"autonomous_system_number", []string{"GeoLite2-ASN-Test.mmdb"}},
+		{"country.iso_code", []string{"GeoLite2-Country-Test.mmdb"}},
+		{"traits.user_type", []string{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.field, func(t *testing.T) {
+			structured := callTool(t, server.handleFindDatabasesWithField,
+				"find_databases_with_field", map[string]any{"field": test.field})
+			resultMap, ok := structured.(map[string]any)
+			if !ok {
+				t.Fatalf("Unexpected result: %v", structured)
+			}
+
+			matching, _ := resultMap["matching"].([]string)
+			if !reflect.DeepEqual(matching, test.expected) {
+				t.Errorf("Expected %v to contain %s, got %v", test.expected, test.field, matching)
+			}
+
+			results, _ := resultMap["databases"].([]databaseFieldResult)
+			if len(results) != 2 {
+				t.Fatalf("Expected 2 probed databases, got %d", len(results))
+			}
+			for _, result := range results {
+				if result.Found != slices.Contains(test.expected, result.Name) {
+					t.Errorf("Unexpected found=%v for %s", result.Found, result.Name)
+				}
+				if result.Found && result.ExampleNetwork == "" {
+					t.Errorf("Expected example network for %s", result.Name)
+				}
+			}
+		})
+	}
+}
+
+func TestFieldPath(t *testing.T) {
+	expected := []any{"subdivisions", 0, "iso_code"}
+	if got := fieldPath("subdivisions.0.iso_code"); !reflect.DeepEqual(got, expected) {
+		t.Errorf("fieldPath() = %v, expected %v", got, expected)
+	}
+}
@@ -0,0 +1,94 @@
+package mcp
+
+import (
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/oschwald/maxminddb-mcp/internal/database"
+
+	"github.com/oschwald/maxminddb-golang/v2"
+)
+
+// lookupIPFirstMatch looks up the IP in the given databases in precedence
+// order and returns the first record found, with the database it came from.
+// If no database has a record, database and data are null.
+func (s *Server) lookupIPFirstMatch(
+	ip netip.Addr,
+	ipStr string,
+	names []string,
+	opts lookupOptions,
+) *mcp.CallToolResult {
+	for _, name := range s.precedenceOrder(names) {
+		var record map[string]any
+		var found bool
+		err := s.dbManager.WithReader(name, func(reader *maxminddb.Reader) error {
+			result := reader.Lookup(ip)
+			found = result.Found()
+			var err error
+			record, err = opts.decode(result)
+			return err
+		})
+		if err != nil || !found {
+			continue // Skip databases that are gone, can't be decoded, or miss the IP
+		}
+
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"ip":       ipStr,
+			"database": name,
+			"data":     s.lookupOptionsFor(name, opts).apply(record),
+		})
+	}
+
+	return mcp.NewToolResultStructuredOnly(map[string]any{
+		"ip":       ipStr,
+		"database": nil,
+		"data":     nil,
+	})
+}
+
+// precedenceOrder orders names by the database_precedence setting. Each
+// entry is a database name or a "type:<type>" selector matching every
+// database of that type, in name order. Databases no entry matches follow
+// in their original order.
+func (s *Server) precedenceOrder(names []string) []string {
+	if len(s.config.DatabasePrecedence) == 0 {
+		return names
+	}
+
+	databases := make(map[string]*database.Info)
+	for _, db := range s.dbManager.ListDatabases() {
+		databases[db.Name] = db
+	}
+
+	ordered := make([]string, 0, len(names))
+	placed := make(map[string]bool, len(names))
+	for _, entry := range s.config.DatabasePrecedence {
+		dbType, isType := strings.CutPrefix(entry, database.TypeSelectorPrefix)
+		matches := make([]string, 0, 1)
+		for _, name := range names {
+			switch {
+			case placed[name]:
+			case isType:
+				if db, ok := databases[name]; ok && db.MatchesType(dbType) {
+					matches = append(matches, name)
+				}
+			case name == entry:
+				matches = append(matches, name)
+			}
+		}
+		slices.Sort(matches)
+		for _, name := range matches {
+			placed[name] = true
+			ordered = append(ordered, name)
+		}
+	}
+
+	for _, name := range names {
+		if !placed[name] {
+			ordered = append(ordered, name)
+		}
+	}
+	return ordered
+}
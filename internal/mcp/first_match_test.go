@@ -0,0 +1,84 @@
+package mcp
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestLookupIPFirstMatch(t *testing.T) {
+	server := newTestServerWithASNDB(t)
+	if err := server.dbManager.LoadDatabase("../../testdata/test-data/GeoIP2-City-Test.mmdb"); err != nil {
+		t.Fatalf("Failed to load test database: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		ip         string
+		database   any
+		precedence []string
+	}{
+		{
+			name:     "name order without precedence",
+			ip:       "81.2.69.160",
+			database: "GeoIP2-City-Test.mmdb",
+		},
+		{
+			name:       "precedence by name",
+			ip:         "81.2.69.160",
+			precedence: []string{"GeoLite2-City-Test.mmdb"},
+			database:   "GeoLite2-City-Test.mmdb",
+		},
+		{
+			name:       "databases without a record are skipped",
+			ip:         "81.2.69.160",
+			precedence: []string{"type:ASN", "GeoLite2-City-Test.mmdb", "GeoIP2-City-Test.mmdb"},
+			database:   "GeoLite2-City-Test.mmdb",
+		},
+		{
+			name:       "precedence by type",
+			ip:         "1.128.0.0",
+			precedence: []string{"type:City", "type:ASN"},
+			database:   "GeoLite2-ASN-Test.mmdb",
+		},
+		{
+			name:     "no database has a record",
+			ip:       "10.0.0.1",
+			database: nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server.config.DatabasePrecedence = test.precedence
+			structured := callTool(t, server.handleLookupIP, "lookup_ip", map[string]any{
+				"ip":          test.ip,
+				"first_match": true,
+			})
+			result, ok := structured.(map[string]any)
+			if !ok {
+				t.Fatalf("Unexpected result: %v", structured)
+			}
+			if result["database"] != test.database {
+				t.Errorf("Expected the record of %v, got %v", test.database, result["database"])
+			}
+			if data, _ := result["data"].(map[string]any); (data != nil) != (test.database != nil) {
+				t.Errorf("Expected data only with a database, got %v", result["data"])
+			}
+		})
+	}
+}
+
+func TestPrecedenceOrder(t *testing.T) {
+	server := newTestServerWithASNDB(t)
+	server.config.DatabasePrecedence = []string{"missing.mmdb", "type:City"}
+
+	names := server.databaseNames()
+	ordered := server.precedenceOrder(names)
+	expected := []string{"GeoLite2-City-Test.mmdb", "GeoLite2-ASN-Test.mmdb"}
+	if !slices.Equal(ordered, expected) {
+		t.Errorf("Expected %v, got %v", expected, ordered)
+	}
+	if !slices.Equal(names, []string{"GeoLite2-ASN-Test.mmdb", "GeoLite2-City-Test.mmdb"}) {
+		t.Errorf("Expected the names to be left unchanged, got %v", names)
+	}
+}
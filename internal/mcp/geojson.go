@@ -0,0 +1,86 @@
+package mcp
+
+import (
+	"strings"
+
+	"github.com/oschwald/maxminddb-mcp/internal/iterator"
+)
+
+// Output formats for lookup_network.
+const (
+	formatJSON    = "json"
+	formatGeoJSON = "geojson"
+)
+
+// geoJSONProperties lists the record fields copied into each feature's
+// properties, keyed by their dotted path.
+var geoJSONProperties = []string{
+	"city.names.en",
+	"country.iso_code",
+	"location.accuracy_radius",
+	"location.time_zone",
+}
+
+// toGeoJSON renders an iteration batch as a GeoJSON FeatureCollection of
+// Point features. Records without coordinates are skipped. The pagination
+// fields are kept as foreign members so the scan can be continued.
+func toGeoJSON(result *iterator.IterationResult) map[string]any {
+	features := make([]map[string]any, 0, len(result.Results))
+	skipped := 0
+
+	for _, res := range result.Results {
+		location, _ := res.Data["location"].(map[string]any)
+		latitude, hasLatitude := location["latitude"].(float64)
+		longitude, hasLongitude := location["longitude"].(float64)
+		if !hasLatitude || !hasLongitude {
+			skipped++
+			continue
+		}
+
+		properties := map[string]any{
+			"network": res.Network.String(),
+		}
+		for _, path := range geoJSONProperties {
+			if value, ok := lookupPath(res.Data, path); ok {
+				properties[path] = value
+			}
+		}
+
+		features = append(features, map[string]any{
+			"type": "Feature",
+			"geometry": map[string]any{
+				"type":        "Point",
+				"coordinates": []float64{longitude, latitude},
+			},
+			"properties": properties,
+		})
+	}
+
+	return map[string]any{
+		"type":                     "FeatureCollection",
+		"features":                 features,
+		"skipped_without_location": skipped,
+		"iterator_id":              result.IteratorID,
+		"resume_token":             result.ResumeToken,
+		"resume_source":            result.ResumeSource,
+		"has_more":                 result.HasMore,
+		"total_processed":          result.TotalProcessed,
+		"total_matched":            result.TotalMatched,
+	}
+}
+
+// lookupPath returns the value at a dotted path in a decoded record.
+func lookupPath(record map[string]any, path string) (any, bool) {
+	var current any = record
+	for part := range strings.SplitSeq(path, ".") {
+		m, ok := current.(map[string]any)
+		if !ok {
+			return nil, false
+		}
+		current, ok = m[part]
+		if !ok {
+			return nil, false
+		}
+	}
+	return current, true
+}
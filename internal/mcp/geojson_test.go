@@ -0,0 +1,107 @@
+package mcp
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/oschwald/maxminddb-mcp/internal/iterator"
+)
+
+func TestLookupNetworkGeoJSON(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	structured := callTool(t, server.handleLookupNetwork, "lookup_network", map[string]any{
+		"network":  "81.2.69.0/24",
+		"database": "GeoLite2-City-Test.mmdb",
+		"format":   "geojson",
+	})
+	collection, ok := structured.(map[string]any)
+	if !ok {
+		t.Fatalf("Expected map result, got %T", structured)
+	}
+	if collection["type"] != "FeatureCollection" {
+		t.Fatalf("Expected FeatureCollection, got %v", collection)
+	}
+	if _, ok := collection["iterator_id"].(string); !ok {
+		t.Error("Expected iterator_id to be kept for pagination")
+	}
+
+	features, _ := collection["features"].([]map[string]any)
+	if len(features) == 0 {
+		t.Fatal("Expected features for London networks")
+	}
+
+	scanned := netip.MustParsePrefix("81.2.69.0/24")
+	for _, feature := range features {
+		if feature["type"] != "Feature" {
+			t.Errorf("Expected Feature, got %v", feature["type"])
+		}
+
+		geometry, _ := feature["geometry"].(map[string]any)
+		if geometry["type"] != "Point" {
+			t.Errorf("Expected Point geometry, got %v", geometry["type"])
+		}
+		coordinates, _ := geometry["coordinates"].([]float64)
+		if len(coordinates) != 2 {
+			t.Fatalf("Expected [longitude, latitude], got %v", geometry["coordinates"])
+		}
+		if coordinates[0] < -180 || coordinates[0] > 180 ||
+			coordinates[1] < -90 || coordinates[1] > 90 {
+			t.Errorf("Coordinates out of range: %v", coordinates)
+		}
+
+		properties, _ := feature["properties"].(map[string]any)
+		networkStr, _ := properties["network"].(string)
+		network, err := netip.ParsePrefix(networkStr)
+		if err != nil || !scanned.Overlaps(network) {
+			t.Errorf("Expected network within %s, got %q", scanned, networkStr)
+		}
+		if properties["country.iso_code"] != "GB" {
+			t.Errorf("Expected country.iso_code GB, got %v", properties["country.iso_code"])
+		}
+	}
+}
+
+func TestLookupNetworkInvalidFormat(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	structured := callTool(t, server.handleLookupNetwork, "lookup_network", map[string]any{
+		"network":  "81.2.69.0/24",
+		"database": "GeoLite2-City-Test.mmdb",
+		"format":   "csv",
+	})
+	if code := errorCode(structured); code != "invalid_format" {
+		t.Errorf("Expected invalid_format, got %v", structured)
+	}
+}
+
+func TestToGeoJSONSkipsRecordsWithoutLocation(t *testing.T) {
+	result := &iterator.IterationResult{
+		Results: []iterator.NetworkResult{
+			{
+				Network: netip.MustParsePrefix("192.0.2.0/24"),
+				Data: map[string]any{
+					"location": map[string]any{"latitude": 51.5, "longitude": -0.1},
+				},
+			},
+			{
+				Network: netip.MustParsePrefix("198.51.100.0/24"),
+				Data:    map[string]any{"country": map[string]any{"iso_code": "US"}},
+			},
+		},
+	}
+
+	collection := toGeoJSON(result)
+
+	features, _ := collection["features"].([]map[string]any)
+	if len(features) != 1 {
+		t.Fatalf("Expected 1 feature, got %d", len(features))
+	}
+	if collection["skipped_without_location"] != 1 {
+		t.Errorf("Expected 1 skipped record, got %v", collection["skipped_without_location"])
+	}
+	coordinates, _ := features[0]["geometry"].(map[string]any)["coordinates"].([]float64)
+	if len(coordinates) != 2 || coordinates[0] != -0.1 || coordinates[1] != 51.5 {
+		t.Errorf("Expected [-0.1, 51.5], got %v", coordinates)
+	}
+}
@@ -0,0 +1,27 @@
+package mcp
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/oschwald/maxminddb-mcp/internal/database"
+)
+
+// handleGetHealth handles the get_health tool.
+func (s *Server) handleGetHealth(
+	_ context.Context,
+	_ mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	watcher := s.dbManager.WatcherHealth()
+
+	status := "ok"
+	if watcher.Status == database.WatcherDegraded || watcher.Status == database.WatcherStopped {
+		status = "degraded"
+	}
+
+	return mcp.NewToolResultStructuredOnly(map[string]any{
+		"status":           status,
+		"databases_loaded": len(s.dbManager.ListDatabases()),
+		"watcher":          watcher,
+	}), nil
+}
@@ -0,0 +1,34 @@
+package mcp
+
+import (
+	"testing"
+
+	"github.com/oschwald/maxminddb-mcp/internal/database"
+)
+
+func TestGetHealth(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	structured := callTool(t, server.handleGetHealth, "get_health", nil)
+	resultMap, ok := structured.(map[string]any)
+	if !ok {
+		t.Fatalf("Unexpected result: %v", structured)
+	}
+	if resultMap["status"] != "ok" {
+		t.Errorf("Expected status ok, got %v", resultMap["status"])
+	}
+	if resultMap["databases_loaded"] != 1 {
+		t.Errorf("Expected 1 database loaded, got %v", resultMap["databases_loaded"])
+	}
+	watcher, _ := resultMap["watcher"].(database.WatcherHealth)
+	if watcher.Status != database.WatcherNotStarted {
+		t.Errorf("Expected watcher %s, got %v", database.WatcherNotStarted, watcher.Status)
+	}
+
+	// A closed manager reports a stopped watcher.
+	_ = server.dbManager.Close()
+	structured = callTool(t, server.handleGetHealth, "get_health", nil)
+	if status := structured.(map[string]any)["status"]; status != "degraded" {
+		t.Errorf("Expected degraded status after watcher stopped, got %v", status)
+	}
+}
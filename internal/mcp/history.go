@@ -0,0 +1,158 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/oschwald/maxminddb-mcp/internal/database"
+
+	"github.com/oschwald/maxminddb-golang/v2"
+)
+
+// historyVersion is the record of an IP in one version of a database.
+type historyVersion struct {
+	BuildDate time.Time      `json:"build_date"`
+	Data      map[string]any `json:"data"`
+	File      string         `json:"file"`
+	Network   string         `json:"network,omitempty"`
+	Error     string         `json:"error,omitempty"`
+	Found     bool           `json:"found"`
+	Current   bool           `json:"current"`
+}
+
+// handleLookupIPHistory handles the lookup_ip_history tool. It looks up an
+// IP in the loaded version of a database and in each older version retained
+// next to it, such as backups and dated copies, and returns the records in
+// chronological order of their build dates.
+func (s *Server) handleLookupIPHistory(
+	_ context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	ipStr, err := request.RequireString("ip")
+	if err != nil {
+		//nolint:nilerr // MCP protocol expects error result, not Go error
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "missing_parameter",
+				"message": "Missing required parameter: ip",
+			},
+		}), nil
+	}
+
+	ip, err := netip.ParseAddr(ipStr)
+	if err != nil {
+		//nolint:nilerr // MCP protocol expects error result, not Go error
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "invalid_ip",
+				"message": "Invalid IP address: " + ipStr,
+			},
+		}), nil
+	}
+
+	edition, err := request.RequireString("edition")
+	if err != nil {
+		//nolint:nilerr // MCP protocol expects error result, not Go error
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "missing_parameter",
+				"message": "Missing required parameter: edition",
+			},
+		}), nil
+	}
+
+	// Edition IDs such as GeoLite2-City name the database file without its
+	// extension.
+	dbName := edition
+	if _, exists := s.dbManager.GetDatabase(edition + ".mmdb"); exists {
+		dbName = edition + ".mmdb"
+	}
+	dbName, errResult := s.resolveDatabase(dbName)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	info, exists := s.dbManager.GetDatabase(dbName)
+	if !exists {
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "db_not_found",
+				"message": "Database not found: " + dbName,
+			},
+		}), nil
+	}
+
+	paths, err := database.RetainedVersions(info.Path)
+	if err != nil {
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "history_unavailable",
+				"message": fmt.Sprintf("Failed to list retained versions of %s: %v", dbName, err),
+			},
+		}), nil
+	}
+
+	versions := make([]historyVersion, 0, len(paths)+1)
+	for _, path := range paths {
+		versions = append(versions, lookupRetainedVersion(ip, path))
+	}
+
+	current := historyVersion{File: filepath.Base(info.Path), Current: true}
+	err = s.dbManager.WithReader(dbName, func(reader *maxminddb.Reader) error {
+		lookupVersion(ip, reader, &current)
+		return nil
+	})
+	if err != nil {
+		current.Error = err.Error()
+	}
+	versions = append(versions, current)
+
+	// Versions with the same build date keep their name order, with the
+	// loaded version last.
+	slices.SortStableFunc(versions, func(a, b historyVersion) int {
+		return a.BuildDate.Compare(b.BuildDate)
+	})
+
+	return mcp.NewToolResultStructuredOnly(map[string]any{
+		"ip":       ipStr,
+		"database": dbName,
+		"versions": versions,
+	}), nil
+}
+
+// lookupRetainedVersion looks up an IP in the retained database version at
+// path. Versions that cannot be opened are returned with an error.
+func lookupRetainedVersion(ip netip.Addr, path string) historyVersion {
+	version := historyVersion{File: filepath.Base(path)}
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		version.Error = err.Error()
+		return version
+	}
+	defer func() { _ = reader.Close() }()
+
+	lookupVersion(ip, reader, &version)
+	return version
+}
+
+// lookupVersion fills in the build date of a database version and the record
+// of an IP in it.
+func lookupVersion(ip netip.Addr, reader *maxminddb.Reader, version *historyVersion) {
+	//nolint:gosec // Build epochs are Unix timestamps that fit in int64
+	version.BuildDate = time.Unix(int64(reader.Metadata.BuildEpoch), 0).UTC()
+
+	result := reader.Lookup(ip)
+	if err := result.Decode(&version.Data); err != nil {
+		version.Error = err.Error()
+		return
+	}
+	version.Found = result.Found()
+	if version.Found {
+		version.Network = result.Prefix().String()
+	}
+}
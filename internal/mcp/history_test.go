@@ -0,0 +1,100 @@
+package mcp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/oschwald/maxminddb-mcp/internal/database"
+	"github.com/oschwald/maxminddb-mcp/internal/iterator"
+)
+
+func TestLookupIPHistory(t *testing.T) {
+	// The loaded GeoLite2-City database has two retained versions: a backup
+	// holding the Country test database and a corrupt dated copy.
+	dir := t.TempDir()
+	copyTestDB := func(name, target string) {
+		t.Helper()
+		data, err := os.ReadFile("../../testdata/test-data/" + name)
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", name, err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, target), data, 0o600); err != nil {
+			t.Fatalf("Failed to write %s: %v", target, err)
+		}
+	}
+	copyTestDB("GeoLite2-City-Test.mmdb", "GeoLite2-City.mmdb")
+	copyTestDB("GeoLite2-Country-Test.mmdb", "GeoLite2-City.mmdb.bak")
+	if err := os.WriteFile(filepath.Join(dir, "GeoLite2-City_20240102.mmdb"), []byte("corrupt"), 0o600); err != nil {
+		t.Fatalf("Failed to write dated copy: %v", err)
+	}
+
+	dbManager, err := database.New()
+	if err != nil {
+		t.Fatalf("Failed to create database manager: %v", err)
+	}
+	t.Cleanup(func() { _ = dbManager.Close() })
+	if err := dbManager.LoadDatabase(filepath.Join(dir, "GeoLite2-City.mmdb")); err != nil {
+		t.Fatalf("Failed to load database: %v", err)
+	}
+	iterMgr := iterator.New(30*time.Minute, 5*time.Minute)
+	t.Cleanup(iterMgr.StopCleanup)
+	server := New(createTestMCPConfig(t), dbManager, nil, iterMgr, BuildInfo{})
+
+	structured := callTool(t, server.handleLookupIPHistory, "lookup_ip_history", map[string]any{
+		"ip":      "81.2.69.142",
+		"edition": "GeoLite2-City",
+	})
+	result, ok := structured.(map[string]any)
+	if !ok {
+		t.Fatalf("Expected history result, got %v", structured)
+	}
+	if result["database"] != "GeoLite2-City.mmdb" {
+		t.Errorf("Expected GeoLite2-City.mmdb, got %v", result["database"])
+	}
+	versions, ok := result["versions"].([]historyVersion)
+	if !ok || len(versions) != 3 {
+		t.Fatalf("Expected 3 versions, got %v", result["versions"])
+	}
+
+	byFile := make(map[string]historyVersion)
+	for i, version := range versions {
+		byFile[version.File] = version
+		if i > 0 && version.BuildDate.Before(versions[i-1].BuildDate) {
+			t.Errorf("Expected versions in chronological order, got %v", versions)
+		}
+	}
+
+	current := byFile["GeoLite2-City.mmdb"]
+	if !current.Current || !current.Found {
+		t.Errorf("Expected the loaded version to be current and to have the IP, got %+v", current)
+	}
+	if city, _ := lookupPath(current.Data, "city.names.en"); city != "London" {
+		t.Errorf("Expected London in the current version, got %v", current.Data)
+	}
+
+	// The versions differ: the backup only has country data.
+	backup := byFile["GeoLite2-City.mmdb.bak"]
+	if backup.Current || !backup.Found {
+		t.Errorf("Expected the backup to have the IP, got %+v", backup)
+	}
+	if country, _ := lookupPath(backup.Data, "country.iso_code"); country != "GB" {
+		t.Errorf("Expected GB in the backup, got %v", backup.Data)
+	}
+	if _, hasCity := backup.Data["city"]; hasCity {
+		t.Errorf("Expected no city in the backup, got %v", backup.Data)
+	}
+
+	if corrupt := byFile["GeoLite2-City_20240102.mmdb"]; corrupt.Error == "" {
+		t.Errorf("Expected an error for the corrupt dated copy, got %+v", corrupt)
+	}
+
+	structured = callTool(t, server.handleLookupIPHistory, "lookup_ip_history", map[string]any{
+		"ip":      "81.2.69.142",
+		"edition": "GeoIP2-City",
+	})
+	if code := errorCode(structured); code != "db_not_found" {
+		t.Errorf("Expected db_not_found error, got %v", structured)
+	}
+}
@@ -0,0 +1,62 @@
+package mcp
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// databaseLanguage is a language a database has localized names for.
+type databaseLanguage struct {
+	Code        string `json:"code"`
+	Description string `json:"description,omitempty"`
+}
+
+// handleDatabaseLanguages handles the database_languages tool. It returns
+// the languages in a database's metadata, which are the valid values of the
+// language parameter for that database, with the database description in
+// each language when the metadata has one.
+func (s *Server) handleDatabaseLanguages(
+	_ context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	dbName, err := request.RequireString("database")
+	if err != nil {
+		//nolint:nilerr // MCP protocol expects error result, not Go error
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "missing_parameter",
+				"message": "Missing required parameter: database",
+			},
+		}), nil
+	}
+
+	dbName, errResult := s.resolveDatabase(dbName)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	reader, exists := s.dbManager.GetReader(dbName)
+	if !exists {
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "db_not_found",
+				"message": "Database not found: " + dbName,
+			},
+		}), nil
+	}
+
+	metadata := reader.Metadata
+	languages := make([]databaseLanguage, 0, len(metadata.Languages))
+	for _, code := range metadata.Languages {
+		languages = append(languages, databaseLanguage{
+			Code:        code,
+			Description: metadata.Description[code],
+		})
+	}
+
+	return mcp.NewToolResultStructuredOnly(map[string]any{
+		"database":  dbName,
+		"languages": languages,
+	}), nil
+}
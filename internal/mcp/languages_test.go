@@ -0,0 +1,61 @@
+package mcp
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDatabaseLanguages(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	structured := callTool(t, server.handleDatabaseLanguages, "database_languages", map[string]any{
+		"database": "type:City",
+	})
+	result, ok := structured.(map[string]any)
+	if !ok {
+		t.Fatalf("Unexpected result: %v", structured)
+	}
+	if result["database"] != "GeoLite2-City-Test.mmdb" {
+		t.Errorf("Expected the City test database, got %v", result["database"])
+	}
+
+	languages, _ := result["languages"].([]databaseLanguage)
+	codes := make([]string, 0, len(languages))
+	for _, language := range languages {
+		codes = append(codes, language.Code)
+	}
+	expected := []string{"de", "en", "es", "fr", "ja", "pt-BR", "ru", "zh-CN"}
+	if !slices.Equal(codes, expected) {
+		t.Errorf("Expected languages %v, got %v", expected, codes)
+	}
+
+	// Only languages with a description in the metadata have one.
+	for _, language := range languages {
+		hasDescription := language.Description != ""
+		if hasDescription != (language.Code == "en") {
+			t.Errorf("Unexpected description for %s: %q", language.Code, language.Description)
+		}
+	}
+}
+
+func TestDatabaseLanguagesErrors(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	tests := []struct {
+		args map[string]any
+		name string
+		code string
+	}{
+		{name: "missing database", args: map[string]any{}, code: "missing_parameter"},
+		{name: "unknown database", args: map[string]any{"database": "missing.mmdb"}, code: "db_not_found"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			structured := callTool(t, server.handleDatabaseLanguages, "database_languages", test.args)
+			if code := errorCode(structured); code != test.code {
+				t.Errorf("Expected %s, got %v", test.code, structured)
+			}
+		})
+	}
+}
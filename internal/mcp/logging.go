@@ -0,0 +1,159 @@
+package mcp
+
+import (
+	"context"
+	"log/slog"
+	"net/netip"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// Prefix lengths used when anonymizing logged IP addresses.
+const (
+	anonymizedIPv4Bits = 24
+	anonymizedIPv6Bits = 48
+)
+
+// logToolCall is a tool handler middleware that logs each tool invocation
+// with its arguments and duration. Calls taking longer than
+// slow_query_threshold are also logged as warnings.
+func (s *Server) logToolCall(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		start := time.Now()
+		result, err := next(ctx, request)
+		duration := time.Since(start)
+		args := s.loggableArguments(request.GetArguments())
+
+		slog.Debug("Tool call",
+			"tool", request.Params.Name,
+			"args", args,
+			"duration", duration,
+			"err", err,
+		)
+
+		if threshold := s.config.SlowQueryThresholdDuration; threshold > 0 && duration > threshold {
+			filters, _ := args["filters"].([]any)
+			slog.Warn("Slow tool call",
+				"tool", request.Params.Name,
+				"database", args["database"],
+				"network", args["network"],
+				"filters", len(filters),
+				"args", args,
+				"duration", duration,
+				"threshold", threshold,
+			)
+		}
+
+		return result, err
+	}
+}
+
+// loggableArguments returns a copy of the tool arguments suitable for
+// logging. When IP anonymization is enabled, every value that is an IP
+// address, a network, or an address range is masked, wherever it appears in
+// the arguments, e.g. the networks of classify_networks or the nested specs
+// of network_set_op. The original arguments are left untouched so that
+// lookups still use the full-precision address.
+func (s *Server) loggableArguments(args map[string]any) map[string]any {
+	if !s.config.AnonymizeLogIPs || len(args) == 0 {
+		return args
+	}
+
+	logged, _ := anonymizeValue(args).(map[string]any)
+	return logged
+}
+
+// anonymizeValue returns a copy of value with the addresses in its strings
+// masked, recursing into arrays and objects.
+func anonymizeValue(value any) any {
+	switch v := value.(type) {
+	case string:
+		return anonymizeString(v)
+	case []any:
+		masked := make([]any, len(v))
+		for i, elem := range v {
+			masked[i] = anonymizeValue(elem)
+		}
+		return masked
+	case map[string]any:
+		masked := make(map[string]any, len(v))
+		for key, elem := range v {
+			masked[key] = anonymizeValue(elem)
+		}
+		return masked
+	default:
+		return value
+	}
+}
+
+// anonymizeString masks s if it is an IP address, a CIDR network, or a
+// start-end address range. Other strings are returned unchanged.
+func anonymizeString(s string) string {
+	if _, err := netip.ParseAddr(s); err == nil {
+		return anonymizeIP(s)
+	}
+	if _, err := netip.ParsePrefix(s); err == nil {
+		return anonymizeNetwork(s)
+	}
+	startStr, endStr, ok := strings.Cut(s, "-")
+	if !ok {
+		return s
+	}
+	startStr, endStr = strings.TrimSpace(startStr), strings.TrimSpace(endStr)
+	if _, err := netip.ParseAddr(startStr); err != nil {
+		return s
+	}
+	if _, err := netip.ParseAddr(endStr); err != nil {
+		return s
+	}
+	return anonymizeIP(startStr) + "-" + anonymizeIP(endStr)
+}
+
+// anonymizeIP zeroes the host bits of an IP address, keeping the first 24
+// bits of an IPv4 address or the first 48 bits of an IPv6 address. Values
+// that are not valid IP addresses are returned unchanged.
+func anonymizeIP(ipStr string) string {
+	ip, err := netip.ParseAddr(ipStr)
+	if err != nil {
+		return ipStr
+	}
+	ip = ip.Unmap()
+
+	bits := anonymizedIPv6Bits
+	if ip.Is4() {
+		bits = anonymizedIPv4Bits
+	}
+
+	prefix, err := ip.Prefix(bits)
+	if err != nil {
+		return ipStr
+	}
+	return prefix.Addr().String()
+}
+
+// anonymizeNetwork masks a CIDR network that is more specific than the
+// anonymization prefix length. Values that are not valid networks are
+// returned unchanged.
+func anonymizeNetwork(networkStr string) string {
+	network, err := netip.ParsePrefix(networkStr)
+	if err != nil {
+		return networkStr
+	}
+
+	bits := anonymizedIPv6Bits
+	if network.Addr().Is4() {
+		bits = anonymizedIPv4Bits
+	}
+	if network.Bits() <= bits {
+		return networkStr
+	}
+
+	masked, err := network.Addr().Prefix(bits)
+	if err != nil {
+		return networkStr
+	}
+	return masked.String()
+}
@@ -0,0 +1,245 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/oschwald/maxminddb-mcp/internal/database"
+	"github.com/oschwald/maxminddb-mcp/internal/iterator"
+)
+
+func TestAnonymizeIP(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"81.2.69.142", "81.2.69.0"},
+		{"2001:db8:1234:5678::1", "2001:db8:1234::"},
+		{"::ffff:81.2.69.142", "81.2.69.0"},
+		{"not-an-ip", "not-an-ip"},
+	}
+
+	for _, test := range tests {
+		if got := anonymizeIP(test.input); got != test.expected {
+			t.Errorf("anonymizeIP(%q) = %q, expected %q", test.input, got, test.expected)
+		}
+	}
+}
+
+func TestAnonymizeNetwork(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"81.2.69.128/25", "81.2.69.0/24"},
+		{"81.2.0.0/16", "81.2.0.0/16"},
+		{"2001:db8:1234:5678::/64", "2001:db8:1234::/48"},
+		{"invalid", "invalid"},
+	}
+
+	for _, test := range tests {
+		if got := anonymizeNetwork(test.input); got != test.expected {
+			t.Errorf("anonymizeNetwork(%q) = %q, expected %q", test.input, got, test.expected)
+		}
+	}
+}
+
+func TestLoggableArgumentsMasksNestedAddresses(t *testing.T) {
+	cfg := createTestMCPConfig(t)
+	cfg.AnonymizeLogIPs = true
+	server := &Server{config: cfg}
+
+	args := map[string]any{
+		"networks": []any{"81.2.69.142/32", "2001:db8:1234:5678::1"},
+		"a": map[string]any{
+			"network":  "81.2.69.128/25",
+			"database": "GeoLite2-City-Test.mmdb",
+		},
+		"range":    "81.2.69.142-81.2.69.160",
+		"database": "GeoLite2-City-Test.mmdb",
+		"limit":    10,
+	}
+
+	logged := server.loggableArguments(args)
+
+	networks, _ := logged["networks"].([]any)
+	if len(networks) != 2 || networks[0] != "81.2.69.0/24" || networks[1] != "2001:db8:1234::" {
+		t.Errorf("Expected masked networks, got %v", logged["networks"])
+	}
+	a, _ := logged["a"].(map[string]any)
+	if a["network"] != "81.2.69.0/24" {
+		t.Errorf("Expected masked a.network, got %v", a["network"])
+	}
+	if a["database"] != "GeoLite2-City-Test.mmdb" {
+		t.Errorf("Expected a.database to be kept, got %v", a["database"])
+	}
+	if logged["range"] != "81.2.69.0-81.2.69.0" {
+		t.Errorf("Expected masked range, got %v", logged["range"])
+	}
+	if logged["database"] != "GeoLite2-City-Test.mmdb" || logged["limit"] != 10 {
+		t.Errorf("Expected other arguments to be kept, got %v", logged)
+	}
+
+	// The original arguments are not modified.
+	if args["networks"].([]any)[0] != "81.2.69.142/32" || args["a"].(map[string]any)["network"] != "81.2.69.128/25" {
+		t.Errorf("Arguments should not be modified, got %v", args)
+	}
+}
+
+func TestLogToolCallAnonymizesIP(t *testing.T) {
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})))
+	defer slog.SetDefault(previous)
+
+	cfg := createTestMCPConfig(t)
+	cfg.AnonymizeLogIPs = true
+
+	dbManager, err := database.New()
+	if err != nil {
+		t.Fatalf("Failed to create database manager: %v", err)
+	}
+	defer func() { _ = dbManager.Close() }()
+
+	if err := dbManager.LoadDatabase(testCityDB); err != nil {
+		t.Fatalf("Failed to load test database: %v", err)
+	}
+
+	iterMgr := iterator.New(30*time.Minute, 5*time.Minute)
+	defer iterMgr.StopCleanup()
+
+	server := New(cfg, dbManager, nil, iterMgr, BuildInfo{})
+
+	request := mcp.CallToolRequest{}
+	request.Params.Name = "lookup_ip"
+	request.Params.Arguments = map[string]any{
+		"ip":       "81.2.69.142",
+		"database": "GeoLite2-City-Test.mmdb",
+	}
+
+	result, err := server.logToolCall(server.handleLookupIP)(context.Background(), request)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The lookup must use the full-precision address. 81.2.69.0 is not in
+	// the test database, so a city is only returned for the real IP.
+	structured, ok := result.StructuredContent.(map[string]any)
+	if !ok {
+		t.Fatalf("Expected structured content, got %T", result.StructuredContent)
+	}
+	if structured["ip"] != "81.2.69.142" {
+		t.Errorf("Expected result for 81.2.69.142, got %v", structured["ip"])
+	}
+	data, _ := structured["data"].(map[string]any)
+	if _, hasCity := data["city"]; !hasCity {
+		t.Errorf("Expected lookup of the real IP to return a city, got %v", data)
+	}
+
+	// The request arguments must not have been modified.
+	if request.GetArguments()["ip"] != "81.2.69.142" {
+		t.Error("Request arguments should not be modified by logging")
+	}
+
+	logged := logs.String()
+	if !strings.Contains(logged, `"ip":"81.2.69.0"`) {
+		t.Errorf("Expected masked IP in log output, got: %s", logged)
+	}
+	if strings.Contains(logged, "81.2.69.142") {
+		t.Errorf("Log output should not contain the full IP, got: %s", logged)
+	}
+}
+
+func TestLogToolCallWithoutAnonymization(t *testing.T) {
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+	})))
+	defer slog.SetDefault(previous)
+
+	cfg := createTestMCPConfig(t)
+	server := &Server{config: cfg}
+
+	request := mcp.CallToolRequest{}
+	request.Params.Name = "lookup_ip"
+	request.Params.Arguments = map[string]any{"ip": "81.2.69.142"}
+
+	handler := server.logToolCall(
+		func(_ context.Context, _ mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return mcp.NewToolResultStructuredOnly(map[string]any{}), nil
+		},
+	)
+	if _, err := handler(context.Background(), request); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !strings.Contains(logs.String(), `"ip":"81.2.69.142"`) {
+		t.Errorf("Expected full IP in log output, got: %s", logs.String())
+	}
+}
+
+func TestLogToolCallSlowQuery(t *testing.T) {
+	var logs bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{
+		Level: slog.LevelWarn,
+	})))
+	defer slog.SetDefault(previous)
+
+	cfg := createTestMCPConfig(t)
+	cfg.SlowQueryThresholdDuration = time.Nanosecond
+	server := &Server{config: cfg}
+
+	request := mcp.CallToolRequest{}
+	request.Params.Name = "lookup_network"
+	request.Params.Arguments = map[string]any{
+		"database": "GeoLite2-City-Test.mmdb",
+		"network":  "81.2.69.0/24",
+		"filters": []any{
+			map[string]any{"field": "country.iso_code", "operator": "equals", "value": "GB"},
+			map[string]any{"field": "city.names.en", "operator": "equals", "value": "London"},
+		},
+	}
+
+	handler := server.logToolCall(
+		func(_ context.Context, _ mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			time.Sleep(time.Millisecond)
+			return mcp.NewToolResultStructuredOnly(map[string]any{}), nil
+		},
+	)
+	if _, err := handler(context.Background(), request); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	logged := logs.String()
+	for _, expected := range []string{
+		`"level":"WARN"`,
+		`"msg":"Slow tool call"`,
+		`"tool":"lookup_network"`,
+		`"database":"GeoLite2-City-Test.mmdb"`,
+		`"network":"81.2.69.0/24"`,
+		`"filters":2`,
+	} {
+		if !strings.Contains(logged, expected) {
+			t.Errorf("Expected %s in log output, got: %s", expected, logged)
+		}
+	}
+
+	// Without a threshold, no warning is logged.
+	logs.Reset()
+	cfg.SlowQueryThresholdDuration = 0
+	if _, err := handler(context.Background(), request); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if logs.Len() != 0 {
+		t.Errorf("Expected no slow query warning, got: %s", logs.String())
+	}
+}
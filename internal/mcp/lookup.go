@@ -0,0 +1,361 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/netip"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/oschwald/maxminddb-mcp/internal/filter"
+	"github.com/oschwald/maxminddb-mcp/internal/iterator"
+
+	"github.com/oschwald/maxminddb-golang/v2"
+)
+
+// lookupOptions controls how lookup_ip records are post-processed.
+type lookupOptions struct {
+	// languages is the ordered language preference used to flatten
+	// localized names maps.
+	languages []string
+	// pruneEmpty removes empty strings, maps, and arrays from records.
+	pruneEmpty bool
+	// minConfidence removes sections such as city or postal whose
+	// confidence score is below this value. Zero keeps all sections.
+	minConfidence float64
+	// flattenSubdivisions replaces the subdivisions array with
+	// subdivision_1, subdivision_2, ... fields.
+	flattenSubdivisions bool
+	// enrichTimeZone adds the current UTC offset of location.time_zone.
+	enrichTimeZone bool
+	// typedValues decodes records with iterator.DecodeTyped.
+	typedValues bool
+	// includeMisses adds a found field to each entry when querying all
+	// databases.
+	includeMisses bool
+	// omitMisses leaves out the databases without a record for the IP when
+	// querying all databases, instead of listing them with null data.
+	omitMisses bool
+	// firstMatch returns only the first record found when querying all
+	// databases, in database_precedence order.
+	firstMatch bool
+	// includeType adds the type of each database, such as City or ASN, to
+	// its entry when querying all databases.
+	includeType bool
+}
+
+// maxConfidence is the highest confidence score in Enterprise databases.
+const maxConfidence = 100
+
+// defaultLanguage is used to select names when no preferred language is
+// available.
+const defaultLanguage = "en"
+
+// decode decodes a lookup result according to the options.
+func (o lookupOptions) decode(result maxminddb.Result) (map[string]any, error) {
+	if o.typedValues {
+		return iterator.DecodeTyped(result)
+	}
+	var record map[string]any
+	if err := result.Decode(&record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// apply post-processes a decoded record according to the options.
+func (o lookupOptions) apply(record map[string]any) map[string]any {
+	if record == nil {
+		return nil
+	}
+	if o.minConfidence > 0 {
+		record = dropLowConfidence(record, o.minConfidence)
+	}
+	if o.flattenSubdivisions {
+		record = flattenSubdivisions(record, o.languages)
+	}
+	if o.enrichTimeZone {
+		record = enrichTimeZone(record, time.Now())
+	}
+	if len(o.languages) > 0 {
+		record, _ = flattenNames(record, o.languages).(map[string]any)
+	}
+	if o.pruneEmpty {
+		pruned, _ := pruneEmpty(record)
+		record, _ = pruned.(map[string]any)
+		if record == nil {
+			record = map[string]any{}
+		}
+	}
+	return record
+}
+
+// lookupOptionsFor returns the options to use for a database. When the
+// request did not specify languages, the database's configured default
+// language is used.
+func (s *Server) lookupOptionsFor(dbName string, opts lookupOptions) lookupOptions {
+	if len(opts.languages) > 0 {
+		return opts
+	}
+	if language, ok := s.config.DefaultLanguage[dbName]; ok {
+		opts.languages = []string{language}
+	}
+	return opts
+}
+
+// pruneEmpty returns a copy of value without empty strings, maps, and arrays.
+// Maps and arrays that only contained empty values are removed as well. The
+// boolean result is false when value itself is empty and should be dropped.
+func pruneEmpty(value any) (any, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, v != ""
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, child := range v {
+			if pruned, keep := pruneEmpty(child); keep {
+				out[key] = pruned
+			}
+		}
+		return out, len(out) > 0
+	case []any:
+		out := make([]any, 0, len(v))
+		for _, child := range v {
+			if pruned, keep := pruneEmpty(child); keep {
+				out = append(out, pruned)
+			}
+		}
+		return out, len(out) > 0
+	default:
+		return value, value != nil
+	}
+}
+
+// dropLowConfidence returns a copy of record without the sections whose
+// confidence is below minConfidence, e.g. the city of an Enterprise record
+// with a city confidence of 11 when minConfidence is 50. Subdivisions are
+// checked individually. Sections without a confidence are kept.
+func dropLowConfidence(record map[string]any, minConfidence float64) map[string]any {
+	confident := filter.New([]filter.Filter{{
+		Field:    "confidence",
+		Operator: "greater_than_or_equal",
+		Value:    minConfidence,
+	}}, filter.ModeAnd)
+	keep := func(value any) bool {
+		section, ok := value.(map[string]any)
+		if !ok {
+			return true
+		}
+		if _, ok := section["confidence"]; !ok {
+			return true
+		}
+		return confident.Matches(section)
+	}
+
+	out := make(map[string]any, len(record))
+	for key, value := range record {
+		if sections, ok := value.([]any); ok {
+			kept := slices.DeleteFunc(slices.Clone(sections), func(section any) bool {
+				return !keep(section)
+			})
+			if len(kept) > 0 {
+				out[key] = kept
+			}
+			continue
+		}
+		if keep(value) {
+			out[key] = value
+		}
+	}
+	return out
+}
+
+// flattenSubdivisions returns a copy of record in which the subdivisions
+// array is replaced by subdivision_1, subdivision_2, ... fields, ordered from
+// largest to smallest. Each holds the iso_code and the name in the first
+// available preferred language, falling back to English.
+func flattenSubdivisions(record map[string]any, languages []string) map[string]any {
+	subdivisions, ok := record["subdivisions"].([]any)
+	if !ok {
+		return record
+	}
+
+	out := maps.Clone(record)
+	delete(out, "subdivisions")
+
+	preferred := append(slices.Clone(languages), defaultLanguage)
+	for i, sub := range subdivisions {
+		subMap, ok := sub.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		flat := make(map[string]any, 2)
+		if isoCode, ok := subMap["iso_code"]; ok {
+			flat["iso_code"] = isoCode
+		}
+		names, _ := subMap["names"].(map[string]any)
+		for _, lang := range preferred {
+			if name, ok := names[lang].(string); ok {
+				flat["name"] = name
+				break
+			}
+		}
+		out["subdivision_"+strconv.Itoa(i+1)] = flat
+	}
+	return out
+}
+
+// enrichTimeZone returns a copy of record in which location holds a
+// utc_offset field, such as "+01:00", with the offset of location.time_zone at
+// the given time. Records without a time zone, or with one unknown to the
+// system time zone database, are returned unchanged.
+func enrichTimeZone(record map[string]any, at time.Time) map[string]any {
+	location, ok := record["location"].(map[string]any)
+	if !ok {
+		return record
+	}
+	zone, ok := location["time_zone"].(string)
+	if !ok || zone == "" {
+		return record
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		return record
+	}
+
+	out := maps.Clone(record)
+	enriched := maps.Clone(location)
+	enriched["utc_offset"] = at.In(loc).Format("-07:00")
+	out["location"] = enriched
+	return out
+}
+
+// flattenNames returns a copy of value in which every localized names map is
+// replaced by a name field holding the first available preferred language.
+// Names maps without any of the preferred languages are kept as is.
+func flattenNames(value any, languages []string) any {
+	switch v := value.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for key, child := range v {
+			out[key] = flattenNames(child, languages)
+		}
+		names, ok := v["names"].(map[string]any)
+		if !ok {
+			return out
+		}
+		for _, lang := range languages {
+			if name, ok := names[lang].(string); ok {
+				delete(out, "names")
+				out["name"] = name
+				break
+			}
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, child := range v {
+			out[i] = flattenNames(child, languages)
+		}
+		return out
+	default:
+		return value
+	}
+}
+
+// handleLookupIPHierarchy handles the lookup_ip_hierarchy tool. MaxMind DB
+// files store data only at the most specific network, so the chain consists
+// of the search tree nodes enclosing that network, from the root of the IP's
+// address family down to the matched network. When the matched network is
+// the root itself, the chain has a single entry.
+func (s *Server) handleLookupIPHierarchy(
+	_ context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	ipStr, err := request.RequireString("ip")
+	if err != nil {
+		//nolint:nilerr // MCP protocol expects error result, not Go error
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "missing_parameter",
+				"message": "Missing required parameter: ip",
+			},
+		}), nil
+	}
+
+	ip, err := netip.ParseAddr(ipStr)
+	if err != nil {
+		//nolint:nilerr // MCP protocol expects error result, not Go error
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "invalid_ip",
+				"message": "Invalid IP address: " + ipStr,
+			},
+		}), nil
+	}
+
+	dbName, err := request.RequireString("database")
+	if err != nil {
+		//nolint:nilerr // MCP protocol expects error result, not Go error
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "missing_parameter",
+				"message": "Missing required parameter: database",
+			},
+		}), nil
+	}
+
+	dbName, errResult := s.resolveDatabase(dbName)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	if _, exists := s.dbManager.GetReader(dbName); !exists {
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "db_not_found",
+				"message": "Database not found: " + dbName,
+			},
+		}), nil
+	}
+
+	var result maxminddb.Result
+	var record map[string]any
+	err = s.dbManager.WithReader(dbName, func(reader *maxminddb.Reader) error {
+		result = reader.Lookup(ip)
+		return result.Decode(&record)
+	})
+	if err != nil {
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "lookup_failed",
+				"message": fmt.Sprintf("Lookup failed: %v", err),
+			},
+		}), nil
+	}
+
+	network := result.Prefix()
+	if !network.IsValid() {
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "lookup_failed",
+				"message": "Lookup failed: no network found for IP",
+			},
+		}), nil
+	}
+
+	// A MaxMind DB holds one record per address, stored at the most
+	// specific network, so no enclosing network has data of its own and the
+	// chain holds only the record's network.
+	return mcp.NewToolResultStructuredOnly(map[string]any{
+		"ip":       ipStr,
+		"network":  network.String(),
+		"found":    result.Found(),
+		"prefixes": []string{network.String()},
+		"data":     record,
+	}), nil
+}
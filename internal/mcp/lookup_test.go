@@ -0,0 +1,758 @@
+package mcp
+
+import (
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/oschwald/maxminddb-mcp/internal/iterator"
+)
+
+func TestFlattenNames(t *testing.T) {
+	record := map[string]any{
+		"country": map[string]any{
+			"iso_code": "JP",
+			"names": map[string]any{
+				"en": "Japan",
+				"ja": "日本",
+			},
+		},
+		"city": map[string]any{
+			"names": map[string]any{
+				"en": "Tokyo",
+			},
+		},
+		"subdivisions": []any{
+			map[string]any{
+				"iso_code": "13",
+				"names":    map[string]any{"de": "Tokio"},
+			},
+		},
+	}
+
+	got := lookupOptions{languages: []string{"ja", "en"}}.apply(record)
+
+	expected := map[string]any{
+		"country": map[string]any{
+			"iso_code": "JP",
+			"name":     "日本",
+		},
+		"city": map[string]any{
+			"name": "Tokyo",
+		},
+		"subdivisions": []any{
+			map[string]any{
+				"iso_code": "13",
+				"names":    map[string]any{"de": "Tokio"},
+			},
+		},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("apply() = %v, expected %v", got, expected)
+	}
+
+	// The original record must not be modified.
+	if _, ok := record["country"].(map[string]any)["names"]; !ok {
+		t.Error("apply() should not modify the original record")
+	}
+}
+
+func TestLookupIPWithLanguages(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	plain := callTool(t, server.handleLookupIP, "lookup_ip", map[string]any{
+		"ip":       "81.2.69.142",
+		"database": "GeoLite2-City-Test.mmdb",
+	})
+	plainData, _ := plain.(map[string]any)["data"].(map[string]any)
+	plainCountry, _ := plainData["country"].(map[string]any)
+	plainNames, _ := plainCountry["names"].(map[string]any)
+	if plainNames["en"] == nil {
+		t.Fatalf("Expected English country name without languages, got %v", plainCountry)
+	}
+
+	// "xx" is not present, so lookups should fall back to English.
+	structured := callTool(t, server.handleLookupIP, "lookup_ip", map[string]any{
+		"ip":        "81.2.69.142",
+		"database":  "GeoLite2-City-Test.mmdb",
+		"languages": []any{"xx", "en"},
+	})
+	data, _ := structured.(map[string]any)["data"].(map[string]any)
+	country, _ := data["country"].(map[string]any)
+	if country["name"] != plainNames["en"] {
+		t.Errorf("Expected country name %v, got %v", plainNames["en"], country["name"])
+	}
+	if _, hasNames := country["names"]; hasNames {
+		t.Error("Expected country names map to be flattened")
+	}
+	city, _ := data["city"].(map[string]any)
+	if city["name"] != "London" {
+		t.Errorf("Expected city name London, got %v", city["name"])
+	}
+}
+
+func TestLookupIPHierarchy(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+	reader, _ := server.dbManager.GetReader("GeoLite2-City-Test.mmdb")
+
+	for _, ipStr := range []string{"81.2.69.142", "2.125.160.216", "216.160.83.56"} {
+		t.Run(ipStr, func(t *testing.T) {
+			structured := callTool(t, server.handleLookupIPHierarchy, "lookup_ip_hierarchy",
+				map[string]any{
+					"ip":       ipStr,
+					"database": "GeoLite2-City-Test.mmdb",
+				})
+			resultMap, ok := structured.(map[string]any)
+			if !ok || resultMap["error"] != nil {
+				t.Fatalf("Unexpected result: %v", structured)
+			}
+
+			// The only prefix is the network of the record the database
+			// holds for the IP, and the database has no other record within
+			// it.
+			ip := netip.MustParseAddr(ipStr)
+			result := reader.Lookup(ip)
+			prefixes, _ := resultMap["prefixes"].([]string)
+			if len(prefixes) != 1 || prefixes[0] != result.Prefix().String() {
+				t.Fatalf("Expected prefixes [%s], got %v", result.Prefix(), prefixes)
+			}
+			if resultMap["network"] != prefixes[0] {
+				t.Errorf("Expected network %s, got %v", prefixes[0], resultMap["network"])
+			}
+
+			var records int
+			for network := range reader.NetworksWithin(result.Prefix()) {
+				if network.Err() != nil {
+					t.Fatalf("Unexpected error: %v", network.Err())
+				}
+				records++
+				if network.Prefix() != result.Prefix() || network.Offset() != result.Offset() {
+					t.Errorf("Expected only the record at %s, got %s", result.Prefix(), network.Prefix())
+				}
+			}
+			if records != 1 {
+				t.Errorf("Expected one record within %s, got %d", result.Prefix(), records)
+			}
+
+			if resultMap["found"] != true || resultMap["data"] == nil {
+				t.Errorf("Expected data for %s, got %v", ipStr, resultMap["data"])
+			}
+		})
+	}
+}
+
+func TestPruneEmpty(t *testing.T) {
+	record := map[string]any{
+		"city": map[string]any{
+			"geoname_id": uint64(2643743),
+			"names":      map[string]any{},
+		},
+		"country": map[string]any{
+			"iso_code": "GB",
+			"names":    map[string]any{"en": "United Kingdom", "fr": ""},
+		},
+		"postal":       map[string]any{"code": ""},
+		"subdivisions": []any{map[string]any{}, map[string]any{"iso_code": "ENG"}},
+		"traits":       map[string]any{"is_anycast": false},
+		"tags":         []any{},
+	}
+
+	got := lookupOptions{pruneEmpty: true}.apply(record)
+
+	expected := map[string]any{
+		"city": map[string]any{
+			"geoname_id": uint64(2643743),
+		},
+		"country": map[string]any{
+			"iso_code": "GB",
+			"names":    map[string]any{"en": "United Kingdom"},
+		},
+		"subdivisions": []any{map[string]any{"iso_code": "ENG"}},
+		"traits":       map[string]any{"is_anycast": false},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("apply() = %v, expected %v", got, expected)
+	}
+
+	// Without pruning, the record is returned unchanged.
+	if raw := (lookupOptions{}).apply(record); !reflect.DeepEqual(raw, record) {
+		t.Errorf("Expected raw record without pruning, got %v", raw)
+	}
+
+	// A record that is entirely empty becomes an empty map.
+	empty := lookupOptions{pruneEmpty: true}.apply(map[string]any{"postal": map[string]any{}})
+	if empty == nil || len(empty) != 0 {
+		t.Errorf("Expected empty map, got %v", empty)
+	}
+}
+
+func TestLookupNetworkPruneEmpty(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	structured := callTool(t, server.handleLookupNetwork, "lookup_network", map[string]any{
+		"network":     "81.2.69.0/24",
+		"database":    "GeoLite2-City-Test.mmdb",
+		"prune_empty": true,
+	})
+	result, ok := structured.(*iterator.IterationResult)
+	if !ok {
+		t.Fatalf("Expected iteration result, got %v", structured)
+	}
+	if len(result.Results) == 0 {
+		t.Fatal("Expected results in 81.2.69.0/24")
+	}
+	for _, res := range result.Results {
+		if _, keep := pruneEmpty(res.Data); !keep || hasEmptyValue(res.Data) {
+			t.Errorf("Expected pruned record for %s, got %v", res.Network, res.Data)
+		}
+		country, _ := res.Data["country"].(map[string]any)
+		if country["iso_code"] != "GB" {
+			t.Errorf("Expected populated country to remain, got %v", res.Data)
+		}
+	}
+}
+
+// hasEmptyValue reports whether value contains an empty string, map, or array.
+func hasEmptyValue(value any) bool {
+	switch v := value.(type) {
+	case string:
+		return v == ""
+	case map[string]any:
+		if len(v) == 0 {
+			return true
+		}
+		for _, child := range v {
+			if hasEmptyValue(child) {
+				return true
+			}
+		}
+	case []any:
+		if len(v) == 0 {
+			return true
+		}
+		for _, child := range v {
+			if hasEmptyValue(child) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestLookupIPByDatabaseType(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+	if err := server.dbManager.LoadDatabase("../../testdata/test-data/GeoLite2-ASN-Test.mmdb"); err != nil {
+		t.Fatalf("Failed to load ASN test database: %v", err)
+	}
+
+	structured := callTool(t, server.handleLookupIP, "lookup_ip", map[string]any{
+		"ip":       "1.128.0.1",
+		"database": "type:ASN",
+	})
+	data, _ := structured.(map[string]any)["data"].(map[string]any)
+	if data["autonomous_system_number"] != uint64(1221) {
+		t.Errorf("Expected ASN 1221 from the ASN database, got %v", structured)
+	}
+
+	structured = callTool(t, server.handleLookupIP, "lookup_ip", map[string]any{
+		"ip":       "1.128.0.1",
+		"database": "type:ISP",
+	})
+	if code := errorCode(structured); code != "db_not_found" {
+		t.Errorf("Expected db_not_found, got %v", structured)
+	}
+}
+
+func TestLookupIPFlattenSubdivisions(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	// 2.125.160.216 is in a network with two subdivisions (ENG, WBK).
+	structured := callTool(t, server.handleLookupIP, "lookup_ip", map[string]any{
+		"ip":                   "2.125.160.216",
+		"database":             "GeoLite2-City-Test.mmdb",
+		"flatten_subdivisions": true,
+		"languages":            []any{"xx", "en"},
+	})
+	data, _ := structured.(map[string]any)["data"].(map[string]any)
+
+	if _, hasArray := data["subdivisions"]; hasArray {
+		t.Error("Expected subdivisions array to be replaced")
+	}
+	for key, isoCode := range map[string]string{"subdivision_1": "ENG", "subdivision_2": "WBK"} {
+		sub, ok := data[key].(map[string]any)
+		if !ok {
+			t.Fatalf("Expected %s, got %v", key, data)
+		}
+		if sub["iso_code"] != isoCode {
+			t.Errorf("Expected %s iso_code %s, got %v", key, isoCode, sub["iso_code"])
+		}
+		if name, _ := sub["name"].(string); name == "" {
+			t.Errorf("Expected %s name, got %v", key, sub)
+		}
+	}
+	if _, ok := data["subdivision_3"]; ok {
+		t.Error("Expected only two subdivisions")
+	}
+
+	// Without the option, the raw array is kept.
+	structured = callTool(t, server.handleLookupIP, "lookup_ip", map[string]any{
+		"ip":       "2.125.160.216",
+		"database": "GeoLite2-City-Test.mmdb",
+	})
+	data, _ = structured.(map[string]any)["data"].(map[string]any)
+	if subdivisions, _ := data["subdivisions"].([]any); len(subdivisions) != 2 {
+		t.Errorf("Expected raw subdivisions array, got %v", data["subdivisions"])
+	}
+}
+
+func TestFlattenSubdivisionsLanguageFallback(t *testing.T) {
+	record := map[string]any{
+		"subdivisions": []any{
+			map[string]any{
+				"iso_code": "13",
+				"names":    map[string]any{"en": "Tokyo", "ja": "東京都"},
+			},
+		},
+	}
+
+	got := flattenSubdivisions(record, []string{"ja"})
+	expected := map[string]any{
+		"subdivision_1": map[string]any{"iso_code": "13", "name": "東京都"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("flattenSubdivisions() = %v, expected %v", got, expected)
+	}
+
+	got = flattenSubdivisions(record, []string{"de"})
+	if name := got["subdivision_1"].(map[string]any)["name"]; name != "Tokyo" {
+		t.Errorf("Expected English fallback, got %v", name)
+	}
+}
+
+func TestLookupIPEnrichTimeZone(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	if _, err := time.LoadLocation("Europe/London"); err != nil {
+		t.Skipf("Time zone database unavailable: %v", err)
+	}
+
+	structured := callTool(t, server.handleLookupIP, "lookup_ip", map[string]any{
+		"ip":              "81.2.69.142",
+		"database":        "GeoLite2-City-Test.mmdb",
+		"enrich_timezone": true,
+	})
+	data, _ := structured.(map[string]any)["data"].(map[string]any)
+	location, _ := data["location"].(map[string]any)
+	if location["time_zone"] != "Europe/London" {
+		t.Fatalf("Expected time zone Europe/London, got %v", location)
+	}
+	// London is at +00:00 or +01:00 depending on daylight saving time.
+	offset := location["utc_offset"]
+	if offset != "+00:00" && offset != "+01:00" {
+		t.Errorf("Expected the current London offset, got %v", offset)
+	}
+
+	// Without the option, no offset is added.
+	structured = callTool(t, server.handleLookupIP, "lookup_ip", map[string]any{
+		"ip":       "81.2.69.142",
+		"database": "GeoLite2-City-Test.mmdb",
+	})
+	data, _ = structured.(map[string]any)["data"].(map[string]any)
+	location, _ = data["location"].(map[string]any)
+	if _, ok := location["utc_offset"]; ok {
+		t.Errorf("Expected no utc_offset, got %v", location)
+	}
+}
+
+func TestEnrichTimeZone(t *testing.T) {
+	if _, err := time.LoadLocation("America/Los_Angeles"); err != nil {
+		t.Skipf("Time zone database unavailable: %v", err)
+	}
+
+	winter := time.Date(2024, time.January, 15, 12, 0, 0, 0, time.UTC)
+	summer := time.Date(2024, time.July, 15, 12, 0, 0, 0, time.UTC)
+	record := map[string]any{
+		"location": map[string]any{"time_zone": "America/Los_Angeles", "latitude": 34.05},
+	}
+
+	tests := []struct {
+		at       time.Time
+		expected string
+	}{
+		{at: winter, expected: "-08:00"},
+		{at: summer, expected: "-07:00"},
+	}
+	for _, test := range tests {
+		got := enrichTimeZone(record, test.at)
+		location, _ := got["location"].(map[string]any)
+		if location["utc_offset"] != test.expected || location["latitude"] != 34.05 {
+			t.Errorf("enrichTimeZone() at %v = %v, expected utc_offset %s", test.at, got, test.expected)
+		}
+	}
+	if _, ok := record["location"].(map[string]any)["utc_offset"]; ok {
+		t.Error("enrichTimeZone() modified its input")
+	}
+
+	// Records without a known time zone are returned unchanged.
+	for _, unchanged := range []map[string]any{
+		{"country": map[string]any{"iso_code": "GB"}},
+		{"location": map[string]any{"latitude": 51.5}},
+		{"location": map[string]any{"time_zone": "Mars/Olympus_Mons"}},
+	} {
+		if got := enrichTimeZone(unchanged, winter); !reflect.DeepEqual(got, unchanged) {
+			t.Errorf("enrichTimeZone(%v) = %v, expected it unchanged", unchanged, got)
+		}
+	}
+}
+
+func TestLookupIPDefaultLanguage(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+	server.config.DefaultLanguage = map[string]string{"GeoLite2-City-Test.mmdb": "ja"}
+
+	reader, _ := server.dbManager.GetReader("GeoLite2-City-Test.mmdb")
+	var names map[string]string
+	if err := reader.Lookup(netip.MustParseAddr("81.2.69.142")).
+		DecodePath(&names, "country", "names"); err != nil {
+		t.Fatalf("Failed to decode country names: %v", err)
+	}
+	if names["ja"] == "" || names["ja"] == names["en"] {
+		t.Fatalf("Expected distinct ja and en country names, got %v", names)
+	}
+
+	countryName := func(args map[string]any) any {
+		t.Helper()
+		structured := callTool(t, server.handleLookupIP, "lookup_ip", args)
+		result, ok := structured.(map[string]any)
+		if !ok {
+			t.Fatalf("Expected map result, got %T", structured)
+		}
+		data, _ := result["data"].(map[string]any)
+		country, _ := data["country"].(map[string]any)
+		return country["name"]
+	}
+
+	// The configured default applies when no languages are requested.
+	if name := countryName(map[string]any{
+		"ip":       "81.2.69.142",
+		"database": "GeoLite2-City-Test.mmdb",
+	}); name != names["ja"] {
+		t.Errorf("Expected Japanese country name %q, got %v", names["ja"], name)
+	}
+
+	// Requested languages override it.
+	if name := countryName(map[string]any{
+		"ip":        "81.2.69.142",
+		"database":  "GeoLite2-City-Test.mmdb",
+		"languages": []any{"en"},
+	}); name != names["en"] {
+		t.Errorf("Expected English country name %q, got %v", names["en"], name)
+	}
+
+	// It also applies when looking up in all databases.
+	structured := callTool(t, server.handleLookupIP, "lookup_ip", map[string]any{"ip": "81.2.69.142"})
+	databases, _ := structured.(map[string]any)["databases"].(map[string]any)
+	dbResult, _ := databases["GeoLite2-City-Test.mmdb"].(map[string]any)
+	data, _ := dbResult["data"].(map[string]any)
+	country, _ := data["country"].(map[string]any)
+	if country["name"] != names["ja"] {
+		t.Errorf("Expected Japanese country name %q across databases, got %v", names["ja"], country["name"])
+	}
+}
+
+func TestLookupIPMinConfidence(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+	const enterpriseDB = "GeoIP2-Enterprise-Test.mmdb"
+	if err := server.dbManager.LoadDatabase("../../testdata/test-data/" + enterpriseDB); err != nil {
+		t.Fatalf("Failed to load database: %v", err)
+	}
+
+	// 74.209.24.0 has a country confidence of 99, a subdivision confidence
+	// of 93, and city and postal confidences of 11.
+	lookup := func(args map[string]any) map[string]any {
+		t.Helper()
+		args["ip"] = "74.209.24.0"
+		args["database"] = enterpriseDB
+		structured := callTool(t, server.handleLookupIP, "lookup_ip", args)
+		data, ok := structured.(map[string]any)["data"].(map[string]any)
+		if !ok {
+			t.Fatalf("Expected data, got %v", structured)
+		}
+		return data
+	}
+
+	tests := []struct {
+		args map[string]any
+		want map[string]bool
+		name string
+	}{
+		{
+			name: "no threshold",
+			args: map[string]any{},
+			want: map[string]bool{"country": true, "subdivisions": true, "city": true, "postal": true},
+		},
+		{
+			name: "drops city and postal",
+			args: map[string]any{"min_confidence": 50.0},
+			want: map[string]bool{"country": true, "subdivisions": true, "city": false, "postal": false},
+		},
+		{
+			name: "drops subdivisions",
+			args: map[string]any{"min_confidence": 95.0},
+			want: map[string]bool{"country": true, "subdivisions": false, "city": false, "postal": false},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data := lookup(test.args)
+			for section, want := range test.want {
+				if _, got := data[section]; got != want {
+					t.Errorf("Expected %s present=%t, got %v", section, want, data[section])
+				}
+			}
+			// Sections without a confidence are always kept.
+			if _, ok := data["traits"]; !ok {
+				t.Error("Expected traits to be kept")
+			}
+		})
+	}
+
+	structured := callTool(t, server.handleLookupIP, "lookup_ip", map[string]any{
+		"ip":             "74.209.24.0",
+		"min_confidence": 101.0,
+	})
+	if code := errorCode(structured); code != "invalid_parameter" {
+		t.Errorf("Expected invalid_parameter error, got %v", structured)
+	}
+}
+
+func TestLookupNetworkConfidenceFilter(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+	const enterpriseDB = "GeoIP2-Enterprise-Test.mmdb"
+	if err := server.dbManager.LoadDatabase("../../testdata/test-data/" + enterpriseDB); err != nil {
+		t.Fatalf("Failed to load database: %v", err)
+	}
+
+	// Confidence scores decode as unsigned integers and compare as numbers.
+	matches := func(operator string, value float64) int {
+		t.Helper()
+		structured := callTool(t, server.handleLookupNetwork, "lookup_network", map[string]any{
+			"network":  "74.209.24.0/24",
+			"database": enterpriseDB,
+			"filters": []any{map[string]any{
+				"field":    "city.confidence",
+				"operator": operator,
+				"value":    value,
+			}},
+		})
+		result, ok := structured.(*iterator.IterationResult)
+		if !ok {
+			t.Fatalf("Expected iteration result, got %v", structured)
+		}
+		return len(result.Results)
+	}
+
+	if n := matches("less_than", 50); n != 1 {
+		t.Errorf("Expected 1 network with city confidence below 50, got %d", n)
+	}
+	if n := matches("greater_than", 50); n != 0 {
+		t.Errorf("Expected no networks with city confidence above 50, got %d", n)
+	}
+}
+
+func TestLookupIPTypedValues(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	structured := callTool(t, server.handleLookupIP, "lookup_ip", map[string]any{
+		"ip":           "81.2.69.142",
+		"database":     "GeoLite2-City-Test.mmdb",
+		"typed_values": true,
+		"languages":    []any{"en"},
+	})
+	data, _ := structured.(map[string]any)["data"].(map[string]any)
+	city, _ := data["city"].(map[string]any)
+	geonameID, ok := city["geoname_id"].(iterator.TypedNumber)
+	if !ok || geonameID.Type != "uint32" {
+		t.Errorf("Expected a uint32 geoname_id, got %#v", city["geoname_id"])
+	}
+	if city["name"] != "London" {
+		t.Errorf("Expected names to be flattened, got %v", city)
+	}
+	location, _ := data["location"].(map[string]any)
+	if latitude, ok := location["latitude"].(iterator.TypedNumber); !ok || latitude.Type != "double" {
+		t.Errorf("Expected a double latitude, got %#v", location["latitude"])
+	}
+
+	// Across all databases.
+	structured = callTool(t, server.handleLookupIP, "lookup_ip", map[string]any{
+		"ip":           "81.2.69.142",
+		"typed_values": true,
+	})
+	databases, _ := structured.(map[string]any)["databases"].(map[string]any)
+	result, _ := databases["GeoLite2-City-Test.mmdb"].(map[string]any)
+	data, _ = result["data"].(map[string]any)
+	location, _ = data["location"].(map[string]any)
+	if _, ok := location["accuracy_radius"].(iterator.TypedNumber); !ok {
+		t.Errorf("Expected a typed accuracy_radius, got %#v", location["accuracy_radius"])
+	}
+
+	structured = callTool(t, server.handleLookupIP, "lookup_ip", map[string]any{
+		"ip":             "81.2.69.142",
+		"typed_values":   true,
+		"min_confidence": 50.0,
+	})
+	if code := errorCode(structured); code != "invalid_parameter" {
+		t.Errorf("Expected invalid_parameter error, got %v", structured)
+	}
+}
+
+func TestLookupIPIncludeMisses(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+	const asnDB = "GeoLite2-ASN-Test.mmdb"
+	if err := server.dbManager.LoadDatabase("../../testdata/test-data/" + asnDB); err != nil {
+		t.Fatalf("Failed to load database: %v", err)
+	}
+
+	lookup := func(args map[string]any) map[string]any {
+		t.Helper()
+		args["ip"] = "81.2.69.142"
+		structured := callTool(t, server.handleLookupIP, "lookup_ip", args)
+		databases, ok := structured.(map[string]any)["databases"].(map[string]any)
+		if !ok {
+			t.Fatalf("Expected databases, got %v", structured)
+		}
+		return databases
+	}
+
+	// The ASN test database has no record for the IP. By default it is
+	// listed with null data, and no entry has a found field.
+	databases := lookup(map[string]any{})
+	miss, listed := databases[asnDB].(map[string]any)
+	if !listed || len(miss) != 1 || miss["data"] != nil {
+		t.Errorf(`Expected %s to be listed as {"data": null}, got %v`, asnDB, databases[asnDB])
+	}
+	city, _ := databases["GeoLite2-City-Test.mmdb"].(map[string]any)
+	if len(city) != 1 || city["data"] == nil {
+		t.Errorf("Expected the City record with only data, got %v", city)
+	}
+
+	databases = lookup(map[string]any{"include_misses": true})
+	miss, _ = databases[asnDB].(map[string]any)
+	if miss == nil || miss["found"] != false || miss["data"] != nil {
+		t.Errorf("Expected %s to be listed as a miss, got %v", asnDB, databases[asnDB])
+	}
+	city, _ = databases["GeoLite2-City-Test.mmdb"].(map[string]any)
+	if city["found"] != true || city["data"] == nil {
+		t.Errorf("Expected the City record to be marked as found, got %v", city)
+	}
+
+	databases = lookup(map[string]any{"include_misses": false})
+	if _, listed := databases[asnDB]; listed {
+		t.Errorf("Expected %s to be omitted, got %v", asnDB, databases[asnDB])
+	}
+	city, _ = databases["GeoLite2-City-Test.mmdb"].(map[string]any)
+	if _, hasFound := city["found"]; hasFound || city["data"] == nil {
+		t.Errorf("Expected the City record without a found field, got %v", city)
+	}
+}
+
+func TestLookupIPIncludeType(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+	const asnDB = "GeoLite2-ASN-Test.mmdb"
+	if err := server.dbManager.LoadDatabase("../../testdata/test-data/" + asnDB); err != nil {
+		t.Fatalf("Failed to load database: %v", err)
+	}
+
+	lookup := func(args map[string]any) map[string]any {
+		t.Helper()
+		structured := callTool(t, server.handleLookupIP, "lookup_ip", args)
+		databases, ok := structured.(map[string]any)["databases"].(map[string]any)
+		if !ok {
+			t.Fatalf("Expected databases, got %v", structured)
+		}
+		return databases
+	}
+
+	// The test databases share no IP, so each is looked up with its own.
+	tests := []struct {
+		ip       string
+		database string
+		dbType   string
+	}{
+		{ip: "81.2.69.142", database: "GeoLite2-City-Test.mmdb", dbType: "City"},
+		{ip: "1.128.0.0", database: asnDB, dbType: "ASN"},
+	}
+	for _, test := range tests {
+		databases := lookup(map[string]any{"ip": test.ip, "include_type": true})
+		entry, _ := databases[test.database].(map[string]any)
+		if entry["type"] != test.dbType || entry["data"] == nil {
+			t.Errorf("Expected %s data with type %s, got %v", test.database, test.dbType, entry)
+		}
+	}
+
+	// Misses carry the type too.
+	databases := lookup(map[string]any{"ip": "81.2.69.142", "include_type": true, "include_misses": true})
+	if miss, _ := databases[asnDB].(map[string]any); miss["type"] != "ASN" || miss["found"] != false {
+		t.Errorf("Expected %s to be listed as an ASN miss, got %v", asnDB, databases[asnDB])
+	}
+
+	// The type is omitted unless requested or configured.
+	databases = lookup(map[string]any{"ip": "81.2.69.142"})
+	city, _ := databases["GeoLite2-City-Test.mmdb"].(map[string]any)
+	if _, hasType := city["type"]; hasType {
+		t.Errorf("Expected no type by default, got %v", city)
+	}
+	server.config.IncludeDatabaseType = true
+	databases = lookup(map[string]any{"ip": "81.2.69.142"})
+	city, _ = databases["GeoLite2-City-Test.mmdb"].(map[string]any)
+	if city["type"] != "City" {
+		t.Errorf("Expected the configured type, got %v", city)
+	}
+}
+
+func TestLookupIPCollapseSingleDatabase(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	lookup := func(args map[string]any) map[string]any {
+		t.Helper()
+		args["ip"] = "81.2.69.142"
+		result, ok := callTool(t, server.handleLookupIP, "lookup_ip", args).(map[string]any)
+		if !ok {
+			t.Fatalf("Expected lookup result, got %v", result)
+		}
+		return result
+	}
+
+	// Without the option, the single database is nested.
+	if _, ok := lookup(map[string]any{})["databases"].(map[string]any); !ok {
+		t.Error("Expected nested databases by default")
+	}
+
+	collapsed := func(args map[string]any) {
+		t.Helper()
+		result := lookup(args)
+		if _, nested := result["databases"]; nested {
+			t.Errorf("Expected a collapsed result, got %v", result)
+		}
+		data, _ := result["data"].(map[string]any)
+		if city, _ := lookupPath(data, "city.names.en"); city != "London" {
+			t.Errorf("Expected London data, got %v", result)
+		}
+	}
+	collapsed(map[string]any{"collapse_single_database": true})
+	server.config.CollapseSingleDatabase = true
+	collapsed(map[string]any{})
+
+	// With several databases loaded, results stay nested.
+	if err := server.dbManager.LoadDatabase("../../testdata/test-data/GeoLite2-ASN-Test.mmdb"); err != nil {
+		t.Fatalf("Failed to load database: %v", err)
+	}
+	databases, ok := lookup(map[string]any{"collapse_single_database": true})["databases"].(map[string]any)
+	if !ok {
+		t.Fatal("Expected nested databases with several loaded")
+	}
+	if _, ok := databases["GeoLite2-City-Test.mmdb"]; !ok {
+		t.Errorf("Expected the City result, got %v", databases)
+	}
+}
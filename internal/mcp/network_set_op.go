@@ -0,0 +1,234 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/netip"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/oschwald/maxminddb-mcp/internal/config"
+	"github.com/oschwald/maxminddb-mcp/internal/iterator"
+
+	"github.com/oschwald/maxminddb-golang/v2"
+)
+
+// Set operations supported by network_set_op.
+const (
+	setOpDifference   = "difference"
+	setOpIntersection = "intersection"
+	setOpUnion        = "union"
+)
+
+// Limits of the network_set_op tool.
+const (
+	// maxSetOpNetworks is the maximum number of networks either query may
+	// match, bounding the memory used to hold them.
+	maxSetOpNetworks = 100000
+	// setOpTimeout bounds the time spent running both queries.
+	setOpTimeout = 30 * time.Second
+)
+
+// setOpQuery describes one of the queries of network_set_op.
+type setOpQuery struct {
+	Network string `json:"network"`
+	Matched int    `json:"matched"`
+}
+
+// setOpResult is the result of network_set_op.
+type setOpResult struct {
+	Database string     `json:"database"`
+	Op       string     `json:"op"`
+	Networks []string   `json:"networks"`
+	A        setOpQuery `json:"a"`
+	B        setOpQuery `json:"b"`
+	Count    int        `json:"count"`
+}
+
+// handleNetworkSetOp handles the network_set_op tool. It runs two
+// network+filters queries against a database and returns the difference
+// (a minus b), intersection, or union of the address space they matched, as
+// the fewest CIDR networks covering it. Adjacent matches are merged, so the
+// returned networks need not be networks of the database.
+func (s *Server) handleNetworkSetOp(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	dbName, err := request.RequireString("database")
+	if err != nil {
+		//nolint:nilerr // MCP protocol expects error result, not Go error
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "missing_parameter",
+				"message": "Missing required parameter: database",
+			},
+		}), nil
+	}
+
+	op, err := request.RequireString("op")
+	if err != nil {
+		//nolint:nilerr // MCP protocol expects error result, not Go error
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "missing_parameter",
+				"message": "Missing required parameter: op",
+			},
+		}), nil
+	}
+	if op != setOpDifference && op != setOpIntersection && op != setOpUnion {
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code": "invalid_parameter",
+				"message": "Invalid op: " + op +
+					" (must be " + setOpDifference + ", " + setOpIntersection + ", or " + setOpUnion + ")",
+			},
+		}), nil
+	}
+
+	dbName, errResult := s.resolveDatabase(dbName)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	reader, exists := s.dbManager.GetReader(dbName)
+	if !exists {
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "db_not_found",
+				"message": "Database not found: " + dbName,
+			},
+		}), nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, setOpTimeout)
+	defer cancel()
+
+	result := setOpResult{Database: dbName, Op: op}
+	var sets [2]iterator.PrefixSet
+	for i, name := range []string{"a", "b"} {
+		query, errResult := setOpQueryRequest(request, name)
+		if errResult != nil {
+			return errResult, nil
+		}
+		prefixes, network, errResult := s.runSetOpQuery(ctx, query, name, reader)
+		if errResult != nil {
+			return errResult, nil
+		}
+		sets[i] = iterator.NewPrefixSet(prefixes)
+
+		queryResult := setOpQuery{Network: network.String(), Matched: len(prefixes)}
+		if name == "a" {
+			result.A = queryResult
+		} else {
+			result.B = queryResult
+		}
+	}
+
+	var set iterator.PrefixSet
+	switch op {
+	case setOpDifference:
+		set = sets[0].Difference(sets[1])
+	case setOpIntersection:
+		set = sets[0].Intersection(sets[1])
+	case setOpUnion:
+		set = sets[0].Union(sets[1])
+	}
+
+	prefixes := set.Prefixes()
+	result.Networks = make([]string, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		result.Networks = append(result.Networks, prefix.String())
+	}
+	result.Count = len(result.Networks)
+	return mcp.NewToolResultStructuredOnly(result), nil
+}
+
+// setOpQueryRequest returns a request holding the parameters of query a or b
+// of network_set_op, so that they can be parsed like those of other tools.
+// confirm_full_scan applies to both queries.
+func setOpQueryRequest(request mcp.CallToolRequest, name string) (mcp.CallToolRequest, *mcp.CallToolResult) {
+	spec, ok := request.GetArguments()[name].(map[string]any)
+	if !ok {
+		return mcp.CallToolRequest{}, mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "missing_parameter",
+				"message": "Missing required parameter: " + name + " (must be an object {network, filters, filter_mode})",
+			},
+		})
+	}
+
+	args := maps.Clone(spec)
+	if confirm, ok := request.GetArguments()["confirm_full_scan"]; ok {
+		args["confirm_full_scan"] = confirm
+	}
+	query := request
+	query.Params.Arguments = args
+	return query, nil
+}
+
+// runSetOpQuery runs query a or b of network_set_op in a single pass over
+// the database and returns the networks it matched and the network it
+// scanned. It fails if the query matches more than maxSetOpNetworks networks
+// or does not finish in time.
+func (s *Server) runSetOpQuery(
+	ctx context.Context,
+	query mcp.CallToolRequest,
+	name string,
+	reader *maxminddb.Reader,
+) ([]netip.Prefix, netip.Prefix, *mcp.CallToolResult) {
+	network, errResult := boundingNetwork(query, reader)
+	if errResult != nil {
+		return nil, netip.Prefix{}, errResult
+	}
+
+	filters, errResult := s.requestFilters(query)
+	if errResult != nil {
+		return nil, netip.Prefix{}, errResult
+	}
+
+	defaultFilterMode := s.config.DefaultFilterMode
+	if defaultFilterMode == "" {
+		defaultFilterMode = config.FilterModeAnd
+	}
+	filterMode := query.GetString("filter_mode", defaultFilterMode)
+
+	var prefixes []netip.Prefix
+	err := iterator.Scan(ctx, reader, network, filters, filterMode, func(prefix netip.Prefix) bool {
+		prefixes = append(prefixes, prefix)
+		return len(prefixes) <= maxSetOpNetworks
+	})
+	if ctx.Err() != nil {
+		return nil, netip.Prefix{}, mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code": "scan_timeout",
+				"message": fmt.Sprintf(
+					"Query %s did not finish within %s; pass a narrower network",
+					name,
+					setOpTimeout,
+				),
+			},
+		})
+	}
+	if err != nil {
+		return nil, netip.Prefix{}, mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "iteration_failed",
+				"message": "Iteration failed for " + name + ": " + err.Error(),
+			},
+		})
+	}
+	if len(prefixes) > maxSetOpNetworks {
+		return nil, netip.Prefix{}, mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code": "too_many_networks",
+				"message": fmt.Sprintf(
+					"Query %s matches more than %d networks; pass a narrower network or more filters",
+					name,
+					maxSetOpNetworks,
+				),
+			},
+		})
+	}
+	return prefixes, network, nil
+}
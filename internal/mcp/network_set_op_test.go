@@ -0,0 +1,184 @@
+package mcp
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+func TestNetworkSetOp(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	tests := []struct {
+		name     string
+		op       string
+		a        map[string]any
+		b        map[string]any
+		expected []string
+	}{
+		{
+			name:     "difference",
+			op:       "difference",
+			a:        map[string]any{"network": "81.2.69.0/24"},
+			b:        map[string]any{"network": "81.2.69.160/27"},
+			expected: []string{"81.2.69.142/31", "81.2.69.144/28", "81.2.69.192/28"},
+		},
+		{
+			name:     "intersection",
+			op:       "intersection",
+			a:        map[string]any{"network": "81.2.69.0/24"},
+			b:        map[string]any{"network": "81.2.69.128/26"},
+			expected: []string{"81.2.69.142/31", "81.2.69.144/28", "81.2.69.160/27"},
+		},
+		{
+			name:     "union merges adjacent networks",
+			op:       "union",
+			a:        map[string]any{"network": "81.2.69.144/28"},
+			b:        map[string]any{"network": "81.2.69.160/27"},
+			expected: []string{"81.2.69.144/28", "81.2.69.160/27"},
+		},
+		{
+			name: "difference with filters",
+			op:   "difference",
+			a: map[string]any{
+				"network": "81.2.69.0/24",
+				"filters": []any{map[string]any{"field": "city.names.en", "operator": "equals", "value": "London"}},
+			},
+			b: map[string]any{
+				"network": "81.2.69.0/24",
+				"filters": []any{map[string]any{"field": "country.iso_code", "operator": "equals", "value": "US"}},
+			},
+			expected: []string{"81.2.69.142/31", "81.2.69.144/28", "81.2.69.160/27", "81.2.69.192/28"},
+		},
+		{
+			name: "intersection of disjoint filters",
+			op:   "intersection",
+			a: map[string]any{
+				"network": "81.2.69.0/24",
+				"filters": []any{map[string]any{"field": "city.names.en", "operator": "equals", "value": "London"}},
+			},
+			b: map[string]any{
+				"network": "81.2.69.0/24",
+				"filters": []any{map[string]any{"field": "country.iso_code", "operator": "equals", "value": "US"}},
+			},
+			expected: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			structured := callTool(t, server.handleNetworkSetOp, "network_set_op", map[string]any{
+				"database": "GeoLite2-City-Test.mmdb",
+				"op":       tt.op,
+				"a":        tt.a,
+				"b":        tt.b,
+			})
+			result, ok := structured.(setOpResult)
+			if !ok {
+				t.Fatalf("Unexpected result: %v", structured)
+			}
+			if !slices.Equal(result.Networks, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, result.Networks)
+			}
+			if result.Count != len(tt.expected) {
+				t.Errorf("Expected count %d, got %d", len(tt.expected), result.Count)
+			}
+			if result.Op != tt.op || result.A.Network != tt.a["network"] || result.B.Network != tt.b["network"] {
+				t.Errorf("Unexpected query details: %+v", result)
+			}
+		})
+	}
+}
+
+func TestNetworkSetOpErrors(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+	a := map[string]any{"network": "81.2.69.0/24"}
+
+	tests := []struct {
+		name string
+		args map[string]any
+		code string
+	}{
+		{
+			name: "missing database",
+			args: map[string]any{"op": "union", "a": a, "b": a},
+			code: "missing_parameter",
+		},
+		{
+			name: "invalid op",
+			args: map[string]any{"database": "GeoLite2-City-Test.mmdb", "op": "xor", "a": a, "b": a},
+			code: "invalid_parameter",
+		},
+		{
+			name: "missing query",
+			args: map[string]any{"database": "GeoLite2-City-Test.mmdb", "op": "union", "a": a},
+			code: "missing_parameter",
+		},
+		{
+			name: "unknown database",
+			args: map[string]any{"database": "missing", "op": "union", "a": a, "b": a},
+			code: "db_not_found",
+		},
+		{
+			name: "invalid network",
+			args: map[string]any{
+				"database": "GeoLite2-City-Test.mmdb",
+				"op":       "union",
+				"a":        a,
+				"b":        map[string]any{"network": "nope"},
+			},
+			code: "invalid_network",
+		},
+		{
+			name: "invalid filter",
+			args: map[string]any{
+				"database": "GeoLite2-City-Test.mmdb",
+				"op":       "union",
+				"a":        a,
+				"b":        map[string]any{"network": "81.2.69.0/24", "filters": "country"},
+			},
+			code: "invalid_filter",
+		},
+		{
+			name: "unconfirmed full scan",
+			args: map[string]any{"database": "GeoLite2-City-Test.mmdb", "op": "union", "a": a, "b": map[string]any{}},
+			code: "full_scan_not_confirmed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			structured := callTool(t, server.handleNetworkSetOp, "network_set_op", tt.args)
+			if code := errorCode(structured); code != tt.code {
+				t.Errorf("Expected error code %s, got %s (%v)", tt.code, code, structured)
+			}
+		})
+	}
+}
+
+func TestNetworkSetOpTimeout(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	// The deadline is checked while a query scans, not only between
+	// batches, so a done context stops the first query right away.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	request := mcp.CallToolRequest{}
+	request.Params.Name = "network_set_op"
+	request.Params.Arguments = map[string]any{
+		"database": "GeoLite2-City-Test.mmdb",
+		"op":       "union",
+		"a":        map[string]any{"network": "81.2.69.0/24"},
+		"b":        map[string]any{"network": "81.2.69.0/24"},
+	}
+	result, err := server.handleNetworkSetOp(ctx, request)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if code := errorCode(result.StructuredContent); code != "scan_timeout" {
+		t.Errorf("Expected scan_timeout, got %v", result.StructuredContent)
+	}
+}
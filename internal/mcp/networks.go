@@ -0,0 +1,520 @@
+package mcp
+
+import (
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/oschwald/maxminddb-mcp/internal/filter"
+	"github.com/oschwald/maxminddb-mcp/internal/iterator"
+
+	"github.com/oschwald/maxminddb-golang/v2"
+)
+
+// handleCountryNetworks handles the country_networks tool. It is a shortcut
+// for lookup_network with a country.iso_code equals filter.
+func (s *Server) handleCountryNetworks(
+	_ context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	countryCode, err := request.RequireString("country_iso_code")
+	if err != nil || strings.TrimSpace(countryCode) == "" {
+		//nolint:nilerr // MCP protocol expects error result, not Go error
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "missing_parameter",
+				"message": "Missing required parameter: country_iso_code",
+			},
+		}), nil
+	}
+
+	dbName, err := request.RequireString("database")
+	if err != nil {
+		//nolint:nilerr // MCP protocol expects error result, not Go error
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "missing_parameter",
+				"message": "Missing required parameter: database",
+			},
+		}), nil
+	}
+
+	dbName, errResult := s.resolveDatabase(dbName)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	reader, exists := s.dbManager.GetReader(dbName)
+	if !exists {
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "db_not_found",
+				"message": "Database not found: " + dbName,
+			},
+		}), nil
+	}
+
+	network, errResult := boundingNetwork(request, reader)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	filters := []filter.Filter{
+		{
+			Field:    "country.iso_code",
+			Operator: "equals",
+			Value:    strings.ToUpper(strings.TrimSpace(countryCode)),
+		},
+	}
+
+	return s.iterateNetworks(
+		request,
+		reader,
+		dbName,
+		[]netip.Prefix{network},
+		filters,
+		string(filter.ModeAnd),
+	)
+}
+
+// defaultASNDatabase selects the database used by asn_networks when no
+// database is given.
+const defaultASNDatabase = "type:ASN"
+
+// maxASN is the largest autonomous system number.
+const maxASN = math.MaxUint32
+
+// handleASNNetworks handles the asn_networks tool. It is a shortcut for
+// lookup_network with an autonomous_system_number filter.
+func (s *Server) handleASNNetworks(
+	_ context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	asn, err := request.RequireFloat("asn")
+	if err != nil {
+		//nolint:nilerr // MCP protocol expects error result, not Go error
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "missing_parameter",
+				"message": "Missing required parameter: asn",
+			},
+		}), nil
+	}
+	if asn < 0 || asn > maxASN || asn != math.Trunc(asn) {
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "invalid_parameter",
+				"message": fmt.Sprintf("Invalid ASN: %v (must be an integer from 0 to %d)", asn, maxASN),
+			},
+		}), nil
+	}
+
+	dbName, errResult := s.resolveDatabase(request.GetString("database", defaultASNDatabase))
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	reader, exists := s.dbManager.GetReader(dbName)
+	if !exists {
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "db_not_found",
+				"message": "Database not found: " + dbName,
+			},
+		}), nil
+	}
+
+	network, errResult := boundingNetwork(request, reader)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	filters := []filter.Filter{
+		{Field: "autonomous_system_number", Operator: "equals", Value: asn},
+	}
+
+	return s.iterateNetworks(
+		request,
+		reader,
+		dbName,
+		[]netip.Prefix{network},
+		filters,
+		string(filter.ModeAnd),
+	)
+}
+
+// defaultConfidenceField is the confidence score sort_by_confidence orders
+// by unless the request or the confidence_field config setting names another.
+const defaultConfidenceField = "country.confidence"
+
+// sortByConfidence orders results by the confidence score at the dotted field
+// path, highest first. Results without the score keep their order after the
+// others, as do results with equal scores.
+func sortByConfidence(results []iterator.NetworkResult, field string) {
+	slices.SortStableFunc(results, func(a, b iterator.NetworkResult) int {
+		scoreA, okA := confidenceScore(a.Data, field)
+		scoreB, okB := confidenceScore(b.Data, field)
+		switch {
+		case okA && okB:
+			return cmp.Compare(scoreB, scoreA)
+		case okA:
+			return -1
+		case okB:
+			return 1
+		default:
+			return 0
+		}
+	})
+}
+
+// confidenceScore returns the number at the dotted field path of a record,
+// which may be a typed_values number.
+func confidenceScore(record map[string]any, field string) (float64, bool) {
+	value, ok := lookupPath(record, field)
+	if !ok {
+		return 0, false
+	}
+	if typed, isTyped := value.(iterator.TypedNumber); isTyped {
+		value = typed.Value
+	}
+
+	switch v := value.(type) {
+	case uint16:
+		return float64(v), true
+	case uint32:
+		return float64(v), true
+	case uint64:
+		return float64(v), true
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+// databaseCursor is the position of one database in a multi-database scan.
+type databaseCursor struct {
+	Database    string `json:"database"`
+	IteratorID  string `json:"iterator_id,omitempty"`
+	ResumeToken string `json:"resume_token,omitempty"`
+	Processed   int64  `json:"processed"`
+	Matched     int64  `json:"matched"`
+	Done        bool   `json:"done,omitempty"`
+}
+
+// multiResumeToken is the composite resume token of a multi-database scan.
+// It holds the iterator and resume token of each database.
+type multiResumeToken struct {
+	Databases []databaseCursor `json:"databases"`
+}
+
+// multiNetworkResult is a batch of a multi-database scan. Databases holds
+// the batch of each database that was not finished before this call; the
+// totals cover all databases.
+type multiNetworkResult struct {
+	Databases      map[string]*iterator.IterationResult `json:"databases"`
+	ResumeToken    string                               `json:"resume_token"`
+	TotalProcessed int64                                `json:"total_processed"`
+	TotalMatched   int64                                `json:"total_matched"`
+	HasMore        bool                                 `json:"has_more"`
+}
+
+// lookupNetworkInDatabases scans the same networks in several databases. Each
+// call returns the next batch of every unfinished database. Pagination uses
+// a composite resume token; iterator_id is not used.
+func (s *Server) lookupNetworkInDatabases(
+	request mcp.CallToolRequest,
+	names []string,
+	networks []netip.Prefix,
+	filters []filter.Filter,
+	filterMode string,
+) *mcp.CallToolResult {
+	cursors := make([]databaseCursor, 0, len(names))
+	if token := request.GetString("resume_token", ""); token != "" {
+		var err error
+		cursors, err = parseMultiResumeToken(token, names)
+		if err != nil {
+			return mcp.NewToolResultStructuredOnly(map[string]any{
+				"error": map[string]any{
+					"code":    "resume_failed",
+					"message": fmt.Sprintf("Failed to resume iterators: %v", err),
+				},
+			})
+		}
+	} else {
+		for _, name := range names {
+			cursors = append(cursors, databaseCursor{Database: name})
+		}
+	}
+
+	result := multiNetworkResult{
+		Databases: make(map[string]*iterator.IterationResult, len(cursors)),
+	}
+	for i := range cursors {
+		cursor := &cursors[i]
+		if !cursor.Done {
+			reader, exists := s.dbManager.GetReader(cursor.Database)
+			if !exists {
+				return mcp.NewToolResultStructuredOnly(map[string]any{
+					"error": map[string]any{
+						"code":    "db_not_found",
+						"message": "Database not found: " + cursor.Database,
+					},
+				})
+			}
+
+			batch, errResult := s.nextNetworkBatch(
+				request,
+				reader,
+				cursor.Database,
+				networks,
+				filters,
+				filterMode,
+				cursor.IteratorID,
+				cursor.ResumeToken,
+			)
+			if errResult != nil {
+				return errResult
+			}
+
+			result.Databases[cursor.Database] = batch
+			cursor.IteratorID = batch.IteratorID
+			cursor.ResumeToken = batch.ResumeToken
+			cursor.Processed = batch.TotalProcessed
+			cursor.Matched = batch.TotalMatched
+			cursor.Done = !batch.HasMore
+		}
+
+		result.TotalProcessed += cursor.Processed
+		result.TotalMatched += cursor.Matched
+		result.HasMore = result.HasMore || !cursor.Done
+	}
+
+	data, err := json.Marshal(multiResumeToken{Databases: cursors})
+	if err != nil {
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "iteration_failed",
+				"message": fmt.Sprintf("Failed to generate resume token: %v", err),
+			},
+		})
+	}
+	result.ResumeToken = base64.StdEncoding.EncodeToString(data)
+
+	return mcp.NewToolResultStructuredOnly(result)
+}
+
+// parseMultiResumeToken parses a composite resume token. It must list the
+// given databases in the same order.
+func parseMultiResumeToken(token string, names []string) ([]databaseCursor, error) {
+	data, err := base64.StdEncoding.DecodeString(token)
+	if err != nil {
+		return nil, err
+	}
+
+	var parsed multiResumeToken
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return nil, err
+	}
+
+	tokenNames := make([]string, 0, len(parsed.Databases))
+	for _, cursor := range parsed.Databases {
+		tokenNames = append(tokenNames, cursor.Database)
+	}
+	if !slices.Equal(tokenNames, names) {
+		return nil, fmt.Errorf(
+			"token is for databases %s, not %s",
+			strings.Join(tokenNames, ", "),
+			strings.Join(names, ", "),
+		)
+	}
+
+	return parsed.Databases, nil
+}
+
+// scanNetworks parses the network or range parameter of lookup_network into
+// the networks to scan. A range is converted into the smallest set of
+// prefixes covering it. On failure, the returned result holds the error to
+// send to the client.
+func scanNetworks(request mcp.CallToolRequest) ([]netip.Prefix, *mcp.CallToolResult) {
+	networkStr := request.GetString("network", "")
+	rangeStr := request.GetString("range", "")
+
+	switch {
+	case networkStr == "" && rangeStr == "":
+		return nil, mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "missing_parameter",
+				"message": "Missing required parameter: network or range",
+			},
+		})
+	case networkStr != "" && rangeStr != "":
+		return nil, mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "invalid_parameter",
+				"message": "Specify either network or range, not both",
+			},
+		})
+	case rangeStr != "":
+		prefixes, err := parseRange(rangeStr)
+		if err != nil {
+			return nil, mcp.NewToolResultStructuredOnly(map[string]any{
+				"error": map[string]any{
+					"code":    "invalid_range",
+					"message": fmt.Sprintf("Invalid range %s: %v", rangeStr, err),
+				},
+			})
+		}
+		return prefixes, nil
+	default:
+		network, err := netip.ParsePrefix(networkStr)
+		if err != nil {
+			return nil, mcp.NewToolResultStructuredOnly(map[string]any{
+				"error": map[string]any{
+					"code":    "invalid_network",
+					"message": "Invalid network: " + networkStr,
+				},
+			})
+		}
+		return []netip.Prefix{network}, nil
+	}
+}
+
+// parseRange parses an address range of the form start-end into the
+// prefixes covering it.
+func parseRange(rangeStr string) ([]netip.Prefix, error) {
+	startStr, endStr, ok := strings.Cut(rangeStr, "-")
+	if !ok {
+		return nil, errors.New("expected start-end")
+	}
+	start, err := netip.ParseAddr(strings.TrimSpace(startStr))
+	if err != nil {
+		return nil, err
+	}
+	end, err := netip.ParseAddr(strings.TrimSpace(endStr))
+	if err != nil {
+		return nil, err
+	}
+	return iterator.RangePrefixes(start, end)
+}
+
+// boundingNetwork parses the optional network parameter used to bound a scan.
+// When it is omitted, the whole address space of the database is used. Scans
+// of the whole address space must be confirmed with confirm_full_scan. On
+// failure, the returned result holds the error to send to the client.
+func boundingNetwork(
+	request mcp.CallToolRequest,
+	reader *maxminddb.Reader,
+) (netip.Prefix, *mcp.CallToolResult) {
+	var network netip.Prefix
+	if networkStr := request.GetString("network", ""); networkStr != "" {
+		var err error
+		network, err = netip.ParsePrefix(networkStr)
+		if err != nil {
+			return netip.Prefix{}, mcp.NewToolResultStructuredOnly(map[string]any{
+				"error": map[string]any{
+					"code":    "invalid_network",
+					"message": "Invalid network: " + networkStr,
+				},
+			})
+		}
+	} else {
+		network = fullAddressSpace(reader)
+	}
+
+	if errResult := checkFullScan(request, network); errResult != nil {
+		return netip.Prefix{}, errResult
+	}
+
+	return network, nil
+}
+
+// resolveDatabaseList resolves an array of database selectors, such as the
+// database parameter of lookup_network, to database names. On failure, the
+// returned result holds the error to send to the client.
+func (s *Server) resolveDatabaseList(selectors []any) ([]string, *mcp.CallToolResult) {
+	if len(selectors) == 0 {
+		return nil, mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "invalid_parameter",
+				"message": "database must not be an empty array",
+			},
+		})
+	}
+
+	names := make([]string, 0, len(selectors))
+	for i, selector := range selectors {
+		selectorStr, ok := selector.(string)
+		if !ok || selectorStr == "" {
+			return nil, mcp.NewToolResultStructuredOnly(map[string]any{
+				"error": map[string]any{
+					"code":    "invalid_parameter",
+					"message": fmt.Sprintf("database[%d] must be a database name", i),
+				},
+			})
+		}
+		name, errResult := s.resolveDatabase(selectorStr)
+		if errResult != nil {
+			return nil, errResult
+		}
+		if slices.Contains(names, name) {
+			return nil, mcp.NewToolResultStructuredOnly(map[string]any{
+				"error": map[string]any{
+					"code":    "invalid_parameter",
+					"message": "Database listed more than once: " + name,
+				},
+			})
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// excludedNetworks returns the networks to skip in a scan: the configured
+// bogon networks, or the built-in list, if exclude_bogons is set.
+func (s *Server) excludedNetworks(request mcp.CallToolRequest) []netip.Prefix {
+	if !request.GetBool("exclude_bogons", false) {
+		return nil
+	}
+	if len(s.config.BogonNetworkPrefixes) > 0 {
+		return s.config.BogonNetworkPrefixes
+	}
+	return iterator.BogonNetworks()
+}
+
+// fullAddressSpace returns the prefix covering every network in the database.
+func fullAddressSpace(reader *maxminddb.Reader) netip.Prefix {
+	if reader.Metadata.IPVersion == 6 {
+		return netip.PrefixFrom(netip.IPv6Unspecified(), 0)
+	}
+	return netip.PrefixFrom(netip.IPv4Unspecified(), 0)
+}
+
+// checkFullScan guards against accidentally scanning the whole address space.
+// It returns an error result unless confirm_full_scan is set.
+func checkFullScan(request mcp.CallToolRequest, network netip.Prefix) *mcp.CallToolResult {
+	if network.Bits() != 0 || request.GetBool("confirm_full_scan", false) {
+		return nil
+	}
+	return mcp.NewToolResultStructuredOnly(map[string]any{
+		"error": map[string]any{
+			"code": "full_scan_not_confirmed",
+			"message": "Scanning the whole address space (" + network.String() +
+				") can be slow; pass a narrower network or set confirm_full_scan to true",
+		},
+	})
+}
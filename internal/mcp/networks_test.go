@@ -0,0 +1,1058 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/oschwald/maxminddb-mcp/internal/database"
+	"github.com/oschwald/maxminddb-mcp/internal/iterator"
+)
+
+func newTestServerWithCityDB(t *testing.T) *Server {
+	t.Helper()
+
+	dbManager, err := database.New()
+	if err != nil {
+		t.Fatalf("Failed to create database manager: %v", err)
+	}
+	t.Cleanup(func() { _ = dbManager.Close() })
+
+	if err := dbManager.LoadDatabase(testCityDB); err != nil {
+		t.Fatalf("Failed to load test database: %v", err)
+	}
+
+	iterMgr := iterator.New(30*time.Minute, 5*time.Minute)
+	t.Cleanup(iterMgr.StopCleanup)
+
+	return New(createTestMCPConfig(t), dbManager, nil, iterMgr, BuildInfo{})
+}
+
+func callTool(
+	t *testing.T,
+	handler func(context.Context, mcp.CallToolRequest) (*mcp.CallToolResult, error),
+	name string,
+	args map[string]any,
+) any {
+	t.Helper()
+
+	request := mcp.CallToolRequest{}
+	request.Params.Name = name
+	request.Params.Arguments = args
+
+	result, err := handler(context.Background(), request)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return result.StructuredContent
+}
+
+// errorCode returns the error code of a tool error result, or "" if the
+// result is not an error.
+func errorCode(structured any) string {
+	resultMap, _ := structured.(map[string]any)
+	errObj, _ := resultMap["error"].(map[string]any)
+	code, _ := errObj["code"].(string)
+	return code
+}
+
+// errorMessage returns the error message of a tool error result.
+func errorMessage(structured any) string {
+	resultMap, _ := structured.(map[string]any)
+	errObj, _ := resultMap["error"].(map[string]any)
+	message, _ := errObj["message"].(string)
+	return message
+}
+
+func TestCountryNetworks(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	structured := callTool(t, server.handleCountryNetworks, "country_networks", map[string]any{
+		"country_iso_code": "gb",
+		"database":         "GeoLite2-City-Test.mmdb",
+		"network":          "81.2.69.0/24",
+	})
+	result, ok := structured.(*iterator.IterationResult)
+	if !ok {
+		t.Fatalf("Expected iteration result, got %v", structured)
+	}
+	if len(result.Results) == 0 {
+		t.Fatal("Expected GB networks in 81.2.69.0/24")
+	}
+	for _, res := range result.Results {
+		country, _ := res.Data["country"].(map[string]any)
+		if country["iso_code"] != "GB" {
+			t.Errorf("Expected only GB networks, got %s: %v", res.Network, country["iso_code"])
+		}
+	}
+}
+
+func TestCountryNetworksFullScan(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	args := map[string]any{
+		"country_iso_code": "SE",
+		"database":         "GeoLite2-City-Test.mmdb",
+	}
+	structured := callTool(t, server.handleCountryNetworks, "country_networks", args)
+	if code := errorCode(structured); code != "full_scan_not_confirmed" {
+		t.Errorf("Expected full_scan_not_confirmed, got %v", structured)
+	}
+
+	args["network"] = "::/0"
+	structured = callTool(t, server.handleCountryNetworks, "country_networks", args)
+	if code := errorCode(structured); code != "full_scan_not_confirmed" {
+		t.Errorf("Expected full_scan_not_confirmed for ::/0, got %v", structured)
+	}
+
+	args["confirm_full_scan"] = true
+	structured = callTool(t, server.handleCountryNetworks, "country_networks", args)
+	result, ok := structured.(*iterator.IterationResult)
+	if !ok {
+		t.Fatalf("Expected iteration result, got %v", structured)
+	}
+	if len(result.Results) == 0 {
+		t.Error("Expected SE networks in a confirmed full scan")
+	}
+}
+
+func TestCountryNetworksErrors(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	tests := []struct {
+		args     map[string]any
+		name     string
+		expected string
+	}{
+		{
+			name:     "missing country",
+			args:     map[string]any{"database": "GeoLite2-City-Test.mmdb"},
+			expected: "missing_parameter",
+		},
+		{
+			name:     "missing database",
+			args:     map[string]any{"country_iso_code": "GB"},
+			expected: "missing_parameter",
+		},
+		{
+			name: "unknown database",
+			args: map[string]any{
+				"country_iso_code": "GB",
+				"database":         "nonexistent.mmdb",
+			},
+			expected: "db_not_found",
+		},
+		{
+			name: "invalid network",
+			args: map[string]any{
+				"country_iso_code": "GB",
+				"database":         "GeoLite2-City-Test.mmdb",
+				"network":          "not-a-network",
+			},
+			expected: "invalid_network",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			structured := callTool(t, server.handleCountryNetworks, "country_networks", test.args)
+			if code := errorCode(structured); code != test.expected {
+				t.Errorf("Expected %s, got %v", test.expected, structured)
+			}
+		})
+	}
+}
+
+func TestASNNetworks(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+	if err := server.dbManager.LoadDatabase("../../testdata/test-data/GeoLite2-ASN-Test.mmdb"); err != nil {
+		t.Fatalf("Failed to load database: %v", err)
+	}
+
+	// AS7018 announces 12.81.92.0/22 and 12.81.96.0/19 in the test database.
+	structured := callTool(t, server.handleASNNetworks, "asn_networks", map[string]any{
+		"asn":     7018.0,
+		"network": "12.0.0.0/8",
+	})
+	result, ok := structured.(*iterator.IterationResult)
+	if !ok {
+		t.Fatalf("Expected iteration result, got %v", structured)
+	}
+
+	found := make(map[string]bool)
+	for _, res := range result.Results {
+		found[res.Network.String()] = true
+		if asn := res.Data["autonomous_system_number"]; fmt.Sprint(asn) != "7018" {
+			t.Errorf("Expected only AS7018 networks, got %s: %v", res.Network, asn)
+		}
+	}
+	for _, network := range []string{"12.81.92.0/22", "12.81.96.0/19"} {
+		if !found[network] {
+			t.Errorf("Expected %s in results, got %v", network, found)
+		}
+	}
+	if result.HasMore {
+		t.Error("Expected all networks in one batch")
+	}
+
+	// Results are paginated like lookup_network.
+	structured = callTool(t, server.handleASNNetworks, "asn_networks", map[string]any{
+		"asn":         7018.0,
+		"database":    "GeoLite2-ASN-Test.mmdb",
+		"network":     "12.0.0.0/8",
+		"max_results": 1.0,
+	})
+	result, ok = structured.(*iterator.IterationResult)
+	if !ok {
+		t.Fatalf("Expected iteration result, got %v", structured)
+	}
+	if len(result.Results) != 1 || !result.HasMore || result.IteratorID == "" {
+		t.Errorf("Expected a first batch of one result with more to come, got %+v", result)
+	}
+}
+
+func TestASNNetworksErrors(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+	if err := server.dbManager.LoadDatabase("../../testdata/test-data/GeoLite2-ASN-Test.mmdb"); err != nil {
+		t.Fatalf("Failed to load database: %v", err)
+	}
+
+	tests := []struct {
+		args     map[string]any
+		name     string
+		expected string
+	}{
+		{
+			name:     "missing asn",
+			args:     map[string]any{"network": "12.0.0.0/8"},
+			expected: "missing_parameter",
+		},
+		{
+			name:     "negative asn",
+			args:     map[string]any{"asn": -1.0, "network": "12.0.0.0/8"},
+			expected: "invalid_parameter",
+		},
+		{
+			name:     "fractional asn",
+			args:     map[string]any{"asn": 7018.5, "network": "12.0.0.0/8"},
+			expected: "invalid_parameter",
+		},
+		{
+			name:     "unknown database",
+			args:     map[string]any{"asn": 7018.0, "database": "nonexistent.mmdb", "network": "12.0.0.0/8"},
+			expected: "db_not_found",
+		},
+		{
+			name:     "no database of type",
+			args:     map[string]any{"asn": 7018.0, "database": "type:ISP", "network": "12.0.0.0/8"},
+			expected: "db_not_found",
+		},
+		{
+			name:     "invalid network",
+			args:     map[string]any{"asn": 7018.0, "network": "not-a-network"},
+			expected: "invalid_network",
+		},
+		{
+			name:     "full scan",
+			args:     map[string]any{"asn": 7018.0},
+			expected: "full_scan_not_confirmed",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			structured := callTool(t, server.handleASNNetworks, "asn_networks", test.args)
+			if code := errorCode(structured); code != test.expected {
+				t.Errorf("Expected %s, got %v", test.expected, structured)
+			}
+		})
+	}
+}
+
+func TestLookupNetworkRejectsLongRegex(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+	server.config.MaxRegexLength = 8
+
+	structured := callTool(t, server.handleLookupNetwork, "lookup_network", map[string]any{
+		"network":  "81.2.69.0/24",
+		"database": "GeoLite2-City-Test.mmdb",
+		"filters": []any{
+			map[string]any{
+				"field":    "city.names.en",
+				"operator": "regex",
+				"value":    "^London|Paris$",
+			},
+		},
+	})
+	if code := errorCode(structured); code != "invalid_filter" {
+		t.Errorf("Expected invalid_filter for pattern over the configured limit, got %v", structured)
+	}
+	if message := errorMessage(structured); !strings.Contains(message, "exceeding the limit of 8") {
+		t.Errorf("Expected regex length error, got %q", message)
+	}
+}
+
+func TestLookupNetworkRejectsUnknownOperator(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	structured := callTool(t, server.handleLookupNetwork, "lookup_network", map[string]any{
+		"network":  "81.2.69.0/24",
+		"database": "GeoLite2-City-Test.mmdb",
+		"filters": []any{
+			map[string]any{
+				"field":    "country.iso_code",
+				"operator": "equls",
+				"value":    "GB",
+			},
+		},
+	})
+	if code := errorCode(structured); code != "invalid_filter" {
+		t.Fatalf("Expected invalid_filter for a misspelled operator, got %v", structured)
+	}
+	message := errorMessage(structured)
+	if !strings.Contains(message, "unknown operator 'equls'") {
+		t.Errorf("Expected unknown operator error, got %q", message)
+	}
+	if !strings.Contains(message, "equals, not_equals") {
+		t.Errorf("Expected error to list valid operators, got %q", message)
+	}
+
+	// No iterator is created for the rejected query.
+	if cleared := server.iterMgr.Clear(); cleared != 0 {
+		t.Errorf("Expected no iterators, got %d", cleared)
+	}
+}
+
+func TestClearIterators(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	for _, network := range []string{"81.2.69.0/24", "2.125.160.0/24", "89.160.20.0/24"} {
+		structured := callTool(t, server.handleLookupNetwork, "lookup_network", map[string]any{
+			"network":     network,
+			"database":    "GeoLite2-City-Test.mmdb",
+			"max_results": 1,
+		})
+		if _, ok := structured.(*iterator.IterationResult); !ok {
+			t.Fatalf("Expected iteration result, got %v", structured)
+		}
+	}
+
+	structured := callTool(t, server.handleClearIterators, "clear_iterators", nil)
+	if cleared := structured.(map[string]any)["cleared"]; cleared != 3 {
+		t.Errorf("Expected 3 iterators cleared, got %v", cleared)
+	}
+	if cleared := server.iterMgr.Clear(); cleared != 0 {
+		t.Errorf("Expected no iterators left, got %d", cleared)
+	}
+}
+
+func TestCancelIteration(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	first, ok := callTool(t, server.handleLookupNetwork, "lookup_network", map[string]any{
+		"network":     "81.2.69.0/24",
+		"database":    "GeoLite2-City-Test.mmdb",
+		"max_results": 1,
+	}).(*iterator.IterationResult)
+	if !ok || first.Canceled {
+		t.Fatalf("Expected an uncanceled first batch, got %v", first)
+	}
+
+	// No batch is running, so there is nothing to cancel.
+	structured := callTool(t, server.handleCancelIteration, "cancel_iteration", map[string]any{
+		"iterator_id": first.IteratorID,
+	})
+	result, _ := structured.(map[string]any)
+	if result["iterator_id"] != first.IteratorID || result["canceled"] != false {
+		t.Errorf("Expected canceled to be false, got %v", structured)
+	}
+
+	structured = callTool(t, server.handleCancelIteration, "cancel_iteration", map[string]any{
+		"iterator_id": "unknown",
+	})
+	if code := errorCode(structured); code != "iterator_not_found" {
+		t.Errorf("Expected iterator_not_found, got %v", structured)
+	}
+
+	structured = callTool(t, server.handleCancelIteration, "cancel_iteration", map[string]any{})
+	if code := errorCode(structured); code != "missing_parameter" {
+		t.Errorf("Expected missing_parameter, got %v", structured)
+	}
+}
+
+func TestLookupNetworkResumeAfterExpiry(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	args := map[string]any{
+		"network":     "81.2.69.0/24",
+		"database":    "GeoLite2-City-Test.mmdb",
+		"max_results": 1,
+	}
+	first, ok := callTool(t, server.handleLookupNetwork, "lookup_network", args).(*iterator.IterationResult)
+	if !ok || !first.HasMore {
+		t.Fatalf("Expected a first batch with more results, got %v", first)
+	}
+
+	// Expire the iterator.
+	expired, _ := server.iterMgr.GetIterator(first.IteratorID)
+	expired.LastAccess = time.Now().Add(-time.Hour)
+	server.iterMgr.CleanupNow()
+
+	// The scan resumes from the token with a new iterator.
+	args["iterator_id"] = first.IteratorID
+	args["resume_token"] = first.ResumeToken
+	resumed, ok := callTool(t, server.handleLookupNetwork, "lookup_network", args).(*iterator.IterationResult)
+	if !ok || !resumed.HasMore {
+		t.Fatalf("Expected a resumed batch with more results, got %v", resumed)
+	}
+	if resumed.IteratorID == first.IteratorID {
+		t.Fatal("Expected a new iterator ID after resuming from the token")
+	}
+	if _, exists := server.iterMgr.GetIterator(first.IteratorID); exists {
+		t.Error("Expected the expired iterator ID to stay unknown")
+	}
+
+	// The new ID is immediately usable on the fast path, without the token.
+	delete(args, "resume_token")
+	args["iterator_id"] = resumed.IteratorID
+	next, ok := callTool(t, server.handleLookupNetwork, "lookup_network", args).(*iterator.IterationResult)
+	if !ok {
+		t.Fatalf("Expected iteration result, got %v", next)
+	}
+	if next.IteratorID != resumed.IteratorID {
+		t.Errorf("Expected the resumed iterator %s to be reused, got %s", resumed.IteratorID, next.IteratorID)
+	}
+	if len(next.Results) == 0 {
+		t.Fatal("Expected the remaining results")
+	}
+	last := resumed.Results[len(resumed.Results)-1].Network
+	if !last.Addr().Less(next.Results[0].Network.Addr()) {
+		t.Errorf("Expected the scan to continue after %s, got %s", last, next.Results[0].Network)
+	}
+}
+
+func TestLookupNetworkResumeSource(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	args := map[string]any{
+		"network":     "81.2.69.0/24",
+		"database":    "GeoLite2-City-Test.mmdb",
+		"max_results": 1,
+	}
+	next := func(expected string) *iterator.IterationResult {
+		t.Helper()
+		result, ok := callTool(t, server.handleLookupNetwork, "lookup_network", args).(*iterator.IterationResult)
+		if !ok {
+			t.Fatalf("Expected iteration result, got %v", result)
+		}
+		if result.ResumeSource != expected {
+			t.Errorf("Expected resume_source %s, got %s", expected, result.ResumeSource)
+		}
+		return result
+	}
+
+	first := next("fresh")
+
+	args["iterator_id"] = first.IteratorID
+	args["resume_token"] = first.ResumeToken
+	second := next("iterator_id")
+
+	// With the iterator gone, the token is used.
+	server.iterMgr.Clear()
+	args["iterator_id"] = second.IteratorID
+	args["resume_token"] = second.ResumeToken
+	next("resume_token")
+
+	// An unknown iterator without a token starts over.
+	delete(args, "resume_token")
+	args["iterator_id"] = "unknown"
+	next("fresh")
+}
+
+func TestLookupNetworkSortByConfidence(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+	const enterpriseDB = "GeoIP2-Enterprise-Test.mmdb"
+	if err := server.dbManager.LoadDatabase("../../testdata/test-data/" + enterpriseDB); err != nil {
+		t.Fatalf("Failed to load database: %v", err)
+	}
+
+	scan := func(args map[string]any) []iterator.NetworkResult {
+		t.Helper()
+		args["network"] = "0.0.0.0/0"
+		args["database"] = enterpriseDB
+		args["confirm_full_scan"] = true
+		args["max_results"] = 100000
+		result, ok := callTool(t, server.handleLookupNetwork, "lookup_network", args).(*iterator.IterationResult)
+		if !ok {
+			t.Fatalf("Expected iteration result, got %v", result)
+		}
+		if result.HasMore {
+			t.Fatal("Expected the scan to fit in one batch")
+		}
+		return result.Results
+	}
+
+	unsorted := scan(map[string]any{})
+
+	for _, field := range []string{"", "city.confidence"} {
+		name := field
+		if name == "" {
+			name = defaultConfidenceField
+		}
+		t.Run(name, func(t *testing.T) {
+			args := map[string]any{"sort_by_confidence": true}
+			if field != "" {
+				args["confidence_field"] = field
+			}
+			sorted := scan(args)
+			if len(sorted) != len(unsorted) {
+				t.Fatalf("Expected %d networks, got %d", len(unsorted), len(sorted))
+			}
+
+			seen := make(map[netip.Prefix]bool, len(sorted))
+			scored := true
+			last := 0.0
+			for i, result := range sorted {
+				seen[result.Network] = true
+				score, ok := confidenceScore(result.Data, name)
+				switch {
+				case ok && !scored:
+					t.Errorf("Expected %s (score %v) before networks without a score", result.Network, score)
+				case ok && i > 0 && score > last:
+					t.Errorf("Expected %s (score %v) not to follow a lower score %v", result.Network, score, last)
+				}
+				if ok {
+					last = score
+				}
+				scored = scored && ok
+			}
+			for _, result := range unsorted {
+				if !seen[result.Network] {
+					t.Errorf("Expected %s in sorted results", result.Network)
+				}
+			}
+		})
+	}
+
+	// 74.209.24.0 has a country confidence of 99.
+	for _, result := range scan(map[string]any{"sort_by_confidence": true}) {
+		if result.Network.Contains(netip.MustParseAddr("74.209.24.0")) {
+			if score, _ := confidenceScore(result.Data, defaultConfidenceField); score != 99 {
+				t.Errorf("Expected country confidence 99 for %s, got %v", result.Network, score)
+			}
+		}
+	}
+}
+
+func TestSortByConfidence(t *testing.T) {
+	record := func(network string, data map[string]any) iterator.NetworkResult {
+		return iterator.NetworkResult{Network: netip.MustParsePrefix(network), Data: data}
+	}
+	results := []iterator.NetworkResult{
+		record("1.0.0.0/24", map[string]any{}),
+		record("2.0.0.0/24", map[string]any{"country": map[string]any{"confidence": uint16(50)}}),
+		record("3.0.0.0/24", map[string]any{"country": map[string]any{"confidence": "high"}}),
+		record("4.0.0.0/24", map[string]any{"country": map[string]any{
+			"confidence": iterator.TypedNumber{Value: uint16(90), Type: "uint16"},
+		}}),
+		record("5.0.0.0/24", map[string]any{"country": map[string]any{"confidence": uint16(50)}}),
+	}
+
+	sortByConfidence(results, defaultConfidenceField)
+
+	// Equal scores and records without a numeric score keep their order.
+	want := []string{"4.0.0.0/24", "2.0.0.0/24", "5.0.0.0/24", "1.0.0.0/24", "3.0.0.0/24"}
+	for i, result := range results {
+		if result.Network.String() != want[i] {
+			t.Errorf("Expected %s at %d, got %s", want[i], i, result.Network)
+		}
+	}
+}
+
+func TestLookupNetworkDedupeRecords(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	scan := func(args map[string]any) *iterator.IterationResult {
+		t.Helper()
+		args["network"] = "81.2.69.0/24"
+		args["database"] = "GeoLite2-City-Test.mmdb"
+		result, ok := callTool(t, server.handleLookupNetwork, "lookup_network", args).(*iterator.IterationResult)
+		if !ok {
+			t.Fatalf("Expected iteration result, got %v", result)
+		}
+		return result
+	}
+
+	plain := scan(map[string]any{})
+	deduped := scan(map[string]any{"dedupe_records": true})
+	if len(deduped.Results) != len(plain.Results) {
+		t.Fatalf("Expected %d networks, got %d", len(plain.Results), len(deduped.Results))
+	}
+	if len(deduped.Records) == 0 || len(deduped.Records) > len(deduped.Results) {
+		t.Errorf("Expected between 1 and %d records, got %d", len(deduped.Results), len(deduped.Records))
+	}
+	for i, result := range deduped.Results {
+		if result.Network != plain.Results[i].Network {
+			t.Errorf("Expected %s at %d, got %s", plain.Results[i].Network, i, result.Network)
+		}
+		if result.Record == nil || *result.Record >= len(deduped.Records) {
+			t.Fatalf("Expected a record index for %s, got %v", result.Network, result.Record)
+		}
+		if city, _ := lookupPath(deduped.Records[*result.Record], "city.names.en"); city != "London" {
+			t.Errorf("Expected London for %s, got %v", result.Network, city)
+		}
+	}
+
+	structured := callTool(t, server.handleLookupNetwork, "lookup_network", map[string]any{
+		"network":        "81.2.69.0/24",
+		"dedupe_records": true,
+		"format":         "geojson",
+	})
+	if code := errorCode(structured); code != "invalid_parameter" {
+		t.Errorf("Expected invalid_parameter error, got %v", structured)
+	}
+}
+
+func TestLookupNetworkStrictPaths(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	scan := func(field string, args map[string]any) any {
+		t.Helper()
+		args["network"] = "81.2.69.0/24"
+		args["filters"] = []any{map[string]any{"field": field, "operator": "exists", "value": true}}
+		return callTool(t, server.handleLookupNetwork, "lookup_network", args)
+	}
+
+	// By default, a path past a scalar silently matches nothing.
+	result, ok := scan("country.iso_code.foo", map[string]any{}).(*iterator.IterationResult)
+	if !ok || len(result.Results) != 0 {
+		t.Errorf("Expected no matches, got %v", result)
+	}
+
+	structured := scan("country.iso_code.foo", map[string]any{"strict_paths": true})
+	if code := errorCode(structured); code != "invalid_filter" {
+		t.Fatalf("Expected invalid_filter error, got %v", structured)
+	}
+	if msg := errorMessage(structured); !strings.Contains(msg, "country.iso_code is a string, not a map") {
+		t.Errorf("Expected message naming the string field, got %q", msg)
+	}
+
+	if _, ok := scan("country.iso_code", map[string]any{"strict_paths": true}).(*iterator.IterationResult); !ok {
+		t.Error("Expected a valid path to be accepted in strict mode")
+	}
+
+	server.config.StrictFilterPaths = true
+	if code := errorCode(scan("country.iso_code.foo", map[string]any{})); code != "invalid_filter" {
+		t.Error("Expected strict_filter_paths to enable strict mode")
+	}
+	if _, ok := scan("country.iso_code.foo", map[string]any{"strict_paths": false}).(*iterator.IterationResult); !ok {
+		t.Error("Expected strict_paths to override strict_filter_paths")
+	}
+}
+
+func TestLookupNetworkDefaultMaxResults(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+	server.config.DefaultMaxResults = 2
+
+	args := map[string]any{
+		"network":  "81.2.69.0/24",
+		"database": "GeoLite2-City-Test.mmdb",
+	}
+	result, ok := callTool(t, server.handleLookupNetwork, "lookup_network", args).(*iterator.IterationResult)
+	if !ok {
+		t.Fatalf("Expected iteration result, got %v", result)
+	}
+	if len(result.Results) != 2 || !result.HasMore {
+		t.Errorf("Expected the configured default of 2 results with more left, got %d", len(result.Results))
+	}
+
+	// An explicit max_results overrides the configured default.
+	args["max_results"] = 3
+	result, ok = callTool(t, server.handleLookupNetwork, "lookup_network", args).(*iterator.IterationResult)
+	if !ok {
+		t.Fatalf("Expected iteration result, got %v", result)
+	}
+	if len(result.Results) != 3 {
+		t.Errorf("Expected 3 results, got %d", len(result.Results))
+	}
+}
+
+func TestLookupNetworkBatchBudget(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+	server.config.MaxBatchesPerIterator = 1
+	server.iterMgr.SetMaxBatches(1)
+
+	args := map[string]any{
+		"network":     "81.2.69.0/24",
+		"database":    "GeoLite2-City-Test.mmdb",
+		"max_results": 1,
+	}
+	first, ok := callTool(t, server.handleLookupNetwork, "lookup_network", args).(*iterator.IterationResult)
+	if !ok || !first.HasMore {
+		t.Fatalf("Expected a first batch with more results, got %v", first)
+	}
+
+	args["iterator_id"] = first.IteratorID
+	structured := callTool(t, server.handleLookupNetwork, "lookup_network", args)
+	if code := errorCode(structured); code != "iterator_exhausted_budget" {
+		t.Fatalf("Expected iterator_exhausted_budget, got %v", structured)
+	}
+	if _, exists := server.iterMgr.GetIterator(first.IteratorID); exists {
+		t.Error("Expected the iterator to be closed")
+	}
+
+	// The resume token carries the batch count, so it cannot restart the
+	// budget.
+	delete(args, "iterator_id")
+	args["resume_token"] = first.ResumeToken
+	structured = callTool(t, server.handleLookupNetwork, "lookup_network", args)
+	if code := errorCode(structured); code != "iterator_exhausted_budget" {
+		t.Errorf("Expected iterator_exhausted_budget after resuming, got %v", structured)
+	}
+}
+
+func TestLookupNetworkNumericEquals(t *testing.T) {
+	server := newTestServerWithASNDB(t)
+
+	// Filter values arrive from JSON, so numbers are float64 while the
+	// database decodes the ASN as an unsigned integer.
+	for _, value := range []any{float64(7018), 7018} {
+		structured := callTool(t, server.handleLookupNetwork, "lookup_network", map[string]any{
+			"network":  "12.0.0.0/8",
+			"database": "GeoLite2-ASN-Test.mmdb",
+			"filters": []any{
+				map[string]any{"field": "autonomous_system_number", "operator": "equals", "value": value},
+			},
+		})
+		result, ok := structured.(*iterator.IterationResult)
+		if !ok {
+			t.Fatalf("Unexpected result for %T: %v", value, structured)
+		}
+		if len(result.Results) == 0 {
+			t.Fatalf("Expected %T 7018 to match AS7018 networks", value)
+		}
+		for _, network := range result.Results {
+			if asn := network.Data["autonomous_system_number"]; asn != uint64(7018) {
+				t.Errorf("Expected only AS7018 networks, got %s with %v", network.Network, asn)
+			}
+		}
+	}
+
+	// A string does not equal a number.
+	structured := callTool(t, server.handleLookupNetwork, "lookup_network", map[string]any{
+		"network":  "12.0.0.0/8",
+		"database": "GeoLite2-ASN-Test.mmdb",
+		"filters": []any{
+			map[string]any{"field": "autonomous_system_number", "operator": "equals", "value": "7018"},
+		},
+	})
+	if result, ok := structured.(*iterator.IterationResult); !ok || len(result.Results) != 0 {
+		t.Errorf("Expected no matches for a string ASN, got %v", structured)
+	}
+}
+
+func TestLookupNetworkMaxPrefixLength(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	structured := callTool(t, server.handleLookupNetwork, "lookup_network", map[string]any{
+		"network":           "81.2.69.0/24",
+		"database":          "GeoLite2-City-Test.mmdb",
+		"max_prefix_length": 26,
+	})
+	result, ok := structured.(*iterator.IterationResult)
+	if !ok {
+		t.Fatalf("Expected iteration result, got %v", structured)
+	}
+	if len(result.Results) == 0 {
+		t.Fatal("Expected results")
+	}
+	for _, r := range result.Results {
+		if r.Network.Bits() > 26 {
+			t.Errorf("Network %s is longer than /26", r.Network)
+		}
+	}
+
+	structured = callTool(t, server.handleLookupNetwork, "lookup_network", map[string]any{
+		"network":           "81.2.69.0/24",
+		"database":          "GeoLite2-City-Test.mmdb",
+		"max_prefix_length": 129,
+	})
+	if code := errorCode(structured); code != "invalid_parameter" {
+		t.Errorf("Expected invalid_parameter error, got %q", code)
+	}
+}
+
+func TestLookupNetworkExcludeBogons(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	count := func(args map[string]any) int {
+		t.Helper()
+		structured := callTool(t, server.handleLookupNetwork, "lookup_network", args)
+		result, ok := structured.(*iterator.IterationResult)
+		if !ok {
+			t.Fatalf("Expected iteration result, got %v", structured)
+		}
+		return len(result.Results)
+	}
+
+	args := map[string]any{
+		"network":        "81.2.69.0/24",
+		"database":       "GeoLite2-City-Test.mmdb",
+		"exclude_bogons": true,
+	}
+	// The built-in list leaves public networks alone.
+	if got := count(args); got != 4 {
+		t.Errorf("Expected 4 networks, got %d", got)
+	}
+
+	// A configured list replaces the built-in one.
+	server.config.BogonNetworkPrefixes = []netip.Prefix{netip.MustParsePrefix("81.2.69.128/25")}
+	if got := count(args); got != 0 {
+		t.Errorf("Expected the configured bogon networks to be excluded, got %d networks", got)
+	}
+	args["exclude_bogons"] = false
+	if got := count(args); got != 4 {
+		t.Errorf("Expected 4 networks without exclude_bogons, got %d", got)
+	}
+}
+
+func TestLookupNetworkDefaultFilterMode(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+	server.config.DefaultFilterMode = "or"
+
+	// No record is in both countries, so only "or" matches anything.
+	args := func(filterMode string) map[string]any {
+		args := map[string]any{
+			"network":  "::/0",
+			"database": "GeoLite2-City-Test.mmdb",
+			"filters": []any{
+				map[string]any{"field": "country.iso_code", "operator": "equals", "value": "GB"},
+				map[string]any{"field": "country.iso_code", "operator": "equals", "value": "SE"},
+			},
+		}
+		if filterMode != "" {
+			args["filter_mode"] = filterMode
+		}
+		return args
+	}
+
+	structured := callTool(t, server.handleLookupNetwork, "lookup_network", args(""))
+	result, ok := structured.(*iterator.IterationResult)
+	if !ok {
+		t.Fatalf("Expected iteration result, got %v", structured)
+	}
+	if len(result.Results) == 0 {
+		t.Error("Expected the configured or mode to match records")
+	}
+
+	structured = callTool(t, server.handleLookupNetwork, "lookup_network", args("and"))
+	result, ok = structured.(*iterator.IterationResult)
+	if !ok {
+		t.Fatalf("Expected iteration result, got %v", structured)
+	}
+	if len(result.Results) != 0 {
+		t.Errorf("Expected the and override to match nothing, got %d results", len(result.Results))
+	}
+}
+
+func TestLookupNetworkRange(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	structured := callTool(t, server.handleLookupNetwork, "lookup_network", map[string]any{
+		"range":    "81.2.69.143 - 81.2.69.190",
+		"database": "GeoLite2-City-Test.mmdb",
+	})
+	result, ok := structured.(*iterator.IterationResult)
+	if !ok {
+		t.Fatalf("Expected iteration result, got %v", structured)
+	}
+	if len(result.Results) == 0 {
+		t.Fatal("Expected results for the range")
+	}
+	for _, r := range result.Results {
+		if !r.Network.Overlaps(netip.MustParsePrefix("81.2.69.128/26")) {
+			t.Errorf("Network %s is outside the range", r.Network)
+		}
+	}
+
+	tests := []struct {
+		name string
+		args map[string]any
+		code string
+	}{
+		{
+			name: "missing network and range",
+			args: map[string]any{},
+			code: "missing_parameter",
+		},
+		{
+			name: "network and range",
+			args: map[string]any{"network": "81.2.69.0/24", "range": "81.2.69.1-81.2.69.2"},
+			code: "invalid_parameter",
+		},
+		{
+			name: "start after end",
+			args: map[string]any{"range": "81.2.69.2-81.2.69.1"},
+			code: "invalid_range",
+		},
+		{
+			name: "mixed address families",
+			args: map[string]any{"range": "81.2.69.1-2001:db8::1"},
+			code: "invalid_range",
+		},
+		{
+			name: "not a range",
+			args: map[string]any{"range": "81.2.69.1"},
+			code: "invalid_range",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			structured := callTool(t, server.handleLookupNetwork, "lookup_network", tt.args)
+			if code := errorCode(structured); code != tt.code {
+				t.Errorf("Expected %s error, got %q", tt.code, code)
+			}
+		})
+	}
+}
+
+func TestLookupNetworkMultipleDatabases(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+	if err := server.dbManager.LoadDatabase("../../testdata/test-data/GeoLite2-Country-Test.mmdb"); err != nil {
+		t.Fatalf("Failed to load test database: %v", err)
+	}
+	databases := []any{"GeoLite2-City-Test.mmdb", "type:Country"}
+
+	// Scanning each database on its own gives the expected totals.
+	expected := map[string]int64{}
+	for _, name := range []string{"GeoLite2-City-Test.mmdb", "GeoLite2-Country-Test.mmdb"} {
+		structured := callTool(t, server.handleLookupNetwork, "lookup_network", map[string]any{
+			"network":  "81.2.69.0/24",
+			"database": name,
+		})
+		single, ok := structured.(*iterator.IterationResult)
+		if !ok {
+			t.Fatalf("Expected iteration result, got %v", structured)
+		}
+		expected[name] = single.TotalMatched
+	}
+	if expected["GeoLite2-City-Test.mmdb"] == 0 || expected["GeoLite2-Country-Test.mmdb"] == 0 {
+		t.Fatalf("Expected matches in both databases, got %v", expected)
+	}
+
+	structured := callTool(t, server.handleLookupNetwork, "lookup_network", map[string]any{
+		"network":  "81.2.69.0/24",
+		"database": databases,
+	})
+	result, ok := structured.(multiNetworkResult)
+	if !ok {
+		t.Fatalf("Expected multi-database result, got %v", structured)
+	}
+	if result.HasMore {
+		t.Error("Expected both databases to be finished")
+	}
+	for name, matched := range expected {
+		batch := result.Databases[name]
+		if batch == nil {
+			t.Fatalf("Expected results for %s, got %v", name, result.Databases)
+		}
+		if int64(len(batch.Results)) != matched {
+			t.Errorf("Expected %d results for %s, got %d", matched, name, len(batch.Results))
+		}
+	}
+	if result.TotalMatched != expected["GeoLite2-City-Test.mmdb"]+expected["GeoLite2-Country-Test.mmdb"] {
+		t.Errorf("Expected combined total matched, got %d", result.TotalMatched)
+	}
+
+	// Page through both databases with the composite resume token.
+	args := map[string]any{
+		"network":     "81.2.69.0/24",
+		"database":    databases,
+		"max_results": 1,
+	}
+	for range 10 {
+		structured = callTool(t, server.handleLookupNetwork, "lookup_network", args)
+		result, ok = structured.(multiNetworkResult)
+		if !ok {
+			t.Fatalf("Expected multi-database result, got %v", structured)
+		}
+		for name, batch := range result.Databases {
+			if len(batch.Results) > 1 {
+				t.Errorf("Expected at most 1 result for %s, got %d", name, len(batch.Results))
+			}
+		}
+		if !result.HasMore {
+			break
+		}
+		args["resume_token"] = result.ResumeToken
+	}
+	if result.HasMore {
+		t.Fatal("Expected paging to finish")
+	}
+
+	// A token for other databases is rejected.
+	structured = callTool(t, server.handleLookupNetwork, "lookup_network", map[string]any{
+		"network":      "81.2.69.0/24",
+		"database":     []any{"GeoLite2-City-Test.mmdb"},
+		"resume_token": result.ResumeToken,
+	})
+	if code := errorCode(structured); code != "resume_failed" {
+		t.Errorf("Expected resume_failed for mismatched databases, got %v", structured)
+	}
+}
+
+func TestLookupNetworkMultipleDatabasesErrors(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	tests := []struct {
+		name string
+		args map[string]any
+		code string
+	}{
+		{
+			name: "empty array",
+			args: map[string]any{"network": "81.2.69.0/24", "database": []any{}},
+			code: "invalid_parameter",
+		},
+		{
+			name: "non-string entry",
+			args: map[string]any{"network": "81.2.69.0/24", "database": []any{1}},
+			code: "invalid_parameter",
+		},
+		{
+			name: "duplicate",
+			args: map[string]any{
+				"network":  "81.2.69.0/24",
+				"database": []any{"GeoLite2-City-Test.mmdb", "type:City"},
+			},
+			code: "invalid_parameter",
+		},
+		{
+			name: "unknown database",
+			args: map[string]any{"network": "81.2.69.0/24", "database": []any{"missing.mmdb"}},
+			code: "db_not_found",
+		},
+		{
+			name: "geojson",
+			args: map[string]any{
+				"network":  "81.2.69.0/24",
+				"database": []any{"GeoLite2-City-Test.mmdb"},
+				"format":   "geojson",
+			},
+			code: "invalid_parameter",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			structured := callTool(t, server.handleLookupNetwork, "lookup_network", test.args)
+			if code := errorCode(structured); code != test.code {
+				t.Errorf("Expected error code %s, got %v", test.code, structured)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package mcp
+
+import (
+	"context"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/oschwald/maxminddb-mcp/internal/config"
+	"github.com/oschwald/maxminddb-mcp/internal/filter"
+)
+
+// normalizedFilters is the result of normalize_filters.
+type normalizedFilters struct {
+	FilterMode string          `json:"filter_mode"`
+	Filters    []filter.Filter `json:"filters"`
+}
+
+// handleNormalizeFilters handles the normalize_filters tool. It parses and
+// validates filters the same way lookup_network does and returns them with
+// canonical operator names, without querying any database.
+func (s *Server) handleNormalizeFilters(
+	_ context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	if _, exists := request.GetArguments()["filters"]; !exists {
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "missing_parameter",
+				"message": "Missing required parameter: filters",
+			},
+		}), nil
+	}
+
+	filters, errResult := s.requestFilters(request)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	defaultFilterMode := s.config.DefaultFilterMode
+	if defaultFilterMode == "" {
+		defaultFilterMode = config.FilterModeAnd
+	}
+	// Iterators treat any mode other than "or" as "and".
+	filterMode := config.FilterModeAnd
+	if strings.EqualFold(request.GetString("filter_mode", defaultFilterMode), config.FilterModeOr) {
+		filterMode = config.FilterModeOr
+	}
+
+	return mcp.NewToolResultStructuredOnly(normalizedFilters{
+		FilterMode: filterMode,
+		Filters:    filters,
+	}), nil
+}
@@ -0,0 +1,126 @@
+package mcp
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/oschwald/maxminddb-mcp/internal/filter"
+)
+
+func TestNormalizeFilters(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	structured := callTool(t, server.handleNormalizeFilters, "normalize_filters", map[string]any{
+		"filters": []any{
+			map[string]any{"field": "location.accuracy_radius", "operator": "gte", "value": float64(100)},
+			map[string]any{"field": "country.iso_code", "operator": "EQ", "value": "GB"},
+			map[string]any{"field": "city.names.en", "operator": "Regex", "value": "^Lon"},
+		},
+		"filter_mode": "OR",
+	})
+	result, ok := structured.(normalizedFilters)
+	if !ok {
+		t.Fatalf("Unexpected result: %v", structured)
+	}
+
+	expected := []filter.Filter{
+		{Field: "location.accuracy_radius", Operator: "greater_than_or_equal", Value: float64(100)},
+		{Field: "country.iso_code", Operator: "equals", Value: "GB"},
+		{Field: "city.names.en", Operator: "regex", Value: "^Lon"},
+	}
+	if len(result.Filters) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, result.Filters)
+	}
+	for i, f := range result.Filters {
+		if f != expected[i] {
+			t.Errorf("Expected filter %d to be %v, got %v", i, expected[i], f)
+		}
+	}
+	if result.FilterMode != "or" {
+		t.Errorf("Expected filter mode or, got %s", result.FilterMode)
+	}
+
+	// The filter mode defaults to the configured one.
+	structured = callTool(t, server.handleNormalizeFilters, "normalize_filters", map[string]any{
+		"filters": []any{},
+	})
+	if result, _ := structured.(normalizedFilters); result.FilterMode != "and" || len(result.Filters) != 0 {
+		t.Errorf("Expected no filters in and mode, got %v", structured)
+	}
+}
+
+func TestNormalizeFiltersErrors(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	tests := []struct {
+		args    map[string]any
+		name    string
+		code    string
+		message string
+	}{
+		{
+			name: "missing filters",
+			args: map[string]any{},
+			code: "missing_parameter",
+		},
+		{
+			name:    "unknown operator",
+			args:    map[string]any{"filters": []any{map[string]any{"field": "a", "operator": "like", "value": "b"}}},
+			code:    "invalid_filter",
+			message: "unknown operator 'like'",
+		},
+		{
+			name:    "invalid value",
+			args:    map[string]any{"filters": []any{map[string]any{"field": "a", "operator": "in", "value": "b"}}},
+			code:    "invalid_filter",
+			message: "filter 0",
+		},
+		{
+			name: "null filter",
+			args: map[string]any{
+				"filters": []any{map[string]any{"field": "a", "operator": "eq", "value": "b"}, nil},
+			},
+			code:    "invalid_filter",
+			message: "filters[1] is null",
+		},
+		{
+			name:    "string filter",
+			args:    map[string]any{"filters": []any{"country.iso_code=GB"}},
+			code:    "invalid_filter",
+			message: "got string",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			structured := callTool(t, server.handleNormalizeFilters, "normalize_filters", test.args)
+			if code := errorCode(structured); code != test.code {
+				t.Fatalf("Expected %s, got %v", test.code, structured)
+			}
+			if !strings.Contains(errorMessage(structured), test.message) {
+				t.Errorf("Expected message containing %q, got %q", test.message, errorMessage(structured))
+			}
+		})
+	}
+}
+
+func TestNormalizeFiltersSkipNull(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+	server.config.SkipNullFilters = true
+
+	structured := callTool(t, server.handleNormalizeFilters, "normalize_filters", map[string]any{
+		"filters": []any{
+			nil,
+			map[string]any{"field": "country.iso_code", "operator": "eq", "value": "GB"},
+			nil,
+		},
+	})
+	result, ok := structured.(normalizedFilters)
+	if !ok {
+		t.Fatalf("Unexpected result: %v", structured)
+	}
+	expected := filter.Filter{Field: "country.iso_code", Operator: "equals", Value: "GB"}
+	if len(result.Filters) != 1 || result.Filters[0] != expected {
+		t.Errorf("Expected only %v, got %v", expected, result.Filters)
+	}
+}
@@ -0,0 +1,206 @@
+package mcp
+
+import (
+	"context"
+	"slices"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/oschwald/maxminddb-mcp/internal/config"
+)
+
+// databaseSelection describes the databases consulted by a lookup_ip or
+// lookup_network request and why they were chosen.
+type databaseSelection struct {
+	Reason    string
+	Databases []string
+	// grouped reports whether results are grouped by database.
+	grouped bool
+}
+
+// lookupIPDatabases returns the databases consulted by a lookup_ip request.
+// On failure, the returned result holds the error to send to the client.
+func (s *Server) lookupIPDatabases(request mcp.CallToolRequest) (databaseSelection, *mcp.CallToolResult) {
+	scope := request.GetString("scope", s.config.LookupIPScope)
+	if scope != "" && scope != config.LookupScopeAll && scope != config.LookupScopeDefault {
+		return databaseSelection{}, mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "invalid_parameter",
+				"message": "Invalid scope: " + scope + " (must be all or default)",
+			},
+		})
+	}
+
+	if dbName := request.GetString("database", ""); dbName != "" {
+		resolved, errResult := s.resolveDatabase(dbName)
+		if errResult != nil {
+			return databaseSelection{}, errResult
+		}
+		return databaseSelection{
+			Databases: []string{resolved},
+			Reason:    "The database parameter selects a single database.",
+		}, nil
+	}
+
+	if scope == config.LookupScopeDefault {
+		if s.config.DefaultDatabase == "" {
+			return databaseSelection{}, mcp.NewToolResultStructuredOnly(map[string]any{
+				"error": map[string]any{
+					"code":    "invalid_parameter",
+					"message": "The default scope requires the default_database config setting",
+				},
+			})
+		}
+		resolved, errResult := s.resolveDatabase(s.config.DefaultDatabase)
+		if errResult != nil {
+			return databaseSelection{}, errResult
+		}
+		return databaseSelection{
+			Databases: []string{resolved},
+			Reason: "Without a database parameter, lookup_ip in the default scope queries only " +
+				"the default_database. Pass scope 'all' to query every loaded database.",
+		}, nil
+	}
+
+	names := s.databaseNames()
+	if len(names) == 1 && request.GetBool("collapse_single_database", s.config.CollapseSingleDatabase) {
+		return databaseSelection{
+			Databases: names,
+			Reason: "Without a database parameter, lookup_ip queries every loaded database. Only one is " +
+				"loaded and collapse_single_database is set, so its result is returned as if it had been " +
+				"passed as the database parameter.",
+		}, nil
+	}
+
+	return databaseSelection{
+		Databases: names,
+		Reason:    "Without a database parameter, lookup_ip queries every loaded database.",
+		grouped:   true,
+	}, nil
+}
+
+// lookupNetworkDatabases returns the databases scanned by a lookup_network
+// request. On failure, the returned result holds the error to send to the
+// client.
+func (s *Server) lookupNetworkDatabases(request mcp.CallToolRequest) (databaseSelection, *mcp.CallToolResult) {
+	acrossAll := request.GetBool("across_all", false)
+	database, hasDatabase := request.GetArguments()["database"]
+	if acrossAll && hasDatabase {
+		return databaseSelection{}, mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "invalid_parameter",
+				"message": "across_all cannot be combined with database",
+			},
+		})
+	}
+
+	if selectors, ok := database.([]any); ok {
+		names, errResult := s.resolveDatabaseList(selectors)
+		if errResult != nil {
+			return databaseSelection{}, errResult
+		}
+		return databaseSelection{
+			Databases: names,
+			Reason:    "The database parameter lists the databases to scan.",
+			grouped:   true,
+		}, nil
+	}
+
+	if dbName := request.GetString("database", ""); dbName != "" {
+		resolved, errResult := s.resolveDatabase(dbName)
+		if errResult != nil {
+			return databaseSelection{}, errResult
+		}
+		return databaseSelection{
+			Databases: []string{resolved},
+			Reason:    "The database parameter selects a single database.",
+		}, nil
+	}
+
+	names := s.databaseNames()
+	if len(names) == 0 {
+		return databaseSelection{}, mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "no_databases",
+				"message": "No databases available",
+			},
+		})
+	}
+	if acrossAll {
+		return databaseSelection{
+			Databases: names,
+			Reason:    "across_all scans every loaded database.",
+			grouped:   true,
+		}, nil
+	}
+	return databaseSelection{
+		Databases: names[:1],
+		Reason: "Without a database parameter, lookup_network scans only the first loaded database " +
+			"by name. Set across_all to scan every loaded database.",
+	}, nil
+}
+
+// databaseNames returns the names of the loaded databases, sorted.
+func (s *Server) databaseNames() []string {
+	databases := s.dbManager.ListDatabases()
+	names := make([]string, 0, len(databases))
+	for _, db := range databases {
+		names = append(names, db.Name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// handleDescribeQueryBehavior handles the describe_query_behavior tool. It
+// reports which databases a lookup_ip or lookup_network call with the given
+// arguments would consult, and why, without running the query.
+func (s *Server) handleDescribeQueryBehavior(
+	_ context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	tool, err := request.RequireString("tool")
+	if err != nil {
+		//nolint:nilerr // MCP protocol expects error result, not Go error
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "missing_parameter",
+				"message": "Missing required parameter: tool",
+			},
+		}), nil
+	}
+
+	var selection databaseSelection
+	var errResult *mcp.CallToolResult
+	switch tool {
+	case "lookup_ip":
+		selection, errResult = s.lookupIPDatabases(request)
+	case "lookup_network":
+		selection, errResult = s.lookupNetworkDatabases(request)
+	default:
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "invalid_parameter",
+				"message": "Invalid tool: " + tool + " (must be lookup_ip or lookup_network)",
+			},
+		}), nil
+	}
+	if errResult != nil {
+		return errResult, nil
+	}
+	for _, name := range selection.Databases {
+		if _, exists := s.dbManager.GetReader(name); !exists {
+			return mcp.NewToolResultStructuredOnly(map[string]any{
+				"error": map[string]any{
+					"code":    "db_not_found",
+					"message": "Database not found: " + name,
+				},
+			}), nil
+		}
+	}
+
+	return mcp.NewToolResultStructuredOnly(map[string]any{
+		"tool":                tool,
+		"consulted_databases": selection.Databases,
+		"grouped_by_database": selection.grouped,
+		"reason":              selection.Reason,
+	}), nil
+}
@@ -0,0 +1,221 @@
+package mcp
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/oschwald/maxminddb-mcp/internal/config"
+)
+
+const testCountryDB = "../../testdata/test-data/GeoLite2-Country-Test.mmdb"
+
+func TestDescribeQueryBehavior(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+	if err := server.dbManager.LoadDatabase(testCountryDB); err != nil {
+		t.Fatalf("Failed to load test database: %v", err)
+	}
+	all := []string{"GeoLite2-City-Test.mmdb", "GeoLite2-Country-Test.mmdb"}
+
+	tests := []struct {
+		args      map[string]any
+		name      string
+		reason    string
+		consulted []string
+		grouped   bool
+	}{
+		{
+			name:      "lookup_ip without database",
+			args:      map[string]any{"tool": "lookup_ip"},
+			consulted: all,
+			grouped:   true,
+			reason:    "every loaded database",
+		},
+		{
+			name:      "lookup_ip with database",
+			args:      map[string]any{"tool": "lookup_ip", "database": "type:Country"},
+			consulted: []string{"GeoLite2-Country-Test.mmdb"},
+			reason:    "single database",
+		},
+		{
+			name:      "lookup_network without database",
+			args:      map[string]any{"tool": "lookup_network"},
+			consulted: all[:1],
+			reason:    "across_all",
+		},
+		{
+			name:      "lookup_network across_all",
+			args:      map[string]any{"tool": "lookup_network", "across_all": true},
+			consulted: all,
+			grouped:   true,
+			reason:    "every loaded database",
+		},
+		{
+			name:      "lookup_network with database array",
+			args:      map[string]any{"tool": "lookup_network", "database": []any{"GeoLite2-Country-Test.mmdb"}},
+			consulted: []string{"GeoLite2-Country-Test.mmdb"},
+			grouped:   true,
+			reason:    "lists the databases",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			structured := callTool(t, server.handleDescribeQueryBehavior, "describe_query_behavior", test.args)
+			result, ok := structured.(map[string]any)
+			if !ok {
+				t.Fatalf("Unexpected result: %v", structured)
+			}
+			consulted, _ := result["consulted_databases"].([]string)
+			if !slices.Equal(consulted, test.consulted) {
+				t.Errorf("Expected consulted databases %v, got %v", test.consulted, result["consulted_databases"])
+			}
+			if result["grouped_by_database"] != test.grouped {
+				t.Errorf("Expected grouped_by_database %v, got %v", test.grouped, result["grouped_by_database"])
+			}
+			if reason, _ := result["reason"].(string); !strings.Contains(reason, test.reason) {
+				t.Errorf("Expected the reason to mention %q, got %q", test.reason, reason)
+			}
+		})
+	}
+
+	errorTests := []struct {
+		args map[string]any
+		name string
+		code string
+	}{
+		{name: "missing tool", args: map[string]any{}, code: "missing_parameter"},
+		{name: "unknown tool", args: map[string]any{"tool": "list_databases"}, code: "invalid_parameter"},
+		{
+			name: "across_all with database",
+			args: map[string]any{"tool": "lookup_network", "across_all": true, "database": "type:City"},
+			code: "invalid_parameter",
+		},
+		{
+			name: "unknown database",
+			args: map[string]any{"tool": "lookup_ip", "database": "missing.mmdb"},
+			code: "db_not_found",
+		},
+	}
+
+	for _, test := range errorTests {
+		t.Run(test.name, func(t *testing.T) {
+			structured := callTool(t, server.handleDescribeQueryBehavior, "describe_query_behavior", test.args)
+			if code := errorCode(structured); code != test.code {
+				t.Errorf("Expected %s, got %v", test.code, structured)
+			}
+		})
+	}
+}
+
+func TestLookupNetworkAcrossAll(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+	if err := server.dbManager.LoadDatabase(testCountryDB); err != nil {
+		t.Fatalf("Failed to load test database: %v", err)
+	}
+
+	structured := callTool(t, server.handleLookupNetwork, "lookup_network", map[string]any{
+		"network":    "81.2.69.0/24",
+		"across_all": true,
+	})
+	result, ok := structured.(multiNetworkResult)
+	if !ok {
+		t.Fatalf("Expected multi-database result, got %v", structured)
+	}
+	for _, name := range []string{"GeoLite2-City-Test.mmdb", "GeoLite2-Country-Test.mmdb"} {
+		if batch := result.Databases[name]; batch == nil || len(batch.Results) == 0 {
+			t.Errorf("Expected results from %s, got %v", name, batch)
+		}
+	}
+
+	structured = callTool(t, server.handleLookupNetwork, "lookup_network", map[string]any{
+		"network":    "81.2.69.0/24",
+		"across_all": true,
+		"format":     "geojson",
+	})
+	if code := errorCode(structured); code != "invalid_parameter" {
+		t.Errorf("Expected invalid_parameter for geojson across databases, got %v", structured)
+	}
+}
+
+func TestLookupIPScope(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+	if err := server.dbManager.LoadDatabase(testCountryDB); err != nil {
+		t.Fatalf("Failed to load test database: %v", err)
+	}
+	server.config.DefaultDatabase = "type:Country"
+
+	tests := []struct {
+		args        map[string]any
+		name        string
+		configScope string
+		grouped     bool
+	}{
+		{name: "all by default", args: map[string]any{}, grouped: true},
+		{name: "default on request", args: map[string]any{"scope": "default"}},
+		{name: "default from config", args: map[string]any{}, configScope: "default"},
+		{
+			name:        "all on request overrides config",
+			args:        map[string]any{"scope": "all"},
+			configScope: "default",
+			grouped:     true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server.config.LookupIPScope = test.configScope
+			args := map[string]any{"ip": "81.2.69.160"}
+			for key, value := range test.args {
+				args[key] = value
+			}
+			result, ok := callTool(t, server.handleLookupIP, "lookup_ip", args).(map[string]any)
+			if !ok {
+				t.Fatalf("Unexpected result: %v", result)
+			}
+
+			if test.grouped {
+				databases, _ := result["databases"].(map[string]any)
+				if len(databases) != 2 {
+					t.Errorf("Expected results from both databases, got %v", result)
+				}
+				return
+			}
+			data, _ := result["data"].(map[string]any)
+			if data == nil || data["city"] != nil {
+				t.Errorf("Expected only the Country record, got %v", result)
+			}
+		})
+	}
+
+	// Describing the call reports the same selection.
+	server.config.LookupIPScope = config.LookupScopeDefault
+	structured := callTool(t, server.handleDescribeQueryBehavior, "describe_query_behavior", map[string]any{
+		"tool": "lookup_ip",
+	})
+	result, _ := structured.(map[string]any)
+	consulted, _ := result["consulted_databases"].([]string)
+	if !slices.Equal(consulted, []string{"GeoLite2-Country-Test.mmdb"}) || result["grouped_by_database"] != false {
+		t.Errorf("Expected only the default database to be consulted, got %v", result)
+	}
+}
+
+func TestLookupIPScopeErrors(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	structured := callTool(t, server.handleLookupIP, "lookup_ip", map[string]any{"ip": "81.2.69.160", "scope": "some"})
+	if code := errorCode(structured); code != "invalid_parameter" {
+		t.Errorf("Expected invalid_parameter for an unknown scope, got %v", structured)
+	}
+
+	structured = callTool(t, server.handleLookupIP, "lookup_ip", map[string]any{"ip": "81.2.69.160", "scope": "default"})
+	if msg := errorMessage(structured); !strings.Contains(msg, "default_database") {
+		t.Errorf("Expected an error about default_database, got %v", structured)
+	}
+
+	server.config.DefaultDatabase = "missing.mmdb"
+	structured = callTool(t, server.handleLookupIP, "lookup_ip", map[string]any{"ip": "81.2.69.160", "scope": "default"})
+	if code := errorCode(structured); code != "db_not_found" {
+		t.Errorf("Expected db_not_found for a missing default database, got %v", structured)
+	}
+}
@@ -0,0 +1,85 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/oschwald/maxminddb-mcp/internal/database"
+)
+
+// databaseResourcePrefix is the URI prefix of database resources.
+const databaseResourcePrefix = "maxminddb://databases/"
+
+// registerResources publishes each loaded database as a resource and keeps
+// the resource list in sync as databases are added and removed. Clients are
+// sent a resource list changed notification on every change.
+func (s *Server) registerResources() {
+	// Register the callback first so that databases loaded concurrently are
+	// not missed. Adding a resource twice is harmless.
+	s.dbManager.SetChangeCallback(s.handleDatabaseChange)
+	for _, db := range s.dbManager.ListDatabases() {
+		s.addDatabaseResource(db.Name, db.Description)
+	}
+}
+
+// handleDatabaseChange updates the resource list for a database change.
+func (s *Server) handleDatabaseChange(change database.Change) {
+	switch change.Kind {
+	case database.DatabaseAdded:
+		s.addDatabaseResource(change.Name, change.Description)
+	case database.DatabaseRemoved:
+		s.mcp.DeleteResources(databaseResourceURI(change.Name))
+	}
+}
+
+// addDatabaseResource adds the resource for a database.
+func (s *Server) addDatabaseResource(name, description string) {
+	s.mcp.AddResource(
+		mcp.NewResource(
+			databaseResourceURI(name),
+			name,
+			mcp.WithResourceDescription(description),
+			mcp.WithMIMEType("application/json"),
+		),
+		s.handleReadDatabaseResource,
+	)
+}
+
+// handleReadDatabaseResource returns the metadata of a database resource.
+func (s *Server) handleReadDatabaseResource(
+	_ context.Context,
+	request mcp.ReadResourceRequest,
+) ([]mcp.ResourceContents, error) {
+	uri := request.Params.URI
+	name, err := url.PathUnescape(strings.TrimPrefix(uri, databaseResourcePrefix))
+	if err != nil {
+		return nil, fmt.Errorf("invalid database resource URI %s: %w", uri, err)
+	}
+
+	db, exists := s.dbManager.GetDatabase(name)
+	if !exists {
+		return nil, fmt.Errorf("database not found: %s", name)
+	}
+
+	data, err := json.Marshal(db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode database %s: %w", name, err)
+	}
+
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{
+			URI:      uri,
+			MIMEType: "application/json",
+			Text:     string(data),
+		},
+	}, nil
+}
+
+// databaseResourceURI returns the resource URI of a database.
+func databaseResourceURI(name string) string {
+	return databaseResourcePrefix + url.PathEscape(name)
+}
@@ -0,0 +1,112 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// testSession is a client session that records notifications.
+type testSession struct {
+	notifications chan mcp.JSONRPCNotification
+}
+
+func (*testSession) Initialize()       {}
+func (*testSession) Initialized() bool { return true }
+func (*testSession) SessionID() string { return "test-session" }
+
+func (s *testSession) NotificationChannel() chan<- mcp.JSONRPCNotification {
+	return s.notifications
+}
+
+// listResourceURIs returns the URIs listed by resources/list.
+func listResourceURIs(t *testing.T, server *Server) []string {
+	t.Helper()
+
+	response := server.mcp.HandleMessage(
+		context.Background(),
+		json.RawMessage(`{"jsonrpc":"2.0","id":1,"method":"resources/list"}`),
+	)
+	result, ok := response.(mcp.JSONRPCResponse)
+	if !ok {
+		t.Fatalf("Expected JSON-RPC response, got %T", response)
+	}
+	list, ok := result.Result.(mcp.ListResourcesResult)
+	if !ok {
+		t.Fatalf("Expected list resources result, got %T", result.Result)
+	}
+
+	uris := make([]string, 0, len(list.Resources))
+	for _, resource := range list.Resources {
+		uris = append(uris, resource.URI)
+	}
+	return uris
+}
+
+func TestDatabaseResources(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	cityURI := databaseResourceURI("GeoLite2-City-Test.mmdb")
+	if uris := listResourceURIs(t, server); len(uris) != 1 || uris[0] != cityURI {
+		t.Fatalf("Expected resources [%s], got %v", cityURI, uris)
+	}
+
+	response := server.mcp.HandleMessage(
+		context.Background(),
+		json.RawMessage(`{"jsonrpc":"2.0","id":2,"method":"resources/read","params":{"uri":"`+cityURI+`"}}`),
+	)
+	result, ok := response.(mcp.JSONRPCResponse)
+	if !ok {
+		t.Fatalf("Expected JSON-RPC response, got %#v", response)
+	}
+	read, ok := result.Result.(mcp.ReadResourceResult)
+	if !ok || len(read.Contents) != 1 {
+		t.Fatalf("Expected one resource content, got %#v", result.Result)
+	}
+	contents, ok := read.Contents[0].(mcp.TextResourceContents)
+	if !ok || !strings.Contains(contents.Text, `"name":"GeoLite2-City-Test.mmdb"`) {
+		t.Errorf("Expected database metadata, got %#v", read.Contents[0])
+	}
+}
+
+func TestDatabaseResourcesNotifyOnChange(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	session := &testSession{notifications: make(chan mcp.JSONRPCNotification, 10)}
+	if err := server.mcp.RegisterSession(context.Background(), session); err != nil {
+		t.Fatalf("Failed to register session: %v", err)
+	}
+
+	expectNotification := func() {
+		t.Helper()
+		select {
+		case notification := <-session.notifications:
+			if notification.Method != mcp.MethodNotificationResourcesListChanged {
+				t.Errorf("Expected resource list changed notification, got %s", notification.Method)
+			}
+		default:
+			t.Fatal("Expected a resource list changed notification")
+		}
+	}
+
+	if err := server.dbManager.LoadDatabase("../../testdata/test-data/GeoLite2-ASN-Test.mmdb"); err != nil {
+		t.Fatalf("Failed to load database: %v", err)
+	}
+	expectNotification()
+
+	asnURI := databaseResourceURI("GeoLite2-ASN-Test.mmdb")
+	uris := listResourceURIs(t, server)
+	if len(uris) != 2 || !strings.Contains(strings.Join(uris, " "), asnURI) {
+		t.Errorf("Expected resources to include %s, got %v", asnURI, uris)
+	}
+
+	server.dbManager.RemoveDatabase("GeoLite2-ASN-Test.mmdb")
+	expectNotification()
+
+	if uris := listResourceURIs(t, server); len(uris) != 1 {
+		t.Errorf("Expected 1 resource after removal, got %v", uris)
+	}
+}
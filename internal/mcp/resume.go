@@ -0,0 +1,120 @@
+package mcp
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/oschwald/maxminddb-mcp/internal/iterator"
+)
+
+// resumeTokenSummary describes a decoded single-database resume token.
+type resumeTokenSummary struct {
+	*iterator.ResumeToken
+	DatabaseLoaded bool `json:"database_loaded"`
+}
+
+// cursorSummary describes one database of a multi-database resume token.
+type cursorSummary struct {
+	// Token is nil once the database has been scanned completely.
+	Token     *resumeTokenSummary `json:"token,omitempty"`
+	Database  string              `json:"database"`
+	Processed int64               `json:"processed"`
+	Matched   int64               `json:"matched"`
+	Done      bool                `json:"done"`
+}
+
+// handleValidateResumeToken handles the validate_resume_token tool. It
+// decodes a resume token as lookup_network would when resuming, without
+// starting iteration, and reports its contents and whether its database is
+// loaded. Resume tokens are not signed, so a valid token is one that can be
+// resumed, not one that is known to come from this server.
+func (s *Server) handleValidateResumeToken(
+	_ context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	token, err := request.RequireString("resume_token")
+	if err != nil || token == "" {
+		//nolint:nilerr // MCP protocol expects error result, not Go error
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "missing_parameter",
+				"message": "Missing required parameter: resume_token",
+			},
+		}), nil
+	}
+
+	if cursors, ok := decodeMultiResumeToken(token); ok {
+		return s.validateMultiResumeToken(cursors), nil
+	}
+
+	summary, err := s.summarizeResumeToken(token)
+	if err != nil {
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"valid":  false,
+			"reason": err.Error(),
+		}), nil
+	}
+
+	return mcp.NewToolResultStructuredOnly(struct {
+		*resumeTokenSummary
+		Valid bool `json:"valid"`
+	}{summary, true}), nil
+}
+
+// validateMultiResumeToken validates the cursors of a multi-database resume
+// token. The token is valid if the token of every unfinished database is.
+func (s *Server) validateMultiResumeToken(cursors []databaseCursor) *mcp.CallToolResult {
+	summaries := make([]cursorSummary, 0, len(cursors))
+	for _, cursor := range cursors {
+		summary := cursorSummary{
+			Database:  cursor.Database,
+			Processed: cursor.Processed,
+			Matched:   cursor.Matched,
+			Done:      cursor.Done,
+		}
+		if !cursor.Done {
+			tokenSummary, err := s.summarizeResumeToken(cursor.ResumeToken)
+			if err != nil {
+				return mcp.NewToolResultStructuredOnly(map[string]any{
+					"valid":  false,
+					"reason": fmt.Sprintf("database %s: %v", cursor.Database, err),
+				})
+			}
+			summary.Token = tokenSummary
+		}
+		summaries = append(summaries, summary)
+	}
+
+	return mcp.NewToolResultStructuredOnly(map[string]any{
+		"valid":     true,
+		"databases": summaries,
+	})
+}
+
+// summarizeResumeToken decodes a single-database resume token.
+func (s *Server) summarizeResumeToken(token string) (*resumeTokenSummary, error) {
+	decoded, err := s.iterMgr.DecodeResumeToken(token)
+	if err != nil {
+		return nil, err
+	}
+	_, loaded := s.dbManager.GetReader(decoded.Database)
+	return &resumeTokenSummary{ResumeToken: decoded, DatabaseLoaded: loaded}, nil
+}
+
+// decodeMultiResumeToken decodes a multi-database resume token. It reports
+// false for tokens that are not multi-database tokens.
+func decodeMultiResumeToken(token string) ([]databaseCursor, bool) {
+	data, err := base64.StdEncoding.DecodeString(token)
+	if err != nil {
+		return nil, false
+	}
+
+	var parsed multiResumeToken
+	if err := json.Unmarshal(data, &parsed); err != nil || len(parsed.Databases) == 0 {
+		return nil, false
+	}
+	return parsed.Databases, true
+}
@@ -0,0 +1,176 @@
+package mcp
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/oschwald/maxminddb-mcp/internal/iterator"
+)
+
+// structuredMap returns structured content as a JSON object.
+func structuredMap(t *testing.T, structured any) map[string]any {
+	t.Helper()
+
+	data, err := json.Marshal(structured)
+	if err != nil {
+		t.Fatalf("Failed to encode result: %v", err)
+	}
+	var result map[string]any
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatalf("Failed to decode result: %v", err)
+	}
+	return result
+}
+
+// validateToken calls validate_resume_token and returns its result.
+func validateToken(t *testing.T, server *Server, token string) map[string]any {
+	t.Helper()
+
+	return structuredMap(t, callTool(t, server.handleValidateResumeToken, "validate_resume_token", map[string]any{
+		"resume_token": token,
+	}))
+}
+
+// firstBatchToken starts a lookup_network scan of the City test database
+// and returns the resume token of the first batch.
+func firstBatchToken(t *testing.T, server *Server) string {
+	t.Helper()
+
+	structured := callTool(t, server.handleLookupNetwork, "lookup_network", map[string]any{
+		"network":     "81.2.69.0/24",
+		"database":    "GeoLite2-City-Test.mmdb",
+		"max_results": 2,
+		"filters": []any{
+			map[string]any{"field": "country.iso_code", "operator": "equals", "value": "GB"},
+		},
+	})
+	result, ok := structured.(*iterator.IterationResult)
+	if !ok || !result.HasMore {
+		t.Fatalf("Expected a partial iteration result, got %v", structured)
+	}
+	return result.ResumeToken
+}
+
+func TestValidateResumeToken(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+	token := firstBatchToken(t, server)
+
+	result := validateToken(t, server, token)
+	if result["valid"] != true {
+		t.Fatalf("Expected a valid token, got %v", result)
+	}
+	if result["database"] != "GeoLite2-City-Test.mmdb" || result["network"] != "81.2.69.0/24" {
+		t.Errorf("Expected the token's database and network, got %v", result)
+	}
+	if result["database_loaded"] != true {
+		t.Errorf("Expected database_loaded, got %v", result["database_loaded"])
+	}
+	if processed, _ := result["processed"].(float64); result["matched"] != 2.0 || processed < 2 {
+		t.Errorf("Expected the token's counts, got processed=%v matched=%v", result["processed"], result["matched"])
+	}
+	if filters, _ := result["filters"].([]any); len(filters) != 1 {
+		t.Errorf("Expected one filter, got %v", result["filters"])
+	}
+
+	// Validating does not start iteration.
+	if count := server.iterMgr.Clear(); count != 1 {
+		t.Errorf("Expected only the lookup_network iterator, got %d iterators", count)
+	}
+}
+
+func TestValidateResumeTokenStale(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+	token := firstBatchToken(t, server)
+
+	// The token decodes after its database is removed, but can no longer
+	// be resumed against it.
+	server.dbManager.RemoveDatabase("GeoLite2-City-Test.mmdb")
+
+	result := validateToken(t, server, token)
+	if result["valid"] != true {
+		t.Fatalf("Expected the token to decode, got %v", result)
+	}
+	if result["database_loaded"] != false {
+		t.Errorf("Expected database_loaded to be false, got %v", result["database_loaded"])
+	}
+}
+
+func TestValidateResumeTokenMalformed(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	encode := func(token string) string {
+		return base64.StdEncoding.EncodeToString([]byte(token))
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		reason string
+	}{
+		{name: "not base64", token: "not a token!", reason: "invalid resume token"},
+		{name: "not json", token: encode("garbage"), reason: "invalid resume token"},
+		{
+			name:   "invalid network",
+			token:  encode(`{"database":"GeoLite2-City-Test.mmdb","network":"bogus"}`),
+			reason: "invalid network in resume token",
+		},
+		{
+			name: "unknown operator",
+			token: encode(`{"database":"GeoLite2-City-Test.mmdb","network":"81.2.69.0/24",` +
+				`"filters":[{"field":"city","operator":"bogus","value":1}]}`),
+			reason: "invalid filters in resume token",
+		},
+		{
+			name:   "invalid last network",
+			token:  encode(`{"database":"GeoLite2-City-Test.mmdb","network":"81.2.69.0/24","last_network":"x"}`),
+			reason: "invalid last network in resume token",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := validateToken(t, server, test.token)
+			if result["valid"] != false {
+				t.Fatalf("Expected an invalid token, got %v", result)
+			}
+			if reason, _ := result["reason"].(string); !strings.Contains(reason, test.reason) {
+				t.Errorf("Expected reason containing %q, got %q", test.reason, reason)
+			}
+		})
+	}
+
+	structured := callTool(t, server.handleValidateResumeToken, "validate_resume_token", map[string]any{})
+	if code := errorCode(structured); code != "missing_parameter" {
+		t.Errorf("Expected missing_parameter, got %v", structured)
+	}
+}
+
+func TestValidateMultiDatabaseResumeToken(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	structured := callTool(t, server.handleLookupNetwork, "lookup_network", map[string]any{
+		"network":     "81.2.69.0/24",
+		"database":    []any{"GeoLite2-City-Test.mmdb"},
+		"max_results": 2,
+	})
+	batch, ok := structured.(multiNetworkResult)
+	if !ok || !batch.HasMore {
+		t.Fatalf("Expected a partial multi-database result, got %v", structured)
+	}
+
+	result := validateToken(t, server, batch.ResumeToken)
+	if result["valid"] != true {
+		t.Fatalf("Expected a valid token, got %v", result)
+	}
+	databases, _ := result["databases"].([]any)
+	if len(databases) != 1 {
+		t.Fatalf("Expected one database, got %v", result["databases"])
+	}
+	cursor, _ := databases[0].(map[string]any)
+	token, _ := cursor["token"].(map[string]any)
+	if cursor["database"] != "GeoLite2-City-Test.mmdb" || token["database_loaded"] != true {
+		t.Errorf("Expected the City database cursor, got %v", cursor)
+	}
+}
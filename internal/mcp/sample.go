@@ -0,0 +1,102 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/oschwald/maxminddb-mcp/internal/iterator"
+)
+
+// Limits on the number of records returned by sample_records.
+const (
+	defaultSampleRecords = 5
+	maxSampleRecords     = 50
+)
+
+// handleSampleRecords handles the sample_records tool. It returns the first
+// records of a database, or of a network within it, so that clients can see
+// the database's schema without knowing any of its IP addresses. The scan
+// stops after count records, so no full scan confirmation is needed.
+func (s *Server) handleSampleRecords(
+	_ context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	dbName, err := request.RequireString("database")
+	if err != nil {
+		//nolint:nilerr // MCP protocol expects error result, not Go error
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "missing_parameter",
+				"message": "Missing required parameter: database",
+			},
+		}), nil
+	}
+
+	dbName, errResult := s.resolveDatabase(dbName)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	reader, exists := s.dbManager.GetReader(dbName)
+	if !exists {
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "db_not_found",
+				"message": "Database not found: " + dbName,
+			},
+		}), nil
+	}
+
+	count := request.GetInt("count", defaultSampleRecords)
+	if count < 1 || count > maxSampleRecords {
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code": "invalid_parameter",
+				"message": fmt.Sprintf(
+					"Invalid count: %d (must be between 1 and %d)",
+					count,
+					maxSampleRecords,
+				),
+			},
+		}), nil
+	}
+
+	network := fullAddressSpace(reader)
+	if networkStr := request.GetString("network", ""); networkStr != "" {
+		network, err = netip.ParsePrefix(networkStr)
+		if err != nil {
+			return mcp.NewToolResultStructuredOnly(map[string]any{
+				"error": map[string]any{
+					"code":    "invalid_network",
+					"message": "Invalid network: " + networkStr,
+				},
+			}), nil
+		}
+	}
+
+	records := make([]iterator.NetworkResult, 0, count)
+	for result := range reader.NetworksWithin(network) {
+		if len(records) >= count {
+			break
+		}
+
+		var record map[string]any
+		if err := result.Decode(&record); err != nil {
+			return mcp.NewToolResultStructuredOnly(map[string]any{
+				"error": map[string]any{
+					"code":    "decode_failed",
+					"message": fmt.Sprintf("Failed to decode %s: %v", result.Prefix(), err),
+				},
+			}), nil
+		}
+		records = append(records, iterator.NetworkResult{Network: result.Prefix(), Data: record})
+	}
+
+	return mcp.NewToolResultStructuredOnly(map[string]any{
+		"database": dbName,
+		"network":  network.String(),
+		"records":  records,
+	}), nil
+}
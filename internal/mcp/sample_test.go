@@ -0,0 +1,87 @@
+package mcp
+
+import (
+	"testing"
+
+	"github.com/oschwald/maxminddb-mcp/internal/iterator"
+)
+
+func TestSampleRecords(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	for _, count := range []int{1, 3, 10} {
+		structured := callTool(t, server.handleSampleRecords, "sample_records", map[string]any{
+			"database": "type:City",
+			"count":    count,
+		})
+		result, ok := structured.(map[string]any)
+		if !ok {
+			t.Fatalf("Unexpected result: %v", structured)
+		}
+		records, _ := result["records"].([]iterator.NetworkResult)
+		if len(records) != count {
+			t.Fatalf("Expected %d records, got %d", count, len(records))
+		}
+		for _, record := range records {
+			if !record.Network.IsValid() || len(record.Data) == 0 {
+				t.Errorf("Expected a network with data, got %+v", record)
+			}
+		}
+		if result["database"] != "GeoLite2-City-Test.mmdb" {
+			t.Errorf("Expected the City test database, got %v", result["database"])
+		}
+	}
+
+	// Without count, the default number of records is returned from within
+	// the network.
+	structured := callTool(t, server.handleSampleRecords, "sample_records", map[string]any{
+		"database": "GeoLite2-City-Test.mmdb",
+		"network":  "81.2.69.0/24",
+	})
+	result, _ := structured.(map[string]any)
+	records, _ := result["records"].([]iterator.NetworkResult)
+	if len(records) != 4 {
+		t.Fatalf("Expected the 4 records of 81.2.69.0/24, got %d", len(records))
+	}
+	network := records[0].Network
+	if network.String() != "81.2.69.142/31" {
+		t.Errorf("Expected the first network of 81.2.69.0/24, got %s", network)
+	}
+}
+
+func TestSampleRecordsErrors(t *testing.T) {
+	server := newTestServerWithCityDB(t)
+
+	tests := []struct {
+		args map[string]any
+		name string
+		code string
+	}{
+		{name: "missing database", args: map[string]any{}, code: "missing_parameter"},
+		{name: "unknown database", args: map[string]any{"database": "missing.mmdb"}, code: "db_not_found"},
+		{
+			name: "count too large",
+			args: map[string]any{"database": "GeoLite2-City-Test.mmdb", "count": maxSampleRecords + 1},
+			code: "invalid_parameter",
+		},
+		{
+			name: "zero count",
+			args: map[string]any{"database": "GeoLite2-City-Test.mmdb", "count": 0},
+			code: "invalid_parameter",
+		},
+		{
+			name: "invalid network",
+			args: map[string]any{"database": "GeoLite2-City-Test.mmdb", "network": "bogus"},
+			code: "invalid_network",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			structured := callTool(t, server.handleSampleRecords, "sample_records", test.args)
+			if code := errorCode(structured); code != test.code {
+				t.Errorf("Expected %s, got %v", test.code, structured)
+			}
+		})
+	}
+}
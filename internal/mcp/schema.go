@@ -0,0 +1,66 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"maps"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// SchemaVersion is the version of the structured tool result shapes. It is
+// added to every structured result as schema_version so that clients can
+// detect breaking changes. Bump it whenever a result shape changes in a way
+// that is not backward compatible, such as a renamed or removed field.
+const SchemaVersion = 1
+
+// schemaVersionField is the name of the result field holding SchemaVersion.
+const schemaVersionField = "schema_version"
+
+// addSchemaVersion is a tool handler middleware that adds the schema version
+// to structured results, including error results.
+func addSchemaVersion(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		result, err := next(ctx, request)
+		if err != nil || result == nil || result.StructuredContent == nil {
+			return result, err
+		}
+
+		structured, ok := versionedContent(result.StructuredContent)
+		if !ok {
+			return result, nil
+		}
+		// Regenerate the text fallback so that it matches the structured
+		// content.
+		result.StructuredContent = structured
+		result.Content = mcp.NewToolResultStructuredOnly(structured).Content
+		return result, nil
+	}
+}
+
+// versionedContent returns a copy of structured content with the schema
+// version added. Content that does not encode as a JSON object is left as
+// is and reported as not ok.
+func versionedContent(structured any) (map[string]any, bool) {
+	object, ok := structured.(map[string]any)
+	if ok {
+		object = maps.Clone(object)
+	} else {
+		data, err := json.Marshal(structured)
+		if err != nil {
+			return nil, false
+		}
+		// Decode numbers as json.Number so that large integers such as
+		// uint64 record values keep their precision when re-encoded.
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&object); err != nil || object == nil {
+			return nil, false
+		}
+	}
+
+	object[schemaVersionField] = SchemaVersion
+	return object, true
+}
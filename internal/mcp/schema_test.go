@@ -0,0 +1,224 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/oschwald/maxminddb-mcp/internal/config"
+	"github.com/oschwald/maxminddb-mcp/internal/database"
+	"github.com/oschwald/maxminddb-mcp/internal/iterator"
+)
+
+// callToolMessage calls a tool through the MCP server, including its
+// middleware, and returns the structured content and text content of the
+// result decoded as JSON objects.
+func callToolMessage(
+	t *testing.T,
+	server *Server,
+	name string,
+	args map[string]any,
+) (structured, text map[string]any) {
+	t.Helper()
+
+	message, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "tools/call",
+		"params":  map[string]any{"name": name, "arguments": args},
+	})
+	if err != nil {
+		t.Fatalf("Failed to encode request: %v", err)
+	}
+
+	response := server.mcp.HandleMessage(context.Background(), message)
+	data, err := json.Marshal(response)
+	if err != nil {
+		t.Fatalf("Failed to encode response: %v", err)
+	}
+
+	var decoded struct {
+		Result struct {
+			StructuredContent map[string]any `json:"structuredContent"`
+			Content           []struct {
+				Text string `json:"text"`
+			} `json:"content"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to decode response %s: %v", data, err)
+	}
+	if len(decoded.Result.Content) != 1 {
+		t.Fatalf("Expected one text content in %s", data)
+	}
+	if err := json.Unmarshal([]byte(decoded.Result.Content[0].Text), &text); err != nil {
+		t.Fatalf("Failed to decode text content %q: %v", decoded.Result.Content[0].Text, err)
+	}
+	return decoded.Result.StructuredContent, text
+}
+
+// newTestServerWithUpdater returns a server in maxmind mode with the City
+// and ASN test databases loaded and an updater whose endpoint rejects all
+// requests.
+func newTestServerWithUpdater(t *testing.T) *Server {
+	t.Helper()
+
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	}))
+	t.Cleanup(endpoint.Close)
+
+	cfg := createTestMCPConfig(t)
+	cfg.Mode = maxmindMode
+	cfg.MaxMind = config.MaxMindConfig{
+		AccountID:   999999,
+		LicenseKey:  "test_key",
+		Editions:    []string{"GeoLite2-City", "GeoLite2-Country", "GeoLite2-ASN"},
+		DatabaseDir: t.TempDir(),
+		Endpoint:    endpoint.URL,
+	}
+	cfg.Export = config.ExportConfig{Enabled: true, Dir: t.TempDir()}
+
+	dbManager, err := database.New()
+	if err != nil {
+		t.Fatalf("Failed to create database manager: %v", err)
+	}
+	t.Cleanup(func() { _ = dbManager.Close() })
+	for _, path := range []string{testCityDB, "../../testdata/test-data/GeoLite2-ASN-Test.mmdb"} {
+		if err := dbManager.LoadDatabase(path); err != nil {
+			t.Fatalf("Failed to load test database: %v", err)
+		}
+	}
+
+	updater, err := database.NewUpdater(cfg, dbManager)
+	if err != nil {
+		t.Fatalf("Failed to create updater: %v", err)
+	}
+
+	iterMgr := iterator.New(30*time.Minute, 5*time.Minute)
+	t.Cleanup(iterMgr.StopCleanup)
+
+	return New(cfg, dbManager, updater, iterMgr, BuildInfo{})
+}
+
+func TestSchemaVersionInToolResults(t *testing.T) {
+	server := newTestServerWithUpdater(t)
+
+	tests := []struct {
+		args map[string]any
+		name string
+	}{
+		{name: "lookup_ip", args: map[string]any{"ip": "81.2.69.142"}},
+		{name: "lookup_ip", args: map[string]any{"ip": "81.2.69.142", "database": "GeoLite2-City-Test.mmdb"}},
+		{name: "lookup_ip_hierarchy", args: map[string]any{"ip": "81.2.69.142"}},
+		{name: "covering_network", args: map[string]any{"ip": "81.2.69.142", "database": "GeoLite2-City-Test.mmdb"}},
+		{
+			name: "lookup_network",
+			args: map[string]any{"network": "81.2.69.0/24", "database": "GeoLite2-City-Test.mmdb"},
+		},
+		{
+			name: "country_networks",
+			args: map[string]any{
+				"country_iso_code": "GB",
+				"network":          "81.2.69.0/24",
+				"database":         "GeoLite2-City-Test.mmdb",
+			},
+		},
+		{name: "asn_networks", args: map[string]any{"asn": 7018, "network": "12.0.0.0/8"}},
+		{name: "find_databases_with_field", args: map[string]any{"field": "city.names.en"}},
+		{name: "sample_records", args: map[string]any{"database": "GeoLite2-City-Test.mmdb"}},
+		{name: "database_languages", args: map[string]any{"database": "GeoLite2-City-Test.mmdb"}},
+		{name: "describe_query_behavior", args: map[string]any{"tool": "lookup_network"}},
+		{
+			name: "estimate_scan",
+			args: map[string]any{"network": "81.2.69.0/24", "database": "GeoLite2-City-Test.mmdb"},
+		},
+		{
+			name: "top_values",
+			args: map[string]any{
+				"database": "GeoLite2-City-Test.mmdb",
+				"field":    "country.iso_code",
+				"network":  "81.2.69.0/24",
+			},
+		},
+		{
+			name: "classify_networks",
+			args: map[string]any{
+				"database": "GeoLite2-City-Test.mmdb",
+				"field":    "country.iso_code",
+				"networks": []any{"81.2.69.0/24"},
+			},
+		},
+		{
+			name: "normalize_filters",
+			args: map[string]any{
+				"filters": []any{map[string]any{"field": "country.iso_code", "operator": "eq", "value": "GB"}},
+			},
+		},
+		{
+			name: "network_set_op",
+			args: map[string]any{
+				"database": "GeoLite2-City-Test.mmdb",
+				"op":       "union",
+				"a":        map[string]any{"network": "81.2.69.0/24"},
+				"b":        map[string]any{"network": "81.2.69.0/25"},
+			},
+		},
+		{
+			name: "export_database",
+			args: map[string]any{"database": "GeoLite2-ASN-Test.mmdb", "confirm_full_scan": true},
+		},
+		{name: "lookup_ip_history", args: map[string]any{"ip": "81.2.69.142", "edition": "GeoLite2-City-Test"}},
+		{name: "list_databases", args: map[string]any{}},
+		{name: "validate_resume_token", args: map[string]any{"resume_token": "not a token"}},
+		{name: "clear_iterators", args: map[string]any{}},
+		{name: "cancel_iteration", args: map[string]any{"iterator_id": "unknown"}},
+		{name: "get_config", args: map[string]any{}},
+		{name: "get_health", args: map[string]any{}},
+		{name: "update_databases", args: map[string]any{}},
+		{name: "get_update_status", args: map[string]any{}},
+		// Error results are versioned too.
+		{name: "lookup_ip", args: map[string]any{"ip": "not-an-ip"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			structured, text := callToolMessage(t, server, test.name, test.args)
+			if got := structured[schemaVersionField]; got != float64(SchemaVersion) {
+				t.Errorf("Expected schema_version %d, got %v in %v", SchemaVersion, got, structured)
+			}
+			if got := text[schemaVersionField]; got != float64(SchemaVersion) {
+				t.Errorf("Expected schema_version %d in text content, got %v", SchemaVersion, got)
+			}
+		})
+	}
+}
+
+func TestVersionedContent(t *testing.T) {
+	original := map[string]any{"cleared": 1}
+	versioned, ok := versionedContent(original)
+	if !ok || versioned[schemaVersionField] != SchemaVersion {
+		t.Fatalf("Expected versioned map, got %v", versioned)
+	}
+	if _, exists := original[schemaVersionField]; exists {
+		t.Error("Expected the original map to be left unchanged")
+	}
+
+	// Struct results are converted without losing integer precision.
+	versioned, ok = versionedContent(struct {
+		Value uint64 `json:"value"`
+	}{Value: 1<<63 + 1})
+	if !ok {
+		t.Fatal("Expected struct content to be versioned")
+	}
+	if value, _ := versioned["value"].(json.Number); value.String() != "9223372036854775809" {
+		t.Errorf("Expected value to keep its precision, got %v", versioned["value"])
+	}
+
+	if _, ok := versionedContent([]any{1, 2}); ok {
+		t.Error("Expected non-object content to be left as is")
+	}
+}
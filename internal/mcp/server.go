@@ -6,6 +6,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net/netip"
 	"slices"
 	"strings"
@@ -16,6 +17,8 @@ import (
 	"github.com/oschwald/maxminddb-mcp/internal/database"
 	"github.com/oschwald/maxminddb-mcp/internal/filter"
 	"github.com/oschwald/maxminddb-mcp/internal/iterator"
+
+	"github.com/oschwald/maxminddb-golang/v2"
 )
 
 // Server wraps the MCP server with our application state.
@@ -25,30 +28,62 @@ type Server struct {
 	dbManager *database.Manager
 	updater   *database.Updater
 	iterMgr   *iterator.Manager
+	// toolSlots limits concurrent tool calls to its capacity. It is nil
+	// when the number is unlimited.
+	toolSlots chan struct{}
+}
+
+// defaultServerName is the MCP server name advertised unless the
+// server_name config setting is set.
+const defaultServerName = "MaxMindDB Server"
+
+// BuildInfo identifies the build of the binary running the server.
+type BuildInfo struct {
+	Version string
+	Commit  string
 }
 
-// New creates a new MCP server instance.
+// serverVersion returns the MCP server version advertised for a build. The
+// commit, if known, is appended as semver build metadata.
+func (b BuildInfo) serverVersion() string {
+	version := cmp.Or(b.Version, "dev")
+	if b.Commit != "" && b.Commit != "none" {
+		version += "+" + b.Commit
+	}
+	return version
+}
+
+// New creates a new MCP server instance advertising the name from the
+// server_name config setting and the version of build.
 func New(
 	cfg *config.Config,
 	dbManager *database.Manager,
 	updater *database.Updater,
 	iterMgr *iterator.Manager,
+	build BuildInfo,
 ) *Server {
-	mcpServer := server.NewMCPServer(
-		"MaxMindDB Server",
-		"1.0.0",
-		server.WithToolCapabilities(true),
-	)
-
 	s := &Server{
-		mcp:       mcpServer,
 		config:    cfg,
 		dbManager: dbManager,
 		updater:   updater,
 		iterMgr:   iterMgr,
 	}
+	if cfg.MaxConcurrentTools > 0 {
+		s.toolSlots = make(chan struct{}, cfg.MaxConcurrentTools)
+	}
+
+	s.mcp = server.NewMCPServer(
+		cmp.Or(cfg.ServerName, defaultServerName),
+		build.serverVersion(),
+		server.WithToolCapabilities(true),
+		server.WithResourceCapabilities(false, true),
+		server.WithToolHandlerMiddleware(s.logToolCall),
+		server.WithToolHandlerMiddleware(addSchemaVersion),
+		server.WithToolHandlerMiddleware(s.limitConcurrency),
+	)
 
 	s.registerTools()
+	s.registerResources()
 
 	return s
 }
@@ -64,48 +99,699 @@ func (s *Server) registerTools() {
 	lookupIPTool := mcp.NewTool("lookup_ip",
 		mcp.WithDescription("Look up information for a specific IP address"),
 		mcp.WithString("ip", mcp.Required(), mcp.Description("IP address to lookup")),
-		mcp.WithString("database", mcp.Description("Specific database to query (optional)")),
+		mcp.WithString(
+			"database",
+			mcp.Description(
+				"Specific database to query, by name or as 'type:<type>' matching exactly one loaded database (optional)",
+			),
+		),
+		mcp.WithString(
+			"scope",
+			mcp.Description(
+				"Databases to query without a database parameter: 'all' for every loaded database or 'default' for the default_database config setting (optional, defaults to the lookup_ip_scope config setting, 'all' unless configured)",
+			),
+			mcp.Enum(config.LookupScopeAll, config.LookupScopeDefault),
+		),
+		mcp.WithBoolean(
+			"collapse_single_database",
+			mcp.Description(
+				"When querying every database and only one is loaded, return its data directly as with a database parameter instead of nesting it under databases (default: the collapse_single_database config setting, false unless configured)",
+			),
+		),
+		mcp.WithArray(
+			"languages",
+			mcp.Description(
+				"Ordered language preference, e.g. ['ja', 'en']. When set, each localized names map is replaced by a name field in the first available language (optional, defaults to the database's configured default language)",
+			),
+			mcp.WithStringItems(),
+		),
+		mcp.WithBoolean(
+			"prune_empty",
+			mcp.Description("Remove empty strings, maps, and arrays from records (optional)"),
+		),
+		mcp.WithBoolean(
+			"flatten_subdivisions",
+			mcp.Description(
+				"Replace the subdivisions array with subdivision_1, subdivision_2, ... fields holding iso_code and name (optional)",
+			),
+		),
+		mcp.WithBoolean(
+			"enrich_timezone",
+			mcp.Description(
+				"Add location.utc_offset, the current UTC offset of location.time_zone such as '+01:00', to records with a known time zone (optional)",
+			),
+		),
+		mcp.WithNumber(
+			"min_confidence",
+			mcp.Description(
+				"Remove sections such as city, postal, or individual subdivisions whose confidence score (0-100, Enterprise databases) is below this value (optional)",
+			),
+			mcp.Min(0),
+			mcp.Max(maxConfidence),
+		),
+		mcp.WithBoolean(
+			"typed_values",
+			mcp.Description(
+				"Return each number as an object holding its MMDB type (uint16, uint32, uint64, uint128, int32, float, or double) and value (default: false)",
+			),
+		),
+		mcp.WithBoolean(
+			"include_misses",
+			mcp.Description(
+				"When querying all databases, databases without a record for the IP are listed with null data by default. Set to true to also add a found field to each entry, or to false to omit such databases",
+			),
+		),
+		mcp.WithBoolean(
+			"include_type",
+			mcp.Description(
+				"When querying all databases, add each database's type, such as City or ASN, to its entry (default: the include_database_type config setting, false unless configured)",
+			),
+		),
+		mcp.WithBoolean(
+			"first_match",
+			mcp.Description(
+				"When querying all databases, return only the first record found, in the configured database_precedence order, with the name of its database (default: false)",
+			),
+		),
 	)
 	s.mcp.AddTool(lookupIPTool, s.handleLookupIP)
 
+	// lookup_ip_hierarchy tool
+	lookupIPHierarchyTool := mcp.NewTool(
+		"lookup_ip_hierarchy",
+		mcp.WithDescription(
+			"Look up an IP address and return the network of the database record containing it, with its data. A MaxMind DB holds one record per address, stored at its most specific network, so prefixes lists only that network",
+		),
+		mcp.WithString("ip", mcp.Required(), mcp.Description("IP address to lookup")),
+		mcp.WithString(
+			"database",
+			mcp.Required(),
+			mcp.Description(
+				"Database to query, by name or as 'type:<type>' matching exactly one loaded database (e.g., 'type:City')",
+			),
+		),
+	)
+	s.mcp.AddTool(lookupIPHierarchyTool, s.handleLookupIPHierarchy)
+
+	// covering_network tool
+	coveringNetworkTool := mcp.NewTool(
+		"covering_network",
+		mcp.WithDescription(
+			"Return the most specific network of a database covering an IP address, with its data. found is false when no network of the database covers the IP; a covered network with an empty record has found true and empty data",
+		),
+		mcp.WithString("ip", mcp.Required(), mcp.Description("IP address to look up")),
+		mcp.WithString(
+			"database",
+			mcp.Required(),
+			mcp.Description(
+				"Database to query, by name or as 'type:<type>' matching exactly one loaded database (e.g., 'type:City')",
+			),
+		),
+	)
+	s.mcp.AddTool(coveringNetworkTool, s.handleCoveringNetwork)
+
+	// lookup_ip_history tool
+	lookupIPHistoryTool := mcp.NewTool(
+		"lookup_ip_history",
+		mcp.WithDescription(
+			"Look up an IP address in the loaded version of a database and in each older version retained next to it, such as GeoLite2-City.mmdb.bak or GeoLite2-City_20240102.mmdb, and return the records in chronological order of their build dates, to see how the IP's data changed",
+		),
+		mcp.WithString("ip", mcp.Required(), mcp.Description("IP address to lookup")),
+		mcp.WithString(
+			"edition",
+			mcp.Required(),
+			mcp.Description(
+				"Edition ID (e.g., 'GeoLite2-City'), database name, or 'type:<type>' selector matching exactly one loaded database",
+			),
+		),
+	)
+	s.mcp.AddTool(lookupIPHistoryTool, s.handleLookupIPHistory)
+
 	// lookup_network tool
 	lookupNetworkTool := mcp.NewTool(
 		"lookup_network",
 		mcp.WithDescription(
-			"Query a CIDR range with optional filters. filters must be an array of objects with keys: field, operator, value. Example: {\"field\":\"traits.user_type\",\"operator\":\"equals\",\"value\":\"residential\"}. Supported operators: equals, not_equals, in, not_in, contains, regex, greater_than, greater_than_or_equal, less_than, less_than_or_equal, exists.",
+			"Query a CIDR range with optional filters. filters must be an array of objects with keys: field, operator, value. Example: {\"field\":\"traits.user_type\",\"operator\":\"equals\",\"value\":\"residential\"}. Supported operators: equals, not_equals, in, not_in, contains, contains_word, regex, greater_than, greater_than_or_equal, less_than, less_than_or_equal, approx_equals, within, exists. The pseudo-fields __prefix__ and __prefix_length__ filter on the network of each record.",
 		),
 		mcp.WithString(
 			"network",
-			mcp.Required(),
-			mcp.Description("CIDR network to scan (e.g., '192.168.1.0/24')"),
+			mcp.Description("CIDR network to scan (e.g., '192.168.1.0/24'). Required unless range is given"),
+		),
+		mcp.WithString(
+			"range",
+			mcp.Description(
+				"Address range to scan instead of a network, as start-end (e.g., '1.0.0.0-1.0.255.255')",
+			),
+		),
+		mcp.WithAny(
+			"database",
+			func(schema map[string]any) {
+				schema["anyOf"] = []any{
+					map[string]any{"type": "string"},
+					map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+				}
+			},
+			mcp.Description(
+				"Database to query, by name or as 'type:<type>' matching exactly one loaded database, or an array of them to scan each database with the same query. Results are then grouped by database and max_results applies to each (optional, defaults to the first database by name)",
+			),
+		),
+		mcp.WithBoolean(
+			"across_all",
+			mcp.Description(
+				"Scan every loaded database, as if all of them were listed in database. Cannot be combined with database (default: false)",
+			),
 		),
-		mcp.WithString("database", mcp.Description("Specific database to query (optional)")),
 		mcp.WithArray(
 			"filters",
 			mcp.Description("Array of filter objects: {field, operator, value} (optional)"),
 		),
 		mcp.WithString(
 			"filter_mode",
-			mcp.Description("How to combine filters: 'and' or 'or' (default: 'and')"),
+			mcp.Description(
+				"How to combine filters: 'and' or 'or' (default: the default_filter_mode config setting, 'and' unless configured)",
+			),
+		),
+		mcp.WithNumber(
+			"max_results",
+			mcp.Description(
+				"Maximum results to return (default: the default_max_results config setting, 1000 unless configured)",
+			),
 		),
-		mcp.WithNumber("max_results", mcp.Description("Maximum results to return (default: 1000)")),
 		mcp.WithString("iterator_id", mcp.Description("Resume existing iterator (fast path)")),
 		mcp.WithString("resume_token", mcp.Description("Fallback token if iterator expired")),
+		mcp.WithBoolean(
+			"prune_empty",
+			mcp.Description("Remove empty strings, maps, and arrays from records (optional)"),
+		),
+		mcp.WithBoolean(
+			"include_aliased_networks",
+			mcp.Description(
+				"Include IPv4 networks reachable through IPv4-in-IPv6 aliases such as ::ffff:0:0/96 (default: false)",
+			),
+		),
+		mcp.WithBoolean(
+			"sort_by_confidence",
+			mcp.Description(
+				"Order each batch by a confidence score, highest first, e.g. for Enterprise databases. Records without the score follow in network order (default: false)",
+			),
+		),
+		mcp.WithString(
+			"confidence_field",
+			mcp.Description(
+				"Dotted path of the confidence score used by sort_by_confidence (default: the confidence_field config setting, 'country.confidence' unless configured)",
+			),
+		),
+		mcp.WithBoolean(
+			"typed_values",
+			mcp.Description(
+				"Return each number as an object holding its MMDB type (uint16, uint32, uint64, uint128, int32, float, or double) and value (default: false)",
+			),
+		),
+		mcp.WithBoolean(
+			"exclude_bogons",
+			mcp.Description(
+				"Skip networks within private, reserved, and documentation ranges such as 10.0.0.0/8 (default: false)",
+			),
+		),
+		mcp.WithNumber(
+			"max_prefix_length",
+			mcp.Description(
+				"Aggregate networks longer than this prefix length into their enclosing network, e.g. 16 to return /16 networks (optional)",
+			),
+		),
+		mcp.WithString(
+			"format",
+			mcp.Description(
+				"Output format: 'json' or 'geojson' (default: 'json'). geojson returns a FeatureCollection of points for records with a location",
+			),
+		),
+		mcp.WithBoolean(
+			"strict_paths",
+			mcp.Description(
+				"Reject filters whose field path descends into a value that is not a map in the database's records, e.g. 'country.iso_code.foo', instead of silently matching nothing (default: the strict_filter_paths config setting, false unless configured)",
+			),
+		),
+		mcp.WithBoolean(
+			"dedupe_records",
+			mcp.Description(
+				"Return each distinct record of a batch once, in 'records', and refer to it from each result by its index in 'record' instead of repeating it in 'data'. Shrinks scans where many networks share a record. Not supported with geojson (default: false)",
+			),
+		),
 	)
 	s.mcp.AddTool(lookupNetworkTool, s.handleLookupNetwork)
 
+	// country_networks tool
+	countryNetworksTool := mcp.NewTool(
+		"country_networks",
+		mcp.WithDescription(
+			"List the networks assigned to a country in a database. This is a shortcut for lookup_network with a country.iso_code filter and supports the same pagination.",
+		),
+		mcp.WithString(
+			"country_iso_code",
+			mcp.Required(),
+			mcp.Description("ISO 3166-1 alpha-2 country code (e.g., 'US')"),
+		),
+		mcp.WithString(
+			"database",
+			mcp.Required(),
+			mcp.Description(
+				"Database to query, by name or as 'type:<type>' matching exactly one loaded database (e.g., 'type:City')",
+			),
+		),
+		mcp.WithString(
+			"network",
+			mcp.Description("CIDR network to bound the scan (default: the whole address space)"),
+		),
+		mcp.WithBoolean(
+			"confirm_full_scan",
+			mcp.Description("Must be true to scan the whole address space"),
+		),
+		mcp.WithNumber(
+			"max_results",
+			mcp.Description(
+				"Maximum results to return (default: the default_max_results config setting, 1000 unless configured)",
+			),
+		),
+		mcp.WithString("iterator_id", mcp.Description("Resume existing iterator (fast path)")),
+		mcp.WithString("resume_token", mcp.Description("Fallback token if iterator expired")),
+		mcp.WithBoolean(
+			"prune_empty",
+			mcp.Description("Remove empty strings, maps, and arrays from records (optional)"),
+		),
+		mcp.WithBoolean(
+			"include_aliased_networks",
+			mcp.Description(
+				"Include IPv4 networks reachable through IPv4-in-IPv6 aliases such as ::ffff:0:0/96 (default: false)",
+			),
+		),
+		mcp.WithBoolean(
+			"typed_values",
+			mcp.Description(
+				"Return each number as an object holding its MMDB type (uint16, uint32, uint64, uint128, int32, float, or double) and value (default: false)",
+			),
+		),
+		mcp.WithBoolean(
+			"exclude_bogons",
+			mcp.Description(
+				"Skip networks within private, reserved, and documentation ranges such as 10.0.0.0/8 (default: false)",
+			),
+		),
+		mcp.WithNumber(
+			"max_prefix_length",
+			mcp.Description(
+				"Aggregate networks longer than this prefix length into their enclosing network, e.g. 16 to return /16 networks (optional)",
+			),
+		),
+	)
+	s.mcp.AddTool(countryNetworksTool, s.handleCountryNetworks)
+
+	// asn_networks tool
+	asnNetworksTool := mcp.NewTool(
+		"asn_networks",
+		mcp.WithDescription(
+			"List the networks of an autonomous system in a database. This is a shortcut for lookup_network with an autonomous_system_number filter and supports the same pagination.",
+		),
+		mcp.WithNumber(
+			"asn",
+			mcp.Required(),
+			mcp.Description("Autonomous system number (e.g., 15169)"),
+			mcp.Min(0),
+			mcp.Max(maxASN),
+		),
+		mcp.WithString(
+			"database",
+			mcp.Description(
+				"Database to query, by name or as 'type:<type>' matching exactly one loaded database (default: the only loaded database of type ASN)",
+			),
+		),
+		mcp.WithString(
+			"network",
+			mcp.Description("CIDR network to bound the scan (default: the whole address space)"),
+		),
+		mcp.WithBoolean(
+			"confirm_full_scan",
+			mcp.Description("Must be true to scan the whole address space"),
+		),
+		mcp.WithNumber(
+			"max_results",
+			mcp.Description(
+				"Maximum results to return (default: the default_max_results config setting, 1000 unless configured)",
+			),
+		),
+		mcp.WithString("iterator_id", mcp.Description("Resume existing iterator (fast path)")),
+		mcp.WithString("resume_token", mcp.Description("Fallback token if iterator expired")),
+		mcp.WithBoolean(
+			"prune_empty",
+			mcp.Description("Remove empty strings, maps, and arrays from records (optional)"),
+		),
+		mcp.WithBoolean(
+			"include_aliased_networks",
+			mcp.Description(
+				"Include IPv4 networks reachable through IPv4-in-IPv6 aliases such as ::ffff:0:0/96 (default: false)",
+			),
+		),
+		mcp.WithBoolean(
+			"typed_values",
+			mcp.Description(
+				"Return each number as an object holding its MMDB type (uint16, uint32, uint64, uint128, int32, float, or double) and value (default: false)",
+			),
+		),
+		mcp.WithBoolean(
+			"exclude_bogons",
+			mcp.Description(
+				"Skip networks within private, reserved, and documentation ranges such as 10.0.0.0/8 (default: false)",
+			),
+		),
+		mcp.WithNumber(
+			"max_prefix_length",
+			mcp.Description(
+				"Aggregate networks longer than this prefix length into their enclosing network, e.g. 16 to return /16 networks (optional)",
+			),
+		),
+	)
+	s.mcp.AddTool(asnNetworksTool, s.handleASNNetworks)
+
+	// find_databases_with_field tool
+	findDatabasesTool := mcp.NewTool(
+		"find_databases_with_field",
+		mcp.WithDescription(
+			"Find which loaded databases contain a field path (e.g., 'traits.user_type') by sampling records from each database",
+		),
+		mcp.WithString(
+			"field",
+			mcp.Required(),
+			mcp.Description("Dotted field path; numeric segments index arrays (e.g., 'subdivisions.0.iso_code')"),
+		),
+		mcp.WithNumber(
+			"sample_size",
+			mcp.Description("Maximum records to sample per database (default: 100)"),
+		),
+	)
+	s.mcp.AddTool(findDatabasesTool, s.handleFindDatabasesWithField)
+
+	// sample_records tool
+	sampleRecordsTool := mcp.NewTool(
+		"sample_records",
+		mcp.WithDescription(
+			"Return the first records of a database with their networks, to show its schema without knowing any of its IP addresses",
+		),
+		mcp.WithString(
+			"database",
+			mcp.Required(),
+			mcp.Description(
+				"Database to sample, by name or as 'type:<type>' matching exactly one loaded database (e.g., 'type:City')",
+			),
+		),
+		mcp.WithNumber(
+			"count",
+			mcp.Description("Number of records to return (default: 5, max: 50)"),
+			mcp.Min(1),
+			mcp.Max(maxSampleRecords),
+		),
+		mcp.WithString(
+			"network",
+			mcp.Description("CIDR network to sample from (default: the whole address space)"),
+		),
+	)
+	s.mcp.AddTool(sampleRecordsTool, s.handleSampleRecords)
+
+	// database_languages tool
+	databaseLanguagesTool := mcp.NewTool(
+		"database_languages",
+		mcp.WithDescription(
+			"List the languages a database has localized names for, which are the valid language parameters for it, with the database description in each language",
+		),
+		mcp.WithString(
+			"database",
+			mcp.Required(),
+			mcp.Description("Database name or 'type:<type>' selector matching exactly one loaded database (e.g., 'type:City')"),
+		),
+	)
+	s.mcp.AddTool(databaseLanguagesTool, s.handleDatabaseLanguages)
+
+	// describe_query_behavior tool
+	describeQueryBehaviorTool := mcp.NewTool(
+		"describe_query_behavior",
+		mcp.WithDescription(
+			"Explain which databases a lookup_ip or lookup_network call with the given arguments would consult, and why, without running it",
+		),
+		mcp.WithString(
+			"tool",
+			mcp.Required(),
+			mcp.Description("Tool to describe: 'lookup_ip' or 'lookup_network'"),
+			mcp.Enum("lookup_ip", "lookup_network"),
+		),
+		mcp.WithAny(
+			"database",
+			mcp.Description("The database argument of the call, a name, 'type:<type>', or an array (optional)"),
+		),
+		mcp.WithBoolean("across_all", mcp.Description("The across_all argument of a lookup_network call (optional)")),
+		mcp.WithString("scope", mcp.Description("The scope argument of a lookup_ip call (optional)")),
+	)
+	s.mcp.AddTool(describeQueryBehaviorTool, s.handleDescribeQueryBehavior)
+
+	// estimate_scan tool
+	estimateScanTool := mcp.NewTool(
+		"estimate_scan",
+		mcp.WithDescription(
+			"Estimate the cost of a lookup_network scan before running it. Scans the first records of the network, measures the match rate and time per record, and extrapolates the record count, result count, and duration of the full scan from the share of the network the sample covered",
+		),
+		mcp.WithString("network", mcp.Required(), mcp.Description("CIDR network to scan (e.g., '10.0.0.0/8')")),
+		mcp.WithString(
+			"database",
+			mcp.Required(),
+			mcp.Description(
+				"Database to query, by name or as 'type:<type>' matching exactly one loaded database (e.g., 'type:City')",
+			),
+		),
+		mcp.WithArray(
+			"filters",
+			mcp.Description("Array of filter objects: {field, operator, value} (optional)"),
+		),
+		mcp.WithString(
+			"filter_mode",
+			mcp.Description(
+				"How to combine filters: 'and' or 'or' (default: the default_filter_mode config setting, 'and' unless configured)",
+			),
+		),
+		mcp.WithNumber(
+			"sample_size",
+			mcp.Description("Maximum records to scan for the estimate (default: 1000)"),
+		),
+	)
+	s.mcp.AddTool(estimateScanTool, s.handleEstimateScan)
+
+	// top_values tool
+	topValuesTool := mcp.NewTool(
+		"top_values",
+		mcp.WithDescription(
+			"Return the most frequent values of a field among the records of a network, with the number of records holding each, e.g. the top ASNs in a range",
+		),
+		mcp.WithString(
+			"database",
+			mcp.Required(),
+			mcp.Description(
+				"Database to scan, by name or as 'type:<type>' matching exactly one loaded database (e.g., 'type:ASN')",
+			),
+		),
+		mcp.WithString(
+			"field",
+			mcp.Required(),
+			mcp.Description("Dotted field path to count (e.g., 'autonomous_system_number', 'country.iso_code')"),
+		),
+		mcp.WithString(
+			"network",
+			mcp.Description("CIDR network to scan (default: the whole address space, which requires confirm_full_scan)"),
+		),
+		mcp.WithNumber(
+			"n",
+			mcp.Description("Number of values to return (default: 10, max: 1000)"),
+			mcp.Min(1),
+			mcp.Max(maxTopValues),
+		),
+		mcp.WithBoolean(
+			"confirm_full_scan",
+			mcp.Description("Must be true to scan the whole address space"),
+		),
+	)
+	s.mcp.AddTool(topValuesTool, s.handleTopValues)
+
+	// classify_networks tool
+	classifyNetworksTool := mcp.NewTool(
+		"classify_networks",
+		mcp.WithDescription(
+			"For each of a list of networks, return the most common value of a field among its records, such as the dominant country or ASN, with the share of records holding it",
+		),
+		mcp.WithString(
+			"database",
+			mcp.Required(),
+			mcp.Description(
+				"Database to scan, by name or as 'type:<type>' matching exactly one loaded database (e.g., 'type:City')",
+			),
+		),
+		mcp.WithArray(
+			"networks",
+			mcp.Required(),
+			mcp.Description("CIDR networks to classify (e.g., ['81.2.69.0/24', '2.125.160.216/29'], max: 100)"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithString(
+			"field",
+			mcp.Required(),
+			mcp.Description("Dotted field path to classify by (e.g., 'country.iso_code', 'autonomous_system_number')"),
+		),
+	)
+	s.mcp.AddTool(classifyNetworksTool, s.handleClassifyNetworks)
+
+	// normalize_filters tool
+	normalizeFiltersTool := mcp.NewTool(
+		"normalize_filters",
+		mcp.WithDescription(
+			"Show how lookup_network would interpret filters, without querying any database. Returns the filters with operator aliases such as 'gte' replaced by their canonical names and the effective filter mode, or the validation error",
+		),
+		mcp.WithArray(
+			"filters",
+			mcp.Required(),
+			mcp.Description("Array of filter objects: {field, operator, value}"),
+		),
+		mcp.WithString(
+			"filter_mode",
+			mcp.Description(
+				"How to combine filters: 'and' or 'or' (default: the default_filter_mode config setting, 'and' unless configured)",
+			),
+		),
+	)
+	s.mcp.AddTool(normalizeFiltersTool, s.handleNormalizeFilters)
+
+	// network_set_op tool
+	setOpQueryProperties := map[string]any{
+		"network": map[string]any{
+			"type":        "string",
+			"description": "CIDR network to scan (default: the whole address space, which requires confirm_full_scan)",
+		},
+		"filters": map[string]any{
+			"type":        "array",
+			"description": "Array of filter objects: {field, operator, value}",
+		},
+		"filter_mode": map[string]any{
+			"type":        "string",
+			"description": "How to combine filters: 'and' or 'or' (default: the default_filter_mode config setting)",
+		},
+	}
+	networkSetOpTool := mcp.NewTool(
+		"network_set_op",
+		mcp.WithDescription(
+			"Run two network queries against a database and return the difference (a minus b), intersection, or union of the networks they match, as the fewest CIDR networks covering the result. Adjacent networks are merged",
+		),
+		mcp.WithString(
+			"database",
+			mcp.Required(),
+			mcp.Description(
+				"Database to query, by name or as 'type:<type>' matching exactly one loaded database (e.g., 'type:City')",
+			),
+		),
+		mcp.WithString(
+			"op",
+			mcp.Required(),
+			mcp.Description("Set operation: 'difference', 'intersection', or 'union'"),
+			mcp.Enum(setOpDifference, setOpIntersection, setOpUnion),
+		),
+		mcp.WithObject(
+			"a",
+			mcp.Required(),
+			mcp.Description("First query: {network, filters, filter_mode}"),
+			mcp.Properties(setOpQueryProperties),
+		),
+		mcp.WithObject(
+			"b",
+			mcp.Required(),
+			mcp.Description("Second query: {network, filters, filter_mode}"),
+			mcp.Properties(setOpQueryProperties),
+		),
+		mcp.WithBoolean(
+			"confirm_full_scan",
+			mcp.Description("Must be true for either query to scan the whole address space"),
+		),
+	)
+	s.mcp.AddTool(networkSetOpTool, s.handleNetworkSetOp)
+
 	// list_databases tool
 	listDBTool := mcp.NewTool("list_databases",
 		mcp.WithDescription("List all available MaxMind databases"),
 	)
 	s.mcp.AddTool(listDBTool, s.handleListDatabases)
 
+	// clear_iterators tool
+	clearIteratorsTool := mcp.NewTool("clear_iterators",
+		mcp.WithDescription("Remove all active lookup_network iterators"),
+	)
+	s.mcp.AddTool(clearIteratorsTool, s.handleClearIterators)
+
+	// cancel_iteration tool
+	cancelIterationTool := mcp.NewTool("cancel_iteration",
+		mcp.WithDescription(
+			"Interrupt a lookup_network batch that is still running for an iterator. The batch returns promptly with the results so far, canceled set to true, and has_more set, so the scan can be continued later",
+		),
+		mcp.WithString("iterator_id", mcp.Required(), mcp.Description("Iterator whose running batch to cancel")),
+	)
+	s.mcp.AddTool(cancelIterationTool, s.handleCancelIteration)
+
+	// validate_resume_token tool
+	validateResumeTokenTool := mcp.NewTool("validate_resume_token",
+		mcp.WithDescription(
+			"Check whether a lookup_network resume token can be resumed, without starting iteration. Returns the token's database, network, filters, and processed/matched counts, and whether the database is currently loaded",
+		),
+		mcp.WithString("resume_token", mcp.Required(), mcp.Description("Resume token to check")),
+	)
+	s.mcp.AddTool(validateResumeTokenTool, s.handleValidateResumeToken)
+
+	// get_config tool
+	getConfigTool := mcp.NewTool("get_config",
+		mcp.WithDescription(
+			"Show the effective server configuration with secrets redacted, and the config file it was loaded from",
+		),
+	)
+	s.mcp.AddTool(getConfigTool, s.handleGetConfig)
+
+	// get_health tool
+	getHealthTool := mcp.NewTool("get_health",
+		mcp.WithDescription("Report server health, including the database file watcher"),
+	)
+	s.mcp.AddTool(getHealthTool, s.handleGetHealth)
+
+	// export_database tool (only when enabled)
+	if s.config.Export.Enabled {
+		exportDatabaseTool := mcp.NewTool("export_database",
+			mcp.WithDescription(
+				"Write every network of a database and its record to a new NDJSON file in the server's export directory, one {network, data} object per line. Meant for small databases; exports beyond the configured record limit fail and leave no file",
+			),
+			mcp.WithString("database", mcp.Required(), mcp.Description("Database to export")),
+			mcp.WithString(
+				"file",
+				mcp.Description(
+					"Name of the file to create in the export directory. It must not exist yet (default: the database name with an .ndjson extension)",
+				),
+			),
+			mcp.WithBoolean(
+				"confirm_full_scan",
+				mcp.Required(),
+				mcp.Description("Must be true, as the export reads the whole database"),
+			),
+		)
+		s.mcp.AddTool(exportDatabaseTool, s.handleExportDatabase)
+	}
+
 	// update_databases tool (only for maxmind/geoip_compat modes)
 	if s.config.Mode == config.ModeMaxMind || s.config.Mode == config.ModeGeoIPCompat {
 		updateDBTool := mcp.NewTool("update_databases",
 			mcp.WithDescription("Trigger manual update of MaxMind databases"),
 		)
 		s.mcp.AddTool(updateDBTool, s.handleUpdateDatabases)
+
+		updateStatusTool := mcp.NewTool("get_update_status",
+			mcp.WithDescription("Get the status of scheduled database updates"),
+		)
+		s.mcp.AddTool(updateStatusTool, s.handleGetUpdateStatus)
 	}
 }
 
@@ -137,15 +823,50 @@ func (s *Server) handleLookupIP(
 		}), nil
 	}
 
-	// Get database name if specified
-	dbName := request.GetString("database", "")
+	opts := lookupOptions{
+		languages:           request.GetStringSlice("languages", nil),
+		pruneEmpty:          request.GetBool("prune_empty", s.config.PruneEmpty),
+		flattenSubdivisions: request.GetBool("flatten_subdivisions", false),
+		enrichTimeZone:      request.GetBool("enrich_timezone", false),
+		minConfidence:       request.GetFloat("min_confidence", 0),
+		typedValues:         request.GetBool("typed_values", false),
+		firstMatch:          request.GetBool("first_match", false),
+		includeType:         request.GetBool("include_type", s.config.IncludeDatabaseType),
+	}
+	if _, ok := request.GetArguments()["include_misses"]; ok {
+		opts.includeMisses = request.GetBool("include_misses", false)
+		opts.omitMisses = !opts.includeMisses
+	}
+	if opts.minConfidence < 0 || opts.minConfidence > maxConfidence {
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "invalid_parameter",
+				"message": fmt.Sprintf("min_confidence must be between 0 and %d", maxConfidence),
+			},
+		}), nil
+	}
+	if opts.typedValues && opts.minConfidence > 0 {
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "invalid_parameter",
+				"message": "min_confidence cannot be combined with typed_values",
+			},
+		}), nil
+	}
 
 	// Perform lookup
-	if dbName != "" {
-		return s.lookupIPInSingleDatabase(ip, ipStr, dbName)
+	selection, errResult := s.lookupIPDatabases(request)
+	if errResult != nil {
+		return errResult, nil
+	}
+	if !selection.grouped {
+		return s.lookupIPInSingleDatabase(ip, ipStr, selection.Databases[0], opts)
+	}
+	if opts.firstMatch {
+		return s.lookupIPFirstMatch(ip, ipStr, selection.Databases, opts), nil
 	}
 
-	return s.lookupIPInAllDatabases(ip, ipStr)
+	return s.lookupIPInAllDatabases(ip, ipStr, selection.Databases, opts)
 }
 
 // handleLookupNetwork handles the lookup_network tool.
@@ -153,45 +874,63 @@ func (s *Server) handleLookupNetwork(
 	_ context.Context,
 	request mcp.CallToolRequest,
 ) (*mcp.CallToolResult, error) {
-	networkStr, err := request.RequireString("network")
-	if err != nil {
-		//nolint:nilerr // MCP protocol expects error result, not Go error
+	networks, errResult := scanNetworks(request)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	filters, errResult := s.requestFilters(request)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	// Get filter mode, falling back to the configured default
+	defaultFilterMode := s.config.DefaultFilterMode
+	if defaultFilterMode == "" {
+		defaultFilterMode = config.FilterModeAnd
+	}
+	filterMode := request.GetString("filter_mode", defaultFilterMode)
+
+	format := strings.ToLower(request.GetString("format", formatJSON))
+	if format != formatJSON && format != formatGeoJSON {
 		return mcp.NewToolResultStructuredOnly(map[string]any{
 			"error": map[string]any{
-				"code":    "missing_parameter",
-				"message": "Missing required parameter: network",
+				"code":    "invalid_format",
+				"message": "Invalid format: " + format + " (must be json or geojson)",
 			},
 		}), nil
 	}
-
-	// Parse network
-	network, err := netip.ParsePrefix(networkStr)
-	if err != nil {
-		//nolint:nilerr // MCP protocol expects error result, not Go error
+	if format == formatGeoJSON && request.GetBool("dedupe_records", false) {
 		return mcp.NewToolResultStructuredOnly(map[string]any{
 			"error": map[string]any{
-				"code":    "invalid_network",
-				"message": "Invalid network: " + networkStr,
+				"code":    "invalid_parameter",
+				"message": "dedupe_records cannot be combined with the geojson format",
 			},
 		}), nil
 	}
 
-	// Get database name
-	dbName := request.GetString("database", "")
+	selection, errResult := s.lookupNetworkDatabases(request)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	if errResult := s.checkFilterPaths(request, filters, selection.Databases); errResult != nil {
+		return errResult, nil
+	}
 
-	// Use first database if none specified
-	if dbName == "" {
-		databases := s.dbManager.ListDatabases()
-		if len(databases) == 0 {
+	// Scan several databases if database is an array or across_all is set
+	if selection.grouped {
+		if format == formatGeoJSON {
 			return mcp.NewToolResultStructuredOnly(map[string]any{
 				"error": map[string]any{
-					"code":    "no_databases",
-					"message": "No databases available",
+					"code":    "invalid_parameter",
+					"message": "The geojson format requires a single database",
 				},
 			}), nil
 		}
-		dbName = databases[0].Name
+		return s.lookupNetworkInDatabases(request, selection.Databases, networks, filters, filterMode), nil
 	}
+	dbName := selection.Databases[0]
 
 	// Get reader
 	reader, exists := s.dbManager.GetReader(dbName)
@@ -204,83 +943,185 @@ func (s *Server) handleLookupNetwork(
 		}), nil
 	}
 
-	// Parse filters from request
-	filters, parseErr := parseFiltersFromRequest(request)
-	if parseErr != nil {
-		return mcp.NewToolResultStructuredOnly(map[string]any{
-			"error": map[string]any{
-				"code":    "invalid_filter",
-				"message": fmt.Sprintf("Invalid filters: %v", parseErr),
-			},
-		}), nil
+	result, err := s.iterateNetworks(request, reader, dbName, networks, filters, filterMode)
+	if err != nil || format != formatGeoJSON {
+		return result, err
 	}
 
-	// Validate filters
-	if err := filter.Validate(filters); err != nil {
-		return mcp.NewToolResultStructuredOnly(map[string]any{
-			"error": map[string]any{
-				"code":    "invalid_filter",
-				"message": fmt.Sprintf("Invalid filters: %v", err),
-			},
-		}), nil
+	if iterResult, ok := result.StructuredContent.(*iterator.IterationResult); ok {
+		return mcp.NewToolResultStructuredOnly(toGeoJSON(iterResult)), nil
 	}
+	return result, nil
+}
+
+// defaultMaxResults is the number of results returned per network scan
+// batch unless the default_max_results config setting or the request sets it.
+const defaultMaxResults = 1000
+
+// Values of the resume_source field of lookup_network batches, telling how
+// the iterator of the batch was obtained.
+const (
+	resumeSourceFresh       = "fresh"
+	resumeSourceIteratorID  = "iterator_id"
+	resumeSourceResumeToken = "resume_token"
+)
 
-	// Get filter mode
-	filterMode := request.GetString("filter_mode", "and")
+// iterateNetworks returns the next batch of results for a scan of the given
+// networks, in order. It resumes the iterator named by iterator_id, falls
+// back to resume_token, and otherwise creates a new iterator.
+func (s *Server) iterateNetworks(
+	request mcp.CallToolRequest,
+	reader *maxminddb.Reader,
+	dbName string,
+	networks []netip.Prefix,
+	filters []filter.Filter,
+	filterMode string,
+) (*mcp.CallToolResult, error) {
+	result, errResult := s.nextNetworkBatch(
+		request,
+		reader,
+		dbName,
+		networks,
+		filters,
+		filterMode,
+		request.GetString("iterator_id", ""),
+		request.GetString("resume_token", ""),
+	)
+	if errResult != nil {
+		return errResult, nil
+	}
+	return mcp.NewToolResultStructuredOnly(result), nil
+}
 
+// nextNetworkBatch returns the next batch of results for a scan of the given
+// networks. It resumes the iterator named by iterID, falls back to
+// resumeToken, and otherwise creates a new iterator. On failure, the
+// returned result holds the error to send to the client.
+func (s *Server) nextNetworkBatch(
+	request mcp.CallToolRequest,
+	reader *maxminddb.Reader,
+	dbName string,
+	networks []netip.Prefix,
+	filters []filter.Filter,
+	filterMode string,
+	iterID string,
+	resumeToken string,
+) (*iterator.IterationResult, *mcp.CallToolResult) {
 	// Get max results
-	maxResults := int(request.GetFloat("max_results", 1000))
+	maxResults := int(request.GetFloat("max_results", float64(s.defaultMaxResults())))
 
 	// Check for existing iterator or resume token
 	var iter *iterator.ManagedIterator
+	source := resumeSourceFresh
 
-	if iterID := request.GetString("iterator_id", ""); iterID != "" {
+	if iterID != "" {
 		if existingIter, found := s.iterMgr.GetIterator(iterID); found {
 			iter = existingIter
+			source = resumeSourceIteratorID
 		}
 	}
 
-	if iter == nil {
-		if resumeToken := request.GetString("resume_token", ""); resumeToken != "" {
-			var err error
-			iter, err = s.iterMgr.ResumeIterator(reader, resumeToken)
-			if err != nil {
-				return mcp.NewToolResultStructuredOnly(map[string]any{
-					"error": map[string]any{
-						"code":    "resume_failed",
-						"message": fmt.Sprintf("Failed to resume iterator: %v", err),
-					},
-				}), nil
-			}
+	if iter == nil && resumeToken != "" {
+		var err error
+		source = resumeSourceResumeToken
+		iter, err = s.iterMgr.ResumeIterator(reader, resumeToken)
+		if err != nil {
+			return nil, mcp.NewToolResultStructuredOnly(map[string]any{
+				"error": map[string]any{
+					"code":    "resume_failed",
+					"message": fmt.Sprintf("Failed to resume iterator: %v", err),
+				},
+			})
 		}
 	}
 
 	// Create new iterator if none found
 	if iter == nil {
 		var err error
-		iter, err = s.iterMgr.CreateIterator(reader, dbName, network, filters, filterMode)
+		maxPrefixLength := request.GetInt("max_prefix_length", 0)
+		if maxPrefixLength < 0 || maxPrefixLength > 128 {
+			return nil, mcp.NewToolResultStructuredOnly(map[string]any{
+				"error": map[string]any{
+					"code": "invalid_parameter",
+					"message": fmt.Sprintf(
+						"Invalid max_prefix_length: %d (must be between 0 and 128)",
+						maxPrefixLength,
+					),
+				},
+			})
+		}
+
+		opts := iterator.Options{
+			IncludeAliasedNetworks: request.GetBool("include_aliased_networks", false),
+			MaxPrefixLength:        maxPrefixLength,
+			ExtraNetworks:          networks[1:],
+			ExcludeNetworks:        s.excludedNetworks(request),
+			TypedValues:            request.GetBool("typed_values", false),
+		}
+		iter, err = s.iterMgr.CreateIteratorWithOptions(reader, dbName, networks[0], filters, filterMode, opts)
 		if err != nil {
-			return mcp.NewToolResultStructuredOnly(map[string]any{
+			return nil, mcp.NewToolResultStructuredOnly(map[string]any{
 				"error": map[string]any{
 					"code":    "iterator_creation_failed",
 					"message": fmt.Sprintf("Failed to create iterator: %v", err),
 				},
-			}), nil
+			})
 		}
 	}
 
-	// Perform iteration
+	// Perform iteration. On a stale file handle, e.g. after the file was
+	// replaced on NFS, the batch is undone and retried once with a fresh
+	// reader.
 	result, err := s.iterMgr.Iterate(iter, maxResults)
+	if database.IsStaleFileHandle(err) {
+		var fresh *maxminddb.Reader
+		if fresh, err = s.dbManager.Reload(iter.Database); err == nil {
+			iter.SetReader(fresh)
+			result, err = s.iterMgr.Iterate(iter, maxResults)
+		}
+	}
+	if errors.Is(err, iterator.ErrBatchBudgetExhausted) {
+		return nil, mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code": "iterator_exhausted_budget",
+				"message": fmt.Sprintf(
+					"Iterator has served the maximum of %d batches and was closed; narrow the network or add filters",
+					s.config.MaxBatchesPerIterator,
+				),
+			},
+		})
+	}
 	if err != nil {
-		return mcp.NewToolResultStructuredOnly(map[string]any{
+		return nil, mcp.NewToolResultStructuredOnly(map[string]any{
 			"error": map[string]any{
 				"code":    "iteration_failed",
 				"message": fmt.Sprintf("Iteration failed: %v", err),
 			},
-		}), nil
+		})
 	}
 
-	return mcp.NewToolResultStructuredOnly(result), nil
+	result.ResumeSource = source
+
+	if request.GetBool("sort_by_confidence", false) {
+		field := request.GetString("confidence_field", s.config.ConfidenceField)
+		if field == "" {
+			field = defaultConfidenceField
+		}
+		sortByConfidence(result.Results, field)
+	}
+
+	if request.GetBool("prune_empty", s.config.PruneEmpty) {
+		opts := lookupOptions{pruneEmpty: true}
+		for i := range result.Results {
+			result.Results[i].Data = opts.apply(result.Results[i].Data)
+		}
+	}
+
+	if request.GetBool("dedupe_records", false) {
+		result.DedupeRecords()
+	}
+
+	return result, nil
 }
 
 // handleListDatabases handles the list_databases tool.
@@ -301,7 +1142,7 @@ func (s *Server) handleListDatabases(
 // handleUpdateDatabases handles the update_databases tool.
 func (s *Server) handleUpdateDatabases(
 	ctx context.Context,
-	_ mcp.CallToolRequest,
+	request mcp.CallToolRequest,
 ) (*mcp.CallToolResult, error) {
 	if s.updater == nil {
 		return mcp.NewToolResultStructuredOnly(map[string]any{
@@ -312,7 +1153,7 @@ func (s *Server) handleUpdateDatabases(
 		}), nil
 	}
 
-	results, err := s.updater.UpdateAll(ctx)
+	results, err := s.updater.UpdateAllWithProgress(ctx, s.updateProgress(ctx, request))
 	if err != nil {
 		return mcp.NewToolResultStructuredOnly(map[string]any{
 			"error": map[string]any{
@@ -327,13 +1168,169 @@ func (s *Server) handleUpdateDatabases(
 	}), nil
 }
 
+// updateProgress returns a callback that reports the result of each edition
+// of update_databases to the client as a progress notification, so that
+// clients see results during long multi-edition updates. Progress is only
+// reported when the request includes a progress token; the aggregate
+// result is returned either way.
+func (s *Server) updateProgress(ctx context.Context, request mcp.CallToolRequest) database.ProgressFunc {
+	if request.Params.Meta == nil || request.Params.Meta.ProgressToken == nil {
+		return nil
+	}
+	token := request.Params.Meta.ProgressToken
+
+	return func(done, total int, result database.UpdateResult) {
+		var message string
+		switch {
+		case result.Error != "":
+			message = fmt.Sprintf("Failed to update %s: %s", result.Database, result.Error)
+		case result.Updated:
+			message = "Updated " + result.Database
+		default:
+			message = result.Database + " is up to date"
+		}
+
+		err := s.mcp.SendNotificationToClient(ctx, "notifications/progress", map[string]any{
+			"progressToken": token,
+			"progress":      done,
+			"total":         total,
+			"message":       message,
+			"result":        result,
+		})
+		if err != nil {
+			slog.Debug("Failed to send update progress", "database", result.Database, "err", err)
+		}
+	}
+}
+
+// handleClearIterators handles the clear_iterators tool.
+func (s *Server) handleClearIterators(
+	_ context.Context,
+	_ mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	return mcp.NewToolResultStructuredOnly(map[string]any{
+		"cleared": s.iterMgr.Clear(),
+	}), nil
+}
+
+// handleCancelIteration handles the cancel_iteration tool. canceled is false
+// if the iterator exists but no batch was running.
+func (s *Server) handleCancelIteration(
+	_ context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	iterID, err := request.RequireString("iterator_id")
+	if err != nil {
+		//nolint:nilerr // MCP protocol expects error result, not Go error
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "missing_parameter",
+				"message": "Missing required parameter: iterator_id",
+			},
+		}), nil
+	}
+
+	if _, exists := s.iterMgr.GetIterator(iterID); !exists {
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "iterator_not_found",
+				"message": "Iterator not found: " + iterID,
+			},
+		}), nil
+	}
+
+	return mcp.NewToolResultStructuredOnly(map[string]any{
+		"iterator_id": iterID,
+		"canceled":    s.iterMgr.CancelIteration(iterID),
+	}), nil
+}
+
+// handleGetConfig handles the get_config tool.
+func (s *Server) handleGetConfig(
+	_ context.Context,
+	_ mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	redacted, err := s.config.Redacted()
+	if err != nil {
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "config_unavailable",
+				"message": fmt.Sprintf("Failed to read configuration: %v", err),
+			},
+		}), nil
+	}
+
+	return mcp.NewToolResultStructuredOnly(map[string]any{
+		"source_path": s.config.SourcePath,
+		"config":      redacted,
+	}), nil
+}
+
+// handleGetUpdateStatus handles the get_update_status tool.
+func (s *Server) handleGetUpdateStatus(
+	_ context.Context,
+	_ mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	if s.updater == nil {
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "updates_not_available",
+				"message": "Database updates not available in this mode",
+			},
+		}), nil
+	}
+
+	return mcp.NewToolResultStructuredOnly(map[string]any{
+		"circuit_breakers": s.updater.BreakerStatuses(),
+	}), nil
+}
+
+// defaultMaxResults returns the number of results returned per network scan
+// batch when a request omits max_results.
+func (s *Server) defaultMaxResults() int {
+	if s.config.DefaultMaxResults > 0 {
+		return s.config.DefaultMaxResults
+	}
+	return defaultMaxResults
+}
+
+// filterLimits returns the limits applied to client-supplied filters.
+func (s *Server) filterLimits() filter.Limits {
+	limits := filter.DefaultLimits()
+	if s.config.MaxRegexLength > 0 {
+		limits.MaxRegexLength = s.config.MaxRegexLength
+	}
+	return limits
+}
+
+// resolveDatabase resolves a database parameter, which may be a display
+// name or a "type:<type>" selector. On failure, the returned result holds
+// the error to send to the client.
+func (s *Server) resolveDatabase(selector string) (string, *mcp.CallToolResult) {
+	dbName, err := s.dbManager.ResolveName(selector)
+	if err == nil {
+		return dbName, nil
+	}
+
+	code := "db_not_found"
+	if errors.Is(err, database.ErrAmbiguousDatabase) {
+		code = "ambiguous_database"
+	}
+	return "", mcp.NewToolResultStructuredOnly(map[string]any{
+		"error": map[string]any{
+			"code":    code,
+			"message": err.Error(),
+		},
+	})
+}
+
 // lookupIPInSingleDatabase performs IP lookup in a specific database.
 func (s *Server) lookupIPInSingleDatabase(
 	ip netip.Addr,
 	ipStr, dbName string,
+	opts lookupOptions,
 ) (*mcp.CallToolResult, error) {
-	reader, exists := s.dbManager.GetReader(dbName)
-	if !exists {
+	if _, exists := s.dbManager.GetReader(dbName); !exists {
 		return mcp.NewToolResultStructuredOnly(map[string]any{
 			"error": map[string]any{
 				"code":    "db_not_found",
@@ -343,7 +1340,12 @@ func (s *Server) lookupIPInSingleDatabase(
 	}
 
 	var record map[string]any
-	if err := reader.Lookup(ip).Decode(&record); err != nil {
+	err := s.dbManager.WithReader(dbName, func(reader *maxminddb.Reader) error {
+		var err error
+		record, err = opts.decode(reader.Lookup(ip))
+		return err
+	})
+	if err != nil {
 		return mcp.NewToolResultStructuredOnly(map[string]any{
 			"error": map[string]any{
 				"code":    "lookup_failed",
@@ -354,33 +1356,59 @@ func (s *Server) lookupIPInSingleDatabase(
 
 	result := map[string]any{
 		"ip":   ipStr,
-		"data": record,
+		"data": s.lookupOptionsFor(dbName, opts).apply(record),
 	}
 
 	return mcp.NewToolResultStructuredOnly(result), nil
 }
 
 // lookupIPInAllDatabases performs IP lookup across all databases.
-func (s *Server) lookupIPInAllDatabases(ip netip.Addr, ipStr string) (*mcp.CallToolResult, error) {
+func (s *Server) lookupIPInAllDatabases(
+	ip netip.Addr,
+	ipStr string,
+	names []string,
+	opts lookupOptions,
+) (*mcp.CallToolResult, error) {
 	results := make(map[string]any)
-	databases := s.dbManager.ListDatabases()
 
-	for _, dbInfo := range databases {
-		reader, exists := s.dbManager.GetReader(dbInfo.Name)
-		if !exists {
-			continue
+	for _, name := range names {
+		var record map[string]any
+		var found bool
+		err := s.dbManager.WithReader(name, func(reader *maxminddb.Reader) error {
+			result := reader.Lookup(ip)
+			found = result.Found()
+			var err error
+			record, err = opts.decode(result)
+			return err
+		})
+		if err != nil {
+			continue // Skip databases that are gone or can't be decoded
 		}
 
-		var record map[string]any
-		if err := reader.Lookup(ip).Decode(&record); err != nil {
-			continue // Skip databases that don't contain this IP
+		// Databases without a record for the IP are listed with null data
+		// unless misses are omitted on request
+		var dbResult map[string]any
+		switch {
+		case found:
+			dbResult = map[string]any{
+				"data": s.lookupOptionsFor(name, opts).apply(record),
+			}
+		case opts.omitMisses:
+			continue
+		default:
+			dbResult = map[string]any{"data": nil}
+		}
+		if opts.includeMisses {
+			dbResult["found"] = found
 		}
 
-		dbResult := map[string]any{
-			"data": record,
+		if opts.includeType {
+			if db, ok := s.dbManager.GetDatabase(name); ok {
+				dbResult["type"] = db.Type
+			}
 		}
 
-		results[dbInfo.Name] = dbResult
+		results[name] = dbResult
 	}
 
 	result := map[string]any{
@@ -391,8 +1419,83 @@ func (s *Server) lookupIPInAllDatabases(ip netip.Addr, ipStr string) (*mcp.CallT
 	return mcp.NewToolResultStructuredOnly(result), nil
 }
 
-// parseFiltersFromRequest extracts filters from MCP request arguments.
-func parseFiltersFromRequest(request mcp.CallToolRequest) ([]filter.Filter, error) {
+// requestFilters parses and validates the filters parameter. On failure, the
+// returned result holds the error to send to the client.
+func (s *Server) requestFilters(request mcp.CallToolRequest) ([]filter.Filter, *mcp.CallToolResult) {
+	filters, err := parseFiltersFromRequest(request, s.config.SkipNullFilters)
+	if err != nil {
+		return nil, mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "invalid_filter",
+				"message": fmt.Sprintf("Invalid filters: %v", err),
+			},
+		})
+	}
+
+	if err := filter.ValidateWithLimits(filters, s.filterLimits()); err != nil {
+		return nil, mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "invalid_filter",
+				"message": fmt.Sprintf("Invalid filters: %v", err),
+			},
+		})
+	}
+
+	return filters, nil
+}
+
+// strictPathSampleSize is the number of records of a database that filter
+// paths are checked against in strict mode.
+const strictPathSampleSize = 10
+
+// checkFilterPaths rejects filters whose paths descend into a value that is
+// not a map, such as country.iso_code.foo, in the first records of any of
+// the databases. Such filters can never match. It only runs when the
+// strict_paths parameter or the strict_filter_paths config setting is set.
+// On failure, the returned result holds the error to send to the client.
+func (s *Server) checkFilterPaths(
+	request mcp.CallToolRequest,
+	filters []filter.Filter,
+	databases []string,
+) *mcp.CallToolResult {
+	if len(filters) == 0 || !request.GetBool("strict_paths", s.config.StrictFilterPaths) {
+		return nil
+	}
+
+	for _, name := range databases {
+		reader, exists := s.dbManager.GetReader(name)
+		if !exists {
+			continue
+		}
+
+		sampled := 0
+		for network := range reader.NetworksWithin(fullAddressSpace(reader)) {
+			if sampled >= strictPathSampleSize {
+				break
+			}
+			sampled++
+
+			var record map[string]any
+			if err := network.Decode(&record); err != nil {
+				continue
+			}
+			if err := filter.ValidatePaths(filters, record); err != nil {
+				return mcp.NewToolResultStructuredOnly(map[string]any{
+					"error": map[string]any{
+						"code":    "invalid_filter",
+						"message": fmt.Sprintf("Invalid filters for %s: %v", name, err),
+					},
+				})
+			}
+		}
+	}
+	return nil
+}
+
+// parseFiltersFromRequest extracts filters from MCP request arguments. Null
+// entries in the filters array are skipped if skipNull is set and rejected
+// otherwise.
+func parseFiltersFromRequest(request mcp.CallToolRequest, skipNull bool) ([]filter.Filter, error) {
 	args := request.GetArguments()
 	filtersParam, exists := args["filters"]
 	if !exists {
@@ -407,6 +1510,16 @@ func parseFiltersFromRequest(request mcp.CallToolRequest) ([]filter.Filter, erro
 	filters := make([]filter.Filter, 0, len(filtersArray))
 
 	for i, filterItem := range filtersArray {
+		if filterItem == nil {
+			if skipNull {
+				continue
+			}
+			return nil, fmt.Errorf(
+				"filters[%d] is null; remove it or pass an object {field, operator, value}",
+				i,
+			)
+		}
+
 		filterMap, ok := filterItem.(map[string]any)
 		if !ok {
 			// Provide a helpful hint if a string like "a=b" was provided
@@ -434,6 +1547,16 @@ func parseFiltersFromRequest(request mcp.CallToolRequest) ([]filter.Filter, erro
 
 		// Normalize operator with case-insensitive aliases
 		normalizedOp := normalizeOperator(operator)
+		if !slices.Contains(filter.SupportedOperators(), normalizedOp) {
+			return nil, fmt.Errorf(
+				"filters[%d] has unknown operator '%s'; valid operators: %s (aliases: eq, ne, gt, gte, lt, lte)",
+				i,
+				operator,
+				strings.Join(filter.SupportedOperators(), ", "),
+			)
+		}
+		// The value is kept as decoded from JSON, so numbers stay float64.
+		// The filter engine compares numbers by value, whatever their type.
 		filters = append(filters, filter.Filter{
 			Field:    field,
 			Operator: normalizedOp,
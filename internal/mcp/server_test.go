@@ -1,10 +1,14 @@
 package mcp
 
 import (
+	"context"
+	"encoding/json"
 	"net/netip"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/oschwald/maxminddb-mcp/internal/config"
 	"github.com/oschwald/maxminddb-mcp/internal/database"
 	"github.com/oschwald/maxminddb-mcp/internal/iterator"
@@ -26,7 +30,7 @@ func TestNew(t *testing.T) {
 	iterMgr := iterator.New(30*time.Minute, 5*time.Minute)
 	defer iterMgr.StopCleanup()
 
-	server := New(cfg, dbManager, nil, iterMgr)
+	server := New(cfg, dbManager, nil, iterMgr, BuildInfo{})
 
 	if server == nil {
 		t.Fatal("Server should not be nil")
@@ -53,6 +57,68 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestNewServerInfo(t *testing.T) {
+	dbManager, err := database.New()
+	if err != nil {
+		t.Fatalf("Failed to create database manager: %v", err)
+	}
+	defer func() { _ = dbManager.Close() }()
+
+	iterMgr := iterator.New(30*time.Minute, 5*time.Minute)
+	defer iterMgr.StopCleanup()
+
+	tests := []struct {
+		build       BuildInfo
+		name        string
+		serverName  string
+		wantName    string
+		wantVersion string
+	}{
+		{
+			name:        "defaults",
+			wantName:    "MaxMindDB Server",
+			wantVersion: "dev",
+		},
+		{
+			name:        "release build",
+			build:       BuildInfo{Version: "1.4.0", Commit: "none"},
+			wantName:    "MaxMindDB Server",
+			wantVersion: "1.4.0",
+		},
+		{
+			name:        "configured name and commit",
+			build:       BuildInfo{Version: "1.4.0", Commit: "abc1234"},
+			serverName:  "GeoIP Lookups",
+			wantName:    "GeoIP Lookups",
+			wantVersion: "1.4.0+abc1234",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cfg := createTestMCPConfig(t)
+			cfg.ServerName = test.serverName
+			server := New(cfg, dbManager, nil, iterMgr, test.build)
+
+			response := server.mcp.HandleMessage(context.Background(), json.RawMessage(
+				`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{"protocolVersion":"2025-03-26",`+
+					`"capabilities":{},"clientInfo":{"name":"test","version":"1.0.0"}}}`,
+			))
+			result, ok := response.(mcp.JSONRPCResponse)
+			if !ok {
+				t.Fatalf("Expected JSON-RPC response, got %v", response)
+			}
+			initialize, ok := result.Result.(mcp.InitializeResult)
+			if !ok {
+				t.Fatalf("Expected initialize result, got %T", result.Result)
+			}
+			if initialize.ServerInfo.Name != test.wantName || initialize.ServerInfo.Version != test.wantVersion {
+				t.Errorf("Expected server %s %s, got %+v", test.wantName, test.wantVersion, initialize.ServerInfo)
+			}
+		})
+	}
+}
+
 func TestNewWithUpdater(t *testing.T) {
 	cfg := createTestMCPConfig(t)
 	cfg.Mode = maxmindMode
@@ -78,7 +144,7 @@ func TestNewWithUpdater(t *testing.T) {
 	iterMgr := iterator.New(30*time.Minute, 5*time.Minute)
 	defer iterMgr.StopCleanup()
 
-	server := New(cfg, dbManager, updater, iterMgr)
+	server := New(cfg, dbManager, updater, iterMgr, BuildInfo{})
 
 	if server.updater != updater {
 		t.Error("Server updater should match input")
@@ -103,7 +169,7 @@ func TestLookupIPInSingleDatabase(t *testing.T) {
 	iterMgr := iterator.New(30*time.Minute, 5*time.Minute)
 	defer iterMgr.StopCleanup()
 
-	server := New(cfg, dbManager, nil, iterMgr)
+	server := New(cfg, dbManager, nil, iterMgr, BuildInfo{})
 
 	ip, err := netip.ParseAddr("1.1.1.1")
 	if err != nil {
@@ -111,7 +177,7 @@ func TestLookupIPInSingleDatabase(t *testing.T) {
 	}
 
 	// Test valid database
-	result, err := server.lookupIPInSingleDatabase(ip, "1.1.1.1", "GeoLite2-City-Test.mmdb")
+	result, err := server.lookupIPInSingleDatabase(ip, "1.1.1.1", "GeoLite2-City-Test.mmdb", lookupOptions{})
 	if err != nil {
 		t.Fatalf("Failed to lookup IP in single database: %v", err)
 	}
@@ -126,7 +192,7 @@ func TestLookupIPInSingleDatabase(t *testing.T) {
 	}
 
 	// Test non-existent database
-	result, err = server.lookupIPInSingleDatabase(ip, "1.1.1.1", "nonexistent.mmdb")
+	result, err = server.lookupIPInSingleDatabase(ip, "1.1.1.1", "nonexistent.mmdb", lookupOptions{})
 	if err != nil {
 		t.Fatalf("Unexpected error: %v", err)
 	}
@@ -161,14 +227,14 @@ func TestLookupIPInAllDatabases(t *testing.T) {
 	iterMgr := iterator.New(30*time.Minute, 5*time.Minute)
 	defer iterMgr.StopCleanup()
 
-	server := New(cfg, dbManager, nil, iterMgr)
+	server := New(cfg, dbManager, nil, iterMgr, BuildInfo{})
 
 	ip, err := netip.ParseAddr("1.1.1.1")
 	if err != nil {
 		t.Fatalf("Failed to parse IP: %v", err)
 	}
 
-	result, err := server.lookupIPInAllDatabases(ip, "1.1.1.1")
+	result, err := server.lookupIPInAllDatabases(ip, "1.1.1.1", server.databaseNames(), lookupOptions{})
 	if err != nil {
 		t.Fatalf("Failed to lookup IP in all databases: %v", err)
 	}
@@ -192,7 +258,7 @@ func TestServe(t *testing.T) {
 	iterMgr := iterator.New(30*time.Minute, 5*time.Minute)
 	defer iterMgr.StopCleanup()
 
-	server := New(cfg, dbManager, nil, iterMgr)
+	server := New(cfg, dbManager, nil, iterMgr, BuildInfo{})
 
 	// Test Serve method exists and doesn't panic
 	// Note: We can't easily test the actual serving without mocking stdio
@@ -218,7 +284,7 @@ func TestRegisterTools(t *testing.T) {
 	defer iterMgr.StopCleanup()
 
 	// This will call registerTools internally
-	server := New(cfg, dbManager, nil, iterMgr)
+	server := New(cfg, dbManager, nil, iterMgr, BuildInfo{})
 
 	if server.mcp == nil {
 		t.Error("MCP server should be initialized after registerTools")
@@ -239,7 +305,7 @@ func TestRegisterTools(t *testing.T) {
 		t.Fatalf("Failed to create updater: %v", err)
 	}
 
-	server = New(cfg, dbManager, updater, iterMgr)
+	server = New(cfg, dbManager, updater, iterMgr, BuildInfo{})
 	if server.mcp == nil {
 		t.Error("MCP server should be initialized with updater tools")
 	}
@@ -256,7 +322,7 @@ func TestServerStructFields(t *testing.T) {
 	iterMgr := iterator.New(30*time.Minute, 5*time.Minute)
 	defer iterMgr.StopCleanup()
 
-	server := New(cfg, dbManager, nil, iterMgr)
+	server := New(cfg, dbManager, nil, iterMgr, BuildInfo{})
 
 	// Test all fields are set correctly
 	if server.config != cfg {
@@ -298,13 +364,13 @@ func TestServerMethods(t *testing.T) {
 	iterMgr := iterator.New(30*time.Minute, 5*time.Minute)
 	defer iterMgr.StopCleanup()
 
-	server := New(cfg, dbManager, nil, iterMgr)
+	server := New(cfg, dbManager, nil, iterMgr, BuildInfo{})
 
 	// Test helper methods exist and work
 	ip, _ := netip.ParseAddr("8.8.8.8")
 
 	// Test lookupIPInSingleDatabase
-	result, err := server.lookupIPInSingleDatabase(ip, "8.8.8.8", "GeoLite2-City-Test.mmdb")
+	result, err := server.lookupIPInSingleDatabase(ip, "8.8.8.8", "GeoLite2-City-Test.mmdb", lookupOptions{})
 	if err != nil {
 		t.Errorf("lookupIPInSingleDatabase failed: %v", err)
 	}
@@ -313,7 +379,7 @@ func TestServerMethods(t *testing.T) {
 	}
 
 	// Test lookupIPInAllDatabases
-	result, err = server.lookupIPInAllDatabases(ip, "8.8.8.8")
+	result, err = server.lookupIPInAllDatabases(ip, "8.8.8.8", server.databaseNames(), lookupOptions{})
 	if err != nil {
 		t.Errorf("lookupIPInAllDatabases failed: %v", err)
 	}
@@ -339,7 +405,7 @@ func TestServerWithDifferentModes(t *testing.T) {
 	iterMgr := iterator.New(30*time.Minute, 5*time.Minute)
 	defer iterMgr.StopCleanup()
 
-	server := New(cfg, dbManager, nil, iterMgr)
+	server := New(cfg, dbManager, nil, iterMgr, BuildInfo{})
 	if server == nil {
 		t.Error("Server should be created for directory mode")
 	}
@@ -354,7 +420,7 @@ func TestServerWithDifferentModes(t *testing.T) {
 		Endpoint:    "https://updates.maxmind.com",
 	}
 
-	server = New(cfg, dbManager, nil, iterMgr)
+	server = New(cfg, dbManager, nil, iterMgr, BuildInfo{})
 	if server == nil {
 		t.Error("Server should be created for maxmind mode")
 	}
@@ -366,7 +432,7 @@ func TestServerWithDifferentModes(t *testing.T) {
 		DatabaseDir: t.TempDir(),
 	}
 
-	server = New(cfg, dbManager, nil, iterMgr)
+	server = New(cfg, dbManager, nil, iterMgr, BuildInfo{})
 	if server == nil {
 		t.Error("Server should be created for geoip_compat mode")
 	}
@@ -390,7 +456,7 @@ func TestServerConcurrency(t *testing.T) {
 	iterMgr := iterator.New(30*time.Minute, 5*time.Minute)
 	defer iterMgr.StopCleanup()
 
-	server := New(cfg, dbManager, nil, iterMgr)
+	server := New(cfg, dbManager, nil, iterMgr, BuildInfo{})
 
 	// Test concurrent access to server methods
 	done := make(chan bool, 3)
@@ -398,7 +464,7 @@ func TestServerConcurrency(t *testing.T) {
 
 	go func() {
 		defer func() { done <- true }()
-		_, err := server.lookupIPInSingleDatabase(ip, "1.1.1.1", "GeoLite2-City-Test.mmdb")
+		_, err := server.lookupIPInSingleDatabase(ip, "1.1.1.1", "GeoLite2-City-Test.mmdb", lookupOptions{})
 		if err != nil {
 			t.Errorf("Concurrent lookup failed: %v", err)
 		}
@@ -406,7 +472,7 @@ func TestServerConcurrency(t *testing.T) {
 
 	go func() {
 		defer func() { done <- true }()
-		_, err := server.lookupIPInAllDatabases(ip, "1.1.1.1")
+		_, err := server.lookupIPInAllDatabases(ip, "1.1.1.1", server.databaseNames(), lookupOptions{})
 		if err != nil {
 			t.Errorf("Concurrent lookup failed: %v", err)
 		}
@@ -438,3 +504,101 @@ func createTestMCPConfig(t *testing.T) *config.Config {
 		IteratorCleanupIntervalDuration: 5 * time.Minute,
 	}
 }
+
+func TestHandleGetConfigRedactsSecrets(t *testing.T) {
+	cfg := createTestMCPConfig(t)
+	cfg.SourcePath = "/etc/maxminddb-mcp/config.toml"
+	cfg.MaxMind.AccountID = 123456
+	cfg.MaxMind.LicenseKey = "secret_license_key"
+
+	dbManager, err := database.New()
+	if err != nil {
+		t.Fatalf("Failed to create database manager: %v", err)
+	}
+	defer func() { _ = dbManager.Close() }()
+
+	server := New(cfg, dbManager, nil, iterator.New(30*time.Minute, 5*time.Minute), BuildInfo{})
+
+	structured := callTool(t, server.handleGetConfig, "get_config", nil)
+	data, err := json.Marshal(structured)
+	if err != nil {
+		t.Fatalf("Failed to marshal result: %v", err)
+	}
+	if strings.Contains(string(data), "secret_license_key") {
+		t.Errorf("License key was not redacted: %s", data)
+	}
+	if strings.Contains(string(data), "123456") {
+		t.Errorf("Account ID was not masked: %s", data)
+	}
+
+	result, ok := structured.(map[string]any)
+	if !ok {
+		t.Fatalf("Expected map result, got %T", structured)
+	}
+	if result["source_path"] != cfg.SourcePath {
+		t.Errorf("Expected source path %s, got %v", cfg.SourcePath, result["source_path"])
+	}
+	maxmind, _ := result["config"].(map[string]any)["maxmind"].(map[string]any)
+	if maxmind["license_key"] != "[REDACTED]" {
+		t.Errorf("Expected redacted license key, got %v", maxmind["license_key"])
+	}
+	if maxmind["account_id"] != "****56" {
+		t.Errorf("Expected masked account ID ****56, got %v", maxmind["account_id"])
+	}
+	if result["config"].(map[string]any)["mode"] != "directory" {
+		t.Errorf("Expected mode directory, got %v", result["config"].(map[string]any)["mode"])
+	}
+}
+
+func TestUpdateDatabasesProgress(t *testing.T) {
+	server := newTestServerWithUpdater(t)
+
+	session := &testSession{notifications: make(chan mcp.JSONRPCNotification, 10)}
+	if err := server.mcp.RegisterSession(context.Background(), session); err != nil {
+		t.Fatalf("Failed to register session: %v", err)
+	}
+	ctx := server.mcp.WithContext(context.Background(), session)
+
+	callUpdate := func(meta string) {
+		t.Helper()
+		response := server.mcp.HandleMessage(ctx, json.RawMessage(
+			`{"jsonrpc":"2.0","id":1,"method":"tools/call","params":{"name":"update_databases","arguments":{}`+
+				meta+`}}`,
+		))
+		if _, ok := response.(mcp.JSONRPCResponse); !ok {
+			t.Fatalf("Expected JSON-RPC response, got %#v", response)
+		}
+	}
+
+	// Without a progress token, only the aggregate result is returned.
+	callUpdate("")
+	if len(session.notifications) != 0 {
+		t.Fatalf("Expected no notifications without a progress token, got %d", len(session.notifications))
+	}
+
+	callUpdate(`,"_meta":{"progressToken":"update-1"}`)
+	editions := server.config.MaxMind.Editions
+	if len(session.notifications) != len(editions) {
+		t.Fatalf("Expected %d progress notifications, got %d", len(editions), len(session.notifications))
+	}
+	for i, edition := range editions {
+		notification := <-session.notifications
+		if notification.Method != "notifications/progress" {
+			t.Errorf("Expected progress notification, got %s", notification.Method)
+		}
+		params := notification.Params.AdditionalFields
+		if params["progressToken"] != "update-1" {
+			t.Errorf("Expected progress token update-1, got %v", params["progressToken"])
+		}
+		if params["progress"] != i+1 || params["total"] != len(editions) {
+			t.Errorf("Expected progress %d/%d, got %v/%v", i+1, len(editions), params["progress"], params["total"])
+		}
+		result, ok := params["result"].(database.UpdateResult)
+		if !ok || result.Database != edition {
+			t.Errorf("Expected result for %s, got %v", edition, params["result"])
+		}
+		if message, _ := params["message"].(string); !strings.Contains(message, edition) {
+			t.Errorf("Expected message about %s, got %q", edition, message)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package mcp
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/oschwald/maxminddb-golang/v2"
+)
+
+// Limits of the top_values tool.
+const (
+	defaultTopValues = 10
+	maxTopValues     = 1000
+	// topValuesTimeout bounds the time spent scanning. When it runs out,
+	// the counts so far are returned with complete set to false.
+	topValuesTimeout = 30 * time.Second
+	// topValuesCheckInterval is the number of records scanned between
+	// checks of the time budget.
+	topValuesCheckInterval = 1024
+)
+
+// valueCount is the number of records with a field value.
+type valueCount struct {
+	Value any   `json:"value"`
+	Count int64 `json:"count"`
+}
+
+// topValuesResult is the result of top_values.
+type topValuesResult struct {
+	Database string       `json:"database"`
+	Network  string       `json:"network"`
+	Field    string       `json:"field"`
+	Values   []valueCount `json:"values"`
+	Records  int64        `json:"records"`
+	Missing  int64        `json:"missing"`
+	Distinct int          `json:"distinct"`
+	Complete bool         `json:"complete"`
+}
+
+// handleTopValues handles the top_values tool. It scans the records of a
+// network and returns the n most frequent values of a field with the number
+// of records holding each. Scans of the whole address space must be
+// confirmed, and scans stop early once the request is canceled or the time
+// budget runs out.
+func (s *Server) handleTopValues(
+	ctx context.Context,
+	request mcp.CallToolRequest,
+) (*mcp.CallToolResult, error) {
+	dbName, err := request.RequireString("database")
+	if err != nil {
+		//nolint:nilerr // MCP protocol expects error result, not Go error
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "missing_parameter",
+				"message": "Missing required parameter: database",
+			},
+		}), nil
+	}
+
+	field, err := request.RequireString("field")
+	if err != nil || strings.TrimSpace(field) == "" {
+		//nolint:nilerr // MCP protocol expects error result, not Go error
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "missing_parameter",
+				"message": "Missing required parameter: field",
+			},
+		}), nil
+	}
+
+	n := request.GetInt("n", defaultTopValues)
+	if n < 1 || n > maxTopValues {
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "invalid_parameter",
+				"message": fmt.Sprintf("Invalid n: %d (must be between 1 and %d)", n, maxTopValues),
+			},
+		}), nil
+	}
+
+	dbName, errResult := s.resolveDatabase(dbName)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	reader, exists := s.dbManager.GetReader(dbName)
+	if !exists {
+		return mcp.NewToolResultStructuredOnly(map[string]any{
+			"error": map[string]any{
+				"code":    "db_not_found",
+				"message": "Database not found: " + dbName,
+			},
+		}), nil
+	}
+
+	network, errResult := boundingNetwork(request, reader)
+	if errResult != nil {
+		return errResult, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, topValuesTimeout)
+	defer cancel()
+
+	counted := countValues(ctx, reader, network, fieldPath(field), 0)
+	result := topValuesResult{
+		Database: dbName,
+		Network:  network.String(),
+		Field:    field,
+		Values:   topValueCounts(counted.counts, n),
+		Records:  counted.records,
+		Missing:  counted.missing,
+		Distinct: len(counted.counts),
+		Complete: counted.complete,
+	}
+	return mcp.NewToolResultStructuredOnly(result), nil
+}
+
+// valueCounts is the result of countValues.
+type valueCounts struct {
+	// counts holds the count of each value, keyed by its JSON encoding.
+	counts   map[string]*valueCount
+	records  int64
+	missing  int64
+	complete bool
+}
+
+// countValues counts the values at path of the records within network.
+// Values are keyed by their JSON encoding so that arrays and maps can be
+// counted too, and records without a value are counted as missing. The scan
+// stops early, with complete set to false, when ctx is done or, if limit is
+// positive, after limit records.
+func countValues(
+	ctx context.Context,
+	reader *maxminddb.Reader,
+	network netip.Prefix,
+	path []any,
+	limit int64,
+) valueCounts {
+	result := valueCounts{
+		counts:   make(map[string]*valueCount),
+		complete: true,
+	}
+
+	for record := range reader.NetworksWithin(network) {
+		if (limit > 0 && result.records >= limit) ||
+			(result.records%topValuesCheckInterval == 0 && ctx.Err() != nil) {
+			result.complete = false
+			break
+		}
+		result.records++
+
+		var value any
+		if err := record.DecodePath(&value, path...); err != nil || value == nil {
+			result.missing++
+			continue
+		}
+		key, err := json.Marshal(value)
+		if err != nil {
+			result.missing++
+			continue
+		}
+		if count, ok := result.counts[string(key)]; ok {
+			count.Count++
+		} else {
+			result.counts[string(key)] = &valueCount{Value: value, Count: 1}
+		}
+	}
+	return result
+}
+
+// topValueCounts returns the n highest counts, ordered by count and then by
+// the JSON encoding of the value, so that ties are ordered deterministically.
+func topValueCounts(counts map[string]*valueCount, n int) []valueCount {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		if c := cmp.Compare(counts[b].Count, counts[a].Count); c != 0 {
+			return c
+		}
+		return cmp.Compare(a, b)
+	})
+
+	values := make([]valueCount, 0, min(n, len(keys)))
+	for _, key := range keys[:min(n, len(keys))] {
+		values = append(values, *counts[key])
+	}
+	return values
+}
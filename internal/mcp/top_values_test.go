@@ -0,0 +1,224 @@
+package mcp
+
+import (
+	"context"
+	"net/netip"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+
+	"github.com/oschwald/maxminddb-golang/v2"
+)
+
+const testASNDB = "../../testdata/test-data/GeoLite2-ASN-Test.mmdb"
+
+func newTestServerWithASNDB(t *testing.T) *Server {
+	t.Helper()
+
+	server := newTestServerWithCityDB(t)
+	if err := server.dbManager.LoadDatabase(testASNDB); err != nil {
+		t.Fatalf("Failed to load ASN test database: %v", err)
+	}
+	return server
+}
+
+func TestTopValues(t *testing.T) {
+	server := newTestServerWithASNDB(t)
+
+	// Count the ASNs of the whole database independently.
+	reader, err := maxminddb.Open(testASNDB)
+	if err != nil {
+		t.Fatalf("Failed to open ASN test database: %v", err)
+	}
+	defer func() { _ = reader.Close() }()
+	expected := make(map[uint64]int64)
+	var records int64
+	for network := range reader.NetworksWithin(netip.MustParsePrefix("::/0")) {
+		records++
+		var asn uint64
+		if err := network.DecodePath(&asn, "autonomous_system_number"); err != nil {
+			t.Fatalf("Failed to decode %s: %v", network.Prefix(), err)
+		}
+		expected[asn]++
+	}
+	if len(expected) < 3 {
+		t.Fatalf("Expected at least 3 distinct ASNs, got %d", len(expected))
+	}
+
+	structured := callTool(t, server.handleTopValues, "top_values", map[string]any{
+		"database":          "type:ASN",
+		"field":             "autonomous_system_number",
+		"n":                 2,
+		"confirm_full_scan": true,
+	})
+	result, ok := structured.(topValuesResult)
+	if !ok {
+		t.Fatalf("Unexpected result: %v", structured)
+	}
+	if !result.Complete || result.Records != records || result.Missing != 0 {
+		t.Errorf("Expected a complete scan of %d records, got %+v", records, result)
+	}
+	if result.Distinct != len(expected) {
+		t.Errorf("Expected %d distinct ASNs, got %d", len(expected), result.Distinct)
+	}
+	if len(result.Values) != 2 {
+		t.Fatalf("Expected the top 2 ASNs, got %v", result.Values)
+	}
+
+	// The values are the most frequent ones, in order of frequency.
+	for i, value := range result.Values {
+		asn, ok := value.Value.(uint64)
+		if !ok || expected[asn] != value.Count {
+			t.Errorf("Expected %v to have count %d, got %d", value.Value, expected[asn], value.Count)
+		}
+		if i > 0 && value.Count > result.Values[i-1].Count {
+			t.Errorf("Expected counts in descending order, got %v", result.Values)
+		}
+		delete(expected, asn)
+	}
+	for asn, count := range expected {
+		if count > result.Values[len(result.Values)-1].Count {
+			t.Errorf("ASN %d with count %d is missing from the top values %v", asn, count, result.Values)
+		}
+	}
+
+	// Within a network, only its records are counted.
+	structured = callTool(t, server.handleTopValues, "top_values", map[string]any{
+		"database": "GeoLite2-ASN-Test.mmdb",
+		"field":    "autonomous_system_number",
+		"network":  "12.0.0.0/8",
+	})
+	result, _ = structured.(topValuesResult)
+	if len(result.Values) == 0 || result.Values[0].Value != uint64(7018) {
+		t.Errorf("Expected AS7018 to be the top ASN in 12.0.0.0/8, got %v", result.Values)
+	}
+}
+
+func TestTopValuesMissingField(t *testing.T) {
+	server := newTestServerWithASNDB(t)
+
+	structured := callTool(t, server.handleTopValues, "top_values", map[string]any{
+		"database": "GeoLite2-ASN-Test.mmdb",
+		"field":    "country.iso_code",
+		"network":  "12.0.0.0/8",
+	})
+	result, ok := structured.(topValuesResult)
+	if !ok {
+		t.Fatalf("Unexpected result: %v", structured)
+	}
+	if len(result.Values) != 0 || result.Records == 0 || result.Missing != result.Records {
+		t.Errorf("Expected every record to miss the field, got %+v", result)
+	}
+}
+
+func TestTopValuesStopsWhenCanceled(t *testing.T) {
+	server := newTestServerWithASNDB(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	request := mcp.CallToolRequest{}
+	request.Params.Name = "top_values"
+	request.Params.Arguments = map[string]any{
+		"database":          "GeoLite2-ASN-Test.mmdb",
+		"field":             "autonomous_system_number",
+		"confirm_full_scan": true,
+	}
+	toolResult, err := server.handleTopValues(ctx, request)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	result, ok := toolResult.StructuredContent.(topValuesResult)
+	if !ok {
+		t.Fatalf("Unexpected result: %v", toolResult.StructuredContent)
+	}
+	if result.Complete || result.Records != 0 {
+		t.Errorf("Expected an incomplete scan, got %+v", result)
+	}
+}
+
+func TestCountValuesLimit(t *testing.T) {
+	reader, err := maxminddb.Open(testASNDB)
+	if err != nil {
+		t.Fatalf("Failed to open ASN test database: %v", err)
+	}
+	defer func() { _ = reader.Close() }()
+
+	network := netip.MustParsePrefix("::/0")
+	path := fieldPath("autonomous_system_number")
+	all := countValues(t.Context(), reader, network, path, 0)
+	if !all.complete || all.records < 3 {
+		t.Fatalf("Expected a complete scan of at least 3 records, got %+v", all)
+	}
+
+	limited := countValues(t.Context(), reader, network, path, 2)
+	if limited.complete || limited.records != 2 {
+		t.Errorf("Expected an incomplete scan of 2 records, got %+v", limited)
+	}
+	var counted int64
+	for _, count := range limited.counts {
+		counted += count.Count
+	}
+	if counted+limited.missing != 2 {
+		t.Errorf("Expected 2 counted records, got %d values and %d missing", counted, limited.missing)
+	}
+}
+
+func TestTopValuesErrors(t *testing.T) {
+	server := newTestServerWithASNDB(t)
+
+	tests := []struct {
+		args map[string]any
+		name string
+		code string
+	}{
+		{
+			name: "missing database",
+			args: map[string]any{"field": "autonomous_system_number"},
+			code: "missing_parameter",
+		},
+		{
+			name: "missing field",
+			args: map[string]any{"database": "GeoLite2-ASN-Test.mmdb"},
+			code: "missing_parameter",
+		},
+		{
+			name: "unknown database",
+			args: map[string]any{"database": "missing.mmdb", "field": "autonomous_system_number"},
+			code: "db_not_found",
+		},
+		{
+			name: "n too large",
+			args: map[string]any{
+				"database": "GeoLite2-ASN-Test.mmdb",
+				"field":    "autonomous_system_number",
+				"network":  "12.0.0.0/8",
+				"n":        maxTopValues + 1,
+			},
+			code: "invalid_parameter",
+		},
+		{
+			name: "unconfirmed full scan",
+			args: map[string]any{"database": "GeoLite2-ASN-Test.mmdb", "field": "autonomous_system_number"},
+			code: "full_scan_not_confirmed",
+		},
+		{
+			name: "invalid network",
+			args: map[string]any{
+				"database": "GeoLite2-ASN-Test.mmdb",
+				"field":    "autonomous_system_number",
+				"network":  "bogus",
+			},
+			code: "invalid_network",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			structured := callTool(t, server.handleTopValues, "top_values", test.args)
+			if code := errorCode(structured); code != test.code {
+				t.Errorf("Expected %s, got %v", test.code, structured)
+			}
+		})
+	}
+}